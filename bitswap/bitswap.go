@@ -54,6 +54,9 @@ type Bitswap struct {
 
 	tracer tracer.Tracer
 	net    network.BitSwapNetwork
+
+	// the number of peers the client broadcasts wants to, 0 for all
+	broadcastLimit int
 }
 
 func New(ctx context.Context, net network.BitSwapNetwork, bstore blockstore.Blockstore, options ...Option) *Bitswap {
@@ -90,12 +93,21 @@ func New(ctx context.Context, net network.BitSwapNetwork, bstore blockstore.Bloc
 	ctx = metrics.CtxSubScope(ctx, "bitswap")
 
 	bs.Server = server.New(ctx, net, bstore, serverOptions...)
+	if bs.broadcastLimit > 0 {
+		clientOptions = append(clientOptions, client.WithBroadcastLimit(bs.broadcastLimit, bs.peerScore))
+	}
 	bs.Client = client.New(ctx, net, bstore, append(clientOptions, client.WithBlockReceivedNotifier(bs.Server))...)
 	net.Start(bs) // use the polyfill receiver to log received errors and trace messages only once
 
 	return bs
 }
 
+// peerScore is the score given to the peer by the score ledger of the
+// server, used to pick the peers the client broadcasts wants to
+func (bs *Bitswap) peerScore(p peer.ID) float64 {
+	return float64(bs.Server.ScoreForPeer(p))
+}
+
 func (bs *Bitswap) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error {
 	return multierr.Combine(
 		bs.Client.NotifyNewBlocks(ctx, blks...),
@@ -114,6 +126,9 @@ type Stat struct {
 	BlocksSent       uint64
 	DataSent         uint64
 	ProvideBufLen    int
+
+	OversizedBlocksDropped uint64
+	TasksDropped           uint64
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
@@ -127,16 +142,18 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 	}
 
 	return &Stat{
-		Wantlist:         cs.Wantlist,
-		BlocksReceived:   cs.BlocksReceived,
-		DataReceived:     cs.DataReceived,
-		DupBlksReceived:  cs.DupBlksReceived,
-		DupDataReceived:  cs.DupDataReceived,
-		MessagesReceived: cs.MessagesReceived,
-		Peers:            ss.Peers,
-		BlocksSent:       ss.BlocksSent,
-		DataSent:         ss.DataSent,
-		ProvideBufLen:    ss.ProvideBufLen,
+		Wantlist:               cs.Wantlist,
+		BlocksReceived:         cs.BlocksReceived,
+		DataReceived:           cs.DataReceived,
+		DupBlksReceived:        cs.DupBlksReceived,
+		DupDataReceived:        cs.DupDataReceived,
+		MessagesReceived:       cs.MessagesReceived,
+		OversizedBlocksDropped: cs.OversizedBlocksDropped,
+		Peers:                  ss.Peers,
+		BlocksSent:             ss.BlocksSent,
+		DataSent:               ss.DataSent,
+		ProvideBufLen:          ss.ProvideBufLen,
+		TasksDropped:           ss.TasksDropped,
 	}, nil
 }
 
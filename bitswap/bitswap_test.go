@@ -17,14 +17,17 @@ import (
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/mikelsr/boxo/bitswap"
 	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	bsnet "github.com/mikelsr/boxo/bitswap/network"
 	"github.com/mikelsr/boxo/bitswap/server"
 	testinstance "github.com/mikelsr/boxo/bitswap/testinstance"
 	tn "github.com/mikelsr/boxo/bitswap/testnet"
+	"github.com/mikelsr/boxo/blockservice"
 	"github.com/mikelsr/boxo/internal/test"
 	mockrouting "github.com/mikelsr/boxo/routing/mock"
 	tu "github.com/mikelsr/go-libp2p-testing/etc"
 	p2ptestutil "github.com/mikelsr/go-libp2p-testing/netutil"
 	peer "github.com/mikelsr/go-libp2p/core/peer"
+	protocol "github.com/mikelsr/go-libp2p/core/protocol"
 )
 
 func isCI() bool {
@@ -123,6 +126,185 @@ func TestGetBlockFromPeerAfterPeerAnnounces(t *testing.T) {
 	}
 }
 
+func TestGetBlockRange(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("some block data"))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+
+	peers := ig.Instances(2)
+	hasBlock := peers[0]
+	defer hasBlock.Exchange.Close()
+
+	addBlock(t, context.Background(), hasBlock, block)
+
+	wantsBlock := peers[1]
+	defer wantsBlock.Exchange.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, err := wantsBlock.Exchange.GetBlockRange(ctx, block.Cid(), 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, block.RawData()[5:10]) {
+		t.Fatal("Data doesn't match")
+	}
+
+	// only the range was fetched, not the full block
+	st, err := wantsBlock.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.BlocksReceived != 0 {
+		t.Fatal("Expected the full block not to be fetched")
+	}
+}
+
+func TestGetBlockRangeConcurrent(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("some block data"))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+
+	peers := ig.Instances(2)
+	hasBlock := peers[0]
+	defer hasBlock.Exchange.Close()
+
+	addBlock(t, context.Background(), hasBlock, block)
+
+	wantsBlock := peers[1]
+	defer wantsBlock.Exchange.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// different ranges of the same block wanted at the same time are all
+	// answered
+	ranges := [][2]int{{0, 4}, {5, 5}, {11, 10}}
+	errs := make(chan error, len(ranges))
+	for _, r := range ranges {
+		go func(offset, length int) {
+			data, err := wantsBlock.Exchange.GetBlockRange(ctx, block.Cid(), offset, length)
+			if err != nil {
+				errs <- err
+				return
+			}
+			end := offset + length
+			if end > len(block.RawData()) {
+				end = len(block.RawData())
+			}
+			if !bytes.Equal(data, block.RawData()[offset:end]) {
+				errs <- fmt.Errorf("data of range %d+%d doesn't match: %q", offset, length, data)
+				return
+			}
+			errs <- nil
+		}(r[0], r[1])
+	}
+	for range ranges {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetBlockRangeNoDontHave(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("some block data"))
+
+	// a Bitswap 1.1 peer, which never sends DONT_HAVE
+	oldNetOpts := []bsnet.NetOpt{bsnet.SupportedProtocols([]protocol.ID{bsnet.ProtocolBitswapOneOne})}
+	oldBsOpts := []bitswap.Option{bitswap.SetSendDontHaves(false)}
+	oldIg := testinstance.NewTestInstanceGenerator(net, oldNetOpts, oldBsOpts)
+	defer oldIg.Close()
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+
+	hasBlock := oldIg.Next()
+	defer hasBlock.Exchange.Close()
+	wantsBlock := ig.Next()
+	defer wantsBlock.Exchange.Close()
+	testinstance.ConnectInstances([]testinstance.Instance{hasBlock, wantsBlock})
+
+	addBlock(t, context.Background(), hasBlock, block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, err := wantsBlock.Exchange.GetBlockRange(ctx, block.Cid(), 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, block.RawData()[5:10]) {
+		t.Fatal("Data doesn't match")
+	}
+
+	// the peer can't answer DONT_HAVE, so the range is taken from the full
+	// block fetched right away
+	st, err := wantsBlock.Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.BlocksReceived != 1 {
+		t.Fatalf("Expected the full block to be fetched, got %d blocks", st.BlocksReceived)
+	}
+}
+
+func TestDuplicateBlocksReceivedStat(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	block := blocks.NewBlock([]byte("block"))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.ProvideEnabled(false)})
+	defer ig.Close()
+
+	receiver := ig.Next()
+	defer receiver.Exchange.Close()
+	first := ig.Next()
+	defer first.Exchange.Close()
+	second := ig.Next()
+	defer second.Exchange.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the first peer sends the block the receiver wants, which stores it
+	addBlock(t, ctx, first, block)
+	if err := receiver.Adapter.ConnectTo(ctx, first.Peer); err != nil {
+		t.Fatal(err)
+	}
+	bsrv := blockservice.New(receiver.Blockstore(), receiver.Exchange)
+	if _, err := bsrv.GetBlock(ctx, block.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second peer sends the same block again
+	if err := second.Adapter.ConnectTo(ctx, receiver.Peer); err != nil {
+		t.Fatal(err)
+	}
+	msg := bsmsg.New(false)
+	msg.AddBlock(block)
+	if err := second.Adapter.SendMessage(ctx, receiver.Peer, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tu.WaitFor(ctx, func() error {
+		st, err := receiver.Exchange.Stat()
+		if err != nil {
+			return err
+		}
+		if st.BlocksReceived != 2 {
+			return fmt.Errorf("expected 2 blocks received, got %d", st.BlocksReceived)
+		}
+		if st.DupBlksReceived != 1 {
+			return fmt.Errorf("expected 1 duplicate block received, got %d", st.DupBlksReceived)
+		}
+		if st.DupDataReceived != uint64(len(block.RawData())) {
+			return fmt.Errorf("expected %d duplicate bytes received, got %d", len(block.RawData()), st.DupDataReceived)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDoesNotProvideWhenConfiguredNotTo(t *testing.T) {
 	test.Flaky(t)
 
@@ -428,6 +610,274 @@ func assertStat(t *testing.T, st *bitswap.Stat, sblks, rblks, sdata, rdata uint6
 	}
 }
 
+func TestMaxIncomingBlockSize(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithMaxIncomingBlockSize(1024)})
+	defer ig.Close()
+
+	instances := ig.Instances(2)
+	small := blocks.NewBlock(make([]byte, 1024))
+	big := blocks.NewBlock(make([]byte, 1025))
+	addBlock(t, context.Background(), instances[0], small)
+	addBlock(t, context.Background(), instances[0], big)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err := instances[1].Exchange.GetBlock(ctx, small.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	bigctx, bigcancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer bigcancel()
+	if _, err := instances[1].Exchange.GetBlock(bigctx, big.Cid()); err == nil {
+		t.Fatal("expected the oversized block to be dropped")
+	}
+	if has, err := instances[1].Blockstore().Has(ctx, big.Cid()); err != nil || has {
+		t.Fatal("expected the oversized block not to be stored")
+	}
+
+	st, err := instances[1].Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.OversizedBlocksDropped == 0 {
+		t.Fatal("expected the oversized block to be counted")
+	}
+	if st.BlocksReceived != 1 {
+		t.Fatalf("expected only the small block to be received, got %d", st.BlocksReceived)
+	}
+}
+
+func TestUnsolicitedBlocks(t *testing.T) {
+	type unsolicitedBlock struct {
+		p    peer.ID
+		c    cid.Cid
+		size int
+	}
+	var lk sync.Mutex
+	var unsolicited []unsolicitedBlock
+	callback := func(p peer.ID, c cid.Cid, size int) {
+		lk.Lock()
+		defer lk.Unlock()
+		unsolicited = append(unsolicited, unsolicitedBlock{p, c, size})
+	}
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{
+		bitswap.WithUnsolicitedBlockCallback(callback),
+		bitswap.WithDropUnsolicitedBlocks(true),
+	})
+	defer ig.Close()
+
+	instances := ig.Instances(2)
+	wanted := blocks.NewBlock([]byte("wanted"))
+	pushed := blocks.NewBlock([]byte("not asked for"))
+	addBlock(t, context.Background(), instances[0], wanted)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if _, err := instances[1].Exchange.GetBlock(ctx, wanted.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(false)
+	msg.AddBlock(pushed)
+	if err := instances[0].Adapter.SendMessage(ctx, instances[1].Peer, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []unsolicitedBlock
+	for i := 0; i < 100; i++ {
+		lk.Lock()
+		got = append(got[:0], unsolicited...)
+		lk.Unlock()
+		if len(got) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one unsolicited block, got %d", len(got))
+	}
+	if got[0].p != instances[0].Peer || got[0].c != pushed.Cid() || got[0].size != len(pushed.RawData()) {
+		t.Fatalf("unexpected unsolicited block %+v", got[0])
+	}
+
+	st, err := instances[1].Exchange.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.BlocksReceived != 1 {
+		t.Fatalf("expected the unsolicited block to be dropped, got %d blocks received", st.BlocksReceived)
+	}
+}
+
+func TestReceiveBufferBackpressure(t *testing.T) {
+	b1 := blocks.NewBlock([]byte("first block"))
+	b2 := blocks.NewBlock([]byte("second block"))
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{
+		bitswap.WithReceiveBufferBytes(len(b1.RawData())),
+	})
+	defer ig.Close()
+
+	instances := ig.Instances(2)
+	sender, receiver := instances[0], instances[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	out, err := receiver.Exchange.GetBlocks(ctx, []cid.Cid{b1.Cid(), b2.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the session to register the wants
+	for i := 0; len(receiver.Exchange.GetWantlist()) < 2; i++ {
+		if i == 100 {
+			t.Fatal("expected the blocks to be wanted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	receive := func(b blocks.Block) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			msg := bsmsg.New(false)
+			msg.AddBlock(b)
+			receiver.Exchange.ReceiveMessage(ctx, sender.Peer, msg)
+		}()
+		return done
+	}
+
+	select {
+	case <-receive(b1):
+	case <-time.After(time.Second):
+		t.Fatal("expected the first block to fit in the receive buffer")
+	}
+	if blk := <-out; !blk.Cid().Equals(b1.Cid()) {
+		t.Fatalf("expected %s, got %s", b1.Cid(), blk.Cid())
+	}
+
+	// the buffer is full until the first block is stored
+	done := receive(b2)
+	select {
+	case <-done:
+		t.Fatal("expected the second message to wait for the first block to be stored")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	addBlock(t, ctx, receiver, b1)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second message to be processed once the first block is stored")
+	}
+	if blk := <-out; !blk.Cid().Equals(b2.Cid()) {
+		t.Fatalf("expected %s, got %s", b2.Cid(), blk.Cid())
+	}
+}
+
+func TestBroadcastLimit(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{
+		bitswap.WithBroadcastLimit(2),
+	})
+	defer ig.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := ig.Instances(5)
+	requester := instances[0]
+	blk := bg.Next()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go func() {
+		_, _ = requester.Exchange.GetBlock(ctx, blk.Cid())
+	}()
+
+	wanting := func() int {
+		n := 0
+		for _, inst := range instances[1:] {
+			for _, c := range inst.Exchange.WantlistForPeer(requester.Peer) {
+				if c.Equals(blk.Cid()) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	if err := tu.WaitFor(ctx, func() error {
+		if n := wanting(); n != 2 {
+			return fmt.Errorf("expected 2 peers to be sent the want, got %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the want is not sent to more peers later on
+	time.Sleep(100 * time.Millisecond)
+	if n := wanting(); n > 2 {
+		t.Fatalf("expected the want to be sent to 2 peers at most, got %d", n)
+	}
+}
+
+func TestBroadcastLimitScore(t *testing.T) {
+	tsl := newTestingScoreLedger()
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	rig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{
+		bitswap.WithBroadcastLimit(1),
+		bitswap.WithScoreLedger(tsl),
+	})
+	defer rig.Close()
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	requester := rig.Next()
+	instances := append([]testinstance.Instance{requester}, ig.Instances(3)...)
+	testinstance.ConnectInstances(instances)
+	<-tsl.started
+
+	// the peer with the best score is the only one sent the want
+	best := instances[2]
+	tsl.scorePeer(instances[1].Peer, 1)
+	tsl.scorePeer(best.Peer, 10)
+
+	blk := bg.Next()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go func() {
+		_, _ = requester.Exchange.GetBlock(ctx, blk.Cid())
+	}()
+
+	wants := func(inst testinstance.Instance) bool {
+		for _, c := range inst.Exchange.WantlistForPeer(requester.Peer) {
+			if c.Equals(blk.Cid()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := tu.WaitFor(ctx, func() error {
+		if !wants(best) {
+			return fmt.Errorf("expected the peer with the best score to be sent the want")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, inst := range instances[1:] {
+		if inst.Peer != best.Peer && wants(inst) {
+			t.Fatalf("expected only the peer with the best score to be sent the want, got %s", inst.Peer)
+		}
+	}
+}
+
 func TestBasicBitswap(t *testing.T) {
 	test.Flaky(t)
 
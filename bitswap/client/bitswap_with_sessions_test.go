@@ -3,6 +3,7 @@ package client_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,12 +12,15 @@ import (
 	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
 	delay "github.com/ipfs/go-ipfs-delay"
 	"github.com/mikelsr/boxo/bitswap"
+	"github.com/mikelsr/boxo/bitswap/client"
 	"github.com/mikelsr/boxo/bitswap/client/internal/session"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
 	testinstance "github.com/mikelsr/boxo/bitswap/testinstance"
 	tn "github.com/mikelsr/boxo/bitswap/testnet"
 	"github.com/mikelsr/boxo/internal/test"
 	mockrouting "github.com/mikelsr/boxo/routing/mock"
 	tu "github.com/mikelsr/go-libp2p-testing/etc"
+	peer "github.com/mikelsr/go-libp2p/core/peer"
 )
 
 func getVirtualNetwork() tn.Network {
@@ -152,6 +156,86 @@ func TestSessionBetweenPeers(t *testing.T) {
 	}
 }
 
+// priorityRecorder records the priority of the first want received for
+// each CID
+type priorityRecorder struct {
+	lk         sync.Mutex
+	priorities map[cid.Cid]int32
+}
+
+func (pr *priorityRecorder) MessageReceived(_ peer.ID, msg bsmsg.BitSwapMessage) {
+	pr.lk.Lock()
+	defer pr.lk.Unlock()
+	for _, e := range msg.Wantlist() {
+		if _, ok := pr.priorities[e.Cid]; !ok && !e.Cancel {
+			pr.priorities[e.Cid] = e.Priority
+		}
+	}
+}
+
+func (pr *priorityRecorder) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+func (pr *priorityRecorder) get(c cid.Cid) (int32, bool) {
+	pr.lk.Lock()
+	defer pr.lk.Unlock()
+	priority, ok := pr.priorities[c]
+	return priority, ok
+}
+
+func TestSessionWantWithPriority(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vnet := getVirtualNetwork()
+	pr := &priorityRecorder{priorities: make(map[cid.Cid]int32)}
+	rig := testinstance.NewTestInstanceGenerator(vnet, nil, []bitswap.Option{bitswap.WithTracer(pr)})
+	defer rig.Close()
+	ig := testinstance.NewTestInstanceGenerator(vnet, nil, nil)
+	defer ig.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	receiver := rig.Next()
+	requester := ig.Next()
+	testinstance.ConnectInstances([]testinstance.Instance{receiver, requester})
+	blks := bgen.Blocks(2)
+
+	s1 := requester.Exchange.NewSession(ctx).(client.PrioritizedFetcher)
+	s2 := requester.Exchange.NewSession(ctx)
+
+	waitWant := func(c cid.Cid) int32 {
+		t.Helper()
+		var priority int32
+		if err := tu.WaitFor(ctx, func() error {
+			var ok bool
+			if priority, ok = pr.get(c); !ok {
+				return fmt.Errorf("no want received for %s", c)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return priority
+	}
+
+	// the priority set by a session doesn't apply to another session
+	s1.WantWithPriority(blks[0].Cid(), 5)
+	go func() {
+		_, _ = s2.GetBlock(ctx, blks[0].Cid())
+	}()
+	if priority := waitWant(blks[0].Cid()); priority == 5 {
+		t.Fatal("expected the priority of a session not to apply to another session")
+	}
+
+	// it applies to the wants of the session
+	s1.WantWithPriority(blks[1].Cid(), 7)
+	go func() {
+		_, _ = s1.GetBlock(ctx, blks[1].Cid())
+	}()
+	if priority := waitWant(blks[1].Cid()); priority != 7 {
+		t.Fatalf("expected the want to be sent with priority 7, got %d", priority)
+	}
+}
+
 func TestSessionSplitFetch(t *testing.T) {
 	test.Flaky(t)
 
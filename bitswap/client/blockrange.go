@@ -0,0 +1,321 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// ErrInvalidRange is returned by GetBlockRange when the requested range is
+// empty, negative or starts past the end of the block.
+var ErrInvalidRange = errors.New("invalid block range")
+
+// rangeResult is the outcome of a ranged want
+type rangeResult struct {
+	data []byte
+	err  error
+}
+
+// byteRange is the range of a block wanted by a ranged want
+type byteRange struct {
+	offset uint64
+	length uint64
+}
+
+// rangeWaiter tracks a single GetBlockRange call waiting for a response
+type rangeWaiter struct {
+	rng byteRange
+
+	// peers that were asked and have not answered DONT_HAVE yet
+	pending map[peer.ID]struct{}
+
+	res      chan rangeResult
+	fallback chan struct{}
+	done     bool
+}
+
+func (w *rangeWaiter) resolve(r rangeResult) {
+	if w.done {
+		return
+	}
+	w.done = true
+	w.res <- r
+}
+
+// dontHave records that the peer won't send the block, and falls back to
+// fetching the full block once no asked peer is left
+func (w *rangeWaiter) dontHave(p peer.ID) {
+	if _, ok := w.pending[p]; !ok {
+		return
+	}
+	delete(w.pending, p)
+	if len(w.pending) == 0 && !w.done {
+		w.done = true
+		close(w.fallback)
+	}
+}
+
+// rangeWants keeps track of the ranged wants that are waiting for a
+// response, by key and range
+type rangeWants struct {
+	lk      sync.Mutex
+	waiters map[cid.Cid]map[byteRange][]*rangeWaiter
+}
+
+func newRangeWants() *rangeWants {
+	return &rangeWants{waiters: make(map[cid.Cid]map[byteRange][]*rangeWaiter)}
+}
+
+func (rw *rangeWants) add(c cid.Cid, offset, length uint64, peers []peer.ID) *rangeWaiter {
+	w := &rangeWaiter{
+		rng:      byteRange{offset: offset, length: length},
+		pending:  make(map[peer.ID]struct{}, len(peers)),
+		res:      make(chan rangeResult, 1),
+		fallback: make(chan struct{}),
+	}
+	for _, p := range peers {
+		w.pending[p] = struct{}{}
+	}
+
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+	ranges, ok := rw.waiters[c]
+	if !ok {
+		ranges = make(map[byteRange][]*rangeWaiter)
+		rw.waiters[c] = ranges
+	}
+	ranges[w.rng] = append(ranges[w.rng], w)
+	return w
+}
+
+func (rw *rangeWants) remove(c cid.Cid, w *rangeWaiter) {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	ranges := rw.waiters[c]
+	ws := ranges[w.rng]
+	for i, x := range ws {
+		if x == w {
+			ws = append(ws[:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(ws) == 0 {
+		delete(ranges, w.rng)
+	} else {
+		ranges[w.rng] = ws
+	}
+	if len(ranges) == 0 {
+		delete(rw.waiters, c)
+	}
+}
+
+// has returns true if a ranged want for the key is waiting for a response
+func (rw *rangeWants) has(c cid.Cid) bool {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+	return len(rw.waiters[c]) > 0
+}
+
+// failed records that the peer won't answer the ranged want of w, either
+// because the want could not be sent or because the peer doesn't send
+// DONT_HAVE
+func (rw *rangeWants) failed(w *rangeWaiter, p peer.ID) {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+	w.dontHave(p)
+}
+
+// receive resolves the waiters that match the blocks, block ranges and
+// DONT_HAVEs in an incoming message from the peer. A peer that answers
+// DONT_HAVE several times, for example to a want-have and to the ranged want,
+// is only counted once.
+func (rw *rangeWants) receive(from peer.ID, blks []blocks.Block, ranges []bsmsg.BlockRange, dontHaves []cid.Cid) {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	if len(rw.waiters) == 0 {
+		return
+	}
+
+	// Peers that don't support ranged wants, or that were asked for several
+	// ranges of the block, answer with the full block, which is sliced
+	// locally
+	for _, b := range blks {
+		for rng, ws := range rw.waiters[b.Cid()] {
+			data, err := sliceRange(b.RawData(), rng.offset, rng.length)
+			for _, w := range ws {
+				w.resolve(rangeResult{data: data, err: err})
+			}
+		}
+	}
+
+	for _, br := range ranges {
+		for _, w := range rw.rangeWaiters(br) {
+			if len(br.Data) == 0 {
+				w.resolve(rangeResult{err: ErrInvalidRange})
+				continue
+			}
+			w.resolve(rangeResult{data: br.Data})
+		}
+	}
+
+	for _, c := range dontHaves {
+		for _, ws := range rw.waiters[c] {
+			for _, w := range ws {
+				w.dontHave(from)
+			}
+		}
+	}
+}
+
+// rangeWaiters returns the waiters answered by the block range. The range
+// is the answer to the waiters of the same range, or else, when it has been
+// cut at the end of the block, to the waiters of longer ranges at the same
+// offset.
+func (rw *rangeWants) rangeWaiters(br bsmsg.BlockRange) []*rangeWaiter {
+	size := uint64(len(br.Data))
+	ranges := rw.waiters[br.Cid]
+	if ws := ranges[byteRange{offset: br.Offset, length: size}]; waiting(ws) {
+		return ws
+	}
+
+	var res []*rangeWaiter
+	for rng, ws := range ranges {
+		if rng.offset == br.Offset && rng.length > size {
+			res = append(res, ws...)
+		}
+	}
+	return res
+}
+
+// waiting returns true if one of the waiters has not been resolved yet
+func waiting(ws []*rangeWaiter) bool {
+	for _, w := range ws {
+		if !w.done {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBlockRange attempts to retrieve length bytes of the block with the
+// given key, starting at offset. The returned slice is shorter than length
+// when the range goes past the end of the block.
+//
+// The range is requested from the connected peers with an experimental
+// extension of the Bitswap 1.2.0 protocol. Peers that don't support it
+// answer with the full block, which is sliced locally. If no peer is
+// connected, or all of them reply DONT_HAVE or speak a version of the
+// protocol without DONT_HAVE, the full block is fetched with GetBlock
+// instead.
+//
+// Note that, unlike full blocks, the data of a partial response can't be
+// verified against the key, and is not stored in the blockstore.
+//
+// The offset and length are ints, like the indexes of the block data; a
+// negative offset or a length of zero or less is an ErrInvalidRange. They
+// are sent as unsigned varints.
+func (bs *Client) GetBlockRange(ctx context.Context, k cid.Cid, offset, length int) ([]byte, error) {
+	if offset < 0 || length <= 0 {
+		return nil, ErrInvalidRange
+	}
+	return bs.getBlockRange(ctx, k, uint64(offset), uint64(length))
+}
+
+func (bs *Client) getBlockRange(ctx context.Context, k cid.Cid, offset, length uint64) ([]byte, error) {
+	blk, err := bs.blockstore.Get(ctx, k)
+	if err == nil {
+		return sliceRange(blk.RawData(), offset, length)
+	}
+	if !ipld.IsNotFound(err) {
+		return nil, err
+	}
+
+	peers := bs.pm.ConnectedPeers()
+	if len(peers) == 0 {
+		return bs.getBlockRangeFallback(ctx, k, offset, length)
+	}
+
+	w := bs.rangeWants.add(k, offset, length, peers)
+	defer bs.rangeWants.remove(k, w)
+
+	msg := bsmsg.New(false)
+	msg.AddRangeEntry(k, math.MaxInt32, offset, length, true)
+	for _, p := range peers {
+		bs.sendRangeWant(ctx, w, p, msg)
+	}
+	defer bs.sendRangeCancels(k, offset, length, peers)
+
+	select {
+	case r := <-w.res:
+		return r.data, r.err
+	case <-w.fallback:
+		return bs.getBlockRangeFallback(ctx, k, offset, length)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sendRangeWant sends the ranged want to the peer. Peers that speak
+// Bitswap 1.0 or 1.1 don't send DONT_HAVE, so they are not waited for: if
+// they are the only ones asked, the full block is fetched straight away.
+func (bs *Client) sendRangeWant(ctx context.Context, w *rangeWaiter, p peer.ID, msg bsmsg.BitSwapMessage) {
+	sender, err := bs.network.NewMessageSender(ctx, p, nil)
+	if err != nil {
+		log.Debugf("failed to open sender for range want; peer=%s: %s", p, err)
+		bs.rangeWants.failed(w, p)
+		return
+	}
+	defer sender.Close()
+
+	if err := sender.SendMsg(ctx, msg); err != nil {
+		log.Debugf("failed to send range want; peer=%s: %s", p, err)
+		bs.rangeWants.failed(w, p)
+		return
+	}
+	if !sender.SupportsHave() {
+		bs.rangeWants.failed(w, p)
+	}
+}
+
+func (bs *Client) getBlockRangeFallback(ctx context.Context, k cid.Cid, offset, length uint64) ([]byte, error) {
+	blk, err := bs.GetBlock(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(blk.RawData(), offset, length)
+}
+
+// sendRangeCancels cancels a ranged want with the peers it was sent to. The
+// cancel is for the range only, so that a want for the whole block sent by a
+// session is left untouched.
+func (bs *Client) sendRangeCancels(k cid.Cid, offset, length uint64, peers []peer.ID) {
+	msg := bsmsg.New(false)
+	msg.CancelRange(k, offset, length)
+	go func() {
+		for _, p := range peers {
+			if err := bs.network.SendMessage(context.Background(), p, msg); err != nil {
+				log.Debugf("failed to send range cancel; cid=%s, peer=%s: %s", k, p, err)
+			}
+		}
+	}()
+}
+
+func sliceRange(data []byte, offset, length uint64) ([]byte, error) {
+	size := uint64(len(data))
+	if offset >= size {
+		return nil, ErrInvalidRange
+	}
+	if size-offset < length {
+		length = size - offset
+	}
+	return data[offset : offset+length], nil
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+func fellBack(w *rangeWaiter) bool {
+	select {
+	case <-w.fallback:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRangeWantsDontHavePerPeer(t *testing.T) {
+	c := blocks.NewBlock([]byte("range")).Cid()
+	p1, p2 := peer.ID("QmPeer1"), peer.ID("QmPeer2")
+
+	rw := newRangeWants()
+	w1 := rw.add(c, 0, 10, []peer.ID{p1, p2})
+	w2 := rw.add(c, 5, 10, []peer.ID{p1, p2})
+
+	// a failed send only concerns the call that sent the want
+	rw.failed(w1, p1)
+	if fellBack(w1) || fellBack(w2) {
+		t.Fatal("expected no fallback while a peer may answer")
+	}
+
+	// the same peer answering DONT_HAVE twice is counted once
+	rw.receive(p2, nil, nil, []cid.Cid{c})
+	rw.receive(p2, nil, nil, []cid.Cid{c})
+	if !fellBack(w1) {
+		t.Fatal("expected a fallback once no peer is left")
+	}
+	if fellBack(w2) {
+		t.Fatal("expected no fallback while p1 may answer")
+	}
+
+	rw.receive(p1, nil, nil, []cid.Cid{c})
+	if !fellBack(w2) {
+		t.Fatal("expected a fallback once no peer is left")
+	}
+}
+
+func resolved(t *testing.T, w *rangeWaiter) (rangeResult, bool) {
+	t.Helper()
+	select {
+	case r := <-w.res:
+		return r, true
+	default:
+		return rangeResult{}, false
+	}
+}
+
+func TestRangeWantsByRange(t *testing.T) {
+	b := blocks.NewBlock([]byte("some block data"))
+	c := b.Cid()
+	p := peer.ID("QmPeer1")
+
+	rw := newRangeWants()
+	short := rw.add(c, 0, 4, []peer.ID{p})
+	long := rw.add(c, 0, 8, []peer.ID{p})
+	past := rw.add(c, 10, 10, []peer.ID{p})
+
+	// a range only answers the waiters of the same range
+	rw.receive(p, nil, []bsmsg.BlockRange{{Cid: c, Offset: 0, Data: b.RawData()[:4]}}, nil)
+	if r, ok := resolved(t, short); !ok || string(r.data) != "some" {
+		t.Fatalf("expected the short range to be answered, got %q", r.data)
+	}
+	if _, ok := resolved(t, long); ok {
+		t.Fatal("expected the long range not to be answered by the short one")
+	}
+
+	rw.receive(p, nil, []bsmsg.BlockRange{{Cid: c, Offset: 0, Data: b.RawData()[:8]}}, nil)
+	if r, ok := resolved(t, long); !ok || string(r.data) != "some blo" {
+		t.Fatalf("expected the long range to be answered, got %q", r.data)
+	}
+
+	// a range cut at the end of the block answers the longer range
+	rw.receive(p, nil, []bsmsg.BlockRange{{Cid: c, Offset: 10, Data: b.RawData()[10:]}}, nil)
+	if r, ok := resolved(t, past); !ok || string(r.data) != " data" {
+		t.Fatalf("expected the range past the end to be answered, got %q", r.data)
+	}
+
+	rw.remove(c, short)
+	rw.remove(c, long)
+	rw.remove(c, past)
+	if rw.has(c) {
+		t.Fatal("expected no waiter left")
+	}
+}
+
+func TestGetBlockRangeInvalid(t *testing.T) {
+	c := blocks.NewBlock([]byte("range")).Cid()
+	bs := &Client{}
+	for _, r := range [][2]int{{-1, 5}, {0, 0}, {0, -1}} {
+		if _, err := bs.GetBlockRange(context.Background(), c, r[0], r[1]); err != ErrInvalidRange {
+			t.Fatalf("expected ErrInvalidRange for offset %d and length %d, got %v", r[0], r[1], err)
+		}
+	}
+}
@@ -9,12 +9,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	delay "github.com/ipfs/go-ipfs-delay"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipfs/go-metrics-interface"
 	process "github.com/jbenet/goprocess"
@@ -60,6 +62,108 @@ func RebroadcastDelay(newRebroadcastDelay delay.D) Option {
 	}
 }
 
+// WithMaxIncomingBlockSize makes the client drop the blocks larger than n
+// bytes sent by peers, as if they hadn't been received. The number of dropped
+// blocks is reported by Stat. A value of zero (the default) disables the
+// limit.
+func WithMaxIncomingBlockSize(n int) Option {
+	return func(bs *Client) {
+		bs.maxIncomingBlockSize = n
+	}
+}
+
+// WithClock sets the clock used for the timing of the wantlist rebroadcasts,
+// the DONT_HAVE timeouts and the session search timeouts, and the negative
+// cache. It defaults to the wall clock and is meant for deterministic tests
+// with a mock clock.
+func WithClock(clk clock.Clock) Option {
+	return func(bs *Client) {
+		bs.clock = clk
+	}
+}
+
+// BlockRequestStrategy chooses the peer of a session to send a want-block to
+// when several peers may have the block.
+type BlockRequestStrategy = bssession.BlockRequestStrategy
+
+// WithBlockRequestStrategy sets the strategy used by the sessions to choose
+// the peer to request a block from among the peers that sent a HAVE for it
+// (or, if none did, among the peers that didn't answer). By default a peer
+// is picked at random, favouring the peers that sent blocks first in the
+// session. The strategy is shared by all the sessions and must be safe for
+// concurrent use.
+func WithBlockRequestStrategy(strategy BlockRequestStrategy) Option {
+	return func(bs *Client) {
+		bs.blockRequestStrategy = strategy
+	}
+}
+
+// WithNegativeCacheTTL enables caching of the CIDs for which a provider
+// search found nothing. For the given duration, GetBlock fails fast with
+// ipld.ErrNotFound for these CIDs, GetBlocks leaves them out of its request,
+// and sessions don't search providers for them again. An entry is dropped as
+// soon as a peer sends the block or a HAVE for it. A duration of zero (the
+// default) disables the cache.
+func WithNegativeCacheTTL(d time.Duration) Option {
+	return func(bs *Client) {
+		bs.negativeCacheTTL = d
+	}
+}
+
+// WithUnsolicitedBlockCallback sets a function called for each block sent by
+// a peer that has no pending want for it, which may reveal a peer probing the
+// client or trying to fill its memory. Note that a block sent in response to
+// a want that was cancelled in the meantime, e.g. because another peer sent
+// the block first, is unsolicited too.
+//
+// The function is called synchronously while processing the message and
+// must not block. Unsolicited blocks are still processed, unless
+// WithDropUnsolicitedBlocks is set.
+func WithUnsolicitedBlockCallback(f func(p peer.ID, c cid.Cid, size int)) Option {
+	return func(bs *Client) {
+		bs.unsolicitedBlockCallback = f
+	}
+}
+
+// WithDropUnsolicitedBlocks makes the client drop the blocks sent by a peer
+// that has no pending want for them (see WithUnsolicitedBlockCallback), as if
+// they hadn't been received. By default they are accepted, and handed to the
+// requests that want them if any.
+func WithDropUnsolicitedBlocks(drop bool) Option {
+	return func(bs *Client) {
+		bs.dropUnsolicitedBlocks = drop
+	}
+}
+
+// WithReceiveBufferBytes bounds the total size of the blocks received from
+// peers that are not stored yet, to limit the memory used by bursty
+// downloads. A received block counts until it is announced with
+// NotifyNewBlocks, as the blockservice does once it has stored it, or for a
+// minute at most. When the bound is reached, the processing of the messages
+// from peers blocks, which pauses the reads from their streams, until some
+// blocks are stored. A value of zero (the default) disables the bound.
+func WithReceiveBufferBytes(n int) Option {
+	return func(bs *Client) {
+		bs.receiveBufferBytes = n
+	}
+}
+
+// WithBroadcastLimit limits the number of peers the want-haves broadcast by
+// the sessions are sent to, to save bandwidth. The peers with the highest
+// score are picked, ranking all the peers again when a peer connects and at
+// each broadcast; a nil score picks the first peers to connect. The wants
+// sent to the peers that a session knows to have its blocks are not limited.
+// A limit of zero (the default) sends the broadcast wants to all peers.
+//
+// A lower limit makes finding the blocks that no session peer has rely more
+// on the provider search, which is slower.
+func WithBroadcastLimit(n int, score func(p peer.ID) float64) Option {
+	return func(bs *Client) {
+		bs.broadcastLimit = n
+		bs.broadcastScore = score
+	}
+}
+
 func SetSimulateDontHavesOnTimeout(send bool) Option {
 	return func(bs *Client) {
 		bs.simulateDontHavesOnTimeout = send
@@ -115,13 +219,15 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		}
 	}
 	peerQueueFactory := func(ctx context.Context, p peer.ID) bspm.PeerQueue {
-		return bsmq.New(ctx, p, network, onDontHaveTimeout)
+		return bsmq.New(ctx, p, network, onDontHaveTimeout, bs.clock, bs.wantPriorities)
 	}
 
 	sim := bssim.New()
 	bpm := bsbpm.New()
 	pm := bspm.New(ctx, peerQueueFactory, network.Self())
 	pqm := bspqm.New(ctx, network)
+	// set once the options are applied, before any session is created
+	var providerFinder bssession.ProviderFinder = pqm
 
 	sessionFactory := func(
 		sessctx context.Context,
@@ -135,7 +241,7 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		provSearchDelay time.Duration,
 		rebroadcastDelay delay.D,
 		self peer.ID) bssm.Session {
-		return bssession.New(sessctx, sessmgr, id, spm, pqm, sim, pm, bpm, notif, provSearchDelay, rebroadcastDelay, self)
+		return bssession.New(sessctx, sessmgr, id, spm, providerFinder, sim, pm, bpm, notif, provSearchDelay, rebroadcastDelay, self, bs.clock, bs.blockRequestStrategy, bs.wantPriorities)
 	}
 	sessionPeerManagerFactory := func(ctx context.Context, id uint64) bssession.SessionPeerManager {
 		return bsspm.New(id, network.ConnectionManager())
@@ -152,12 +258,15 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		sm:                         sm,
 		sim:                        sim,
 		notif:                      notif,
+		rangeWants:                 newRangeWants(),
+		wantPriorities:             bsmq.NewWantPriorities(),
 		counters:                   new(counters),
 		dupMetric:                  bmetrics.DupHist(ctx),
 		allMetric:                  bmetrics.AllHist(ctx),
 		provSearchDelay:            defaults.ProvSearchDelay,
 		rebroadcastDelay:           delay.Fixed(time.Minute),
 		simulateDontHavesOnTimeout: true,
+		clock:                      clock.New(),
 	}
 
 	// apply functional options before starting and running bitswap
@@ -165,6 +274,20 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 		option(bs)
 	}
 
+	if bs.broadcastLimit > 0 {
+		pm.SetBroadcastLimit(bs.broadcastLimit, bs.broadcastScore)
+	}
+
+	if bs.receiveBufferBytes > 0 {
+		bs.receiveBuffer = newReceiveBuffer(bs.receiveBufferBytes, bs.clock)
+	}
+
+	if bs.negativeCacheTTL > 0 {
+		bs.negativeCache = newNegativeCache(bs.negativeCacheTTL)
+		bs.negativeCache.now = bs.clock.Now
+		providerFinder = &negativeCachingFinder{finder: pqm, cache: bs.negativeCache}
+	}
+
 	bs.pqm.Startup()
 
 	// bind the context and process.
@@ -180,6 +303,27 @@ func New(parent context.Context, network bsnet.BitSwapNetwork, bstore blockstore
 	return bs
 }
 
+// PrioritizedFetcher is implemented by the sessions returned by NewSession,
+// it allows to set the priority of the wants of the session for a CID before
+// fetching it.
+type PrioritizedFetcher interface {
+	exchange.Fetcher
+	WantWithPriority(c cid.Cid, priority int32)
+}
+
+var _ PrioritizedFetcher = (*bssession.Session)(nil)
+
+// ProgressEstimator is implemented by the sessions returned by NewSession, it
+// estimates the bytes left to receive for the blocks requested from the
+// session and the time it will take.
+// See bssession.Session.EstimatedCompletion for how the estimate is made.
+type ProgressEstimator interface {
+	exchange.Fetcher
+	EstimatedCompletion() (remainingBytes uint64, eta time.Duration, ok bool)
+}
+
+var _ ProgressEstimator = (*bssession.Session)(nil)
+
 // Client instances implement the bitswap protocol.
 type Client struct {
 	pm *bspm.PeerManager
@@ -197,6 +341,37 @@ type Client struct {
 	// manages channels of outgoing blocks for sessions
 	notif notifications.PubSub
 
+	// CIDs recently searched and not found, nil when disabled
+	negativeCacheTTL time.Duration
+	negativeCache    *negativeCache
+
+	// the clock of the timers of the message queues and the sessions
+	clock clock.Clock
+
+	// chooses the peers sessions request blocks from, nil for the default
+	blockRequestStrategy BlockRequestStrategy
+
+	// priorities requested with WantWithPriority
+	wantPriorities *bsmq.WantPriorities
+
+	// blocks larger than this are dropped, 0 for no limit
+	maxIncomingBlockSize int
+
+	// bounds the received blocks not stored yet, nil when disabled
+	receiveBufferBytes int
+	receiveBuffer      *receiveBuffer
+
+	// the number of peers the broadcast wants are sent to, 0 for all
+	broadcastLimit int
+	broadcastScore func(peer.ID) float64
+
+	// called for the blocks received from a peer without a pending want
+	unsolicitedBlockCallback func(p peer.ID, c cid.Cid, size int)
+	dropUnsolicitedBlocks    bool
+
+	// ranged wants waiting for a response, see GetBlockRange
+	rangeWants *rangeWants
+
 	process process.Process
 
 	// Counters for various statistics
@@ -230,11 +405,12 @@ type Client struct {
 }
 
 type counters struct {
-	blocksRecvd    uint64
-	dupBlocksRecvd uint64
-	dupDataRecvd   uint64
-	dataRecvd      uint64
-	messagesRecvd  uint64
+	blocksRecvd            uint64
+	dupBlocksRecvd         uint64
+	dupDataRecvd           uint64
+	dataRecvd              uint64
+	messagesRecvd          uint64
+	oversizedBlocksDropped uint64
 }
 
 // GetBlock attempts to retrieve a particular block from peers within the
@@ -242,6 +418,9 @@ type counters struct {
 func (bs *Client) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
 	ctx, span := internal.StartSpan(ctx, "GetBlock", trace.WithAttributes(attribute.String("Key", k.String())))
 	defer span.End()
+	if bs.negativeCache != nil && bs.negativeCache.has(k) {
+		return nil, ipld.ErrNotFound{Cid: k}
+	}
 	return bsgetter.SyncGetBlock(ctx, k, bs.GetBlocks)
 }
 
@@ -255,6 +434,9 @@ func (bs *Client) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error)
 func (bs *Client) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
 	ctx, span := internal.StartSpan(ctx, "GetBlocks", trace.WithAttributes(attribute.Int("NumKeys", len(keys))))
 	defer span.End()
+	if bs.negativeCache != nil {
+		keys = bs.negativeCache.filter(keys)
+	}
 	session := bs.sm.NewSession(ctx, bs.provSearchDelay, bs.rebroadcastDelay)
 	return session.GetBlocks(ctx, keys)
 }
@@ -276,6 +458,13 @@ func (bs *Client) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) err
 	for i, blk := range blks {
 		blkCids[i] = blk.Cid()
 	}
+	if bs.negativeCache != nil {
+		bs.negativeCache.remove(blkCids...)
+	}
+	// The blocks are stored, they no longer use the receive buffer
+	if bs.receiveBuffer != nil {
+		bs.receiveBuffer.release(blkCids...)
+	}
 
 	// Send all block keys (including duplicates) to any sessions that want them.
 	// (The duplicates are needed by sessions for accounting purposes)
@@ -314,6 +503,15 @@ func (bs *Client) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []bl
 	combined = append(combined, dontHaves...)
 	bs.pm.ResponseReceived(from, combined)
 
+	// The blocks are no longer wanted, forget their priorities
+	bs.wantPriorities.Remove(allKs...)
+
+	// A peer has the blocks, the negative results are stale
+	if bs.negativeCache != nil {
+		bs.negativeCache.remove(allKs...)
+		bs.negativeCache.remove(haves...)
+	}
+
 	// Send all block keys (including duplicates) to any sessions that want them for accounting purpose.
 	bs.sm.ReceiveFrom(ctx, from, allKs, haves, dontHaves)
 
@@ -347,6 +545,24 @@ func (bs *Client) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.
 	}
 
 	iblocks := incoming.Blocks()
+	if bs.maxIncomingBlockSize > 0 {
+		iblocks = bs.dropOversizedBlocks(p, iblocks)
+	}
+	// before the blocks are processed, which cancels the wants for them
+	if len(iblocks) > 0 && (bs.unsolicitedBlockCallback != nil || bs.dropUnsolicitedBlocks) {
+		iblocks = bs.checkUnsolicitedBlocks(p, iblocks)
+	}
+
+	if len(iblocks) > 0 && bs.receiveBuffer != nil {
+		reserved, err := bs.receiveBuffer.reserve(ctx, iblocks)
+		if err != nil {
+			log.Warnf("ReceiveMessage waiting for the receive buffer: %s", err)
+			return
+		}
+		// the wanted blocks are held until they are stored
+		_, notWanted := bs.sim.SplitWantedUnwanted(iblocks)
+		defer bs.releaseUnwanted(reserved, notWanted)
+	}
 
 	if len(iblocks) > 0 {
 		bs.updateReceiveCounters(iblocks)
@@ -357,6 +573,7 @@ func (bs *Client) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.
 
 	haves := incoming.Haves()
 	dontHaves := incoming.DontHaves()
+	bs.rangeWants.receive(p, iblocks, incoming.BlockRanges(), dontHaves)
 	if len(iblocks) > 0 || len(haves) > 0 || len(dontHaves) > 0 {
 		// Process blocks
 		err := bs.receiveBlocksFrom(ctx, p, iblocks, haves, dontHaves)
@@ -367,6 +584,81 @@ func (bs *Client) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.
 	}
 }
 
+// releaseUnwanted removes from the receive buffer the blocks that no request
+// wanted, as they won't be stored, among the ones reserved by a message
+func (bs *Client) releaseUnwanted(reserved *cid.Set, notWanted []blocks.Block) {
+	ks := make([]cid.Cid, 0, len(notWanted))
+	for _, b := range notWanted {
+		if reserved.Has(b.Cid()) {
+			ks = append(ks, b.Cid())
+		}
+	}
+	bs.receiveBuffer.release(ks...)
+}
+
+// dropOversizedBlocks filters out the blocks larger than the maximum incoming
+// block size
+func (bs *Client) dropOversizedBlocks(p peer.ID, iblocks []blocks.Block) []blocks.Block {
+	var dropped uint64
+	kept := iblocks[:0:0]
+	for _, b := range iblocks {
+		if size := len(b.RawData()); size > bs.maxIncomingBlockSize {
+			log.Warnw("dropping oversized block", "cid", b.Cid(), "peer", p, "size", size, "max", bs.maxIncomingBlockSize)
+			dropped++
+			continue
+		}
+		kept = append(kept, b)
+	}
+
+	if dropped > 0 {
+		bs.counterLk.Lock()
+		bs.counters.oversizedBlocksDropped += dropped
+		bs.counterLk.Unlock()
+	}
+	return kept
+}
+
+// checkUnsolicitedBlocks reports the blocks for which no want is pending with
+// the peer, and filters them out if they must be dropped
+func (bs *Client) checkUnsolicitedBlocks(p peer.ID, iblocks []blocks.Block) []blocks.Block {
+	ks := make([]cid.Cid, 0, len(iblocks))
+	for _, b := range iblocks {
+		ks = append(ks, b.Cid())
+	}
+	notWanted := bs.pm.NotWantedFrom(p, ks)
+	if len(notWanted) == 0 {
+		return iblocks
+	}
+
+	unsolicited := cid.NewSet()
+	for _, c := range notWanted {
+		// ranged wants are not tracked by the peer manager
+		if !bs.rangeWants.has(c) {
+			unsolicited.Add(c)
+		}
+	}
+	if unsolicited.Len() == 0 {
+		return iblocks
+	}
+
+	kept := iblocks[:0:0]
+	for _, b := range iblocks {
+		if !unsolicited.Has(b.Cid()) {
+			kept = append(kept, b)
+			continue
+		}
+
+		log.Debugw("unsolicited block", "cid", b.Cid(), "peer", p)
+		if bs.unsolicitedBlockCallback != nil {
+			bs.unsolicitedBlockCallback(p, b.Cid(), len(b.RawData()))
+		}
+		if !bs.dropUnsolicitedBlocks {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
 func (bs *Client) updateReceiveCounters(blocks []blocks.Block) {
 	// Check which blocks are in the datastore
 	// (Note: any errors from the blockstore are simply logged out in
@@ -461,6 +753,17 @@ func (bs *Client) GetWantHaves() []cid.Cid {
 	return bs.pm.CurrentWantHaves()
 }
 
+// ResendWantlist sends the full current wantlist to the given peer again,
+// without waiting for the periodic rebroadcast. It can be used when the peer
+// is suspected to have lost track of our wants, for example after it
+// restarted. It does nothing if nothing is wanted from the peer.
+//
+// Every call re-sends every want, so calling it often increases the traffic
+// with the peer considerably.
+func (bs *Client) ResendWantlist(p peer.ID) {
+	bs.pm.ResendWantlist(p)
+}
+
 // IsOnline is needed to match go-ipfs-exchange-interface
 func (bs *Client) IsOnline() bool {
 	return true
@@ -472,6 +775,8 @@ func (bs *Client) IsOnline() bool {
 // method, but the session will use the fact that the requests are related to
 // be more efficient in its requests to peers. If you are using a session
 // from go-blockservice, it will create a bitswap session automatically.
+//
+// The returned session implements PrioritizedFetcher and ProgressEstimator.
 func (bs *Client) NewSession(ctx context.Context) exchange.Fetcher {
 	ctx, span := internal.StartSpan(ctx, "NewSession")
 	defer span.End()
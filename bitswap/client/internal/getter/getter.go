@@ -66,9 +66,10 @@ type WantFunc func(context.Context, []cid.Cid)
 
 // AsyncGetBlocks take a set of block cids, a pubsub channel for incoming
 // blocks, a want function, and a close function, and returns a channel of
-// incoming blocks.
+// incoming blocks. The received function, if not nil, is called for each
+// block before it is sent on the channel.
 func AsyncGetBlocks(ctx context.Context, sessctx context.Context, keys []cid.Cid, notif notifications.PubSub,
-	want WantFunc, cwants func([]cid.Cid)) (<-chan blocks.Block, error) {
+	want WantFunc, cwants func([]cid.Cid), received func(blocks.Block)) (<-chan blocks.Block, error) {
 	ctx, span := internal.StartSpan(ctx, "Getter.AsyncGetBlocks")
 	defer span.End()
 
@@ -91,7 +92,7 @@ func AsyncGetBlocks(ctx context.Context, sessctx context.Context, keys []cid.Cid
 	want(ctx, keys)
 
 	out := make(chan blocks.Block)
-	go handleIncoming(ctx, sessctx, remaining, promise, out, cwants)
+	go handleIncoming(ctx, sessctx, remaining, promise, out, cwants, received)
 	return out, nil
 }
 
@@ -99,7 +100,7 @@ func AsyncGetBlocks(ctx context.Context, sessctx context.Context, keys []cid.Cid
 // If the context is cancelled or the incoming channel closes, calls cfun with
 // any keys corresponding to blocks that were never received.
 func handleIncoming(ctx context.Context, sessctx context.Context, remaining *cid.Set,
-	in <-chan blocks.Block, out chan blocks.Block, cfun func([]cid.Cid)) {
+	in <-chan blocks.Block, out chan blocks.Block, cfun func([]cid.Cid), received func(blocks.Block)) {
 
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -122,6 +123,9 @@ func handleIncoming(ctx context.Context, sessctx context.Context, remaining *cid
 			}
 
 			remaining.Remove(blk.Cid())
+			if received != nil {
+				received(blk)
+			}
 			select {
 			case out <- blk:
 			case <-ctx.Done():
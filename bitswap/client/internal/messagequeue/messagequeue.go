@@ -88,6 +88,8 @@ type MessageQueue struct {
 	peerWants recallWantlist
 	cancels   *cid.Set
 	priority  int32
+	// Priorities requested for specific CIDs, may be nil
+	priorities *WantPriorities
 
 	// Dont touch any of these variables outside of run loop
 	sender                bsnet.MessageSender
@@ -211,15 +213,19 @@ type DontHaveTimeoutManager interface {
 	UpdateMessageLatency(time.Duration)
 }
 
-// New creates a new MessageQueue.
-func New(ctx context.Context, p peer.ID, network MessageNetwork, onDontHaveTimeout OnDontHaveTimeout) *MessageQueue {
+// New creates a new MessageQueue. The clock is used for the rebroadcast of the
+// wantlist and the DONT_HAVE timeouts. Wants for the CIDs that have a priority
+// in priorities are sent with that priority, other wants get decreasing
+// priorities in the order they are added. priorities may be nil.
+func New(ctx context.Context, p peer.ID, network MessageNetwork, onDontHaveTimeout OnDontHaveTimeout, clock clock.Clock, priorities *WantPriorities) *MessageQueue {
 	onTimeout := func(ks []cid.Cid) {
 		log.Infow("Bitswap: timeout waiting for blocks", "cids", ks, "peer", p)
 		onDontHaveTimeout(p, ks)
 	}
-	clock := clock.New()
 	dhTimeoutMgr := newDontHaveTimeoutMgr(newPeerConnection(p, network), onTimeout, clock)
-	return newMessageQueue(ctx, p, network, maxMessageSize, sendErrorBackoff, maxValidLatency, dhTimeoutMgr, clock, nil)
+	mq := newMessageQueue(ctx, p, network, maxMessageSize, sendErrorBackoff, maxValidLatency, dhTimeoutMgr, clock, nil)
+	mq.priorities = priorities
+	return mq
 }
 
 type messageEvent int
@@ -277,8 +283,7 @@ func (mq *MessageQueue) AddBroadcastWantHaves(wantHaves []cid.Cid) {
 	defer mq.wllock.Unlock()
 
 	for _, c := range wantHaves {
-		mq.bcstWants.Add(c, mq.priority, pb.Message_Wantlist_Have)
-		mq.priority--
+		mq.bcstWants.Add(c, mq.nextPriority(c), pb.Message_Wantlist_Have)
 
 		// We're adding a want-have for the cid, so clear any pending cancel
 		// for the cid
@@ -299,16 +304,14 @@ func (mq *MessageQueue) AddWants(wantBlocks []cid.Cid, wantHaves []cid.Cid) {
 	defer mq.wllock.Unlock()
 
 	for _, c := range wantHaves {
-		mq.peerWants.Add(c, mq.priority, pb.Message_Wantlist_Have)
-		mq.priority--
+		mq.peerWants.Add(c, mq.nextPriority(c), pb.Message_Wantlist_Have)
 
 		// We're adding a want-have for the cid, so clear any pending cancel
 		// for the cid
 		mq.cancels.Remove(c)
 	}
 	for _, c := range wantBlocks {
-		mq.peerWants.Add(c, mq.priority, pb.Message_Wantlist_Block)
-		mq.priority--
+		mq.peerWants.Add(c, mq.nextPriority(c), pb.Message_Wantlist_Block)
 
 		// We're adding a want-block for the cid, so clear any pending cancel
 		// for the cid
@@ -319,6 +322,18 @@ func (mq *MessageQueue) AddWants(wantBlocks []cid.Cid, wantHaves []cid.Cid) {
 	mq.signalWorkReady()
 }
 
+// nextPriority returns the priority of a new want for the CID: the one set in
+// the priorities if any, the next default priority otherwise.
+// wllock must be held.
+func (mq *MessageQueue) nextPriority(c cid.Cid) int32 {
+	if p, ok := mq.priorities.get(c); ok {
+		return p
+	}
+	p := mq.priority
+	mq.priority--
+	return p
+}
+
 // Add cancel messages for the given keys.
 func (mq *MessageQueue) AddCancels(cancelKs []cid.Cid) {
 	if len(cancelKs) == 0 {
@@ -328,6 +343,11 @@ func (mq *MessageQueue) AddCancels(cancelKs []cid.Cid) {
 	// Cancel any outstanding DONT_HAVE timers
 	mq.dhTimeoutMgr.CancelPending(cancelKs)
 
+	// The wants are no longer needed, forget their priorities
+	if mq.priorities != nil {
+		mq.priorities.Remove(cancelKs...)
+	}
+
 	mq.wllock.Lock()
 
 	workReady := false
@@ -375,6 +395,15 @@ func (mq *MessageQueue) ResponseReceived(ks []cid.Cid) {
 	}
 }
 
+// ResendWantlist queues all the wants that were already sent to the peer to
+// be sent again, without waiting for the next rebroadcast. It does nothing if
+// no wants were sent to the peer.
+func (mq *MessageQueue) ResendWantlist() {
+	if mq.transferRebroadcastWants() {
+		mq.signalWorkReady()
+	}
+}
+
 // SetRebroadcastInterval sets a new interval on which to rebroadcast the full wantlist
 func (mq *MessageQueue) SetRebroadcastInterval(delay time.Duration) {
 	mq.rebroadcastIntervalLk.Lock()
@@ -164,7 +164,7 @@ func TestStartupAndShutdown(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 	bcstwh := testutil.GenerateCids(10)
 
 	messageQueue.Startup()
@@ -204,7 +204,7 @@ func TestSendingMessagesDeduped(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 	wantHaves := testutil.GenerateCids(10)
 	wantBlocks := testutil.GenerateCids(10)
 
@@ -227,7 +227,7 @@ func TestSendingMessagesPartialDupe(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 	wantHaves := testutil.GenerateCids(10)
 	wantBlocks := testutil.GenerateCids(10)
 
@@ -250,7 +250,7 @@ func TestSendingMessagesPriority(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 	wantHaves1 := testutil.GenerateCids(5)
 	wantHaves2 := testutil.GenerateCids(5)
 	wantHaves := append(wantHaves1, wantHaves2...)
@@ -310,6 +310,57 @@ func TestSendingMessagesPriority(t *testing.T) {
 	}
 }
 
+func TestSendingMessagesRequestedPriority(t *testing.T) {
+	ctx := context.Background()
+	messagesSent := make(chan []bsmsg.Entry)
+	resetChan := make(chan struct{}, 1)
+	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
+	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
+	peerID := testutil.GeneratePeers(1)[0]
+	priorities := NewWantPriorities()
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), priorities)
+	wantBlocks := testutil.GenerateCids(3)
+	wantHaves := testutil.GenerateCids(2)
+
+	priorities.Set(wantBlocks[2], math.MaxInt32)
+	priorities.Set(wantHaves[1], 7)
+
+	messageQueue.Startup()
+	messageQueue.AddWants(wantBlocks, nil)
+	messageQueue.AddBroadcastWantHaves(wantHaves)
+	messages := collectMessages(ctx, t, messagesSent, 20*time.Millisecond)
+
+	byCid := make(map[cid.Cid]bsmsg.Entry)
+	for _, m := range messages {
+		for _, entry := range m {
+			byCid[entry.Cid] = entry
+		}
+	}
+	if len(byCid) != len(wantBlocks)+len(wantHaves) {
+		t.Fatal("wrong number of wants")
+	}
+
+	// Wants without a requested priority keep the default decreasing ones
+	if byCid[wantBlocks[0]].Priority != math.MaxInt32 || byCid[wantBlocks[1]].Priority != math.MaxInt32-1 {
+		t.Fatal("wants without a requested priority should have the default one")
+	}
+	if byCid[wantBlocks[2]].Priority != math.MaxInt32 {
+		t.Fatal("want-block should have the requested priority")
+	}
+	if byCid[wantHaves[1]].Priority != 7 {
+		t.Fatal("broadcast want-have should have the requested priority")
+	}
+
+	// Cancelling the wants forgets their priorities
+	messageQueue.AddCancels(wantBlocks)
+	if _, ok := priorities.get(wantBlocks[2]); ok {
+		t.Fatal("expected the priority to be removed on cancel")
+	}
+	if _, ok := priorities.get(wantHaves[1]); !ok {
+		t.Fatal("expected the priority of a want that was not cancelled to remain")
+	}
+}
+
 func TestCancelOverridesPendingWants(t *testing.T) {
 	test.Flaky(t)
 
@@ -319,7 +370,7 @@ func TestCancelOverridesPendingWants(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 
 	wantHaves := testutil.GenerateCids(2)
 	wantBlocks := testutil.GenerateCids(2)
@@ -371,7 +422,7 @@ func TestWantOverridesPendingCancels(t *testing.T) {
 	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 
 	cids := testutil.GenerateCids(3)
 	wantBlocks := cids[:1]
@@ -520,6 +571,52 @@ func TestWantlistRebroadcast(t *testing.T) {
 	}
 }
 
+func TestResendWantlist(t *testing.T) {
+	ctx := context.Background()
+	messagesSent := make(chan []bsmsg.Entry)
+	resetChan := make(chan struct{}, 1)
+	fakeSender := newFakeMessageSender(resetChan, messagesSent, true)
+	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
+	peerID := testutil.GeneratePeers(1)[0]
+	dhtm := &fakeDontHaveTimeoutMgr{}
+	clock := clock.NewMock()
+	events := make(chan messageEvent)
+	messageQueue := newMessageQueue(ctx, peerID, fakenet, maxMessageSize, sendErrorBackoff, maxValidLatency, dhtm, clock, events)
+	wantHaves := testutil.GenerateCids(10)
+	wantBlocks := testutil.GenerateCids(10)
+
+	messageQueue.Startup()
+	messageQueue.SetRebroadcastInterval(time.Hour)
+
+	// Nothing was sent yet, so there is nothing to resend
+	messageQueue.ResendWantlist()
+	select {
+	case <-events:
+		t.Fatal("expected no message to be queued for an empty wantlist")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	messageQueue.AddWants(wantBlocks, wantHaves)
+	expectEvent(t, events, messageQueued)
+	clock.Add(sendMessageDebounce)
+	message := <-messagesSent
+	expectEvent(t, events, messageFinishedSending)
+	totalWants := len(wantHaves) + len(wantBlocks)
+	if len(message) != totalWants {
+		t.Fatal("wrong number of wants")
+	}
+
+	// All the wants should be sent again
+	messageQueue.ResendWantlist()
+	expectEvent(t, events, messageQueued)
+	clock.Add(sendMessageDebounce)
+	message = <-messagesSent
+	expectEvent(t, events, messageFinishedSending)
+	if len(message) != totalWants {
+		t.Fatalf("expected %d wants to be resent, got %d", totalWants, len(message))
+	}
+}
+
 func TestSendingLargeMessages(t *testing.T) {
 	test.Flaky(t)
 
@@ -561,7 +658,7 @@ func TestSendToPeerThatDoesntSupportHave(t *testing.T) {
 	fakenet := &fakeMessageNetwork{nil, nil, fakeSender}
 	peerID := testutil.GeneratePeers(1)[0]
 
-	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb)
+	messageQueue := New(ctx, peerID, fakenet, mockTimeoutCb, clock.New(), nil)
 	messageQueue.Startup()
 
 	// If the remote peer doesn't support HAVE / DONT_HAVE messages
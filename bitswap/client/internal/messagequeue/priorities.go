@@ -0,0 +1,54 @@
+package messagequeue
+
+import (
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// WantPriorities holds the priorities requested for specific CIDs. It is
+// shared by the message queues of all the peers so that a want is sent with
+// the same priority to every peer.
+type WantPriorities struct {
+	lk         sync.RWMutex
+	priorities map[cid.Cid]int32
+}
+
+// NewWantPriorities creates an empty WantPriorities.
+func NewWantPriorities() *WantPriorities {
+	return &WantPriorities{priorities: make(map[cid.Cid]int32)}
+}
+
+// Set sets the priority of the wants for the CID that are added from now on.
+func (wp *WantPriorities) Set(c cid.Cid, priority int32) {
+	wp.lk.Lock()
+	defer wp.lk.Unlock()
+	wp.priorities[c] = priority
+}
+
+// Remove forgets the priorities of the given CIDs, so that new wants for them
+// get the default priority.
+func (wp *WantPriorities) Remove(ks ...cid.Cid) {
+	wp.lk.Lock()
+	defer wp.lk.Unlock()
+
+	if len(wp.priorities) == 0 {
+		return
+	}
+	for _, c := range ks {
+		delete(wp.priorities, c)
+	}
+}
+
+// get returns the priority set for the CID, if any. It is safe to call on a
+// nil WantPriorities.
+func (wp *WantPriorities) get(c cid.Cid) (int32, bool) {
+	if wp == nil {
+		return 0, false
+	}
+
+	wp.lk.RLock()
+	defer wp.lk.RUnlock()
+	p, ok := wp.priorities[c]
+	return p, ok
+}
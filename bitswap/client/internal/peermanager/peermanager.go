@@ -19,6 +19,7 @@ type PeerQueue interface {
 	AddWants([]cid.Cid, []cid.Cid)
 	AddCancels([]cid.Cid)
 	ResponseReceived(ks []cid.Cid)
+	ResendWantlist()
 	Startup()
 	Shutdown()
 }
@@ -65,6 +66,17 @@ func New(ctx context.Context, createPeerQueue PeerQueueFactory, self peer.ID) *P
 	}
 }
 
+// SetBroadcastLimit limits the number of peers the broadcast want-haves are
+// sent to, picking the peers with the highest score when several are
+// available. The score is called with the PeerManager locked. It must be
+// called before any peer connects.
+func (pm *PeerManager) SetBroadcastLimit(limit int, score func(peer.ID) float64) {
+	pm.pqLk.Lock()
+	defer pm.pqLk.Unlock()
+
+	pm.pwm.setBroadcastLimit(limit, score)
+}
+
 func (pm *PeerManager) AvailablePeers() []peer.ID {
 	// TODO: Rate-limit peers
 	return pm.ConnectedPeers()
@@ -130,6 +142,18 @@ func (pm *PeerManager) ResponseReceived(p peer.ID, ks []cid.Cid) {
 	}
 }
 
+// ResendWantlist sends again to the peer all the wants that were already sent
+// to it. It does nothing if the peer is not connected.
+func (pm *PeerManager) ResendWantlist(p peer.ID) {
+	pm.pqLk.Lock()
+	pq, ok := pm.peerQueues[p]
+	pm.pqLk.Unlock()
+
+	if ok {
+		pq.ResendWantlist()
+	}
+}
+
 // BroadcastWantHaves broadcasts want-haves to all peers (used by the session
 // to discover seeds).
 // For each peer it filters out want-haves that have previously been sent to
@@ -186,6 +210,16 @@ func (pm *PeerManager) CurrentWantHaves() []cid.Cid {
 	return pm.pwm.getWantHaves()
 }
 
+// NotWantedFrom returns the keys for which no want is pending with the peer,
+// i.e. for which the peer was sent no want, or only wants that were
+// cancelled since.
+func (pm *PeerManager) NotWantedFrom(p peer.ID, ks []cid.Cid) []cid.Cid {
+	pm.pqLk.RLock()
+	defer pm.pqLk.RUnlock()
+
+	return pm.pwm.notWantedFrom(p, ks)
+}
+
 func (pm *PeerManager) getOrCreate(p peer.ID) PeerQueue {
 	pq, ok := pm.peerQueues[p]
 	if !ok {
@@ -38,6 +38,8 @@ func (fp *mockPeerQueue) AddCancels(cs []cid.Cid) {
 }
 func (fp *mockPeerQueue) ResponseReceived(ks []cid.Cid) {
 }
+func (fp *mockPeerQueue) ResendWantlist() {
+}
 
 type peerWants struct {
 	wantHaves  []cid.Cid
@@ -342,6 +344,7 @@ func (*benchPeerQueue) AddBroadcastWantHaves(whs []cid.Cid)   {}
 func (*benchPeerQueue) AddWants(wbs []cid.Cid, whs []cid.Cid) {}
 func (*benchPeerQueue) AddCancels(cs []cid.Cid)               {}
 func (*benchPeerQueue) ResponseReceived(ks []cid.Cid)         {}
+func (*benchPeerQueue) ResendWantlist()                       {}
 
 // Simplistic benchmark to allow us to stress test
 func BenchmarkPeerManager(b *testing.B) {
@@ -3,6 +3,7 @@ package peermanager
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	cid "github.com/ipfs/go-cid"
 	peer "github.com/mikelsr/go-libp2p/core/peer"
@@ -34,6 +35,16 @@ type peerWantManager struct {
 	wantGauge Gauge
 	// Keeps track of the number of active want-blocks
 	wantBlockGauge Gauge
+
+	// broadcastLimit is the maximum number of peers the broadcast wants are
+	// sent to, 0 if they are sent to all peers, see setBroadcastLimit
+	broadcastLimit int
+	// peerScore ranks the peers to send the broadcast wants to, nil if
+	// they are taken in the order they connect
+	peerScore func(peer.ID) float64
+	// broadcastPeers are the peers the broadcast wants are sent to, when
+	// they are limited
+	broadcastPeers map[peer.ID]struct{}
 }
 
 type peerWant struct {
@@ -67,6 +78,13 @@ func (pwm *peerWantManager) addPeer(peerQueue PeerQueue, p peer.ID) {
 		peerQueue:  peerQueue,
 	}
 
+	if pwm.broadcastLimit > 0 {
+		// The peer is sent the broadcast wants if it has a place among the
+		// broadcast peers
+		pwm.rankBroadcastPeers()
+		return
+	}
+
 	// Broadcast any live want-haves to the newly connected peer
 	if pwm.broadcastWants.Len() > 0 {
 		wants := pwm.broadcastWants.Keys()
@@ -112,10 +130,107 @@ func (pwm *peerWantManager) removePeer(p peer.ID) {
 	})
 
 	delete(pwm.peerWants, p)
+	delete(pwm.broadcastPeers, p)
+}
+
+// setBroadcastLimit limits the number of peers the broadcast wants are sent
+// to, picking the connected peers with the highest score. The peers are
+// ranked again when a peer connects and at each broadcast, so that a peer
+// that disconnected is replaced and the scores that changed are taken into
+// account. It must be called before any peer is added.
+func (pwm *peerWantManager) setBroadcastLimit(limit int, score func(peer.ID) float64) {
+	pwm.broadcastLimit = limit
+	pwm.peerScore = score
+	pwm.broadcastPeers = make(map[peer.ID]struct{}, limit)
+}
+
+// isBroadcastPeer returns true if the broadcast wants are sent to the peer
+func (pwm *peerWantManager) isBroadcastPeer(p peer.ID) bool {
+	if pwm.broadcastLimit <= 0 {
+		return true
+	}
+	_, ok := pwm.broadcastPeers[p]
+	return ok
+}
+
+// rankBroadcastPeers picks the peers with the highest scores as the broadcast
+// peers. The peers that are picked are sent the broadcast wants, and the
+// peers that are no longer picked are sent cancels for the broadcast wants
+// they weren't sent directly. The current broadcast peers are kept on a tie,
+// and without a score the peers are only picked to fill the free places.
+func (pwm *peerWantManager) rankBroadcastPeers() {
+	if pwm.broadcastLimit <= 0 {
+		return
+	}
+	if pwm.peerScore == nil && (len(pwm.broadcastPeers) >= pwm.broadcastLimit ||
+		len(pwm.broadcastPeers) == len(pwm.peerWants)) {
+		return
+	}
+
+	ranked := make([]peer.ID, 0, len(pwm.peerWants))
+	scores := make(map[peer.ID]float64, len(pwm.peerWants))
+	for p := range pwm.peerWants {
+		ranked = append(ranked, p)
+		if pwm.peerScore != nil {
+			scores[p] = pwm.peerScore(p)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		pi, pj := ranked[i], ranked[j]
+		if scores[pi] != scores[pj] {
+			return scores[pi] > scores[pj]
+		}
+		return pwm.isBroadcastPeer(pi) && !pwm.isBroadcastPeer(pj)
+	})
+	if len(ranked) > pwm.broadcastLimit {
+		ranked = ranked[:pwm.broadcastLimit]
+	}
+	picked := make(map[peer.ID]struct{}, len(ranked))
+	for _, p := range ranked {
+		picked[p] = struct{}{}
+	}
+
+	wants := pwm.broadcastWants.Keys()
+	for p := range pwm.broadcastPeers {
+		if _, ok := picked[p]; ok {
+			continue
+		}
+		delete(pwm.broadcastPeers, p)
+		if pws := pwm.peerWants[p]; len(wants) > 0 {
+			if cancels := pws.notSent(wants); len(cancels) > 0 {
+				pws.peerQueue.AddCancels(cancels)
+			}
+		}
+	}
+	for _, p := range ranked {
+		if pwm.isBroadcastPeer(p) {
+			continue
+		}
+		pwm.broadcastPeers[p] = struct{}{}
+		if pws := pwm.peerWants[p]; len(wants) > 0 {
+			if unsent := pws.notSent(wants); len(unsent) > 0 {
+				pws.peerQueue.AddBroadcastWantHaves(unsent)
+			}
+		}
+	}
+}
+
+// notSent returns the CIDs that were not sent to the peer as a want-block or
+// a want-have
+func (pws *peerWant) notSent(ks []cid.Cid) []cid.Cid {
+	res := make([]cid.Cid, 0, len(ks))
+	for _, c := range ks {
+		if !pws.wantBlocks.Has(c) && !pws.wantHaves.Has(c) {
+			res = append(res, c)
+		}
+	}
+	return res
 }
 
 // broadcastWantHaves sends want-haves to any peers that have not yet been sent them.
 func (pwm *peerWantManager) broadcastWantHaves(wantHaves []cid.Cid) {
+	pwm.rankBroadcastPeers()
+
 	unsent := make([]cid.Cid, 0, len(wantHaves))
 	for _, c := range wantHaves {
 		if pwm.broadcastWants.Has(c) {
@@ -140,7 +255,10 @@ func (pwm *peerWantManager) broadcastWantHaves(wantHaves []cid.Cid) {
 	bcstWantsBuffer := make([]cid.Cid, 0, len(unsent))
 
 	// Send broadcast wants to each peer
-	for _, pws := range pwm.peerWants {
+	for p, pws := range pwm.peerWants {
+		if !pwm.isBroadcastPeer(p) {
+			continue
+		}
 		peerUnsent := bcstWantsBuffer[:0]
 		for _, c := range unsent {
 			// If we've already sent a want to this peer, skip them.
@@ -200,12 +318,6 @@ func (pwm *peerWantManager) sendWants(p peer.ID, wantBlocks []cid.Cid, wantHaves
 
 	// Iterate over the requested want-haves
 	for _, c := range wantHaves {
-		// If we've already broadcasted this want, don't bother with a
-		// want-have.
-		if pwm.broadcastWants.Has(c) {
-			continue
-		}
-
 		// If the CID has not been sent as a want-block or want-have
 		if !pws.wantBlocks.Has(c) && !pws.wantHaves.Has(c) {
 			// Increment the total wants gauge
@@ -214,14 +326,22 @@ func (pwm *peerWantManager) sendWants(p peer.ID, wantBlocks []cid.Cid, wantHaves
 				pwm.wantGauge.Inc()
 			}
 
-			// Record that the CID was sent as a want-have
+			// Record that the CID was sent as a want-have, even if the
+			// broadcast already covers it, so that it is not cancelled if
+			// the peer stops being a broadcast peer
 			pws.wantHaves.Add(c)
 
-			// Add the CID to the results
-			fltWantHvs = append(fltWantHvs, c)
-
 			// Update the reverse index
 			pwm.reverseIndexAdd(c, p)
+
+			// If we've already broadcasted this want, don't bother with a
+			// want-have.
+			if pwm.broadcastWants.Has(c) && pwm.isBroadcastPeer(p) {
+				continue
+			}
+
+			// Add the CID to the results
+			fltWantHvs = append(fltWantHvs, c)
 		}
 	}
 
@@ -256,7 +376,11 @@ func (pwm *peerWantManager) sendCancels(cancelKs []cid.Cid) {
 	// Send cancels to a particular peer
 	send := func(p peer.ID, pws *peerWant) {
 		// Start from the broadcast cancels
-		toCancel := broadcastCancels
+		isBroadcastPeer := pwm.isBroadcastPeer(p)
+		var toCancel []cid.Cid
+		if isBroadcastPeer {
+			toCancel = broadcastCancels
+		}
 
 		// For each key to be cancelled
 		for _, c := range cancelKs {
@@ -271,7 +395,7 @@ func (pwm *peerWantManager) sendCancels(cancelKs []cid.Cid) {
 
 			// If it's a broadcast want, we've already added it to
 			// the peer cancels.
-			if !pwm.broadcastWants.Has(c) {
+			if !pwm.broadcastWants.Has(c) || !isBroadcastPeer {
 				toCancel = append(toCancel, c)
 			}
 		}
@@ -374,6 +498,21 @@ func (pwm *peerWantManager) wantPeerCounts(c cid.Cid) wantPeerCnts {
 	return wantPeerCnts{blockCount, haveCount, pwm.broadcastWants.Has(c)}
 }
 
+// notWantedFrom returns the keys for which no want-block, want-have or
+// broadcast want-have is pending with the peer
+func (pwm *peerWantManager) notWantedFrom(p peer.ID, ks []cid.Cid) []cid.Cid {
+	pws, ok := pwm.peerWants[p]
+
+	var notWanted []cid.Cid
+	for _, c := range ks {
+		if ok && (pws.wantBlocks.Has(c) || pws.wantHaves.Has(c) || (pwm.broadcastWants.Has(c) && pwm.isBroadcastPeer(p))) {
+			continue
+		}
+		notWanted = append(notWanted, c)
+	}
+	return notWanted
+}
+
 // Add the peer to the list of peers that have sent a want with the cid
 func (pwm *peerWantManager) reverseIndexAdd(c cid.Cid, p peer.ID) bool {
 	peers, ok := pwm.wantPeers[c]
@@ -49,6 +49,8 @@ func (mpq *mockPQ) AddCancels(cs []cid.Cid) {
 }
 func (mpq *mockPQ) ResponseReceived(ks []cid.Cid) {
 }
+func (mpq *mockPQ) ResendWantlist() {
+}
 
 func clearSent(pqs map[peer.ID]PeerQueue) {
 	for _, pqi := range pqs {
@@ -183,6 +185,161 @@ func TestPWMBroadcastWantHaves(t *testing.T) {
 	}
 }
 
+func TestPWMBroadcastLimit(t *testing.T) {
+	pwm := newPeerWantManager(&gauge{}, &gauge{})
+
+	peers := testutil.GeneratePeers(4)
+	scores := map[peer.ID]float64{peers[0]: 0.1, peers[1]: 0.9, peers[2]: 0.5, peers[3]: 0}
+	pwm.setBroadcastLimit(2, func(p peer.ID) float64 { return scores[p] })
+
+	cids := testutil.GenerateCids(2)
+	cids2 := testutil.GenerateCids(2)
+
+	pqs := make(map[peer.ID]PeerQueue)
+	for _, p := range peers {
+		pqs[p] = &mockPQ{}
+		pwm.addPeer(pqs[p], p)
+	}
+
+	// the broadcast wants only go to the two peers with the best scores
+	pwm.broadcastWantHaves(cids)
+	for i, p := range peers {
+		sent := len(pqs[p].(*mockPQ).bcst)
+		if (i == 1 || i == 2) && sent != 2 || (i == 0 || i == 3) && sent != 0 {
+			t.Fatalf("peer %d: unexpected %d broadcast wants", i, sent)
+		}
+	}
+
+	// a want-have for a broadcast want is still sent to the other peers
+	pwm.sendWants(peers[0], nil, cids[:1])
+	if whs := pqs[peers[0]].(*mockPQ).whs; len(whs) != 1 || whs[0] != cids[0] {
+		t.Fatalf("expected the want-have to be sent, got %v", whs)
+	}
+	if notWanted := pwm.notWantedFrom(peers[3], cids); len(notWanted) != 2 {
+		t.Fatalf("expected the broadcast wants not to be wanted from the last peer, got %v", notWanted)
+	}
+
+	// a disconnected peer is replaced by the remaining peer with the best
+	// score, which is sent the previous broadcast wants
+	clearSent(pqs)
+	pwm.removePeer(peers[2])
+	pwm.broadcastWantHaves(cids2)
+	if bcst := pqs[peers[0]].(*mockPQ).bcst; len(bcst) != 3 {
+		t.Fatalf("expected 3 broadcast wants for the replacing peer, got %v", bcst)
+	}
+	if bcst := pqs[peers[3]].(*mockPQ).bcst; len(bcst) != 0 {
+		t.Fatalf("expected no broadcast want for the last peer, got %v", bcst)
+	}
+
+	// the broadcast cancels only go to the peers that were sent the wants
+	clearSent(pqs)
+	pwm.sendCancels(cids)
+	for _, p := range peers[:2] {
+		if cancels := pqs[p].(*mockPQ).cancels; len(cancels) != 2 {
+			t.Fatalf("expected 2 cancels, got %v", cancels)
+		}
+	}
+	if cancels := pqs[peers[3]].(*mockPQ).cancels; len(cancels) != 0 {
+		t.Fatalf("expected no cancel for the last peer, got %v", cancels)
+	}
+}
+
+func TestPWMBroadcastLimitRanking(t *testing.T) {
+	pwm := newPeerWantManager(&gauge{}, &gauge{})
+
+	peers := testutil.GeneratePeers(3)
+	scores := map[peer.ID]float64{peers[0]: 0.1, peers[1]: 0.5, peers[2]: 0.9}
+	pwm.setBroadcastLimit(2, func(p peer.ID) float64 { return scores[p] })
+
+	cids := testutil.GenerateCids(2)
+	cids2 := testutil.GenerateCids(2)
+
+	pqs := make(map[peer.ID]PeerQueue)
+	for _, p := range peers[:2] {
+		pqs[p] = &mockPQ{}
+		pwm.addPeer(pqs[p], p)
+	}
+	pwm.broadcastWantHaves(cids)
+
+	// a peer connecting with a better score takes the place of the broadcast
+	// peer with the lowest score
+	clearSent(pqs)
+	pqs[peers[2]] = &mockPQ{}
+	pwm.addPeer(pqs[peers[2]], peers[2])
+	if bcst := pqs[peers[2]].(*mockPQ).bcst; len(bcst) != 2 {
+		t.Fatalf("expected 2 broadcast wants for the new peer, got %v", bcst)
+	}
+	if cancels := pqs[peers[0]].(*mockPQ).cancels; len(cancels) != 2 {
+		t.Fatalf("expected 2 cancels for the replaced peer, got %v", cancels)
+	}
+	if !pwm.isBroadcastPeer(peers[1]) || !pwm.isBroadcastPeer(peers[2]) || pwm.isBroadcastPeer(peers[0]) {
+		t.Fatal("expected the two peers with the best scores to be the broadcast peers")
+	}
+
+	// the peers are ranked again at each broadcast, as the scores change
+	clearSent(pqs)
+	scores[peers[0]] = 0.7
+	pwm.broadcastWantHaves(cids2)
+	if bcst := pqs[peers[0]].(*mockPQ).bcst; len(bcst) != 4 {
+		t.Fatalf("expected 4 broadcast wants for the promoted peer, got %v", bcst)
+	}
+	pq1 := pqs[peers[1]].(*mockPQ)
+	if len(pq1.cancels) != 2 || len(pq1.bcst) != 0 {
+		t.Fatalf("expected the demoted peer to get 2 cancels and no broadcast want, got %v and %v", pq1.cancels, pq1.bcst)
+	}
+
+	// on a tie, the current broadcast peers are kept
+	clearSent(pqs)
+	scores[peers[1]] = 0.7
+	pwm.broadcastWantHaves(testutil.GenerateCids(1))
+	if pwm.isBroadcastPeer(peers[1]) || len(pq1.bcst) != 0 {
+		t.Fatal("expected a peer with the same score not to replace a broadcast peer")
+	}
+}
+
+func TestPWMBroadcastLimitDemotionKeepsDirectWants(t *testing.T) {
+	pwm := newPeerWantManager(&gauge{}, &gauge{})
+
+	peers := testutil.GeneratePeers(2)
+	scores := map[peer.ID]float64{peers[0]: 0.9, peers[1]: 0.1}
+	pwm.setBroadcastLimit(1, func(p peer.ID) float64 { return scores[p] })
+
+	pqs := make(map[peer.ID]PeerQueue)
+	for _, p := range peers {
+		pqs[p] = &mockPQ{}
+		pwm.addPeer(pqs[p], p)
+	}
+	cids := testutil.GenerateCids(2)
+	pwm.broadcastWantHaves(cids)
+
+	// a session sends a want-have to the broadcast peer, which the broadcast
+	// already covers
+	clearSent(pqs)
+	pwm.sendWants(peers[0], nil, cids[:1])
+	pq0 := pqs[peers[0]].(*mockPQ)
+	if len(pq0.whs) != 0 {
+		t.Fatalf("expected the want-have covered by the broadcast not to be sent, got %v", pq0.whs)
+	}
+
+	// once demoted, the peer is only sent a cancel for the want it was not
+	// sent directly
+	scores[peers[0]] = 0
+	pwm.broadcastWantHaves(testutil.GenerateCids(1))
+	if pwm.isBroadcastPeer(peers[0]) {
+		t.Fatal("expected the peer to be demoted")
+	}
+	if !testutil.MatchKeysIgnoreOrder(pq0.cancels, cids[1:]) {
+		t.Fatalf("expected a cancel for the broadcast want only, got %v", pq0.cancels)
+	}
+
+	// the want-have sent directly is still cancelled with the want
+	clearSent(pqs)
+	pwm.sendCancels(cids[:1])
+	if !testutil.MatchKeysIgnoreOrder(pq0.cancels, cids[:1]) {
+		t.Fatalf("expected a cancel for the direct want-have, got %v", pq0.cancels)
+	}
+}
+
 func TestPWMSendWants(t *testing.T) {
 	test.Flaky(t)
 
@@ -345,6 +502,38 @@ func TestPWMSendCancels(t *testing.T) {
 	}
 }
 
+func TestPWMNotWantedFrom(t *testing.T) {
+	pwm := newPeerWantManager(&gauge{}, &gauge{})
+
+	peers := testutil.GeneratePeers(3)
+	p0, p1, stranger := peers[0], peers[1], peers[2]
+	for _, p := range peers[:2] {
+		pwm.addPeer(&mockPQ{}, p)
+	}
+
+	cids := testutil.GenerateCids(4)
+	pwm.broadcastWantHaves(cids[:1])
+	pwm.sendWants(p0, cids[1:2], cids[2:3])
+
+	notWanted := pwm.notWantedFrom(p0, cids)
+	if !testutil.MatchKeysIgnoreOrder(notWanted, cids[3:]) {
+		t.Fatal("expected only the key never wanted to be unwanted from p0")
+	}
+	notWanted = pwm.notWantedFrom(p1, cids)
+	if !testutil.MatchKeysIgnoreOrder(notWanted, cids[1:]) {
+		t.Fatal("expected only the broadcast want to be wanted from p1")
+	}
+	if len(pwm.notWantedFrom(stranger, cids)) != len(cids) {
+		t.Fatal("expected no key to be wanted from an unknown peer")
+	}
+
+	pwm.sendCancels(cids[1:2])
+	notWanted = pwm.notWantedFrom(p0, cids)
+	if !testutil.MatchKeysIgnoreOrder(notWanted, append([]cid.Cid{cids[1]}, cids[3:]...)) {
+		t.Fatal("expected the cancelled want to be unwanted")
+	}
+}
+
 func TestStats(t *testing.T) {
 	test.Flaky(t)
 
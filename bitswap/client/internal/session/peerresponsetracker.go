@@ -3,6 +3,7 @@ package session
 import (
 	"math/rand"
 
+	cid "github.com/ipfs/go-cid"
 	peer "github.com/mikelsr/go-libp2p/core/peer"
 )
 
@@ -56,6 +57,12 @@ func (prt *peerResponseTracker) choose(peers []peer.ID) peer.ID {
 	return peers[index]
 }
 
+// Choose implements BlockRequestStrategy with choose, it is the default
+// strategy of the sessions.
+func (prt *peerResponseTracker) Choose(candidates []peer.ID, _ cid.Cid) peer.ID {
+	return prt.choose(candidates)
+}
+
 // getPeerCount returns the number of times the peer was first to send us a
 // block plus one (in order to never get a zero chance).
 func (prt *peerResponseTracker) getPeerCount(p peer.ID) int {
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	// progressSampleInterval is the period over which the received bytes are
+	// summed up to update the moving average of the throughput
+	progressSampleInterval = time.Second
+	// progressRateWeight is the weight of the last sample in the moving
+	// average of the throughput
+	progressRateWeight = 0.3
+)
+
+// progressTracker estimates when the outstanding wants of a session will be
+// complete, see Session.EstimatedCompletion
+type progressTracker struct {
+	clock clock.Clock
+
+	lk sync.Mutex
+	// blocks requested with GetBlocks and neither received nor cancelled
+	outstanding int
+	// all the blocks received by the session
+	receivedBlocks uint64
+	receivedBytes  uint64
+
+	// bytes per second, an exponentially weighted moving average of the
+	// samples, 0 until the first sample
+	rate         float64
+	sampleStart  time.Time
+	sampledBytes uint64
+}
+
+func newProgressTracker(clk clock.Clock) *progressTracker {
+	return &progressTracker{clock: clk}
+}
+
+// wanted records that blocks were requested
+func (pt *progressTracker) wanted(n int) {
+	pt.lk.Lock()
+	defer pt.lk.Unlock()
+
+	pt.sample(pt.clock.Now())
+	pt.outstanding += n
+}
+
+// cancelled records that requests for blocks not received were cancelled
+func (pt *progressTracker) cancelled(n int) {
+	pt.lk.Lock()
+	defer pt.lk.Unlock()
+
+	pt.sample(pt.clock.Now())
+	pt.outstanding -= n
+}
+
+// received records that a requested block was received
+func (pt *progressTracker) received(size int) {
+	pt.lk.Lock()
+	defer pt.lk.Unlock()
+
+	pt.sample(pt.clock.Now())
+	pt.outstanding--
+	pt.receivedBlocks++
+	pt.receivedBytes += uint64(size)
+	pt.sampledBytes += uint64(size)
+}
+
+// sample updates the moving average of the throughput once a sample interval
+// has elapsed. The time during which there was nothing to fetch is not
+// sampled, so that it doesn't lower the throughput. pt.lk must be held.
+func (pt *progressTracker) sample(now time.Time) {
+	if pt.outstanding <= 0 {
+		pt.sampleStart = now
+		pt.sampledBytes = 0
+		return
+	}
+
+	elapsed := now.Sub(pt.sampleStart)
+	if elapsed < progressSampleInterval {
+		return
+	}
+
+	// a sample spanning several intervals, when no block arrived, lowers the
+	// throughput as much as these intervals would have
+	rate := float64(pt.sampledBytes) / elapsed.Seconds()
+	if pt.rate == 0 {
+		pt.rate = rate
+	} else {
+		pt.rate = progressRateWeight*rate + (1-progressRateWeight)*pt.rate
+	}
+	pt.sampleStart = now
+	pt.sampledBytes = 0
+}
+
+// estimate returns the estimated bytes left to receive and the time it will
+// take, see Session.EstimatedCompletion
+func (pt *progressTracker) estimate() (uint64, time.Duration, bool) {
+	pt.lk.Lock()
+	defer pt.lk.Unlock()
+
+	pt.sample(pt.clock.Now())
+	if pt.outstanding <= 0 {
+		return 0, 0, true
+	}
+	if pt.receivedBlocks == 0 || pt.rate == 0 {
+		return 0, 0, false
+	}
+
+	remaining := uint64(pt.outstanding) * (pt.receivedBytes / pt.receivedBlocks)
+	eta := time.Duration(float64(remaining) / pt.rate * float64(time.Second))
+	return remaining, eta, true
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func TestProgressTrackerEstimate(t *testing.T) {
+	clk := clock.NewMock()
+	pt := newProgressTracker(clk)
+
+	if remaining, eta, ok := pt.estimate(); !ok || remaining != 0 || eta != 0 {
+		t.Fatal("expected an idle tracker to be complete")
+	}
+
+	pt.wanted(10)
+	if _, _, ok := pt.estimate(); ok {
+		t.Fatal("expected no estimate before receiving blocks")
+	}
+
+	// 2 blocks of 1000 bytes in the first second
+	clk.Add(500 * time.Millisecond)
+	pt.received(1000)
+	pt.received(1000)
+	if _, _, ok := pt.estimate(); ok {
+		t.Fatal("expected no estimate before the first sample")
+	}
+
+	clk.Add(500 * time.Millisecond)
+	remaining, eta, ok := pt.estimate()
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	// 8 blocks of 1000 bytes at 2000 bytes per second
+	if remaining != 8000 {
+		t.Fatalf("expected 8000 remaining bytes, got %d", remaining)
+	}
+	if eta != 4*time.Second {
+		t.Fatalf("expected 4s, got %s", eta)
+	}
+
+	// the throughput goes down after a slower second
+	clk.Add(500 * time.Millisecond)
+	pt.received(1000)
+	clk.Add(500 * time.Millisecond)
+	remaining, eta, ok = pt.estimate()
+	if !ok || remaining != 7000 {
+		t.Fatalf("expected 7000 remaining bytes, got %d", remaining)
+	}
+	if eta <= 7000*time.Second/2000 {
+		t.Fatalf("expected the eta to account for the slower throughput, got %s", eta)
+	}
+
+	// cancelling the rest of the wants completes the fetch
+	pt.cancelled(7)
+	if remaining, eta, ok := pt.estimate(); !ok || remaining != 0 || eta != 0 {
+		t.Fatal("expected the tracker to be complete")
+	}
+
+	// time spent idle doesn't lower the throughput
+	clk.Add(time.Minute)
+	pt.wanted(1)
+	clk.Add(500 * time.Millisecond)
+	remaining, eta, ok = pt.estimate()
+	if !ok || remaining != 1000 || eta > time.Second {
+		t.Fatalf("expected the idle time to be ignored, got %d bytes in %s", remaining, eta)
+	}
+}
@@ -2,8 +2,10 @@ package session
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	delay "github.com/ipfs/go-ipfs-delay"
@@ -75,6 +77,14 @@ type ProviderFinder interface {
 	FindProvidersAsync(ctx context.Context, k cid.Cid) <-chan peer.ID
 }
 
+// WantPrioritizer records the priority requested for the wants for a CID
+type WantPrioritizer interface {
+	// Set sets the priority of the wants for the CID
+	Set(c cid.Cid, priority int32)
+	// Remove forgets the priorities of the CIDs
+	Remove(ks ...cid.Cid)
+}
+
 // opType is the kind of operation that is being processed by the event loop
 type opType int
 
@@ -108,19 +118,30 @@ type Session struct {
 	sprm           SessionPeerManager
 	providerFinder ProviderFinder
 	sim            *bssim.SessionInterestManager
+	priorities     WantPrioritizer
+
+	// the priorities set with WantWithPriority, and the CIDs requested by
+	// the session whose priority was applied to the wants
+	priorityLk     sync.Mutex
+	wantPriorities map[cid.Cid]int32
+	prioritized    *cid.Set
 
 	sw  sessionWants
 	sws sessionWantSender
 
 	latencyTrkr latencyTracker
+	progress    *progressTracker
 
 	// channels
 	incoming      chan op
 	tickDelayReqs chan time.Duration
 
+	// clock of the idle and periodic search timers
+	clock clock.Clock
+
 	// do not touch outside run loop
-	idleTick            *time.Timer
-	periodicSearchTimer *time.Timer
+	idleTick            *clock.Timer
+	periodicSearchTimer *clock.Timer
 	baseTickDelay       time.Duration
 	consecutiveTicks    int
 	initialSearchDelay  time.Duration
@@ -146,7 +167,10 @@ func New(
 	notif notifications.PubSub,
 	initialSearchDelay time.Duration,
 	periodicSearchDelay delay.D,
-	self peer.ID) *Session {
+	self peer.ID,
+	clk clock.Clock,
+	strategy BlockRequestStrategy,
+	priorities WantPrioritizer) *Session {
 
 	ctx, cancel := context.WithCancel(ctx)
 	s := &Session{
@@ -161,14 +185,22 @@ func New(
 		sim:                 sim,
 		incoming:            make(chan op, 128),
 		latencyTrkr:         latencyTracker{},
+		progress:            newProgressTracker(clk),
 		notif:               notif,
 		baseTickDelay:       time.Millisecond * 500,
 		id:                  id,
 		initialSearchDelay:  initialSearchDelay,
 		periodicSearchDelay: periodicSearchDelay,
 		self:                self,
+		clock:               clk,
+		priorities:          priorities,
+		wantPriorities:      make(map[cid.Cid]int32),
+		prioritized:         cid.NewSet(),
 	}
 	s.sws = newSessionWantSender(id, pm, sprm, sm, bpm, s.onWantsSent, s.onPeersExhausted)
+	if strategy != nil {
+		s.sws.strategy = strategy
+	}
 
 	go s.run(ctx)
 
@@ -241,6 +273,14 @@ func (s *Session) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.
 
 	return bsgetter.AsyncGetBlocks(ctx, s.ctx, keys, s.notif,
 		func(ctx context.Context, keys []cid.Cid) {
+			// a block is received, or cancelled, once however many times its
+			// CID is requested
+			unique := cid.NewSet()
+			for _, k := range keys {
+				unique.Add(k)
+			}
+			s.progress.wanted(unique.Len())
+			s.applyPriorities(keys)
 			select {
 			case s.incoming <- op{op: opWant, keys: keys}:
 			case <-ctx.Done():
@@ -248,14 +288,89 @@ func (s *Session) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.
 			}
 		},
 		func(keys []cid.Cid) {
+			s.progress.cancelled(len(keys))
 			select {
 			case s.incoming <- op{op: opCancel, keys: keys}:
 			case <-s.ctx.Done():
 			}
 		},
+		func(blk blocks.Block) {
+			s.progress.received(len(blk.RawData()))
+		},
 	)
 }
 
+// EstimatedCompletion estimates how much data is left to receive for the
+// blocks requested with GetBlock and GetBlocks, and how long it will take.
+// It returns false when it can't tell yet, before the first block is
+// received and during the first second of the fetch.
+//
+// The remaining bytes are the number of blocks requested and not received
+// yet times the average size of the blocks received so far: the size of a
+// block isn't known before it is received, so the estimate is only as good
+// as the blocks are of similar sizes, and doesn't account for the blocks that
+// the requests will discover, e.g. the children of a DAG being traversed.
+// The time is the remaining bytes divided by an exponentially weighted moving
+// average of the throughput of the session, sampled every second while there
+// are outstanding requests, so it lags behind sudden changes of speed.
+func (s *Session) EstimatedCompletion() (remainingBytes uint64, eta time.Duration, ok bool) {
+	return s.progress.estimate()
+}
+
+// WantWithPriority sets the priority with which the wants of the session
+// for the CID are sent to peers. It must be called before requesting the
+// block with GetBlock or GetBlocks to have an effect. Wants are otherwise
+// sent with decreasing priorities starting at math.MaxInt32, in the order
+// they are requested, so a priority of math.MaxInt32 moves a want to the
+// front.
+//
+// The priority is only applied once the session requests the CID, and is
+// forgotten when the session shuts down. Note that the wants of all the
+// sessions for a CID are sent as one want to a peer, so another session
+// requesting the same CID at the same time gets the same priority.
+//
+// The priority is only a hint: whether it is honored depends on the peer.
+func (s *Session) WantWithPriority(c cid.Cid, priority int32) {
+	s.priorityLk.Lock()
+	defer s.priorityLk.Unlock()
+	s.wantPriorities[c] = priority
+}
+
+// applyPriorities sets the priorities set with WantWithPriority for the
+// requested CIDs
+func (s *Session) applyPriorities(keys []cid.Cid) {
+	if s.priorities == nil {
+		return
+	}
+
+	s.priorityLk.Lock()
+	defer s.priorityLk.Unlock()
+	if len(s.wantPriorities) == 0 {
+		return
+	}
+	for _, c := range keys {
+		if priority, ok := s.wantPriorities[c]; ok {
+			s.priorities.Set(c, priority)
+			s.prioritized.Add(c)
+		}
+	}
+}
+
+// forgetPriorities removes the priorities applied by the session
+func (s *Session) forgetPriorities() {
+	if s.priorities == nil {
+		return
+	}
+
+	s.priorityLk.Lock()
+	defer s.priorityLk.Unlock()
+	if s.prioritized.Len() > 0 {
+		s.priorities.Remove(s.prioritized.Keys()...)
+	}
+	s.prioritized = cid.NewSet()
+	s.wantPriorities = make(map[cid.Cid]int32)
+}
+
 // SetBaseTickDelay changes the rate at which ticks happen.
 func (s *Session) SetBaseTickDelay(baseTickDelay time.Duration) {
 	select {
@@ -297,8 +412,8 @@ func (s *Session) nonBlockingEnqueue(o op) {
 func (s *Session) run(ctx context.Context) {
 	go s.sws.Run()
 
-	s.idleTick = time.NewTimer(s.initialSearchDelay)
-	s.periodicSearchTimer = time.NewTimer(s.periodicSearchDelay.NextWaitTime())
+	s.idleTick = s.clock.Timer(s.initialSearchDelay)
+	s.periodicSearchTimer = s.clock.Timer(s.periodicSearchDelay.NextWaitTime())
 	for {
 		select {
 		case oper := <-s.incoming:
@@ -407,6 +522,8 @@ func (s *Session) handleShutdown() {
 	// Shut down the sessionWantSender (blocks until sessionWantSender stops
 	// sending)
 	s.sws.Shutdown()
+	// Forget the priorities of the wants of the session
+	s.forgetPriorities()
 	// Signal to the SessionManager that the session has been shutdown
 	// and can be cleaned up
 	s.sm.RemoveSession(s.id)
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	cid "github.com/ipfs/go-cid"
 	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
 	delay "github.com/ipfs/go-ipfs-delay"
@@ -161,7 +162,7 @@ func TestSessionGetBlocks(t *testing.T) {
 	defer notif.Shutdown()
 	id := testutil.GenerateSessionID()
 	sm := newMockSessionMgr()
-	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 	blockGenerator := blocksutil.NewBlockGenerator()
 	blks := blockGenerator.Blocks(broadcastLiveWantsLimit * 2)
 	var cids []cid.Cid
@@ -245,6 +246,139 @@ func TestSessionGetBlocks(t *testing.T) {
 	}
 }
 
+func TestSessionProgressDuplicateKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fpm := newFakePeerManager()
+	fspm := newFakeSessionPeerManager()
+	fpf := newFakeProviderFinder()
+	sim := bssim.New()
+	bpm := bsbpm.New()
+	notif := notifications.New()
+	defer notif.Shutdown()
+	id := testutil.GenerateSessionID()
+	sm := newMockSessionMgr()
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
+	blockGenerator := blocksutil.NewBlockGenerator()
+	blks := blockGenerator.Blocks(2)
+
+	outstanding := func() int {
+		session.progress.lk.Lock()
+		defer session.progress.lk.Unlock()
+		return session.progress.outstanding
+	}
+
+	getCtx, getCancel := context.WithCancel(ctx)
+	out, err := session.GetBlocks(getCtx, []cid.Cid{blks[0].Cid(), blks[0].Cid(), blks[1].Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-fpm.wantReqs
+	if n := outstanding(); n != 2 {
+		t.Fatalf("expected 2 outstanding blocks, got %d", n)
+	}
+
+	// cancelling the request cancels each block once, after the channel
+	// is closed
+	getCancel()
+	for range out {
+	}
+	for start := time.Now(); outstanding() != 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("expected no outstanding block after the cancel, got %d", outstanding())
+		}
+	}
+}
+
+type fakePrioritizer struct {
+	lk         sync.Mutex
+	priorities map[cid.Cid]int32
+}
+
+func newFakePrioritizer() *fakePrioritizer {
+	return &fakePrioritizer{priorities: make(map[cid.Cid]int32)}
+}
+
+func (fp *fakePrioritizer) Set(c cid.Cid, priority int32) {
+	fp.lk.Lock()
+	defer fp.lk.Unlock()
+	fp.priorities[c] = priority
+}
+
+func (fp *fakePrioritizer) Remove(ks ...cid.Cid) {
+	fp.lk.Lock()
+	defer fp.lk.Unlock()
+	for _, c := range ks {
+		delete(fp.priorities, c)
+	}
+}
+
+func (fp *fakePrioritizer) get(c cid.Cid) (int32, bool) {
+	fp.lk.Lock()
+	defer fp.lk.Unlock()
+	priority, ok := fp.priorities[c]
+	return priority, ok
+}
+
+func TestSessionWantWithPriority(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	fpm := newFakePeerManager()
+	fpf := newFakeProviderFinder()
+	sim := bssim.New()
+	bpm := bsbpm.New()
+	notif := notifications.New()
+	defer notif.Shutdown()
+	fp := newFakePrioritizer()
+	newSession := func(ctx context.Context) *Session {
+		return New(ctx, newMockSessionMgr(), testutil.GenerateSessionID(), newFakeSessionPeerManager(), fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, fp)
+	}
+	blockGenerator := blocksutil.NewBlockGenerator()
+	blks := blockGenerator.Blocks(3)
+
+	sessCtx, sessCancel := context.WithCancel(ctx)
+	defer sessCancel()
+	session := newSession(sessCtx)
+	session.WantWithPriority(blks[0].Cid(), 5)
+	session.WantWithPriority(blks[2].Cid(), 3)
+
+	// only the priorities of the requested CIDs are applied
+	if _, err := session.GetBlocks(ctx, []cid.Cid{blks[0].Cid(), blks[1].Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	<-fpm.wantReqs
+	if priority, ok := fp.get(blks[0].Cid()); !ok || priority != 5 {
+		t.Fatalf("expected the priority of the requested CID to be 5, got %d", priority)
+	}
+	if _, ok := fp.get(blks[1].Cid()); ok {
+		t.Fatal("expected no priority for a CID without one")
+	}
+	if _, ok := fp.get(blks[2].Cid()); ok {
+		t.Fatal("expected no priority for a CID that was not requested")
+	}
+
+	// the priorities of a session don't apply to the wants of another one
+	other := newSession(ctx)
+	if _, err := other.GetBlocks(ctx, []cid.Cid{blks[2].Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	<-fpm.wantReqs
+	if _, ok := fp.get(blks[2].Cid()); ok {
+		t.Fatal("expected the priority of a session not to apply to another session")
+	}
+
+	// the priorities are forgotten when the session shuts down
+	sessCancel()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, ok := fp.get(blks[0].Cid()); !ok {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("expected the priority to be forgotten when the session shuts down")
+		}
+	}
+}
+
 func TestSessionFindMorePeers(t *testing.T) {
 	test.Flaky(t)
 
@@ -259,7 +393,7 @@ func TestSessionFindMorePeers(t *testing.T) {
 	defer notif.Shutdown()
 	id := testutil.GenerateSessionID()
 	sm := newMockSessionMgr()
-	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 	session.SetBaseTickDelay(200 * time.Microsecond)
 	blockGenerator := blocksutil.NewBlockGenerator()
 	blks := blockGenerator.Blocks(broadcastLiveWantsLimit * 2)
@@ -336,7 +470,7 @@ func TestSessionOnPeersExhausted(t *testing.T) {
 	defer notif.Shutdown()
 	id := testutil.GenerateSessionID()
 	sm := newMockSessionMgr()
-	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 	blockGenerator := blocksutil.NewBlockGenerator()
 	blks := blockGenerator.Blocks(broadcastLiveWantsLimit + 5)
 	var cids []cid.Cid
@@ -383,7 +517,7 @@ func TestSessionFailingToGetFirstBlock(t *testing.T) {
 	defer notif.Shutdown()
 	id := testutil.GenerateSessionID()
 	sm := newMockSessionMgr()
-	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, 10*time.Millisecond, delay.Fixed(100*time.Millisecond), "")
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, 10*time.Millisecond, delay.Fixed(100*time.Millisecond), "", clock.New(), nil, nil)
 	blockGenerator := blocksutil.NewBlockGenerator()
 	blks := blockGenerator.Blocks(4)
 	var cids []cid.Cid
@@ -486,6 +620,83 @@ func TestSessionFailingToGetFirstBlock(t *testing.T) {
 	}
 }
 
+func TestSessionIdleBroadcastMockClock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fpm := newFakePeerManager()
+	fspm := newFakeSessionPeerManager()
+	fpf := newFakeProviderFinder()
+	sim := bssim.New()
+	bpm := bsbpm.New()
+	notif := notifications.New()
+	defer notif.Shutdown()
+	id := testutil.GenerateSessionID()
+	sm := newMockSessionMgr()
+	clk := clock.NewMock()
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Minute, delay.Fixed(time.Hour), "", clk, nil, nil)
+	blockGenerator := blocksutil.NewBlockGenerator()
+	blks := blockGenerator.Blocks(4)
+	var cids []cid.Cid
+	for _, block := range blks {
+		cids = append(cids, block.Cid())
+	}
+
+	_, err := session.GetBlocks(ctx, cids)
+	if err != nil {
+		t.Fatal("error getting blocks")
+	}
+
+	// The session should initially broadcast want-haves
+	select {
+	case <-fpm.wantReqs:
+	case <-ctx.Done():
+		t.Fatal("Did not make first want request")
+	}
+
+	// idle returns once the run loop of the session is waiting for events,
+	// so that the idle tick has been reset after a broadcast
+	idle := func() { session.SetBaseTickDelay(0) }
+	expectNoBroadcast := func() {
+		t.Helper()
+		idle()
+		select {
+		case <-fpm.wantReqs:
+			t.Fatalf("rebroadcast before the idle tick, at %s", clk.Now())
+		default:
+		}
+	}
+	expectBroadcast := func() {
+		t.Helper()
+		select {
+		case receivedWantReq := <-fpm.wantReqs:
+			if len(receivedWantReq.cids) < len(cids) {
+				t.Fatal("did not rebroadcast whole live list")
+			}
+		case <-ctx.Done():
+			t.Fatalf("Never rebroadcast want list at %s", clk.Now())
+		}
+	}
+
+	// The live wants are rebroadcast once the initial search delay has
+	// passed on the mock clock
+	idle()
+	clk.Add(time.Minute - time.Second)
+	expectNoBroadcast()
+	clk.Add(time.Second)
+	expectBroadcast()
+
+	// The next idle tick comes after the same delay, then it backs off as
+	// the ticks are consecutive
+	idle()
+	clk.Add(time.Minute)
+	expectBroadcast()
+	idle()
+	clk.Add(time.Minute)
+	expectNoBroadcast()
+	clk.Add(time.Minute)
+	expectBroadcast()
+}
+
 func TestSessionCtxCancelClosesGetBlocksChannel(t *testing.T) {
 	test.Flaky(t)
 
@@ -501,7 +712,7 @@ func TestSessionCtxCancelClosesGetBlocksChannel(t *testing.T) {
 
 	// Create a new session with its own context
 	sessctx, sesscancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	session := New(sessctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(sessctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 
 	timerCtx, timerCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 	defer timerCancel()
@@ -554,7 +765,7 @@ func TestSessionOnShutdownCalled(t *testing.T) {
 	// Create a new session with its own context
 	sessctx, sesscancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer sesscancel()
-	session := New(sessctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(sessctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 
 	// Shutdown the session
 	session.Shutdown()
@@ -581,7 +792,7 @@ func TestSessionReceiveMessageAfterCtxCancel(t *testing.T) {
 	defer notif.Shutdown()
 	id := testutil.GenerateSessionID()
 	sm := newMockSessionMgr()
-	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "")
+	session := New(ctx, sm, id, fspm, fpf, sim, fpm, bpm, notif, time.Second, delay.Fixed(time.Minute), "", clock.New(), nil, nil)
 	blockGenerator := blocksutil.NewBlockGenerator()
 	blks := blockGenerator.Blocks(2)
 	cids := []cid.Cid{blks[0].Cid(), blks[1].Cid()}
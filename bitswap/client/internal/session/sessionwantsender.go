@@ -67,6 +67,19 @@ type change struct {
 	availability peerAvailability
 }
 
+// BlockRequestStrategy chooses the peer to send a want-block to when several
+// peers of a session may have the block, i.e. they all sent a HAVE for it or
+// none of them answered yet.
+//
+// A strategy set on the client is shared by all its sessions, so it must be
+// safe for concurrent use.
+type BlockRequestStrategy interface {
+	// Choose returns the peer to request the block with the given CID from,
+	// it must be one of the candidates. It is called concurrently from the
+	// goroutines of the sessions, and should not block.
+	Choose(candidates []peer.ID, c cid.Cid) peer.ID
+}
+
 type onSendFn func(to peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid)
 type onPeersExhaustedFn func([]cid.Cid)
 
@@ -96,6 +109,8 @@ type sessionWantSender struct {
 	swbt *sentWantBlocksTracker
 	// Tracks the number of blocks each peer sent us
 	peerRspTrkr *peerResponseTracker
+	// Chooses the peer to send a want-block to, defaults to peerRspTrkr
+	strategy BlockRequestStrategy
 	// Sends wants to peers
 	pm PeerManager
 	// Keeps track of peers in the session
@@ -132,6 +147,7 @@ func newSessionWantSender(sid uint64, pm PeerManager, spm SessionPeerManager, ca
 		onSend:           onSend,
 		onPeersExhausted: onPeersExhausted,
 	}
+	sws.strategy = sws.peerRspTrkr
 
 	return sws
 }
@@ -347,7 +363,7 @@ func (sws *sessionWantSender) trackWant(c cid.Cid) {
 	}
 
 	// Create the want info
-	wi := newWantInfo(sws.peerRspTrkr)
+	wi := newWantInfo(sws.strategy, c)
 	sws.wants[c] = wi
 
 	// For each available peer, register any information we know about
@@ -686,18 +702,19 @@ type wantInfo struct {
 	sentTo peer.ID
 	// The "best" peer to send the want to next
 	bestPeer peer.ID
-	// Keeps track of how many hits / misses each peer has sent us for wants
-	// in the session
-	peerRspTrkr *peerResponseTracker
+	// The CID of the want
+	c cid.Cid
+	// Chooses the best peer among the peers with the same block presence
+	strategy BlockRequestStrategy
 	// true if all known peers have sent a DONT_HAVE for this want
 	exhausted bool
 }
 
-// func newWantInfo(prt *peerResponseTracker, c cid.Cid, startIndex int) *wantInfo {
-func newWantInfo(prt *peerResponseTracker) *wantInfo {
+func newWantInfo(strategy BlockRequestStrategy, c cid.Cid) *wantInfo {
 	return &wantInfo{
 		blockPresence: make(map[peer.ID]BlockPresence),
-		peerRspTrkr:   prt,
+		c:             c,
+		strategy:      strategy,
 		exhausted:     false,
 	}
 }
@@ -763,5 +780,10 @@ func (wi *wantInfo) calculateBestPeer() {
 			peersWithBest = append(peersWithBest, p)
 		}
 	}
-	wi.bestPeer = wi.peerRspTrkr.choose(peersWithBest)
+	wi.bestPeer = wi.strategy.Choose(peersWithBest, wi.c)
+
+	// Fall back to the first candidate if the strategy returned another peer
+	if bp, ok := wi.blockPresence[wi.bestPeer]; !ok || bp != bestBP {
+		wi.bestPeer = peersWithBest[0]
+	}
 }
@@ -3,11 +3,13 @@ package session
 import (
 	"testing"
 
+	cid "github.com/ipfs/go-cid"
 	"github.com/mikelsr/boxo/bitswap/internal/testutil"
+	peer "github.com/mikelsr/go-libp2p/core/peer"
 )
 
 func TestEmptyWantInfo(t *testing.T) {
-	wp := newWantInfo(newPeerResponseTracker())
+	wp := newWantInfo(newPeerResponseTracker(), cid.Undef)
 
 	if wp.bestPeer != "" {
 		t.Fatal("expected no best peer")
@@ -16,7 +18,7 @@ func TestEmptyWantInfo(t *testing.T) {
 
 func TestSetPeerBlockPresence(t *testing.T) {
 	peers := testutil.GeneratePeers(2)
-	wp := newWantInfo(newPeerResponseTracker())
+	wp := newWantInfo(newPeerResponseTracker(), cid.Undef)
 
 	wp.setPeerBlockPresence(peers[0], BPUnknown)
 	if wp.bestPeer != peers[0] {
@@ -36,7 +38,7 @@ func TestSetPeerBlockPresence(t *testing.T) {
 
 func TestSetPeerBlockPresenceBestLower(t *testing.T) {
 	peers := testutil.GeneratePeers(2)
-	wp := newWantInfo(newPeerResponseTracker())
+	wp := newWantInfo(newPeerResponseTracker(), cid.Undef)
 
 	wp.setPeerBlockPresence(peers[0], BPHave)
 	if wp.bestPeer != peers[0] {
@@ -56,7 +58,7 @@ func TestSetPeerBlockPresenceBestLower(t *testing.T) {
 
 func TestRemoveThenSetDontHave(t *testing.T) {
 	peers := testutil.GeneratePeers(2)
-	wp := newWantInfo(newPeerResponseTracker())
+	wp := newWantInfo(newPeerResponseTracker(), cid.Undef)
 
 	wp.setPeerBlockPresence(peers[0], BPUnknown)
 	if wp.bestPeer != peers[0] {
@@ -78,3 +80,43 @@ func TestRemoveThenSetDontHave(t *testing.T) {
 		t.Fatal("wrong best peer")
 	}
 }
+
+type fixedStrategy struct {
+	choice     peer.ID
+	candidates []peer.ID
+	c          cid.Cid
+}
+
+func (fs *fixedStrategy) Choose(candidates []peer.ID, c cid.Cid) peer.ID {
+	fs.candidates = candidates
+	fs.c = c
+	return fs.choice
+}
+
+func TestWantInfoStrategy(t *testing.T) {
+	peers := testutil.GeneratePeers(3)
+	c := testutil.GenerateCids(1)[0]
+	strategy := &fixedStrategy{choice: peers[1]}
+	wp := newWantInfo(strategy, c)
+
+	wp.setPeerBlockPresence(peers[0], BPHave)
+	wp.setPeerBlockPresence(peers[1], BPHave)
+	wp.setPeerBlockPresence(peers[2], BPUnknown)
+	if wp.bestPeer != peers[1] {
+		t.Fatal("expected the peer chosen by the strategy")
+	}
+	if len(strategy.candidates) != 2 || !strategy.c.Equals(c) {
+		t.Fatalf("expected the strategy to choose among the 2 peers with the block, got %v", strategy.candidates)
+	}
+
+	// a peer that isn't a candidate is ignored
+	strategy.choice = peers[2]
+	wp.setPeerBlockPresence(peers[1], BPDontHave)
+	if wp.bestPeer != peers[0] {
+		t.Fatal("expected the only peer with the block")
+	}
+	wp.setPeerBlockPresence(peers[1], BPHave)
+	if wp.bestPeer != peers[0] && wp.bestPeer != peers[1] {
+		t.Fatal("expected a peer with the block")
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	bssession "github.com/mikelsr/boxo/bitswap/client/internal/session"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// negativeCache remembers the CIDs for which a provider search completed
+// without finding any provider, for a limited time. The expired entries are
+// pruned at most once per ttl, when entries are added, so the cache holds at
+// most the entries added during the last two ttl periods.
+type negativeCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	lk        sync.Mutex
+	entries   map[cid.Cid]time.Time
+	nextPrune time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[cid.Cid]time.Time),
+	}
+}
+
+// has returns true if a search for the CID found nothing less than ttl ago
+func (nc *negativeCache) has(c cid.Cid) bool {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	expiry, ok := nc.entries[c]
+	if !ok {
+		return false
+	}
+	if !nc.now().Before(expiry) {
+		delete(nc.entries, c)
+		return false
+	}
+	return true
+}
+
+// filter returns the CIDs of ks that are not in the cache
+func (nc *negativeCache) filter(ks []cid.Cid) []cid.Cid {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	if len(nc.entries) == 0 {
+		return ks
+	}
+	now := nc.now()
+	filtered := make([]cid.Cid, 0, len(ks))
+	for _, c := range ks {
+		if expiry, ok := nc.entries[c]; ok && now.Before(expiry) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+func (nc *negativeCache) add(c cid.Cid) {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	now := nc.now()
+	if !now.Before(nc.nextPrune) {
+		nc.prune(now)
+		nc.nextPrune = now.Add(nc.ttl)
+	}
+	nc.entries[c] = now.Add(nc.ttl)
+}
+
+// prune drops the expired entries, the lock must be held
+func (nc *negativeCache) prune(now time.Time) {
+	for c, expiry := range nc.entries {
+		if !now.Before(expiry) {
+			delete(nc.entries, c)
+		}
+	}
+}
+
+// remove invalidates the entries of CIDs that were found after all
+func (nc *negativeCache) remove(ks ...cid.Cid) {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	if len(nc.entries) == 0 {
+		return
+	}
+	for _, c := range ks {
+		delete(nc.entries, c)
+	}
+}
+
+// negativeCachingFinder is a ProviderFinder that skips the searches for
+// CIDs in the negative cache, and adds to it the CIDs of the searches that
+// complete without finding a provider
+type negativeCachingFinder struct {
+	finder bssession.ProviderFinder
+	cache  *negativeCache
+}
+
+func (ncf *negativeCachingFinder) FindProvidersAsync(ctx context.Context, k cid.Cid) <-chan peer.ID {
+	out := make(chan peer.ID)
+	if ncf.cache.has(k) {
+		log.Debugf("skipping provider search, cid is in the negative cache; cid=%s", k)
+		close(out)
+		return out
+	}
+
+	provs := ncf.finder.FindProvidersAsync(ctx, k)
+	go func() {
+		defer close(out)
+
+		found := false
+		for p := range provs {
+			found = true
+			select {
+			case out <- p:
+			case <-ctx.Done():
+			}
+		}
+		// A cancelled search says nothing about the providers of the CID
+		if !found && ctx.Err() == nil {
+			ncf.cache.add(k)
+		}
+	}()
+	return out
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+type countingFinder struct {
+	searches  int32
+	providers []peer.ID
+}
+
+func (cf *countingFinder) FindProvidersAsync(ctx context.Context, k cid.Cid) <-chan peer.ID {
+	atomic.AddInt32(&cf.searches, 1)
+	out := make(chan peer.ID, len(cf.providers))
+	for _, p := range cf.providers {
+		out <- p
+	}
+	close(out)
+	return out
+}
+
+func drain(ch <-chan peer.ID) int {
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestNegativeCachingFinder(t *testing.T) {
+	ctx := context.Background()
+	c := blocks.NewBlock([]byte("negative")).Cid()
+
+	now := time.Now()
+	cache := newNegativeCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	finder := &countingFinder{}
+	ncf := &negativeCachingFinder{finder: finder, cache: cache}
+
+	drain(ncf.FindProvidersAsync(ctx, c))
+	if !cache.has(c) {
+		t.Fatal("expected a search without providers to be cached")
+	}
+
+	// within the ttl the search is skipped
+	drain(ncf.FindProvidersAsync(ctx, c))
+	if n := atomic.LoadInt32(&finder.searches); n != 1 {
+		t.Fatalf("expected 1 provider search, got %d", n)
+	}
+
+	// once expired the search happens again
+	now = now.Add(time.Minute)
+	drain(ncf.FindProvidersAsync(ctx, c))
+	if n := atomic.LoadInt32(&finder.searches); n != 2 {
+		t.Fatalf("expected 2 provider searches, got %d", n)
+	}
+
+	// the cid is found by a peer, invalidating the entry
+	cache.remove(c)
+	finder.providers = []peer.ID{"QmProvider"}
+	if n := drain(ncf.FindProvidersAsync(ctx, c)); n != 1 {
+		t.Fatalf("expected 1 provider, got %d", n)
+	}
+	if cache.has(c) {
+		t.Fatal("expected a successful search not to be cached")
+	}
+	if n := atomic.LoadInt32(&finder.searches); n != 3 {
+		t.Fatalf("expected 3 provider searches, got %d", n)
+	}
+}
+
+func TestNegativeCachingFinderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := blocks.NewBlock([]byte("negative")).Cid()
+
+	cache := newNegativeCache(time.Minute)
+	ncf := &negativeCachingFinder{finder: &countingFinder{}, cache: cache}
+	drain(ncf.FindProvidersAsync(ctx, c))
+	if cache.has(c) {
+		t.Fatal("expected a cancelled search not to be cached")
+	}
+}
+
+func TestNegativeCacheFilterAndPrune(t *testing.T) {
+	c1 := blocks.NewBlock([]byte("negative 1")).Cid()
+	c2 := blocks.NewBlock([]byte("negative 2")).Cid()
+	c3 := blocks.NewBlock([]byte("negative 3")).Cid()
+
+	now := time.Now()
+	cache := newNegativeCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.add(c1)
+	ks := []cid.Cid{c1, c2}
+	if filtered := cache.filter(ks); len(filtered) != 1 || filtered[0] != c2 {
+		t.Fatalf("expected only %s, got %v", c2, filtered)
+	}
+	if ks[0] != c1 || ks[1] != c2 {
+		t.Fatal("filter must not modify its argument")
+	}
+
+	// c1 expires and is never looked up again, adding c3 a ttl later prunes it
+	now = now.Add(time.Minute)
+	cache.add(c3)
+	cache.lk.Lock()
+	_, ok := cache.entries[c1]
+	n := len(cache.entries)
+	cache.lk.Unlock()
+	if ok || n != 1 {
+		t.Fatalf("expected the expired entry to be pruned, %d entries left", n)
+	}
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+)
+
+// receiveBufferHoldTimeout is how long a received block is counted in the
+// receive buffer at most, so that the blocks that are never stored and
+// announced with NotifyNewBlocks don't stall the client forever
+const receiveBufferHoldTimeout = time.Minute
+
+// receiveBuffer bounds the total size of the blocks received from peers that
+// are not stored yet, see WithReceiveBufferBytes
+type receiveBuffer struct {
+	max   int
+	clock clock.Clock
+
+	lk      sync.Mutex
+	used    int
+	pending map[cid.Cid]pendingBlock
+	// closed and replaced when space is freed
+	freed chan struct{}
+}
+
+type pendingBlock struct {
+	size   int
+	expiry time.Time
+}
+
+func newReceiveBuffer(max int, clk clock.Clock) *receiveBuffer {
+	return &receiveBuffer{
+		max:     max,
+		clock:   clk,
+		pending: make(map[cid.Cid]pendingBlock),
+		freed:   make(chan struct{}),
+	}
+}
+
+// reserve waits until there is room for the blocks in the buffer and adds
+// them, unless they are already in it. It returns the CIDs of the blocks it
+// added. A message larger than the buffer is let through once the buffer is
+// empty.
+func (rb *receiveBuffer) reserve(ctx context.Context, blks []blocks.Block) (*cid.Set, error) {
+	added := cid.NewSet()
+	size := 0
+	for _, b := range blks {
+		if added.Visit(b.Cid()) {
+			size += len(b.RawData())
+		}
+	}
+
+	for {
+		rb.lk.Lock()
+		now := rb.clock.Now()
+		rb.expire(now)
+		for _, c := range added.Keys() {
+			if p, ok := rb.pending[c]; ok {
+				added.Remove(c)
+				size -= p.size
+			}
+		}
+		if rb.used == 0 || rb.used+size <= rb.max {
+			expiry := now.Add(receiveBufferHoldTimeout)
+			for _, b := range blks {
+				if added.Has(b.Cid()) {
+					rb.pending[b.Cid()] = pendingBlock{size: len(b.RawData()), expiry: expiry}
+				}
+			}
+			rb.used += size
+			rb.lk.Unlock()
+			return added, nil
+		}
+
+		freed := rb.freed
+		wait := rb.nextExpiry().Sub(now)
+		log.Debugw("receive buffer full, waiting for blocks to be stored", "used", rb.used, "max", rb.max, "size", size)
+		rb.lk.Unlock()
+
+		timer := rb.clock.Timer(wait)
+		select {
+		case <-freed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// release removes the blocks with the given CIDs from the buffer
+func (rb *receiveBuffer) release(ks ...cid.Cid) {
+	rb.lk.Lock()
+	defer rb.lk.Unlock()
+
+	released := false
+	for _, c := range ks {
+		if p, ok := rb.pending[c]; ok {
+			delete(rb.pending, c)
+			rb.used -= p.size
+			released = true
+		}
+	}
+	if released {
+		rb.signalFreed()
+	}
+}
+
+// expire removes the blocks held for longer than receiveBufferHoldTimeout,
+// rb.lk must be held
+func (rb *receiveBuffer) expire(now time.Time) {
+	expired := false
+	for c, p := range rb.pending {
+		if !now.Before(p.expiry) {
+			log.Debugw("receive buffer: block not stored in time", "cid", c)
+			delete(rb.pending, c)
+			rb.used -= p.size
+			expired = true
+		}
+	}
+	if expired {
+		rb.signalFreed()
+	}
+}
+
+// nextExpiry returns the expiry of the oldest block of the buffer, rb.lk must
+// be held and the buffer must not be empty
+func (rb *receiveBuffer) nextExpiry() time.Time {
+	var next time.Time
+	for _, p := range rb.pending {
+		if next.IsZero() || p.expiry.Before(next) {
+			next = p.expiry
+		}
+	}
+	return next
+}
+
+func (rb *receiveBuffer) signalFreed() {
+	close(rb.freed)
+	rb.freed = make(chan struct{})
+}
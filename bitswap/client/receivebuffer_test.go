@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	blocks "github.com/ipfs/go-block-format"
+)
+
+func TestReceiveBuffer(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	rb := newReceiveBuffer(10, clk)
+
+	b1 := blocks.NewBlock([]byte("012345"))
+	b2 := blocks.NewBlock([]byte("6789"))
+	b3 := blocks.NewBlock([]byte("abcdef"))
+
+	added, err := rb.reserve(ctx, []blocks.Block{b1, b2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Len() != 2 || rb.used != 10 {
+		t.Fatalf("expected 2 blocks and 10 bytes in the buffer, got %d and %d", added.Len(), rb.used)
+	}
+
+	// a block already in the buffer takes no room
+	added, err = rb.reserve(ctx, []blocks.Block{b1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Len() != 0 || rb.used != 10 {
+		t.Fatalf("expected no block to be added, got %d and %d bytes", added.Len(), rb.used)
+	}
+
+	reserved := make(chan error, 1)
+	go func() {
+		_, err := rb.reserve(ctx, []blocks.Block{b3})
+		reserved <- err
+	}()
+	select {
+	case <-reserved:
+		t.Fatal("expected the reservation to wait for room in the buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 4 bytes are not enough room
+	rb.release(b2.Cid())
+	select {
+	case <-reserved:
+		t.Fatal("expected the reservation to wait for more room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rb.release(b1.Cid())
+	select {
+	case err := <-reserved:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the reservation to go through once the blocks are released")
+	}
+
+	// a message larger than the buffer goes through once it is empty, and
+	// the blocks never released expire
+	big := blocks.NewBlock([]byte("larger than the buffer"))
+	go func() {
+		_, err := rb.reserve(ctx, []blocks.Block{big})
+		reserved <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	clk.Add(receiveBufferHoldTimeout)
+	select {
+	case err := <-reserved:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the held blocks to expire")
+	}
+	if rb.used != len(big.RawData()) {
+		t.Fatalf("expected only the large block in the buffer, got %d bytes", rb.used)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := rb.reserve(cctx, []blocks.Block{b1}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
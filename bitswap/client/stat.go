@@ -12,6 +12,9 @@ type Stat struct {
 	DupBlksReceived  uint64
 	DupDataReceived  uint64
 	MessagesReceived uint64
+	// OversizedBlocksDropped is the number of blocks dropped because they
+	// were larger than the limit set with WithMaxIncomingBlockSize
+	OversizedBlocksDropped uint64
 }
 
 // Stat returns aggregated statistics about bitswap operations
@@ -23,6 +26,7 @@ func (bs *Client) Stat() (st Stat, err error) {
 	st.DupDataReceived = c.dupDataRecvd
 	st.DataReceived = c.dataRecvd
 	st.MessagesReceived = c.messagesRecvd
+	st.OversizedBlocksDropped = c.oversizedBlocksDropped
 	bs.counterLk.Unlock()
 	st.Wantlist = bs.GetWantlist()
 
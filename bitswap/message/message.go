@@ -28,6 +28,9 @@ type BitSwapMessage interface {
 	Blocks() []blocks.Block
 	// BlockPresences returns the list of HAVE / DONT_HAVE in the message
 	BlockPresences() []BlockPresence
+	// BlockRanges returns the slices of blocks sent in response to ranged
+	// wants. This is an experimental extension, see AddRangeEntry.
+	BlockRanges() []BlockRange
 	// Haves returns the Cids for each HAVE
 	Haves() []cid.Cid
 	// DontHaves returns the Cids for each DONT_HAVE
@@ -40,10 +43,22 @@ type BitSwapMessage interface {
 	// AddEntry adds an entry to the Wantlist.
 	AddEntry(key cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool) int
 
+	// AddRangeEntry adds a want-block entry to the Wantlist asking only for
+	// length bytes of the block starting at offset.
+	// This is an experimental extension: peers that don't support it ignore
+	// the range and answer with the full block.
+	AddRangeEntry(key cid.Cid, priority int32, offset, length uint64, sendDontHave bool) int
+
 	// Cancel adds a CANCEL for the given CID to the message
 	// Returns the size of the CANCEL entry in the protobuf
 	Cancel(key cid.Cid) int
 
+	// CancelRange adds a CANCEL for a ranged want added with AddRangeEntry.
+	// Unlike Cancel, it leaves a want for the whole block untouched. Peers
+	// that don't support ranged wants treat it as a Cancel.
+	// Returns the size of the CANCEL entry in the protobuf
+	CancelRange(key cid.Cid, offset, length uint64) int
+
 	// Remove removes any entries for the given CID. Useful when the want
 	// status for the CID changes when preparing a message.
 	Remove(key cid.Cid)
@@ -60,6 +75,9 @@ type BitSwapMessage interface {
 	AddBlock(blocks.Block)
 	// AddBlockPresence adds a HAVE / DONT_HAVE for the given Cid to the message
 	AddBlockPresence(cid.Cid, pb.Message_BlockPresenceType)
+	// AddBlockRange adds a slice of the block with the given Cid, starting at
+	// offset, to the message
+	AddBlockRange(c cid.Cid, offset uint64, data []byte)
 	// AddHave adds a HAVE for the given Cid to the message
 	AddHave(cid.Cid)
 	// AddDontHave adds a DONT_HAVE for the given Cid to the message
@@ -96,14 +114,30 @@ type BlockPresence struct {
 	Type pb.Message_BlockPresenceType
 }
 
+// BlockRange is a slice of a block sent in response to a ranged want.
+// Unlike full blocks, the data can't be verified against the Cid.
+type BlockRange struct {
+	Cid    cid.Cid
+	Offset uint64
+	Data   []byte
+}
+
 // Entry is a wantlist entry in a Bitswap message, with flags indicating
 // - whether message is a cancel
 // - whether requester wants a DONT_HAVE message
 // - whether requester wants a HAVE message (instead of the block)
+// - whether requester only wants a byte range of the block (RangeLength != 0)
 type Entry struct {
 	wantlist.Entry
 	Cancel       bool
 	SendDontHave bool
+	RangeOffset  uint64
+	RangeLength  uint64
+}
+
+// IsRange returns true if the entry only asks for a byte range of the block.
+func (e *Entry) IsRange() bool {
+	return e.RangeLength != 0
 }
 
 // Get the size of the entry on the wire
@@ -120,6 +154,8 @@ func (e *Entry) ToPB() pb.Message_Wantlist_Entry {
 		Cancel:       e.Cancel,
 		WantType:     e.WantType,
 		SendDontHave: e.SendDontHave,
+		RangeOffset:  e.RangeOffset,
+		RangeLength:  e.RangeLength,
 	}
 }
 
@@ -146,6 +182,7 @@ type impl struct {
 	wantlist       map[cid.Cid]*Entry
 	blocks         map[cid.Cid]blocks.Block
 	blockPresences map[cid.Cid]pb.Message_BlockPresenceType
+	blockRanges    map[cid.Cid]BlockRange
 	pendingBytes   int32
 }
 
@@ -160,6 +197,7 @@ func newMsg(full bool) *impl {
 		wantlist:       make(map[cid.Cid]*Entry),
 		blocks:         make(map[cid.Cid]blocks.Block),
 		blockPresences: make(map[cid.Cid]pb.Message_BlockPresenceType),
+		blockRanges:    make(map[cid.Cid]BlockRange),
 	}
 }
 
@@ -175,6 +213,9 @@ func (m *impl) Clone() BitSwapMessage {
 	for k := range m.blockPresences {
 		msg.blockPresences[k] = m.blockPresences[k]
 	}
+	for k := range m.blockRanges {
+		msg.blockRanges[k] = m.blockRanges[k]
+	}
 	msg.pendingBytes = m.pendingBytes
 	return msg
 }
@@ -191,6 +232,9 @@ func (m *impl) Reset(full bool) {
 	for k := range m.blockPresences {
 		delete(m.blockPresences, k)
 	}
+	for k := range m.blockRanges {
+		delete(m.blockRanges, k)
+	}
 	m.pendingBytes = 0
 }
 
@@ -202,6 +246,14 @@ func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
 		if !e.Block.Cid.Defined() {
 			return nil, errCidMissing
 		}
+		if e.RangeLength != 0 {
+			if e.Cancel {
+				m.CancelRange(e.Block.Cid, e.RangeOffset, e.RangeLength)
+			} else {
+				m.AddRangeEntry(e.Block.Cid, e.Priority, e.RangeOffset, e.RangeLength, e.SendDontHave)
+			}
+			continue
+		}
 		m.addEntry(e.Block.Cid, e.Priority, e.Cancel, e.WantType, e.SendDontHave)
 	}
 
@@ -239,6 +291,13 @@ func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
 		m.AddBlockPresence(bi.Cid.Cid, bi.Type)
 	}
 
+	for _, br := range pbm.GetBlockRanges() {
+		if !br.Cid.Cid.Defined() {
+			return nil, errCidMissing
+		}
+		m.AddBlockRange(br.Cid.Cid, br.Offset, br.Data)
+	}
+
 	m.pendingBytes = pbm.PendingBytes
 
 	return m, nil
@@ -249,7 +308,7 @@ func (m *impl) Full() bool {
 }
 
 func (m *impl) Empty() bool {
-	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.blockPresences) == 0
+	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.blockPresences) == 0 && len(m.blockRanges) == 0
 }
 
 func (m *impl) Wantlist() []Entry {
@@ -276,6 +335,14 @@ func (m *impl) BlockPresences() []BlockPresence {
 	return bps
 }
 
+func (m *impl) BlockRanges() []BlockRange {
+	brs := make([]BlockRange, 0, len(m.blockRanges))
+	for _, br := range m.blockRanges {
+		brs = append(brs, br)
+	}
+	return brs
+}
+
 func (m *impl) Haves() []cid.Cid {
 	return m.getBlockPresenceByType(pb.Message_Have)
 }
@@ -310,10 +377,64 @@ func (m *impl) Cancel(k cid.Cid) int {
 	return m.addEntry(k, 0, true, pb.Message_Wantlist_Block, false)
 }
 
+func (m *impl) CancelRange(k cid.Cid, offset, length uint64) int {
+	if e, exists := m.wantlist[k]; exists {
+		// Only the want for the same range is cancelled
+		if e.IsRange() && e.RangeOffset == offset && e.RangeLength == length {
+			e.Cancel = true
+		}
+		return 0
+	}
+
+	e := &Entry{
+		Entry: wantlist.Entry{
+			Cid:      k,
+			WantType: pb.Message_Wantlist_Block,
+		},
+		Cancel:      true,
+		RangeOffset: offset,
+		RangeLength: length,
+	}
+	m.wantlist[k] = e
+
+	return e.Size()
+}
+
 func (m *impl) AddEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool) int {
 	return m.addEntry(k, priority, false, wantType, sendDontHave)
 }
 
+func (m *impl) AddRangeEntry(k cid.Cid, priority int32, offset, length uint64, sendDontHave bool) int {
+	if e, exists := m.wantlist[k]; exists {
+		// An existing want for the whole block or for another range is
+		// turned into a want for the whole block
+		if !e.IsRange() || e.RangeOffset != offset || e.RangeLength != length {
+			e.RangeOffset, e.RangeLength = 0, 0
+		}
+		e.Cancel = false
+		e.Priority = priority
+		e.WantType = pb.Message_Wantlist_Block
+		if sendDontHave {
+			e.SendDontHave = sendDontHave
+		}
+		return 0
+	}
+
+	e := &Entry{
+		Entry: wantlist.Entry{
+			Cid:      k,
+			Priority: priority,
+			WantType: pb.Message_Wantlist_Block,
+		},
+		SendDontHave: sendDontHave,
+		RangeOffset:  offset,
+		RangeLength:  length,
+	}
+	m.wantlist[k] = e
+
+	return e.Size()
+}
+
 func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Message_Wantlist_WantType, sendDontHave bool) int {
 	e, exists := m.wantlist[c]
 	if exists {
@@ -333,6 +454,10 @@ func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Mess
 		if wantType == pb.Message_Wantlist_Block && e.WantType == pb.Message_Wantlist_Have {
 			e.WantType = wantType
 		}
+		// want-block or cancel for the whole block overrides a range
+		if cancel || wantType == pb.Message_Wantlist_Block {
+			e.RangeOffset, e.RangeLength = 0, 0
+		}
 		m.wantlist[c] = e
 		return 0
 	}
@@ -363,6 +488,10 @@ func (m *impl) AddBlockPresence(c cid.Cid, t pb.Message_BlockPresenceType) {
 	m.blockPresences[c] = t
 }
 
+func (m *impl) AddBlockRange(c cid.Cid, offset uint64, data []byte) {
+	m.blockRanges[c] = BlockRange{Cid: c, Offset: offset, Data: data}
+}
+
 func (m *impl) AddHave(c cid.Cid) {
 	m.AddBlockPresence(c, pb.Message_Have)
 }
@@ -379,6 +508,9 @@ func (m *impl) Size() int {
 	for c := range m.blockPresences {
 		size += BlockPresenceSize(c)
 	}
+	for _, br := range m.blockRanges {
+		size += len(br.Data)
+	}
 	for _, e := range m.wantlist {
 		size += e.Size()
 	}
@@ -406,10 +538,15 @@ func FromMsgReader(r msgio.Reader) (BitSwapMessage, error) {
 		return nil, err
 	}
 
+	defer r.ReleaseMsg(msg)
+	return FromBytes(msg)
+}
+
+// FromBytes generates a new Bitswap message from a protobuf encoded message,
+// without the length prefix used on the wire.
+func FromBytes(data []byte) (BitSwapMessage, error) {
 	var pb pb.Message
-	err = pb.Unmarshal(msg)
-	r.ReleaseMsg(msg)
-	if err != nil {
+	if err := pb.Unmarshal(data); err != nil {
 		return nil, err
 	}
 
@@ -457,6 +594,15 @@ func (m *impl) ToProtoV1() *pb.Message {
 		})
 	}
 
+	pbm.BlockRanges = make([]pb.Message_BlockRange, 0, len(m.blockRanges))
+	for _, br := range m.blockRanges {
+		pbm.BlockRanges = append(pbm.BlockRanges, pb.Message_BlockRange{
+			Cid:    pb.Cid{Cid: br.Cid},
+			Offset: br.Offset,
+			Data:   br.Data,
+		})
+	}
+
 	pbm.PendingBytes = m.PendingBytes()
 
 	return pbm
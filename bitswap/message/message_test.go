@@ -241,6 +241,75 @@ func TestBlockPresences(t *testing.T) {
 	}
 }
 
+func TestRangeEntriesAndBlockRanges(t *testing.T) {
+	b1 := blocks.NewBlock([]byte("foo"))
+	b2 := blocks.NewBlock([]byte("bar"))
+	msg := New(true)
+
+	msg.AddRangeEntry(b1.Cid(), 1, 1, 2, true)
+	msg.AddBlockRange(b2.Cid(), 1, []byte("ar"))
+
+	buf := new(bytes.Buffer)
+	if err := msg.ToNetV1(buf); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wl := copied.Wantlist()
+	if len(wl) != 1 || !wl[0].IsRange() || wl[0].RangeOffset != 1 || wl[0].RangeLength != 2 {
+		t.Fatal("expected range entry to be preserved")
+	}
+	if wl[0].WantType != pb.Message_Wantlist_Block || !wl[0].SendDontHave {
+		t.Fatal("expected range entry to be a want-block with send-dont-have")
+	}
+
+	brs := copied.BlockRanges()
+	if len(brs) != 1 || !brs[0].Cid.Equals(b2.Cid()) || brs[0].Offset != 1 || string(brs[0].Data) != "ar" {
+		t.Fatal("expected block range to be preserved")
+	}
+
+	// A want for the whole block supersedes the range
+	msg.AddEntry(b1.Cid(), 1, pb.Message_Wantlist_Block, true)
+	if wl := msg.Wantlist(); len(wl) != 1 || wl[0].IsRange() {
+		t.Fatal("expected want-block to replace range entry")
+	}
+}
+
+func TestCancelRange(t *testing.T) {
+	b1 := blocks.NewBlock([]byte("foo"))
+	b2 := blocks.NewBlock([]byte("bar"))
+	msg := New(false)
+
+	msg.CancelRange(b1.Cid(), 1, 2)
+	msg.AddEntry(b2.Cid(), 1, pb.Message_Wantlist_Block, true)
+	msg.CancelRange(b2.Cid(), 1, 2)
+
+	buf := new(bytes.Buffer)
+	if err := msg.ToNetV1(buf); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range copied.Wantlist() {
+		switch {
+		case e.Cid.Equals(b1.Cid()):
+			if !e.Cancel || !e.IsRange() || e.RangeOffset != 1 || e.RangeLength != 2 {
+				t.Fatalf("expected the range cancel to be preserved, got %+v", e)
+			}
+		case e.Cid.Equals(b2.Cid()):
+			if e.Cancel || e.IsRange() {
+				t.Fatalf("expected the want for the whole block to be untouched, got %+v", e)
+			}
+		}
+	}
+}
+
 func TestAddWantlistEntry(t *testing.T) {
 	b := blocks.NewBlock([]byte("foo"))
 	msg := New(true)
@@ -79,6 +79,7 @@ type Message struct {
 	Payload        []Message_Block         `protobuf:"bytes,3,rep,name=payload,proto3" json:"payload"`
 	BlockPresences []Message_BlockPresence `protobuf:"bytes,4,rep,name=blockPresences,proto3" json:"blockPresences"`
 	PendingBytes   int32                   `protobuf:"varint,5,opt,name=pendingBytes,proto3" json:"pendingBytes,omitempty"`
+	BlockRanges    []Message_BlockRange    `protobuf:"bytes,6,rep,name=blockRanges,proto3" json:"blockRanges"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
@@ -149,6 +150,13 @@ func (m *Message) GetPendingBytes() int32 {
 	return 0
 }
 
+func (m *Message) GetBlockRanges() []Message_BlockRange {
+	if m != nil {
+		return m.BlockRanges
+	}
+	return nil
+}
+
 type Message_Wantlist struct {
 	Entries []Message_Wantlist_Entry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries"`
 	Full    bool                     `protobuf:"varint,2,opt,name=full,proto3" json:"full,omitempty"`
@@ -207,6 +215,12 @@ type Message_Wantlist_Entry struct {
 	Cancel       bool                      `protobuf:"varint,3,opt,name=cancel,proto3" json:"cancel,omitempty"`
 	WantType     Message_Wantlist_WantType `protobuf:"varint,4,opt,name=wantType,proto3,enum=bitswap.message.v1.pb.Message_Wantlist_WantType" json:"wantType,omitempty"`
 	SendDontHave bool                      `protobuf:"varint,5,opt,name=sendDontHave,proto3" json:"sendDontHave,omitempty"`
+	// Experimental: when rangeLength is not 0 only the bytes in
+	// [rangeOffset, rangeOffset+rangeLength) of the block are wanted.
+	// Peers that don't know about these fields ignore them and answer with
+	// the full block.
+	RangeOffset uint64 `protobuf:"varint,6,opt,name=rangeOffset,proto3" json:"rangeOffset,omitempty"`
+	RangeLength uint64 `protobuf:"varint,7,opt,name=rangeLength,proto3" json:"rangeLength,omitempty"`
 }
 
 func (m *Message_Wantlist_Entry) Reset()         { *m = Message_Wantlist_Entry{} }
@@ -270,6 +284,20 @@ func (m *Message_Wantlist_Entry) GetSendDontHave() bool {
 	return false
 }
 
+func (m *Message_Wantlist_Entry) GetRangeOffset() uint64 {
+	if m != nil {
+		return m.RangeOffset
+	}
+	return 0
+}
+
+func (m *Message_Wantlist_Entry) GetRangeLength() uint64 {
+	if m != nil {
+		return m.RangeLength
+	}
+	return 0
+}
+
 type Message_Block struct {
 	Prefix []byte `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
 	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -367,6 +395,60 @@ func (m *Message_BlockPresence) GetType() Message_BlockPresenceType {
 	return Message_Have
 }
 
+// Experimental: a slice of a block sent in response to a ranged want.
+type Message_BlockRange struct {
+	Cid    Cid    `protobuf:"bytes,1,opt,name=cid,proto3,customtype=Cid" json:"cid"`
+	Offset uint64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data   []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Message_BlockRange) Reset()         { *m = Message_BlockRange{} }
+func (m *Message_BlockRange) String() string { return proto.CompactTextString(m) }
+func (*Message_BlockRange) ProtoMessage()    {}
+func (*Message_BlockRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_33c57e4bae7b9afd, []int{0, 3}
+}
+func (m *Message_BlockRange) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Message_BlockRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Message_BlockRange.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Message_BlockRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Message_BlockRange.Merge(m, src)
+}
+func (m *Message_BlockRange) XXX_Size() int {
+	return m.Size()
+}
+func (m *Message_BlockRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_Message_BlockRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Message_BlockRange proto.InternalMessageInfo
+
+func (m *Message_BlockRange) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *Message_BlockRange) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("bitswap.message.v1.pb.Message_BlockPresenceType", Message_BlockPresenceType_name, Message_BlockPresenceType_value)
 	proto.RegisterEnum("bitswap.message.v1.pb.Message_Wantlist_WantType", Message_Wantlist_WantType_name, Message_Wantlist_WantType_value)
@@ -375,43 +457,49 @@ func init() {
 	proto.RegisterType((*Message_Wantlist_Entry)(nil), "bitswap.message.v1.pb.Message.Wantlist.Entry")
 	proto.RegisterType((*Message_Block)(nil), "bitswap.message.v1.pb.Message.Block")
 	proto.RegisterType((*Message_BlockPresence)(nil), "bitswap.message.v1.pb.Message.BlockPresence")
+	proto.RegisterType((*Message_BlockRange)(nil), "bitswap.message.v1.pb.Message.BlockRange")
 }
 
 func init() { proto.RegisterFile("message.proto", fileDescriptor_33c57e4bae7b9afd) }
 
 var fileDescriptor_33c57e4bae7b9afd = []byte{
-	// 496 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xdf, 0x8a, 0xd3, 0x40,
-	0x14, 0xc6, 0x33, 0x4d, 0xd2, 0xc6, 0xd3, 0xec, 0x52, 0x07, 0x95, 0x10, 0x30, 0x8d, 0x45, 0x30,
-	0xe0, 0x1a, 0xb5, 0xfb, 0x06, 0xb1, 0x82, 0x82, 0x0b, 0x32, 0x08, 0x0b, 0x7b, 0xb3, 0xe4, 0xcf,
-	0x6c, 0x09, 0xc6, 0x24, 0x64, 0xc6, 0x5d, 0xf3, 0x16, 0x3e, 0xd6, 0x5e, 0xf6, 0x52, 0xbc, 0x58,
-	0xb4, 0xbd, 0xf7, 0x19, 0x64, 0x26, 0x93, 0x42, 0x75, 0x61, 0x7b, 0x37, 0xe7, 0xcc, 0xf9, 0x7e,
-	0x73, 0xbe, 0x73, 0x18, 0x38, 0xf8, 0x42, 0x19, 0x8b, 0x97, 0x34, 0xac, 0x9b, 0x8a, 0x57, 0xf8,
-	0x61, 0x92, 0x73, 0x76, 0x15, 0xd7, 0x61, 0x9f, 0xbe, 0x7c, 0x1d, 0xd6, 0x89, 0xfb, 0x60, 0x59,
-	0x2d, 0x2b, 0x59, 0xf1, 0x52, 0x9c, 0xba, 0xe2, 0xd9, 0x9f, 0x21, 0x8c, 0x4e, 0xba, 0x3a, 0xfc,
-	0x1e, 0xac, 0xab, 0xb8, 0xe4, 0x45, 0xce, 0xb8, 0x83, 0x7c, 0x14, 0x8c, 0xe7, 0xcf, 0xc2, 0x5b,
-	0x59, 0xa1, 0x52, 0x84, 0xa7, 0xaa, 0x3c, 0x32, 0xae, 0x6f, 0xa6, 0x1a, 0xd9, 0xca, 0xf1, 0x23,
-	0x18, 0x26, 0x45, 0x95, 0x7e, 0x66, 0xce, 0xc0, 0xd7, 0x03, 0x9b, 0xa8, 0x08, 0x2f, 0x60, 0x54,
-	0xc7, 0x6d, 0x51, 0xc5, 0x99, 0xa3, 0xfb, 0x7a, 0x30, 0x9e, 0x3f, 0xbd, 0xe3, 0x85, 0x48, 0xe8,
-	0x14, 0xbe, 0x97, 0xe2, 0x33, 0x38, 0x94, 0xbc, 0x8f, 0x0d, 0x65, 0xb4, 0x4c, 0x29, 0x73, 0x0c,
-	0x09, 0x3b, 0xda, 0x07, 0xd6, 0x8b, 0x14, 0xf4, 0x1f, 0x12, 0x9e, 0x81, 0x5d, 0xd3, 0x32, 0xcb,
-	0xcb, 0x65, 0xd4, 0x72, 0xca, 0x1c, 0xd3, 0x47, 0x81, 0x49, 0x76, 0x72, 0xee, 0xef, 0x01, 0x58,
-	0xbd, 0x75, 0x7c, 0x02, 0x23, 0x5a, 0xf2, 0x26, 0xa7, 0xcc, 0x41, 0xb2, 0x8b, 0x17, 0x7b, 0x0e,
-	0x2d, 0x7c, 0x5b, 0xf2, 0xa6, 0xed, 0xbd, 0x29, 0x06, 0xc6, 0x60, 0x5c, 0x7c, 0x2d, 0x0a, 0x67,
-	0xe0, 0xa3, 0xc0, 0x22, 0xf2, 0xec, 0xae, 0x10, 0x98, 0xb2, 0x18, 0x3f, 0x01, 0x53, 0xf6, 0x2b,
-	0xf7, 0x63, 0x47, 0x63, 0xa1, 0xfd, 0x79, 0x33, 0xd5, 0xdf, 0xe4, 0x19, 0xe9, 0x6e, 0xb0, 0x0b,
-	0x56, 0xdd, 0xe4, 0x55, 0x93, 0xf3, 0x56, 0x42, 0x4c, 0xb2, 0x8d, 0xc5, 0x5a, 0xd2, 0xb8, 0x4c,
-	0x69, 0xe1, 0xe8, 0x12, 0xaf, 0x22, 0xfc, 0xa1, 0xdb, 0xfc, 0xa7, 0xb6, 0xa6, 0x8e, 0xe1, 0xa3,
-	0xe0, 0x70, 0xfe, 0x6a, 0x5f, 0x13, 0xa7, 0x4a, 0x47, 0xb6, 0x04, 0x31, 0x42, 0x46, 0xcb, 0x6c,
-	0x51, 0x95, 0xfc, 0x5d, 0x7c, 0x49, 0xe5, 0x08, 0x2d, 0xb2, 0x93, 0x9b, 0x4d, 0xbb, 0x09, 0xca,
-	0xfa, 0x7b, 0x60, 0xca, 0xcd, 0x4c, 0x34, 0x6c, 0x81, 0x21, 0xae, 0x27, 0xc8, 0x3d, 0x56, 0x49,
-	0xd1, 0x73, 0xdd, 0xd0, 0x8b, 0xfc, 0x5b, 0xe7, 0x99, 0xa8, 0x48, 0x0c, 0x2a, 0x8b, 0x79, 0x2c,
-	0x3d, 0xda, 0x44, 0x9e, 0x5d, 0x0e, 0x07, 0x3b, 0x3b, 0xc6, 0x8f, 0x41, 0x4f, 0xf3, 0xec, 0xb6,
-	0x69, 0x89, 0x3c, 0x5e, 0x80, 0xc1, 0x85, 0xe7, 0xc1, 0x5e, 0x9e, 0x77, 0xd0, 0xd2, 0xb3, 0x54,
-	0xcf, 0x9e, 0xc3, 0xfd, 0xff, 0xae, 0xb6, 0x4e, 0x34, 0x6c, 0x83, 0xd5, 0xdb, 0x9e, 0xa0, 0xe8,
-	0xe8, 0x7a, 0xed, 0xa1, 0xd5, 0xda, 0x43, 0xbf, 0xd6, 0x1e, 0xfa, 0xbe, 0xf1, 0xb4, 0xd5, 0xc6,
-	0xd3, 0x7e, 0x6c, 0x3c, 0xed, 0x0c, 0xab, 0xd7, 0xcf, 0xd5, 0xeb, 0xe7, 0x75, 0x92, 0x0c, 0xe5,
-	0x2f, 0x3d, 0xfe, 0x1b, 0x00, 0x00, 0xff, 0xff, 0x44, 0xa6, 0x21, 0xa7, 0xe3, 0x03, 0x00, 0x00,
+	// 573 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xd1, 0x8a, 0xd3, 0x40,
+	0x14, 0xcd, 0x34, 0x49, 0x1b, 0x6f, 0xbb, 0xcb, 0x3a, 0xe8, 0x32, 0x04, 0xcc, 0xc6, 0x22, 0x18,
+	0x71, 0x8d, 0xda, 0xfd, 0x83, 0x5a, 0x41, 0x61, 0x17, 0x75, 0x10, 0x0a, 0xfb, 0xb2, 0xa4, 0xcd,
+	0x34, 0x06, 0x63, 0x12, 0x32, 0xe3, 0xae, 0xfd, 0x0b, 0x1f, 0xfc, 0x05, 0xff, 0x65, 0x1f, 0xf7,
+	0x51, 0x7c, 0x28, 0xd2, 0xfe, 0x88, 0x64, 0x32, 0xc9, 0xb6, 0xba, 0xb0, 0x7d, 0x9b, 0x7b, 0x7b,
+	0xcf, 0x39, 0xbd, 0xe7, 0x5c, 0x02, 0x3b, 0x5f, 0x18, 0xe7, 0x41, 0xc4, 0xfc, 0xbc, 0xc8, 0x44,
+	0x86, 0xef, 0x4f, 0x62, 0xc1, 0x2f, 0x82, 0xdc, 0xaf, 0xdb, 0xe7, 0x2f, 0xfd, 0x7c, 0x62, 0xdf,
+	0x8b, 0xb2, 0x28, 0x93, 0x13, 0xcf, 0xcb, 0x57, 0x35, 0xdc, 0x5f, 0x58, 0xd0, 0x39, 0xa9, 0xe6,
+	0xf0, 0x5b, 0xb0, 0x2e, 0x82, 0x54, 0x24, 0x31, 0x17, 0x04, 0xb9, 0xc8, 0xeb, 0x0e, 0x1e, 0xfb,
+	0x37, 0x72, 0xf9, 0x0a, 0xe1, 0x8f, 0xd5, 0xf8, 0xd0, 0xb8, 0x5c, 0x1c, 0x68, 0xb4, 0x81, 0xe3,
+	0x7d, 0x68, 0x4f, 0x92, 0x6c, 0xfa, 0x99, 0x93, 0x96, 0xab, 0x7b, 0x3d, 0xaa, 0x2a, 0x3c, 0x82,
+	0x4e, 0x1e, 0xcc, 0x93, 0x2c, 0x08, 0x89, 0xee, 0xea, 0x5e, 0x77, 0xf0, 0xe8, 0x16, 0x85, 0x61,
+	0x89, 0x53, 0xf4, 0x35, 0x14, 0x9f, 0xc2, 0xae, 0xe4, 0x7b, 0x5f, 0x30, 0xce, 0xd2, 0x29, 0xe3,
+	0xc4, 0x90, 0x64, 0x87, 0xdb, 0x90, 0xd5, 0x20, 0x45, 0xfa, 0x0f, 0x13, 0xee, 0x43, 0x2f, 0x67,
+	0x69, 0x18, 0xa7, 0xd1, 0x70, 0x2e, 0x18, 0x27, 0xa6, 0x8b, 0x3c, 0x93, 0x6e, 0xf4, 0xf0, 0x07,
+	0xe8, 0x4a, 0x14, 0x0d, 0xd2, 0x88, 0x71, 0xd2, 0x96, 0xe2, 0x4f, 0xb6, 0x11, 0x97, 0x08, 0xa5,
+	0xbc, 0xce, 0x61, 0xff, 0xd4, 0xc1, 0xaa, 0xdd, 0xc4, 0x27, 0xd0, 0x61, 0xa9, 0x28, 0x62, 0xc6,
+	0x09, 0x92, 0xdc, 0xcf, 0xb6, 0xcc, 0xc1, 0x7f, 0x9d, 0x8a, 0x62, 0x5e, 0xdb, 0xa5, 0x38, 0x30,
+	0x06, 0x63, 0xf6, 0x35, 0x49, 0x48, 0xcb, 0x45, 0x9e, 0x45, 0xe5, 0xdb, 0xfe, 0xd1, 0x02, 0x53,
+	0x0e, 0xe3, 0x87, 0x60, 0xca, 0x3f, 0x22, 0x23, 0xef, 0x0d, 0xbb, 0x25, 0xf6, 0xf7, 0xe2, 0x40,
+	0x7f, 0x15, 0x87, 0xb4, 0xfa, 0x05, 0xdb, 0x60, 0xe5, 0x45, 0x9c, 0x15, 0xb1, 0x98, 0x4b, 0x12,
+	0x93, 0x36, 0x75, 0x99, 0xf4, 0x34, 0x48, 0xa7, 0x2c, 0x21, 0xba, 0xa4, 0x57, 0x15, 0x3e, 0xae,
+	0x8e, 0xe9, 0xe3, 0x3c, 0x67, 0xc4, 0x70, 0x91, 0xb7, 0x3b, 0x78, 0xb1, 0xed, 0x12, 0x63, 0x85,
+	0xa3, 0x0d, 0x43, 0x99, 0x0a, 0x67, 0x69, 0x38, 0xca, 0x52, 0xf1, 0x26, 0x38, 0x67, 0x32, 0x15,
+	0x8b, 0x6e, 0xf4, 0xb0, 0x0b, 0xdd, 0xa2, 0x34, 0xf3, 0xdd, 0x6c, 0xc6, 0x99, 0x20, 0x6d, 0x17,
+	0x79, 0x06, 0x5d, 0x6f, 0x35, 0x13, 0xc7, 0x2c, 0x8d, 0xc4, 0x27, 0xd2, 0x59, 0x9b, 0xa8, 0x5a,
+	0xfd, 0x83, 0x2a, 0x05, 0xa9, 0x79, 0x07, 0x4c, 0x99, 0xd9, 0x9e, 0x86, 0x2d, 0x30, 0x4a, 0x89,
+	0x3d, 0x64, 0x1f, 0xa9, 0x66, 0xb9, 0x77, 0x5e, 0xb0, 0x59, 0xfc, 0xad, 0xf2, 0x8d, 0xaa, 0xaa,
+	0x34, 0x3b, 0x0c, 0x44, 0x20, 0x7d, 0xea, 0x51, 0xf9, 0xb6, 0x05, 0xec, 0x6c, 0x9c, 0x1e, 0x7e,
+	0x00, 0xfa, 0x34, 0x0e, 0x6f, 0x72, 0xbc, 0xec, 0xe3, 0x11, 0x18, 0xa2, 0xf4, 0xad, 0xb5, 0x95,
+	0x6f, 0x1b, 0xd4, 0xd2, 0x37, 0x89, 0xb6, 0xc7, 0x00, 0xd7, 0x37, 0x77, 0x9b, 0xe4, 0x3e, 0xb4,
+	0xb3, 0xca, 0xb7, 0x96, 0x74, 0x45, 0x55, 0xcd, 0x3a, 0xfa, 0xf5, 0x3a, 0xfd, 0xa7, 0x70, 0xf7,
+	0x3f, 0xcd, 0xc6, 0x22, 0x0d, 0xf7, 0xc0, 0xaa, 0x33, 0xd9, 0x43, 0xc3, 0xc3, 0xcb, 0xa5, 0x83,
+	0xae, 0x96, 0x0e, 0xfa, 0xb3, 0x74, 0xd0, 0xf7, 0x95, 0xa3, 0x5d, 0xad, 0x1c, 0xed, 0xd7, 0xca,
+	0xd1, 0x4e, 0xb1, 0x5a, 0xeb, 0x4c, 0xad, 0x75, 0x96, 0x4f, 0x26, 0x6d, 0xf9, 0x55, 0x3a, 0xfa,
+	0x1b, 0x00, 0x00, 0xff, 0xff, 0xc0, 0xad, 0x52, 0xd7, 0xd3, 0x04, 0x00, 0x00,
 }
 
 func (m *Message) Marshal() (dAtA []byte, err error) {
@@ -434,6 +522,20 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.BlockRanges) > 0 {
+		for iNdEx := len(m.BlockRanges) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.BlockRanges[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintMessage(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x32
+		}
+	}
 	if m.PendingBytes != 0 {
 		i = encodeVarintMessage(dAtA, i, uint64(m.PendingBytes))
 		i--
@@ -556,6 +658,16 @@ func (m *Message_Wantlist_Entry) MarshalToSizedBuffer(dAtA []byte) (int, error)
 	_ = i
 	var l int
 	_ = l
+	if m.RangeLength != 0 {
+		i = encodeVarintMessage(dAtA, i, uint64(m.RangeLength))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.RangeOffset != 0 {
+		i = encodeVarintMessage(dAtA, i, uint64(m.RangeOffset))
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.SendDontHave {
 		i--
 		if m.SendDontHave {
@@ -674,6 +786,51 @@ func (m *Message_BlockPresence) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *Message_BlockRange) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message_BlockRange) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message_BlockRange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Data) > 0 {
+		i -= len(m.Data)
+		copy(dAtA[i:], m.Data)
+		i = encodeVarintMessage(dAtA, i, uint64(len(m.Data)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Offset != 0 {
+		i = encodeVarintMessage(dAtA, i, uint64(m.Offset))
+		i--
+		dAtA[i] = 0x10
+	}
+	{
+		size := m.Cid.Size()
+		i -= size
+		if _, err := m.Cid.MarshalTo(dAtA[i:]); err != nil {
+			return 0, err
+		}
+		i = encodeVarintMessage(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintMessage(dAtA []byte, offset int, v uint64) int {
 	offset -= sovMessage(v)
 	base := offset
@@ -714,6 +871,12 @@ func (m *Message) Size() (n int) {
 	if m.PendingBytes != 0 {
 		n += 1 + sovMessage(uint64(m.PendingBytes))
 	}
+	if len(m.BlockRanges) > 0 {
+		for _, e := range m.BlockRanges {
+			l = e.Size()
+			n += 1 + l + sovMessage(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -755,6 +918,12 @@ func (m *Message_Wantlist_Entry) Size() (n int) {
 	if m.SendDontHave {
 		n += 2
 	}
+	if m.RangeOffset != 0 {
+		n += 1 + sovMessage(uint64(m.RangeOffset))
+	}
+	if m.RangeLength != 0 {
+		n += 1 + sovMessage(uint64(m.RangeLength))
+	}
 	return n
 }
 
@@ -789,6 +958,24 @@ func (m *Message_BlockPresence) Size() (n int) {
 	return n
 }
 
+func (m *Message_BlockRange) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = m.Cid.Size()
+	n += 1 + l + sovMessage(uint64(l))
+	if m.Offset != 0 {
+		n += 1 + sovMessage(uint64(m.Offset))
+	}
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovMessage(uint64(l))
+	}
+	return n
+}
+
 func sovMessage(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -976,6 +1163,40 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockRanges", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessage
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessage
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BlockRanges = append(m.BlockRanges, Message_BlockRange{})
+			if err := m.BlockRanges[len(m.BlockRanges)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessage(dAtA[iNdEx:])
@@ -1241,6 +1462,44 @@ func (m *Message_Wantlist_Entry) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.SendDontHave = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RangeOffset", wireType)
+			}
+			m.RangeOffset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RangeOffset |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RangeLength", wireType)
+			}
+			m.RangeLength = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RangeLength |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessage(dAtA[iNdEx:])
@@ -1482,6 +1741,142 @@ func (m *Message_BlockPresence) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *Message_BlockRange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowMessage
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BlockRange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BlockRange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Cid", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthMessage
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessage
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Cid.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+			}
+			m.Offset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Offset |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthMessage
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessage
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipMessage(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthMessage
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipMessage(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -44,3 +44,7 @@ func PendingBlocksGauge(ctx context.Context) metrics.Gauge {
 func ActiveBlocksGauge(ctx context.Context) metrics.Gauge {
 	return metrics.NewCtx(ctx, "active_block_tasks", "Total number of active blockstore tasks").Gauge()
 }
+
+func DroppedTasksCounter(ctx context.Context) metrics.Counter {
+	return metrics.NewCtx(ctx, "dropped_tasks", "Total number of tasks dropped because the queued tasks reached their maximum size").Counter()
+}
@@ -0,0 +1,147 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	"github.com/mikelsr/boxo/bitswap/network/internal"
+
+	"github.com/libp2p/go-msgio"
+	"github.com/mikelsr/go-libp2p/core/protocol"
+)
+
+// Compressor compresses the messages exchanged with the peers that support
+// it, see WithMessageCompression.
+type Compressor interface {
+	// Name identifies the compression algorithm. It is part of the protocol
+	// ID, so both peers must use the same name for the same algorithm.
+	Name() string
+
+	// Compress returns the compressed data.
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress returns the decompressed data, or an error if it is larger
+	// than maxSize bytes.
+	Decompress(data []byte, maxSize int) ([]byte, error)
+}
+
+// Gzip is a Compressor using gzip, with the default compression level.
+var Gzip Compressor = gzipCompressor{}
+
+// ErrMessageTooLarge is returned when a decompressed message is larger than
+// the maximum message size
+var ErrMessageTooLarge = errors.New("bitswap: decompressed message too large")
+
+// minCompressedSize is the size under which messages are not worth
+// compressing
+const minCompressedSize = 256
+
+// Frames of the compressed protocols start with a flag telling whether the
+// rest of the frame is compressed
+const (
+	frameUncompressed byte = 0
+	frameCompressed   byte = 1
+)
+
+// compressedProtocol returns the protocol ID used to exchange messages
+// compressed with c, which is Bitswap 1.2.0 with compressed frames
+func compressedProtocol(c Compressor) protocol.ID {
+	return internal.ProtocolBitswap + protocol.ID("+"+c.Name())
+}
+
+// writeCompressed writes the message as a frame of the compressed protocol:
+// a varint length, followed by the flag and the protobuf message. The message
+// is sent uncompressed if compressing it doesn't make it smaller, e.g. when it
+// only holds blocks of compressed or random data.
+func writeCompressed(w io.Writer, msg bsmsg.BitSwapMessage, c Compressor) error {
+	data, err := msg.ToProtoV1().Marshal()
+	if err != nil {
+		return err
+	}
+
+	flag := frameUncompressed
+	if len(data) >= minCompressedSize {
+		compressed, err := c.Compress(data)
+		if err != nil {
+			return err
+		}
+		if len(compressed) < len(data) {
+			data = compressed
+			flag = frameCompressed
+		}
+	}
+
+	buf := make([]byte, 0, binary.MaxVarintLen64+1+len(data))
+	buf = binary.AppendUvarint(buf, uint64(len(data)+1))
+	buf = append(buf, flag)
+	buf = append(buf, data...)
+	_, err = w.Write(buf)
+	return err
+}
+
+// readCompressed reads a message written with writeCompressed
+func readCompressed(r msgio.Reader, c Compressor, maxSize int) (bsmsg.BitSwapMessage, error) {
+	frame, err := r.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	defer r.ReleaseMsg(frame)
+
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("empty compressed frame")
+	}
+
+	data := frame[1:]
+	switch frame[0] {
+	case frameUncompressed:
+	case frameCompressed:
+		data, err = c.Decompress(data, maxSize)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown compressed frame flag %d", frame[0])
+	}
+
+	return bsmsg.FromBytes(data)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string {
+	return "gzip"
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte, maxSize int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxSize {
+		return nil, ErrMessageTooLarge
+	}
+	return out, nil
+}
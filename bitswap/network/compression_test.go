@@ -0,0 +1,141 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	pb "github.com/mikelsr/boxo/bitswap/message/pb"
+
+	"github.com/libp2p/go-msgio"
+	"github.com/mikelsr/go-libp2p/core/network"
+)
+
+// repetitiveWantlist returns a message with a wantlist of n entries, all
+// with the same priority and type, like the ones sent when fetching a DAG
+func repetitiveWantlist(n int) bsmsg.BitSwapMessage {
+	msg := bsmsg.New(true)
+	gen := blocksutil.NewBlockGenerator()
+	for i := 0; i < n; i++ {
+		msg.AddEntry(gen.Next().Cid(), 1, pb.Message_Wantlist_Have, true)
+	}
+	return msg
+}
+
+func containsEntry(msg bsmsg.BitSwapMessage, e bsmsg.Entry) bool {
+	for _, x := range msg.Wantlist() {
+		if x.Cid.Equals(e.Cid) && x.WantType == e.WantType && x.Priority == e.Priority {
+			return true
+		}
+	}
+	return false
+}
+
+// roundTrip writes the message with writeCompressed and reads it back,
+// returning the frame flag
+func roundTrip(t testing.TB, msg bsmsg.BitSwapMessage) (bsmsg.BitSwapMessage, byte) {
+	var buf bytes.Buffer
+	if err := writeCompressed(&buf, msg, Gzip); err != nil {
+		t.Fatal(err)
+	}
+	_, n := binary.Uvarint(buf.Bytes())
+	flag := buf.Bytes()[n]
+
+	received, err := readCompressed(msgio.NewVarintReaderSize(&buf, network.MessageSizeMax), Gzip, network.MessageSizeMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return received, flag
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	msg := repetitiveWantlist(100)
+	received, flag := roundTrip(t, msg)
+	if flag != frameCompressed {
+		t.Fatal("expected a repetitive wantlist to be compressed")
+	}
+	if !received.Full() || len(received.Wantlist()) != 100 {
+		t.Fatalf("expected a full wantlist of 100 entries, got %d", len(received.Wantlist()))
+	}
+	for _, e := range received.Wantlist() {
+		if !containsEntry(msg, e) {
+			t.Fatalf("unexpected entry %s", e.Cid)
+		}
+	}
+
+	// small messages and random data are sent as is
+	small := bsmsg.New(false)
+	small.AddBlock(blocks.NewBlock([]byte("small")))
+	if _, flag := roundTrip(t, small); flag != frameUncompressed {
+		t.Fatal("expected a small message not to be compressed")
+	}
+
+	data := make([]byte, 4096)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	random := bsmsg.New(false)
+	random.AddBlock(blocks.NewBlock(data))
+	received, flag = roundTrip(t, random)
+	if flag != frameUncompressed {
+		t.Fatal("expected random data not to be compressed")
+	}
+	if blks := received.Blocks(); len(blks) != 1 || !bytes.Equal(blks[0].RawData(), data) {
+		t.Fatal("unexpected blocks")
+	}
+}
+
+func TestDecompressTooLarge(t *testing.T) {
+	compressed, err := Gzip.Compress(make([]byte, 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Gzip.Decompress(compressed, 1023); err != ErrMessageTooLarge {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if _, err := Gzip.Decompress(compressed, 1024); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkRepetitiveWantlist(b *testing.B) {
+	msg := repetitiveWantlist(1000)
+
+	b.Run("uncompressed", func(b *testing.B) {
+		var buf bytes.Buffer
+		var size int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := msg.ToNetV1(&buf); err != nil {
+				b.Fatal(err)
+			}
+			size = buf.Len()
+			if _, err := bsmsg.FromNet(&buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(size), "wire-bytes")
+	})
+
+	b.Run("gzip", func(b *testing.B) {
+		var buf bytes.Buffer
+		var size int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			if err := writeCompressed(&buf, msg, Gzip); err != nil {
+				b.Fatal(err)
+			}
+			size = buf.Len()
+			if _, err := readCompressed(msgio.NewVarintReaderSize(&buf, network.MessageSizeMax), Gzip, network.MessageSizeMax); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(size), "wire-bytes")
+	})
+}
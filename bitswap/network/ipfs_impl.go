@@ -35,6 +35,14 @@ var minSendTimeout = 10 * time.Second
 var sendLatency = 2 * time.Second
 var minSendRate = (100 * 1000) / 8 // 100kbit/s
 
+// ErrPeerNotAllowed is returned when trying to exchange with a peer that is
+// blocklisted or not allowlisted, see WithPeerAllowlist and WithPeerBlocklist
+var ErrPeerNotAllowed = errors.New("bitswap: peer not allowed")
+
+// ErrSendTimeout is returned by SendMessage when the message could not be
+// sent within the timeout set with WithSendTimeout
+var ErrSendTimeout = errors.New("bitswap: send timed out")
+
 // NewFromIpfsHost returns a BitSwapNetwork supported by underlying IPFS host.
 func NewFromIpfsHost(host host.Host, r routing.ContentRouting, opts ...NetOpt) BitSwapNetwork {
 	s := processSettings(opts...)
@@ -49,6 +57,25 @@ func NewFromIpfsHost(host host.Host, r routing.ContentRouting, opts ...NetOpt) B
 		protocolBitswap:        s.ProtocolPrefix + ProtocolBitswap,
 
 		supportedProtocols: s.SupportedProtocols,
+		sendTimeout:        s.SendTimeout,
+	}
+
+	if s.Compressor != nil {
+		bitswapNetwork.compressor = s.Compressor
+		bitswapNetwork.protocolBitswapCompressed = s.ProtocolPrefix + compressedProtocol(s.Compressor)
+	}
+
+	if s.PeerAllowlist != nil {
+		bitswapNetwork.allowlist = make(map[peer.ID]struct{}, len(s.PeerAllowlist))
+		for _, p := range s.PeerAllowlist {
+			bitswapNetwork.allowlist[p] = struct{}{}
+		}
+	}
+	if len(s.PeerBlocklist) > 0 {
+		bitswapNetwork.blocklist = make(map[peer.ID]struct{}, len(s.PeerBlocklist))
+		for _, p := range s.PeerBlocklist {
+			bitswapNetwork.blocklist[p] = struct{}{}
+		}
 	}
 
 	return &bitswapNetwork
@@ -59,6 +86,10 @@ func processSettings(opts ...NetOpt) Settings {
 	for _, opt := range opts {
 		opt(&s)
 	}
+	if s.Compressor != nil {
+		// prefer compression with the peers that support it
+		s.SupportedProtocols = append([]protocol.ID{compressedProtocol(s.Compressor)}, s.SupportedProtocols...)
+	}
 	for i, proto := range s.SupportedProtocols {
 		s.SupportedProtocols[i] = s.ProtocolPrefix + proto
 	}
@@ -83,6 +114,19 @@ type impl struct {
 
 	supportedProtocols []protocol.ID
 
+	// compresses the messages sent with protocolBitswapCompressed, nil if
+	// compression is disabled
+	compressor                Compressor
+	protocolBitswapCompressed protocol.ID
+
+	// if not zero, overrides the size based timeout of SendMessage
+	sendTimeout time.Duration
+
+	// peers bitswap is restricted to (nil if not restricted) and peers
+	// bitswap refuses to exchange with, see isAllowed
+	allowlist map[peer.ID]struct{}
+	blocklist map[peer.ID]struct{}
+
 	// inbound messages from the network are forwarded to the receiver
 	receivers []Receiver
 }
@@ -164,8 +208,9 @@ func (s *streamMessageSender) multiAttempt(ctx context.Context, fn func() error)
 		default:
 		}
 
-		// Protocol is not supported, so no need to try multiple times
-		if errors.Is(err, multistream.ErrNotSupported[protocol.ID]{}) {
+		// Protocol is not supported or peer is not allowed, so no need to try
+		// multiple times
+		if errors.Is(err, multistream.ErrNotSupported[protocol.ID]{}) || errors.Is(err, ErrPeerNotAllowed) {
 			s.bsnet.connectEvtMgr.MarkUnresponsive(s.to)
 			return err
 		}
@@ -211,6 +256,20 @@ func (s *streamMessageSender) send(ctx context.Context, msg bsmsg.BitSwapMessage
 	return nil
 }
 
+// isAllowed returns false if bitswap must not exchange with the peer, either
+// because it's in the blocklist or because there is an allowlist and the peer
+// is not in it. The blocklist takes precedence.
+func (bsnet *impl) isAllowed(p peer.ID) bool {
+	if _, blocked := bsnet.blocklist[p]; blocked {
+		return false
+	}
+	if bsnet.allowlist == nil {
+		return true
+	}
+	_, allowed := bsnet.allowlist[p]
+	return allowed
+}
+
 func (bsnet *impl) Self() peer.ID {
 	return bsnet.host.ID()
 }
@@ -249,6 +308,14 @@ func (bsnet *impl) msgToStream(ctx context.Context, s network.Stream, msg bsmsg.
 	// to convert the message to the appropriate format depending on the remote
 	// peer's Bitswap version.
 	switch s.Protocol() {
+	case bsnet.protocolBitswapCompressed:
+		if bsnet.compressor == nil {
+			return fmt.Errorf("unrecognized protocol on remote: %s", s.Protocol())
+		}
+		if err := writeCompressed(s, msg, bsnet.compressor); err != nil {
+			log.Debugf("error: %s", err)
+			return err
+		}
 	case bsnet.protocolBitswapOneOne, bsnet.protocolBitswap:
 		if err := msg.ToNetV1(s); err != nil {
 			log.Debugf("error: %s", err)
@@ -331,15 +398,36 @@ func (bsnet *impl) SendMessage(
 	}
 
 	timeout := sendTimeout(outgoing.Size())
+	var timedOut atomic.Bool
+	if bsnet.sendTimeout > 0 {
+		timeout = bsnet.sendTimeout
+		// Not every stream supports write deadlines, and closing the stream
+		// can block as well: reset it if the whole send takes too long.
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			_ = s.Reset()
+		})
+		defer timer.Stop()
+	}
+
 	if err = bsnet.msgToStream(ctx, s, outgoing, timeout); err != nil {
 		_ = s.Reset()
+		if timedOut.Load() {
+			return ErrSendTimeout
+		}
 		return err
 	}
 
-	return s.Close()
+	if err = s.Close(); err != nil && timedOut.Load() {
+		return ErrSendTimeout
+	}
+	return err
 }
 
 func (bsnet *impl) newStreamToPeer(ctx context.Context, p peer.ID) (network.Stream, error) {
+	if !bsnet.isAllowed(p) {
+		return nil, ErrPeerNotAllowed
+	}
 	return bsnet.host.NewStream(ctx, p, bsnet.supportedProtocols...)
 }
 
@@ -366,6 +454,9 @@ func (bsnet *impl) Stop() {
 }
 
 func (bsnet *impl) ConnectTo(ctx context.Context, p peer.ID) error {
+	if !bsnet.isAllowed(p) {
+		return ErrPeerNotAllowed
+	}
 	return bsnet.host.Connect(ctx, peer.AddrInfo{ID: p})
 }
 
@@ -383,6 +474,9 @@ func (bsnet *impl) FindProvidersAsync(ctx context.Context, k cid.Cid, max int) <
 			if info.ID == bsnet.host.ID() {
 				continue // ignore self as provider
 			}
+			if !bsnet.isAllowed(info.ID) {
+				continue
+			}
 			bsnet.host.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.TempAddrTTL)
 			select {
 			case <-ctx.Done():
@@ -408,9 +502,22 @@ func (bsnet *impl) handleNewStream(s network.Stream) {
 		return
 	}
 
+	if !bsnet.isAllowed(s.Conn().RemotePeer()) {
+		log.Debugf("bitswap net handleNewStream from %s: peer not allowed", s.Conn().RemotePeer())
+		_ = s.Reset()
+		return
+	}
+
+	readMsg := bsmsg.FromMsgReader
+	if bsnet.compressor != nil && s.Protocol() == bsnet.protocolBitswapCompressed {
+		readMsg = func(r msgio.Reader) (bsmsg.BitSwapMessage, error) {
+			return readCompressed(r, bsnet.compressor, network.MessageSizeMax)
+		}
+	}
+
 	reader := msgio.NewVarintReaderSize(s, network.MessageSizeMax)
 	for {
-		received, err := bsmsg.FromMsgReader(reader)
+		received, err := readMsg(reader)
 		if err != nil {
 			if err != io.EOF {
 				_ = s.Reset()
@@ -456,9 +563,18 @@ func (nn *netNotifiee) Connected(n network.Network, v network.Conn) {
 		return
 	}
 
+	// ignore peers bitswap doesn't exchange with
+	if !nn.impl().isAllowed(v.RemotePeer()) {
+		return
+	}
+
 	nn.impl().connectEvtMgr.Connected(v.RemotePeer())
 }
 func (nn *netNotifiee) Disconnected(n network.Network, v network.Conn) {
+	if !nn.impl().isAllowed(v.RemotePeer()) {
+		return
+	}
+
 	// Only record a "disconnect" when we actually disconnect.
 	if n.Connectedness(v.RemotePeer()) == network.Connected {
 		return
@@ -2,6 +2,7 @@ package network_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
@@ -271,6 +272,160 @@ func TestMessageSendAndReceive(t *testing.T) {
 	}
 }
 
+func TestPeerAllowlistAndBlocklist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mn := mocknet.New()
+	defer mn.Close()
+	streamNet, err := tn.StreamNet(ctx, mn, mockrouting.NewServer())
+	if err != nil {
+		t.Fatal("Unable to setup network")
+	}
+	p1 := tnet.RandIdentityOrFatal(t)
+	p2 := tnet.RandIdentityOrFatal(t)
+	p3 := tnet.RandIdentityOrFatal(t)
+
+	// p1 allows p2 and p3, but blocks p3: the blocklist wins. The peers of
+	// several calls of the options add up
+	bsnet1 := streamNet.Adapter(p1,
+		bsnet.WithPeerAllowlist([]peer.ID{p2.ID()}),
+		bsnet.WithPeerAllowlist([]peer.ID{p3.ID()}),
+		bsnet.WithPeerBlocklist(nil),
+		bsnet.WithPeerBlocklist([]peer.ID{p3.ID()}),
+	)
+	bsnet2 := streamNet.Adapter(p2)
+	bsnet3 := streamNet.Adapter(p3)
+	r1 := newReceiver()
+	r2 := newReceiver()
+	r3 := newReceiver()
+	bsnet1.Start(r1)
+	t.Cleanup(bsnet1.Stop)
+	bsnet2.Start(r2)
+	t.Cleanup(bsnet2.Stop)
+	bsnet3.Start(r3)
+	t.Cleanup(bsnet3.Stop)
+
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bsnet1.ConnectTo(ctx, p3.ID()); !errors.Is(err, bsnet.ErrPeerNotAllowed) {
+		t.Fatalf("expected ErrPeerNotAllowed, got %v", err)
+	}
+
+	blockGenerator := blocksutil.NewBlockGenerator()
+	msg := bsmsg.New(false)
+	msg.AddEntry(blockGenerator.Next().Cid(), 1, pb.Message_Wantlist_Block, true)
+
+	if err := bsnet1.SendMessage(ctx, p3.ID(), msg); !errors.Is(err, bsnet.ErrPeerNotAllowed) {
+		t.Fatalf("expected ErrPeerNotAllowed, got %v", err)
+	}
+
+	// messages from the blocked peer are dropped
+	if err := bsnet3.ConnectTo(ctx, p1.ID()); err != nil {
+		t.Fatal(err)
+	}
+	_ = bsnet3.SendMessage(ctx, p1.ID(), msg)
+
+	// messages from the allowed peer are received
+	if err := bsnet2.SendMessage(ctx, p1.ID(), msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal("did not receive message sent")
+	case <-r1.messageReceived:
+	}
+	if r1.lastSender != p2.ID() {
+		t.Fatal("received message from wrong peer")
+	}
+
+	select {
+	case <-r1.messageReceived:
+		t.Fatal("received message from blocked peer")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, ok := r1.peers[p3.ID()]; ok {
+		t.Fatal("blocked peer should not be reported as connected")
+	}
+}
+
+func TestMessageCompression(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mn := mocknet.New()
+	defer mn.Close()
+	streamNet, err := tn.StreamNet(ctx, mn, mockrouting.NewServer())
+	if err != nil {
+		t.Fatal("Unable to setup network")
+	}
+	p1 := tnet.RandIdentityOrFatal(t)
+	p2 := tnet.RandIdentityOrFatal(t)
+	p3 := tnet.RandIdentityOrFatal(t)
+
+	// p1 and p2 support compression, p3 doesn't
+	bsnet1 := streamNet.Adapter(p1, bsnet.WithMessageCompression(bsnet.Gzip))
+	bsnet2 := streamNet.Adapter(p2, bsnet.WithMessageCompression(bsnet.Gzip))
+	bsnet3 := streamNet.Adapter(p3)
+	r1 := newReceiver()
+	r2 := newReceiver()
+	r3 := newReceiver()
+	bsnet1.Start(r1)
+	t.Cleanup(bsnet1.Stop)
+	bsnet2.Start(r2)
+	t.Cleanup(bsnet2.Stop)
+	bsnet3.Start(r3)
+	t.Cleanup(bsnet3.Stop)
+
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := bsnet.ProtocolBitswap + "+gzip"
+	h1 := mn.Host(p1.ID())
+	s, err := h1.NewStream(ctx, p2.ID(), compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Reset()
+	if _, err := h1.NewStream(ctx, p3.ID(), compressed); err == nil {
+		t.Fatal("expected p3 not to support compression")
+	}
+
+	blockGenerator := blocksutil.NewBlockGenerator()
+	msg := bsmsg.New(true)
+	for i := 0; i < 100; i++ {
+		msg.AddEntry(blockGenerator.Next().Cid(), 1, pb.Message_Wantlist_Have, true)
+	}
+
+	for _, to := range []struct {
+		id peer.ID
+		r  *receiver
+	}{{p2.ID(), r2}, {p3.ID(), r3}} {
+		if err := bsnet1.SendMessage(ctx, to.id, msg); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("did not receive message sent")
+		case <-to.r.messageReceived:
+		}
+		if len(to.r.lastMessage.Wantlist()) != 100 {
+			t.Fatalf("expected 100 entries, got %d", len(to.r.lastMessage.Wantlist()))
+		}
+	}
+
+	// and back, p3 sends uncompressed messages to p1
+	if err := bsnet3.SendMessage(ctx, p1.ID(), msg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal("did not receive message sent")
+	case <-r1.messageReceived:
+	}
+}
+
 func prepareNetwork(t *testing.T, ctx context.Context, p1 tnet.Identity, r1 *receiver, p2 tnet.Identity, r2 *receiver) (*ErrHost, bsnet.BitSwapNetwork, *ErrHost, bsnet.BitSwapNetwork, bsmsg.BitSwapMessage) {
 	// create network
 	mn := mocknet.New()
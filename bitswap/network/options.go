@@ -1,12 +1,30 @@
 package network
 
-import "github.com/mikelsr/go-libp2p/core/protocol"
+import (
+	"time"
+
+	"github.com/mikelsr/go-libp2p/core/peer"
+	"github.com/mikelsr/go-libp2p/core/protocol"
+)
 
 type NetOpt func(*Settings)
 
 type Settings struct {
 	ProtocolPrefix     protocol.ID
 	SupportedProtocols []protocol.ID
+
+	// PeerAllowlist, if not nil, are the only peers bitswap exchanges with
+	PeerAllowlist []peer.ID
+	// PeerBlocklist are the peers bitswap never exchanges with
+	PeerBlocklist []peer.ID
+
+	// SendTimeout, if not zero, is the time SendMessage has to write a
+	// message to a peer, see WithSendTimeout
+	SendTimeout time.Duration
+
+	// Compressor, if not nil, compresses the messages exchanged with the
+	// peers that support it, see WithMessageCompression
+	Compressor Compressor
 }
 
 func Prefix(prefix protocol.ID) NetOpt {
@@ -20,3 +38,51 @@ func SupportedProtocols(protos []protocol.ID) NetOpt {
 		settings.SupportedProtocols = protos
 	}
 }
+
+// WithPeerAllowlist restricts bitswap to the given peers: messages from other
+// peers are dropped before reaching bitswap, and no messages are sent to them.
+// If a peer is in both the allowlist and the blocklist, the blocklist wins.
+// Like WithPeerBlocklist, it adds to the peers of the previous calls; an
+// allowlist without peers allows none.
+func WithPeerAllowlist(peers []peer.ID) NetOpt {
+	return func(settings *Settings) {
+		if settings.PeerAllowlist == nil {
+			settings.PeerAllowlist = []peer.ID{}
+		}
+		settings.PeerAllowlist = append(settings.PeerAllowlist, peers...)
+	}
+}
+
+// WithPeerBlocklist prevents bitswap from exchanging with the given peers:
+// their messages are dropped before reaching bitswap, and no messages are
+// sent to them. The blocklist takes precedence over the allowlist. Like
+// WithPeerAllowlist, it adds to the peers of the previous calls.
+func WithPeerBlocklist(peers []peer.ID) NetOpt {
+	return func(settings *Settings) {
+		settings.PeerBlocklist = append(settings.PeerBlocklist, peers...)
+	}
+}
+
+// WithSendTimeout sets how long SendMessage may take to write a message to a
+// peer and close the stream. Past this deadline the stream is reset and
+// SendMessage returns ErrSendTimeout, so that a stalled peer can't block the
+// caller. By default the timeout depends on the size of the message.
+func WithSendTimeout(d time.Duration) NetOpt {
+	return func(settings *Settings) {
+		settings.SendTimeout = d
+	}
+}
+
+// WithMessageCompression compresses the messages exchanged with the peers
+// that support the same compression. It is negotiated with a distinct
+// protocol, Bitswap 1.2.0 followed by "+" and the name of the compressor,
+// which is preferred over the other supported protocols. Messages to other
+// peers are sent uncompressed.
+//
+// Messages that compression doesn't make smaller, e.g. the ones made of
+// already compressed blocks, are sent uncompressed.
+func WithMessageCompression(c Compressor) NetOpt {
+	return func(settings *Settings) {
+		settings.Compressor = c
+	}
+}
@@ -3,10 +3,13 @@ package bitswap
 import (
 	"time"
 
+	"github.com/benbjohnson/clock"
+	cid "github.com/ipfs/go-cid"
 	delay "github.com/ipfs/go-ipfs-delay"
 	"github.com/mikelsr/boxo/bitswap/client"
 	"github.com/mikelsr/boxo/bitswap/server"
 	"github.com/mikelsr/boxo/bitswap/tracer"
+	"github.com/mikelsr/go-libp2p/core/peer"
 )
 
 type option func(*Bitswap)
@@ -55,10 +58,26 @@ func WithPeerBlockRequestFilter(pbrf server.PeerBlockRequestFilter) Option {
 	return Option{server.WithPeerBlockRequestFilter(pbrf)}
 }
 
+func WithDisableHaveMessages(pdhm server.PeerDisableHaveMessages) Option {
+	return Option{server.WithDisableHaveMessages(pdhm)}
+}
+
 func WithScoreLedger(scoreLedger server.ScoreLedger) Option {
 	return Option{server.WithScoreLedger(scoreLedger)}
 }
 
+func WithAvailabilityOracle(oracle server.AvailabilityOracle) Option {
+	return Option{server.WithAvailabilityOracle(oracle)}
+}
+
+func WithSendRateWindow(window time.Duration) Option {
+	return Option{server.WithSendRateWindow(window)}
+}
+
+func WithMaxQueuedTaskBytes(max int) Option {
+	return Option{server.WithMaxQueuedTaskBytes(max)}
+}
+
 func WithTargetMessageSize(tms int) Option {
 	return Option{server.WithTargetMessageSize(tms)}
 }
@@ -67,6 +86,10 @@ func WithTaskComparator(comparator server.TaskComparator) Option {
 	return Option{server.WithTaskComparator(comparator)}
 }
 
+func WithReciprocity(enabled bool) Option {
+	return Option{server.WithReciprocity(enabled)}
+}
+
 func ProviderSearchDelay(newProvSearchDelay time.Duration) Option {
 	return Option{client.ProviderSearchDelay(newProvSearchDelay)}
 }
@@ -75,10 +98,49 @@ func RebroadcastDelay(newRebroadcastDelay delay.D) Option {
 	return Option{client.RebroadcastDelay(newRebroadcastDelay)}
 }
 
+func WithNegativeCacheTTL(d time.Duration) Option {
+	return Option{client.WithNegativeCacheTTL(d)}
+}
+
+func WithMaxIncomingBlockSize(n int) Option {
+	return Option{client.WithMaxIncomingBlockSize(n)}
+}
+
+func WithReceiveBufferBytes(n int) Option {
+	return Option{client.WithReceiveBufferBytes(n)}
+}
+
+func WithUnsolicitedBlockCallback(f func(p peer.ID, c cid.Cid, size int)) Option {
+	return Option{client.WithUnsolicitedBlockCallback(f)}
+}
+
+func WithDropUnsolicitedBlocks(drop bool) Option {
+	return Option{client.WithDropUnsolicitedBlocks(drop)}
+}
+
+func WithClock(clk clock.Clock) Option {
+	return Option{client.WithClock(clk)}
+}
+
+func WithBlockRequestStrategy(strategy client.BlockRequestStrategy) Option {
+	return Option{client.WithBlockRequestStrategy(strategy)}
+}
+
 func SetSimulateDontHavesOnTimeout(send bool) Option {
 	return Option{client.SetSimulateDontHavesOnTimeout(send)}
 }
 
+// WithBroadcastLimit limits the number of peers the client broadcasts
+// want-haves to, picking the peers with the best score in the ScoreLedger of
+// the server. See client.WithBroadcastLimit.
+func WithBroadcastLimit(n int) Option {
+	return Option{
+		option(func(bs *Bitswap) {
+			bs.broadcastLimit = n
+		}),
+	}
+}
+
 func WithTracer(tap tracer.Tracer) Option {
 	// Only trace the server, both receive the same messages anyway
 	return Option{
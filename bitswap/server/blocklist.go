@@ -0,0 +1,182 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	cid "github.com/ipfs/go-cid"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	pb "github.com/mikelsr/boxo/bitswap/message/pb"
+	"github.com/mikelsr/boxo/bitswap/tracer"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// Blocklist temporarily blocks the peers that repeatedly request blocks the
+// server doesn't have. It is a Tracer counting the DONT_HAVEs sent to each
+// peer in answer to its want-blocks: a peer sent more than threshold such
+// DONT_HAVEs within a window is denied all block requests for ttl.
+//
+// The DONT_HAVEs answering want-haves are not counted: Bitswap 1.2.0 sessions
+// broadcast want-haves asking for DONT_HAVEs to all their peers while looking
+// for providers, so any peer searching for content the server doesn't have
+// gets many of them. A want-block is only sent to a peer expected to have the
+// block. At most maxTrackedWantBlocks want-blocks are tracked per peer, and
+// they are forgotten once the peer has been idle for a window.
+//
+// To use it, pass it to both WithTracer and WithPeerBlockRequestFilter (with
+// its Filter method).
+type Blocklist struct {
+	threshold int
+	window    time.Duration
+	ttl       time.Duration
+	clock     clock.Clock
+
+	lk        sync.Mutex
+	peers     map[peer.ID]*blocklistEntry
+	lastPrune time.Time
+}
+
+// maxTrackedWantBlocks bounds the number of want-blocks tracked per peer
+const maxTrackedWantBlocks = 1024
+
+type blocklistEntry struct {
+	// misses counted since windowStart
+	misses      int
+	windowStart time.Time
+	// zero if the peer isn't blocked
+	blockedUntil time.Time
+
+	// the want-blocks of the peer that were not answered yet
+	wantBlocks map[cid.Cid]struct{}
+	lastActive time.Time
+}
+
+var _ tracer.Tracer = (*Blocklist)(nil)
+
+// NewBlocklist creates a Blocklist blocking for ttl the peers sent more than
+// threshold DONT_HAVEs within window.
+func NewBlocklist(threshold int, window, ttl time.Duration) *Blocklist {
+	clk := clock.New()
+	return &Blocklist{
+		threshold: threshold,
+		window:    window,
+		ttl:       ttl,
+		clock:     clk,
+		peers:     make(map[peer.ID]*blocklistEntry),
+		lastPrune: clk.Now(),
+	}
+}
+
+// MessageReceived implements Tracer, it records the want-blocks of the peer.
+func (b *Blocklist) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	now := b.clock.Now()
+	b.prune(now)
+
+	e, ok := b.peers[p]
+	if msg.Full() && ok {
+		e.wantBlocks = nil
+	}
+	for _, entry := range msg.Wantlist() {
+		switch {
+		case entry.Cancel:
+			if ok {
+				delete(e.wantBlocks, entry.Cid)
+			}
+		case entry.WantType == pb.Message_Wantlist_Block:
+			if !ok {
+				e = &blocklistEntry{windowStart: now}
+				b.peers[p] = e
+				ok = true
+			}
+			if e.wantBlocks == nil {
+				e.wantBlocks = make(map[cid.Cid]struct{})
+			}
+			if len(e.wantBlocks) < maxTrackedWantBlocks {
+				e.wantBlocks[entry.Cid] = struct{}{}
+			}
+		}
+	}
+	if ok {
+		e.lastActive = now
+	}
+}
+
+// MessageSent implements Tracer, it counts the DONT_HAVEs sent to the peer
+// in answer to its want-blocks.
+func (b *Blocklist) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	now := b.clock.Now()
+	b.prune(now)
+
+	e, ok := b.peers[p]
+	if !ok || len(e.wantBlocks) == 0 {
+		return
+	}
+	e.lastActive = now
+
+	for _, blk := range msg.Blocks() {
+		delete(e.wantBlocks, blk.Cid())
+	}
+	misses := 0
+	for _, c := range msg.DontHaves() {
+		if _, ok := e.wantBlocks[c]; ok {
+			delete(e.wantBlocks, c)
+			misses++
+		}
+	}
+	if misses == 0 {
+		return
+	}
+
+	// the DONT_HAVEs of the denied requests don't extend the block
+	if now.Before(e.blockedUntil) {
+		return
+	}
+	if now.Sub(e.windowStart) >= b.window {
+		e.misses = 0
+		e.windowStart = now
+	}
+
+	e.misses += misses
+	if e.misses > b.threshold {
+		log.Infow("blocking peer requesting missing blocks", "peer", p, "misses", e.misses, "until", now.Add(b.ttl))
+		e.blockedUntil = now.Add(b.ttl)
+		e.misses = 0
+		e.windowStart = e.blockedUntil
+	}
+}
+
+// prune removes the entries of the peers that are neither blocked nor in a
+// window, and have been idle for a window, once per window. b.lk must be held.
+func (b *Blocklist) prune(now time.Time) {
+	if now.Sub(b.lastPrune) < b.window {
+		return
+	}
+	b.lastPrune = now
+
+	for p, e := range b.peers {
+		if !now.Before(e.blockedUntil) && now.Sub(e.windowStart) >= b.window && now.Sub(e.lastActive) >= b.window {
+			delete(b.peers, p)
+		}
+	}
+}
+
+// Blocked returns true if the peer is currently blocked.
+func (b *Blocklist) Blocked(p peer.ID) bool {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	e, ok := b.peers[p]
+	return ok && b.clock.Now().Before(e.blockedUntil)
+}
+
+// Filter is a PeerBlockRequestFilter denying the requests of blocked peers.
+func (b *Blocklist) Filter(p peer.ID, c cid.Cid) bool {
+	return !b.Blocked(p)
+}
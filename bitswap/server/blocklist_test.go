@@ -0,0 +1,149 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/mikelsr/boxo/bitswap/internal/testutil"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	pb "github.com/mikelsr/boxo/bitswap/message/pb"
+	"github.com/mikelsr/go-libp2p/core/peer"
+	libp2ptest "github.com/mikelsr/go-libp2p/core/test"
+)
+
+// sendDontHaves makes the peer send n want-blocks, answered with DONT_HAVEs
+func sendDontHaves(b *Blocklist, p peer.ID, n int) {
+	sendDontHavesFor(b, p, n, pb.Message_Wantlist_Block)
+}
+
+func sendDontHavesFor(b *Blocklist, p peer.ID, n int, wantType pb.Message_Wantlist_WantType) {
+	wants := bsmsg.New(false)
+	dontHaves := bsmsg.New(false)
+	for _, c := range testutil.GenerateCids(n) {
+		wants.AddEntry(c, 1, wantType, true)
+		dontHaves.AddDontHave(c)
+	}
+	b.MessageReceived(p, wants)
+	b.MessageSent(p, dontHaves)
+}
+
+func TestBlocklist(t *testing.T) {
+	clk := clock.NewMock()
+	b := NewBlocklist(5, time.Minute, time.Hour)
+	b.clock = clk
+	b.lastPrune = clk.Now()
+
+	bad := libp2ptest.RandPeerIDFatal(t)
+	good := libp2ptest.RandPeerIDFatal(t)
+	c := testutil.GenerateCids(1)[0]
+
+	// within the threshold
+	sendDontHaves(b, bad, 3)
+	sendDontHaves(b, bad, 2)
+	sendDontHaves(b, good, 5)
+	b.MessageSent(bad, bsmsg.New(false))
+	if b.Blocked(bad) || !b.Filter(bad, c) {
+		t.Fatal("peer should not be blocked within the threshold")
+	}
+
+	// the misses of a past window are forgotten
+	clk.Add(time.Minute)
+	sendDontHaves(b, good, 5)
+	if b.Blocked(good) {
+		t.Fatal("peer should not be blocked by the misses of different windows")
+	}
+
+	sendDontHaves(b, bad, 6)
+	if !b.Blocked(bad) || b.Filter(bad, c) {
+		t.Fatal("peer should be blocked past the threshold")
+	}
+	if b.Blocked(good) || !b.Filter(good, c) {
+		t.Fatal("other peers should not be blocked")
+	}
+
+	// the DONT_HAVEs of the denied requests don't extend the block
+	clk.Add(30 * time.Minute)
+	sendDontHaves(b, bad, 10)
+	clk.Add(30 * time.Minute)
+	if b.Blocked(bad) || !b.Filter(bad, c) {
+		t.Fatal("peer should be unblocked after the TTL")
+	}
+
+	// and the peer can be blocked again
+	sendDontHaves(b, bad, 6)
+	if !b.Blocked(bad) {
+		t.Fatal("peer should be blocked again past the threshold")
+	}
+}
+
+func TestBlocklistIgnoresWantHaves(t *testing.T) {
+	clk := clock.NewMock()
+	b := NewBlocklist(5, time.Minute, time.Hour)
+	b.clock = clk
+	b.lastPrune = clk.Now()
+
+	// a session broadcasting want-haves for content the server doesn't have
+	p := libp2ptest.RandPeerIDFatal(t)
+	sendDontHavesFor(b, p, 100, pb.Message_Wantlist_Have)
+	if b.Blocked(p) {
+		t.Fatal("DONT_HAVEs answering want-haves should not be counted")
+	}
+
+	// unsolicited DONT_HAVEs are not counted either
+	msg := bsmsg.New(false)
+	for _, c := range testutil.GenerateCids(10) {
+		msg.AddDontHave(c)
+	}
+	b.MessageSent(p, msg)
+	if b.Blocked(p) {
+		t.Fatal("DONT_HAVEs without a want-block should not be counted")
+	}
+
+	// nor the DONT_HAVEs for cancelled want-blocks
+	wants := bsmsg.New(false)
+	cancels := bsmsg.New(false)
+	for _, c := range testutil.GenerateCids(10) {
+		wants.AddEntry(c, 1, pb.Message_Wantlist_Block, true)
+		cancels.Cancel(c)
+		msg.AddDontHave(c)
+	}
+	b.MessageReceived(p, wants)
+	b.MessageReceived(p, cancels)
+	b.MessageSent(p, msg)
+	if b.Blocked(p) {
+		t.Fatal("DONT_HAVEs for cancelled want-blocks should not be counted")
+	}
+
+	sendDontHaves(b, p, 6)
+	if !b.Blocked(p) {
+		t.Fatal("peer should be blocked past the threshold of want-block misses")
+	}
+}
+
+func TestBlocklistPrune(t *testing.T) {
+	clk := clock.NewMock()
+	b := NewBlocklist(5, time.Minute, time.Hour)
+	b.clock = clk
+	b.lastPrune = clk.Now()
+
+	bad := libp2ptest.RandPeerIDFatal(t)
+	sendDontHaves(b, bad, 6)
+	for i := 0; i < 10; i++ {
+		sendDontHaves(b, libp2ptest.RandPeerIDFatal(t), 1)
+	}
+
+	clk.Add(2 * time.Minute)
+	sendDontHaves(b, libp2ptest.RandPeerIDFatal(t), 1)
+
+	b.lk.Lock()
+	n := len(b.peers)
+	b.lk.Unlock()
+	// the blocked peer and the last one
+	if n != 2 {
+		t.Fatalf("expected 2 peers to be tracked, got %d", n)
+	}
+	if !b.Blocked(bad) {
+		t.Fatal("blocked peer should not be pruned")
+	}
+}
@@ -5,10 +5,13 @@ import (
 )
 
 type (
-	Receipt                = decision.Receipt
-	PeerBlockRequestFilter = decision.PeerBlockRequestFilter
-	TaskComparator         = decision.TaskComparator
-	TaskInfo               = decision.TaskInfo
-	ScoreLedger            = decision.ScoreLedger
-	ScorePeerFunc          = decision.ScorePeerFunc
+	Receipt                 = decision.Receipt
+	PeerScore               = decision.PeerScore
+	PeerBlockRequestFilter  = decision.PeerBlockRequestFilter
+	PeerDisableHaveMessages = decision.PeerDisableHaveMessages
+	AvailabilityOracle      = decision.AvailabilityOracle
+	TaskComparator          = decision.TaskComparator
+	TaskInfo                = decision.TaskInfo
+	ScoreLedger             = decision.ScoreLedger
+	ScorePeerFunc           = decision.ScorePeerFunc
 )
@@ -147,6 +147,14 @@ type Engine struct {
 
 	tagQueued, tagUseful string
 
+	// the last score given to each peer by the score ledger, see PeerScores
+	scoresLk sync.Mutex
+	scores   map[peer.ID]int
+
+	// the bytes of blocks sent to each peer recently, see PerPeerSendRates
+	sendRateWindow time.Duration
+	sendRates      *sendRates
+
 	lock sync.RWMutex // protects the fields immediately below
 
 	// peerLedger saves which peers are waiting for a Cid
@@ -181,14 +189,32 @@ type Engine struct {
 	metricUpdateCounter int
 
 	taskComparator TaskComparator
+	// when set, the peers that sent us more than we sent them are served
+	// first, see WithReciprocity
+	reciprocity bool
+	// the net contributions of the peers with queued tasks, nil unless
+	// reciprocity is set
+	contributions *contributionSnapshot
 
 	peerBlockRequestFilter PeerBlockRequestFilter
 
+	disableHaveMessages PeerDisableHaveMessages
+
+	// when set, answers want-haves instead of the blockstore, and blocks are
+	// never sent, see WithAvailabilityOracle
+	availabilityOracle AvailabilityOracle
+
 	bstoreWorkerCount          int
 	maxOutstandingBytesPerPeer int
 
 	maxQueuedWantlistEntriesPerPeer uint
 	maxCidSize                      uint
+
+	// bounds the work of the queued tasks, nil if unbounded, see
+	// WithMaxQueuedTaskBytes
+	maxQueuedTaskBytes  int
+	taskBudget          *taskBudget
+	droppedTasksCounter metrics.Counter
 }
 
 // TaskInfo represents the details of a request from a peer.
@@ -204,16 +230,116 @@ type TaskInfo struct {
 	BlockSize int
 	// Whether the block was found
 	HaveBlock bool
+	// The priority of the want, as sent by the peer
+	Priority int
 }
 
 // TaskComparator is used for task prioritization.
 // It should return true if task 'ta' has higher priority than task 'tb'
 type TaskComparator func(ta, tb *TaskInfo) bool
 
+// LocalFirstTaskComparator returns a TaskComparator that orders the tasks for
+// the blocks that are available ahead of the tasks for the blocks that are
+// not, which are only answered with a DONT_HAVE, so that the peers get the
+// blocks and HAVEs first. The blocks are available when they are in the
+// blockstore, or, with an AvailabilityOracle, when it says so.
+// The tasks that are both for available blocks, or both not, are ordered by
+// next, or if it is nil by the priority of the wants of the same peer.
+func LocalFirstTaskComparator(next TaskComparator) TaskComparator {
+	return func(ta, tb *TaskInfo) bool {
+		if ta.HaveBlock != tb.HaveBlock {
+			return ta.HaveBlock
+		}
+		if next != nil {
+			return next(ta, tb)
+		}
+		return ta.Peer == tb.Peer && ta.Priority > tb.Priority
+	}
+}
+
+// reciprocityPeerComparator returns a PeerComparator that serves the peers
+// that have sent us more bytes than we have sent them ahead of the other
+// peers, the largest net contribution first. The peers with the same
+// contribution, such as the peers that haven't sent us anything, and the peers
+// without pending tasks are ordered by next.
+//
+// The contributions are those of the snapshot, which is only updated when
+// tasks are pushed for a peer, so the order is approximate but doesn't change
+// between the updates of the queue.
+func reciprocityPeerComparator(contributions *contributionSnapshot, next peertracker.PeerComparator) peertracker.PeerComparator {
+	return func(pa, pb *peertracker.PeerTracker) bool {
+		if pa.Stats().NumPending == 0 || pb.Stats().NumPending == 0 {
+			return next(pa, pb)
+		}
+		ca, cb := contributions.get(pa.Target()), contributions.get(pb.Target())
+		if ca != cb {
+			return ca > cb
+		}
+		return next(pa, pb)
+	}
+}
+
+// contributionSnapshot holds the net contributions of the peers with queued
+// tasks, taken from the score ledger when tasks are pushed for them, so that
+// the ledger isn't queried on every comparison of the peers.
+type contributionSnapshot struct {
+	lk sync.RWMutex
+	m  map[peer.ID]uint64
+}
+
+func newContributionSnapshot() *contributionSnapshot {
+	return &contributionSnapshot{m: make(map[peer.ID]uint64)}
+}
+
+// update records the net contribution of the peer according to the ledger
+func (s *contributionSnapshot) update(ledger ScoreLedger, p peer.ID) {
+	c := netContribution(ledger, p)
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if c == 0 {
+		delete(s.m, p)
+	} else {
+		s.m[p] = c
+	}
+}
+
+func (s *contributionSnapshot) get(p peer.ID) uint64 {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	return s.m[p]
+}
+
+func (s *contributionSnapshot) remove(p peer.ID) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	delete(s.m, p)
+}
+
+// netContribution returns how many more bytes the peer sent us than we sent
+// it, 0 if it didn't send more
+func netContribution(ledger ScoreLedger, p peer.ID) uint64 {
+	r := ledger.GetReceipt(p)
+	if r == nil || r.Recv <= r.Sent {
+		return 0
+	}
+	return r.Recv - r.Sent
+}
+
 // PeerBlockRequestFilter is used to accept / deny requests for a CID coming from a PeerID
 // It should return true if the request should be fullfilled.
 type PeerBlockRequestFilter func(p peer.ID, c cid.Cid) bool
 
+// PeerDisableHaveMessages is used to select the peers that must never be sent
+// HAVE messages, for example simple clients that don't understand them.
+// It should return true if only blocks and DONT_HAVEs should be sent to the peer.
+type PeerDisableHaveMessages func(p peer.ID) bool
+
+// AvailabilityOracle tells whether a CID is available in the network, for
+// engines that don't serve blocks themselves. It should return true to answer
+// a want-have for the CID with a HAVE.
+type AvailabilityOracle func(c cid.Cid) bool
+
 type Option func(*Engine)
 
 func WithTaskComparator(comparator TaskComparator) Option {
@@ -222,12 +348,43 @@ func WithTaskComparator(comparator TaskComparator) Option {
 	}
 }
 
+// WithReciprocity makes the engine serve the peers that have sent us more
+// bytes than we have sent them first, the largest net contribution first, as
+// accounted by the score ledger when their wants are received. The peers with
+// the same contribution are served as without reciprocity: by the comparator
+// set with WithTaskComparator if any, or by the default peer fairness.
+func WithReciprocity(enabled bool) Option {
+	return func(e *Engine) {
+		e.reciprocity = enabled
+	}
+}
+
 func WithPeerBlockRequestFilter(pbrf PeerBlockRequestFilter) Option {
 	return func(e *Engine) {
 		e.peerBlockRequestFilter = pbrf
 	}
 }
 
+// WithDisableHaveMessages configures the peers that are sent the full block
+// instead of a HAVE in response to a want-have.
+func WithDisableHaveMessages(pdhm PeerDisableHaveMessages) Option {
+	return func(e *Engine) {
+		e.disableHaveMessages = pdhm
+	}
+}
+
+// WithAvailabilityOracle makes the engine answer want-haves with the oracle
+// instead of the blockstore, and never send blocks: want-blocks are answered
+// with a DONT_HAVE. This turns the node into a beacon announcing the content
+// available in the network, e.g. for indexers that don't store the data.
+// The peers that must not be sent HAVEs, see WithDisableHaveMessages, only
+// get DONT_HAVEs.
+func WithAvailabilityOracle(oracle AvailabilityOracle) Option {
+	return func(e *Engine) {
+		e.availabilityOracle = oracle
+	}
+}
+
 func WithTargetMessageSize(size int) Option {
 	return func(e *Engine) {
 		e.targetMessageSize = size
@@ -288,6 +445,32 @@ func WithMaxCidSize(n uint) Option {
 	}
 }
 
+// WithSendRateWindow sets the duration of the sliding window over which the
+// send rates returned by PerPeerSendRates are computed.
+func WithSendRateWindow(window time.Duration) Option {
+	if window <= 0 {
+		panic(fmt.Sprintf("send rate window is %s but must be > 0", window))
+	}
+	return func(e *Engine) {
+		e.sendRateWindow = window
+	}
+}
+
+// WithMaxQueuedTaskBytes bounds the total size in bytes of the tasks waiting
+// in the request queue, summed over all the peers. Once it is reached, the
+// queued tasks of lower scoring peers are evicted to make room for the new
+// tasks of a peer, and the new tasks that still don't fit are dropped. Both
+// count as dropped tasks. The wants of the evicted tasks are removed from the
+// wantlists of their peers. Setting it to 0 disables the limit.
+func WithMaxQueuedTaskBytes(max int) Option {
+	if max < 0 {
+		panic(fmt.Sprintf("max queued task bytes is %d but must be >= 0", max))
+	}
+	return func(e *Engine) {
+		e.maxQueuedTaskBytes = max
+	}
+}
+
 func WithSetSendDontHave(send bool) Option {
 	return func(e *Engine) {
 		e.sendDontHaves = send
@@ -305,6 +488,7 @@ func wrapTaskComparator(tc TaskComparator) peertask.QueueTaskComparator {
 			SendDontHave: taskDataA.SendDontHave,
 			BlockSize:    taskDataA.BlockSize,
 			HaveBlock:    taskDataA.HaveBlock,
+			Priority:     a.Priority,
 		}
 		taskDataB := b.Task.Data.(*taskData)
 		taskInfoB := &TaskInfo{
@@ -314,6 +498,7 @@ func wrapTaskComparator(tc TaskComparator) peertask.QueueTaskComparator {
 			SendDontHave: taskDataB.SendDontHave,
 			BlockSize:    taskDataB.BlockSize,
 			HaveBlock:    taskDataB.HaveBlock,
+			Priority:     b.Priority,
 		}
 		return tc(taskInfoA, taskInfoB)
 	}
@@ -352,6 +537,8 @@ func newEngine(
 		bstoreWorkerCount:               defaults.BitswapEngineBlockstoreWorkerCount,
 		maxOutstandingBytesPerPeer:      defaults.BitswapMaxOutstandingBytesPerPeer,
 		peerTagger:                      peerTagger,
+		scores:                          make(map[peer.ID]int),
+		sendRateWindow:                  defaultSendRateWindow,
 		outbox:                          make(chan (<-chan *Envelope), outboxChanBuffer),
 		workSignal:                      make(chan struct{}, 1),
 		ticker:                          time.NewTicker(time.Millisecond * 100),
@@ -373,6 +560,11 @@ func newEngine(
 		opt(e)
 	}
 
+	e.sendRates = newSendRates(e.sendRateWindow)
+	if e.maxQueuedTaskBytes > 0 {
+		e.taskBudget = newTaskBudget(e.maxQueuedTaskBytes)
+		e.droppedTasksCounter = bmetrics.DroppedTasksCounter(ctx)
+	}
 	e.bsm = newBlockstoreManager(bs, e.bstoreWorkerCount, bmetrics.PendingBlocksGauge(ctx), bmetrics.ActiveBlocksGauge(ctx))
 
 	// default peer task queue options
@@ -384,11 +576,17 @@ func newEngine(
 		peertaskqueue.MaxOutstandingWorkPerPeer(e.maxOutstandingBytesPerPeer),
 	}
 
+	peerComparator := peertracker.DefaultPeerComparator
 	if e.taskComparator != nil {
 		queueTaskComparator := wrapTaskComparator(e.taskComparator)
-		peerTaskQueueOpts = append(peerTaskQueueOpts, peertaskqueue.PeerComparator(peertracker.TaskPriorityPeerComparator(queueTaskComparator)))
+		peerComparator = peertracker.TaskPriorityPeerComparator(queueTaskComparator)
 		peerTaskQueueOpts = append(peerTaskQueueOpts, peertaskqueue.TaskComparator(queueTaskComparator))
 	}
+	if e.reciprocity {
+		e.contributions = newContributionSnapshot()
+		peerComparator = reciprocityPeerComparator(e.contributions, peerComparator)
+	}
+	peerTaskQueueOpts = append(peerTaskQueueOpts, peertaskqueue.PeerComparator(peerComparator))
 
 	e.peerRequestQueue = peertaskqueue.New(peerTaskQueueOpts...)
 
@@ -423,6 +621,14 @@ func (e *Engine) SetSendDontHaves(send bool) {
 // implementation.
 func (e *Engine) startScoreLedger(px process.Process) {
 	e.scoreLedger.Start(func(p peer.ID, score int) {
+		e.scoresLk.Lock()
+		if score == 0 {
+			delete(e.scores, p)
+		} else {
+			e.scores[p] = score
+		}
+		e.scoresLk.Unlock()
+
 		if score == 0 {
 			e.peerTagger.UntagPeer(p, e.tagUseful)
 		} else {
@@ -465,6 +671,72 @@ func (e *Engine) onPeerAdded(p peer.ID) {
 
 func (e *Engine) onPeerRemoved(p peer.ID) {
 	e.peerTagger.UntagPeer(p, e.tagQueued)
+	if e.taskBudget != nil {
+		e.taskBudget.clear(p)
+	}
+	if e.contributions != nil {
+		e.contributions.remove(p)
+	}
+}
+
+// pushTasks queues the tasks for the peer, evicting the queued tasks of lower
+// scoring peers and dropping the tasks that still don't fit in the task
+// budget if there is one. Returns false if all were dropped.
+func (e *Engine) pushTasks(p peer.ID, tasks ...peertask.Task) bool {
+	if e.taskBudget != nil {
+		var dropped int
+		var evicted []evictedTask
+		tasks, dropped, evicted = e.taskBudget.admit(p, tasks, e.ScoreForPeer)
+		for _, ev := range evicted {
+			e.peerRequestQueue.Remove(ev.topic, ev.peer)
+		}
+		if len(evicted) > 0 {
+			// the wants of the evicted tasks are forgotten, as if the peers
+			// had cancelled them, so that they aren't served later: the
+			// peers want them again when they rebroadcast their wantlist
+			e.lock.Lock()
+			for _, ev := range evicted {
+				e.peerLedger.CancelWant(ev.peer, ev.topic)
+			}
+			e.lock.Unlock()
+		}
+		if len(evicted) > 0 {
+			log.Debugw("Bitswap engine: task budget exhausted, evicting tasks of lower scoring peers", "local", e.self, "remote", p, "count", len(evicted))
+		}
+		if dropped > 0 {
+			log.Debugw("Bitswap engine: task budget exhausted, dropping tasks", "local", e.self, "remote", p, "count", dropped)
+		}
+		if n := dropped + len(evicted); n > 0 {
+			e.droppedTasksCounter.Add(float64(n))
+		}
+		if len(tasks) == 0 {
+			return false
+		}
+	}
+
+	if e.contributions != nil {
+		e.contributions.update(e.scoreLedger, p)
+	}
+	e.peerRequestQueue.PushTasksTruncated(e.maxQueuedWantlistEntriesPerPeer, p, tasks...)
+	e.updateMetrics()
+	return true
+}
+
+// ScoreForPeer returns the last score given to the peer by the score ledger,
+// 0 if it has none.
+func (e *Engine) ScoreForPeer(p peer.ID) int {
+	e.scoresLk.Lock()
+	defer e.scoresLk.Unlock()
+	return e.scores[p]
+}
+
+// DroppedTasks returns the number of tasks dropped because the queued tasks
+// had reached the size set with WithMaxQueuedTaskBytes.
+func (e *Engine) DroppedTasks() uint64 {
+	if e.taskBudget == nil {
+		return 0
+	}
+	return e.taskBudget.droppedTasks()
 }
 
 // WantlistForPeer returns the list of keys that the given peer has asked for
@@ -480,6 +752,64 @@ func (e *Engine) LedgerForPeer(p peer.ID) *Receipt {
 	return e.scoreLedger.GetReceipt(p)
 }
 
+// PeerScore is the current score of a peer, as given by the ScoreLedger, and
+// the summary of its ledger.
+type PeerScore struct {
+	Peer    peer.ID
+	Score   int
+	Receipt *Receipt
+}
+
+// PeerLister is implemented by the ScoreLedgers that can list the peers they
+// keep a ledger for, like the DefaultScoreLedger.
+type PeerLister interface {
+	Peers() []peer.ID
+}
+
+// PeerScores returns the current score and ledger summary of the peers with a
+// score, with an active session, or known by the ScoreLedger if it is a
+// PeerLister. It is meant for debugging, and is safe to call while the engine
+// runs.
+func (e *Engine) PeerScores() []PeerScore {
+	e.scoresLk.Lock()
+	scores := make(map[peer.ID]int, len(e.scores))
+	for p, score := range e.scores {
+		scores[p] = score
+	}
+	e.scoresLk.Unlock()
+
+	peers := make(map[peer.ID]struct{}, len(scores))
+	for p := range scores {
+		peers[p] = struct{}{}
+	}
+	for _, p := range e.Peers() {
+		peers[p] = struct{}{}
+	}
+	if pl, ok := e.scoreLedger.(PeerLister); ok {
+		for _, p := range pl.Peers() {
+			peers[p] = struct{}{}
+		}
+	}
+
+	dump := make([]PeerScore, 0, len(peers))
+	for p := range peers {
+		dump = append(dump, PeerScore{
+			Peer:    p,
+			Score:   scores[p],
+			Receipt: e.scoreLedger.GetReceipt(p),
+		})
+	}
+	return dump
+}
+
+// PerPeerSendRates returns the current rate in bytes per second at which
+// blocks are sent to each peer, averaged over the send rate window (see
+// WithSendRateWindow). Peers that weren't sent any block during the window
+// are omitted. It is safe to call while the engine runs.
+func (e *Engine) PerPeerSendRates() map[peer.ID]float64 {
+	return e.sendRates.rates()
+}
+
 // Each taskWorker pulls items off the request queue up to the maximum size
 // and adds them to an envelope that is passed off to the bitswap workers,
 // which send the message to the network.
@@ -538,6 +868,9 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 				e.updateMetrics()
 			}
 		}
+		if e.taskBudget != nil {
+			e.taskBudget.popped(p, nextTasks)
+		}
 
 		// Create a new message
 		msg := bsmsg.New(false)
@@ -582,6 +915,15 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 				if t.SendDontHave {
 					msg.AddDontHave(c)
 				}
+			} else if t.isRange() {
+				// Only a range of the block was requested
+				data := blk.RawData()
+				start := t.RangeOffset
+				if start > uint64(len(data)) {
+					start = uint64(len(data))
+				}
+				end := start + uint64(t.rangeSize())
+				msg.AddBlockRange(c, t.RangeOffset, data[start:end])
 			} else {
 				// Add the block to the message
 				// log.Debugf("  make evlp %s->%s block: %s (%d bytes)", e.self, p, c, len(blk.RawData()))
@@ -663,10 +1005,14 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 	for _, entry := range wants {
 		wantKs.Add(entry.Cid)
 	}
-	blockSizes, err := e.bsm.getBlockSizes(ctx, wantKs.Keys())
-	if err != nil {
-		log.Info("aborting message processing", err)
-		return
+	var blockSizes map[cid.Cid]int
+	if e.availabilityOracle == nil {
+		var err error
+		blockSizes, err = e.bsm.getBlockSizes(ctx, wantKs.Keys())
+		if err != nil {
+			log.Info("aborting message processing", err)
+			return
+		}
 	}
 
 	e.lock.Lock()
@@ -700,7 +1046,11 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 			continue
 		}
 
-		e.peerLedger.Wants(p, entry.Entry)
+		if entry.IsRange() {
+			e.peerLedger.WantsRange(p, entry.Entry, entry.RangeOffset, entry.RangeLength)
+		} else {
+			e.peerLedger.Wants(p, entry.Entry)
+		}
 		filteredWants = append(filteredWants, entry)
 	}
 	clear := wants[len(filteredWants):]
@@ -721,8 +1071,18 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 		}
 
 		log.Debugw("Bitswap engine <- cancel", "local", e.self, "from", p, "cid", entry.Cid)
-		if e.peerLedger.CancelWant(p, entry.Cid) {
+		cancelled := false
+		if entry.IsRange() {
+			// A cancel for a range leaves a want for the whole block
+			cancelled = e.peerLedger.CancelRangeWant(p, entry.Cid, entry.RangeOffset, entry.RangeLength)
+		} else {
+			cancelled = e.peerLedger.CancelWant(p, entry.Cid)
+		}
+		if cancelled {
 			e.peerRequestQueue.Remove(entry.Cid, p)
+			if e.taskBudget != nil {
+				e.taskBudget.remove(p, entry.Cid)
+			}
 		}
 	}
 	e.lock.Unlock()
@@ -764,6 +1124,32 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 	// For each want-have / want-block
 	for _, entry := range wants {
 		c := entry.Cid
+
+		if e.availabilityOracle != nil {
+			// the peers that must not be sent HAVEs can't be answered
+			// either, as blocks are never sent
+			if entry.WantType != pb.Message_Wantlist_Have || e.haveMessagesDisabled(p) || !e.availabilityOracle(c) {
+				log.Debugw("Bitswap engine: not available", "local", e.self, "from", p, "cid", entry.Cid, "sendDontHave", entry.SendDontHave)
+				sendDontHave(entry)
+				continue
+			}
+
+			log.Debugw("Bitswap engine: available", "local", e.self, "from", p, "cid", entry.Cid)
+			newWorkExists = true
+			activeEntries = append(activeEntries, peertask.Task{
+				Topic:    c,
+				Priority: int(entry.Priority),
+				Work:     bsmsg.BlockPresenceSize(c),
+				Data: &taskData{
+					BlockSize:    0,
+					HaveBlock:    true,
+					IsWantBlock:  false,
+					SendDontHave: entry.SendDontHave,
+				},
+			})
+			continue
+		}
+
 		blockSize, found := blockSizes[entry.Cid]
 
 		// If the block was not found
@@ -774,7 +1160,7 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 			// The block was found, add it to the queue
 			newWorkExists = true
 
-			isWantBlock := e.sendAsBlock(entry.WantType, blockSize)
+			isWantBlock := e.sendAsBlock(p, entry.WantType, blockSize)
 
 			log.Debugw("Bitswap engine: block found", "local", e.self, "from", p, "cid", entry.Cid, "isWantBlock", isWantBlock)
 
@@ -782,28 +1168,35 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 			// message we send to the recipient. If we're sending a block, the
 			// entrySize is the size of the block. Otherwise it's the size of
 			// a block presence entry.
+			td := &taskData{
+				BlockSize:    blockSize,
+				HaveBlock:    true,
+				IsWantBlock:  isWantBlock,
+				SendDontHave: entry.SendDontHave,
+			}
+			if isWantBlock && entry.IsRange() {
+				td.RangeOffset = entry.RangeOffset
+				td.RangeLength = entry.RangeLength
+			}
+
 			entrySize := blockSize
 			if !isWantBlock {
 				entrySize = bsmsg.BlockPresenceSize(c)
+			} else if td.isRange() {
+				entrySize = td.rangeSize()
 			}
 			activeEntries = append(activeEntries, peertask.Task{
 				Topic:    c,
 				Priority: int(entry.Priority),
 				Work:     entrySize,
-				Data: &taskData{
-					BlockSize:    blockSize,
-					HaveBlock:    true,
-					IsWantBlock:  isWantBlock,
-					SendDontHave: entry.SendDontHave,
-				},
+				Data:     td,
 			})
 		}
 	}
 
 	// Push entries onto the request queue
-	if len(activeEntries) > 0 {
-		e.peerRequestQueue.PushTasksTruncated(e.maxQueuedWantlistEntriesPerPeer, p, activeEntries...)
-		e.updateMetrics()
+	if len(activeEntries) > 0 && !e.pushTasks(p, activeEntries...) {
+		newWorkExists = false
 	}
 	return false
 }
@@ -859,7 +1252,8 @@ func (e *Engine) ReceivedBlocks(from peer.ID, blks []blocks.Block) {
 // NotifyNewBlocks is called when new blocks becomes available locally, and in particular when the caller of bitswap
 // decide to store those blocks and make them available on the network.
 func (e *Engine) NotifyNewBlocks(blks []blocks.Block) {
-	if len(blks) == 0 {
+	// blocks are never served with an availability oracle
+	if len(blks) == 0 || e.availabilityOracle != nil {
 		return
 	}
 
@@ -879,17 +1273,15 @@ func (e *Engine) NotifyNewBlocks(blks []blocks.Block) {
 		e.lock.RUnlock()
 
 		for _, entry := range peers {
-			work = true
-
 			blockSize := blockSizes[k]
-			isWantBlock := e.sendAsBlock(entry.WantType, blockSize)
+			isWantBlock := e.sendAsBlock(entry.Peer, entry.WantType, blockSize)
 
 			entrySize := blockSize
 			if !isWantBlock {
 				entrySize = bsmsg.BlockPresenceSize(k)
 			}
 
-			e.peerRequestQueue.PushTasksTruncated(e.maxQueuedWantlistEntriesPerPeer, entry.Peer, peertask.Task{
+			if e.pushTasks(entry.Peer, peertask.Task{
 				Topic:    k,
 				Priority: int(entry.Priority),
 				Work:     entrySize,
@@ -899,8 +1291,9 @@ func (e *Engine) NotifyNewBlocks(blks []blocks.Block) {
 					IsWantBlock:  isWantBlock,
 					SendDontHave: false,
 				},
-			})
-			e.updateMetrics()
+			}) {
+				work = true
+			}
 		}
 	}
 
@@ -924,9 +1317,17 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) {
 	// Remove sent blocks from the want list for the peer
 	for _, block := range m.Blocks() {
 		e.scoreLedger.AddToSentBytes(p, len(block.RawData()))
+		e.sendRates.add(p, len(block.RawData()))
 		e.peerLedger.CancelWantWithType(p, block.Cid(), pb.Message_Wantlist_Block)
 	}
 
+	// Remove sent block ranges from the want list for the peer
+	for _, br := range m.BlockRanges() {
+		e.scoreLedger.AddToSentBytes(p, len(br.Data))
+		e.sendRates.add(p, len(br.Data))
+		e.peerLedger.CancelWantWithType(p, br.Cid, pb.Message_Wantlist_Block)
+	}
+
 	// Remove sent block presences from the want list for the peer
 	for _, bp := range m.BlockPresences() {
 		// Don't record sent data. We reserve that for data blocks.
@@ -948,19 +1349,36 @@ func (e *Engine) PeerConnected(p peer.ID) {
 // PeerDisconnected is called when a peer disconnects.
 func (e *Engine) PeerDisconnected(p peer.ID) {
 	e.peerRequestQueue.Clear(p)
+	if e.taskBudget != nil {
+		e.taskBudget.clear(p)
+	}
 
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
 	e.peerLedger.PeerDisconnected(p)
 	e.scoreLedger.PeerDisconnected(p)
+	e.sendRates.remove(p)
+
+	e.scoresLk.Lock()
+	delete(e.scores, p)
+	e.scoresLk.Unlock()
 }
 
-// If the want is a want-have, and it's below a certain size, send the full
-// block (instead of sending a HAVE)
-func (e *Engine) sendAsBlock(wantType pb.Message_Wantlist_WantType, blockSize int) bool {
+// If the want is a want-have, and it's below a certain size or the peer
+// must not be sent HAVEs, send the full block (instead of sending a HAVE)
+func (e *Engine) sendAsBlock(p peer.ID, wantType pb.Message_Wantlist_WantType, blockSize int) bool {
 	isWantBlock := wantType == pb.Message_Wantlist_Block
-	return isWantBlock || blockSize <= e.maxBlockSizeReplaceHasWithBlock
+	if isWantBlock || blockSize <= e.maxBlockSizeReplaceHasWithBlock {
+		return true
+	}
+	return e.haveMessagesDisabled(p)
+}
+
+// haveMessagesDisabled returns true if the peer must not be sent HAVEs, see
+// WithDisableHaveMessages
+func (e *Engine) haveMessagesDisabled(p peer.ID) bool {
+	return e.disableHaveMessages != nil && e.disableHaveMessages(p)
 }
 
 func (e *Engine) numBytesSentTo(p peer.ID) uint64 {
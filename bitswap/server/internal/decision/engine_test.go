@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -25,6 +26,8 @@ import (
 	"github.com/mikelsr/boxo/internal/test"
 	peer "github.com/mikelsr/go-libp2p/core/peer"
 	libp2ptest "github.com/mikelsr/go-libp2p/core/test"
+	"github.com/mikelsr/go-peertaskqueue/peertask"
+	"github.com/mikelsr/go-peertaskqueue/peertracker"
 	mh "github.com/multiformats/go-multihash"
 )
 
@@ -1023,6 +1026,143 @@ func TestSendDontHave(t *testing.T) {
 	}
 }
 
+func TestSendBlockRange(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partner := libp2ptest.RandPeerIDFatal(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(NewTestScoreLedger(shortTerm, nil, clock.New())), WithBlockstoreWorkerCount(4))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	blks := testutil.GenerateBlocksOfSize(2, 1024)
+	if err := bs.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New(false)
+	msg.AddRangeEntry(blks[0].Cid(), 2, 100, 200, true)
+	// past the end of the block, only the remaining bytes are sent
+	msg.AddRangeEntry(blks[1].Cid(), 1, 1000, 200, true)
+	e.MessageReceived(ctx, partner, msg)
+
+	_, env := getNextEnvelope(e, nil, time.Second)
+	if env == nil {
+		t.Fatal("expected envelope")
+	}
+	if len(env.Message.Blocks()) != 0 {
+		t.Fatal("expected no full blocks")
+	}
+
+	brs := env.Message.BlockRanges()
+	if len(brs) != 2 {
+		t.Fatalf("expected 2 block ranges, got %d", len(brs))
+	}
+	for _, br := range brs {
+		switch {
+		case br.Cid.Equals(blks[0].Cid()):
+			if br.Offset != 100 || !bytes.Equal(br.Data, blks[0].RawData()[100:300]) {
+				t.Fatal("unexpected range for first block")
+			}
+		case br.Cid.Equals(blks[1].Cid()):
+			if br.Offset != 1000 || !bytes.Equal(br.Data, blks[1].RawData()[1000:]) {
+				t.Fatal("unexpected range for second block")
+			}
+		default:
+			t.Fatal("unexpected block range")
+		}
+	}
+}
+
+func TestCancelBlockRange(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partner := libp2ptest.RandPeerIDFatal(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(NewTestScoreLedger(shortTerm, nil, clock.New())), WithBlockstoreWorkerCount(4))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	blks := testutil.GenerateBlocksOfSize(2, 1024)
+	if err := bs.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+
+	// a range of the first block, the whole second block and then a range
+	// of it
+	msg := message.New(false)
+	msg.AddRangeEntry(blks[0].Cid(), 1, 100, 200, true)
+	msg.AddEntry(blks[1].Cid(), 1, pb.Message_Wantlist_Block, true)
+	e.MessageReceived(ctx, partner, msg)
+	msg = message.New(false)
+	msg.AddRangeEntry(blks[1].Cid(), 1, 100, 200, true)
+	e.MessageReceived(ctx, partner, msg)
+
+	msg = message.New(false)
+	msg.CancelRange(blks[0].Cid(), 100, 200)
+	msg.CancelRange(blks[1].Cid(), 100, 200)
+	e.MessageReceived(ctx, partner, msg)
+
+	e.lock.RLock()
+	wl := e.peerLedger.WantlistForPeer(partner)
+	e.lock.RUnlock()
+	if len(wl) != 1 || !wl[0].Cid.Equals(blks[1].Cid()) {
+		t.Fatalf("expected only the want for the whole second block to be left, got %v", wl)
+	}
+}
+
+func TestDisableHaveMessages(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	simple := libp2ptest.RandPeerIDFatal(t)
+	other := libp2ptest.RandPeerIDFatal(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	disableHaves := func(p peer.ID) bool { return p == simple }
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(NewTestScoreLedger(shortTerm, nil, clock.New())), WithBlockstoreWorkerCount(4), WithDisableHaveMessages(disableHaves))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	// blocks too big to be sent instead of a HAVE
+	blks := testutil.GenerateBlocksOfSize(2, 8*1024)
+	if err := bs.Put(ctx, blks[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New(false)
+	msg.AddEntry(blks[0].Cid(), 2, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(blks[1].Cid(), 1, pb.Message_Wantlist_Have, true)
+
+	// A flagged peer gets the block and a DONT_HAVE
+	e.MessageReceived(ctx, simple, msg)
+	_, env := getNextEnvelope(e, nil, time.Second)
+	if env == nil || env.Peer != simple {
+		t.Fatal("expected envelope to flagged peer")
+	}
+	if len(env.Message.Blocks()) != 1 || !env.Message.Blocks()[0].Cid().Equals(blks[0].Cid()) {
+		t.Fatal("expected block to be sent instead of HAVE")
+	}
+	if len(env.Message.Haves()) != 0 {
+		t.Fatal("expected no HAVE")
+	}
+	if len(env.Message.DontHaves()) != 1 || !env.Message.DontHaves()[0].Equals(blks[1].Cid()) {
+		t.Fatal("expected DONT_HAVE for missing block")
+	}
+	env.Sent()
+
+	// Other peers still get a HAVE
+	e.MessageReceived(ctx, other, msg)
+	_, env = getNextEnvelope(e, nil, time.Second)
+	if env == nil || env.Peer != other {
+		t.Fatal("expected envelope to other peer")
+	}
+	if len(env.Message.Blocks()) != 0 {
+		t.Fatal("expected no block")
+	}
+	if len(env.Message.Haves()) != 1 || !env.Message.Haves()[0].Equals(blks[0].Cid()) {
+		t.Fatal("expected HAVE")
+	}
+}
+
 func TestWantlistForPeer(t *testing.T) {
 	test.Flaky(t)
 
@@ -1118,6 +1258,174 @@ func TestTaskComparator(t *testing.T) {
 	}
 }
 
+func TestLocalFirstTaskComparator(t *testing.T) {
+	cids := testutil.GenerateCids(4)
+	p := libp2ptest.RandPeerIDFatal(t)
+	newTask := func(c cid.Cid, priority int, haveBlock, isWantBlock bool) *peertask.QueueTask {
+		return peertask.NewQueueTask(peertask.Task{
+			Topic:    c,
+			Priority: priority,
+			Data: &taskData{
+				HaveBlock:    haveBlock,
+				IsWantBlock:  isWantBlock,
+				SendDontHave: true,
+			},
+		}, p, time.Now())
+	}
+
+	tasks := []*peertask.QueueTask{
+		newTask(cids[0], 4, false, true),
+		newTask(cids[1], 3, true, false),
+		newTask(cids[2], 2, false, false),
+		newTask(cids[3], 1, true, true),
+	}
+	expected := []cid.Cid{cids[1], cids[3], cids[0], cids[2]}
+
+	compare := wrapTaskComparator(LocalFirstTaskComparator(nil))
+	sort.SliceStable(tasks, func(i, j int) bool { return compare(tasks[i], tasks[j]) })
+	for i, task := range tasks {
+		if task.Topic.(cid.Cid) != expected[i] {
+			t.Fatalf("task %d: expected %s, got %s", i, expected[i], task.Topic)
+		}
+	}
+
+	// the available blocks still come first when next disagrees
+	lowestPriority := func(ta, tb *TaskInfo) bool { return ta.Priority < tb.Priority }
+	compare = wrapTaskComparator(LocalFirstTaskComparator(lowestPriority))
+	sort.SliceStable(tasks, func(i, j int) bool { return compare(tasks[i], tasks[j]) })
+	expected = []cid.Cid{cids[3], cids[1], cids[2], cids[0]}
+	for i, task := range tasks {
+		if task.Topic.(cid.Cid) != expected[i] {
+			t.Fatalf("task %d: expected %s, got %s", i, expected[i], task.Topic)
+		}
+	}
+}
+
+func TestReciprocityPeerComparator(t *testing.T) {
+	cids := testutil.GenerateCids(6)
+	peers := testutil.GeneratePeers(5)
+
+	ledger := NewDefaultScoreLedger()
+	// peers[0] sent us more than we sent it, less than peers[1] did, peers[2]
+	// got more than it sent, peers[3] sent nothing and peers[4] sent the most
+	// but has no pending task
+	ledger.AddToReceivedBytes(peers[0], 100)
+	ledger.AddToSentBytes(peers[0], 50)
+	ledger.AddToReceivedBytes(peers[1], 200)
+	ledger.AddToSentBytes(peers[2], 100)
+	ledger.AddToReceivedBytes(peers[4], 1000)
+
+	contributions := newContributionSnapshot()
+	for _, p := range peers {
+		contributions.update(ledger, p)
+	}
+
+	trackers := make(map[peer.ID]*peertracker.PeerTracker)
+	for _, p := range peers {
+		trackers[p] = peertracker.New(p, &peertracker.DefaultTaskMerger{}, 1<<20)
+	}
+	push := func(p peer.ID, cids ...cid.Cid) {
+		for _, c := range cids {
+			trackers[p].PushTasks(peertask.Task{Topic: c, Priority: 1, Work: 1})
+		}
+	}
+	push(peers[0], cids[0])
+	push(peers[1], cids[1])
+	push(peers[2], cids[2], cids[3])
+	push(peers[3], cids[4])
+
+	// peers[2] and peers[3] contributed the same: the default fairness
+	// serves first the peer with the most pending tasks
+	compare := reciprocityPeerComparator(contributions, peertracker.DefaultPeerComparator)
+	sorted := []*peertracker.PeerTracker{trackers[peers[4]], trackers[peers[3]], trackers[peers[2]], trackers[peers[0]], trackers[peers[1]]}
+	sort.SliceStable(sorted, func(i, j int) bool { return compare(sorted[i], sorted[j]) })
+	expected := []peer.ID{peers[1], peers[0], peers[2], peers[3], peers[4]}
+	for i, pt := range sorted {
+		if pt.Target() != expected[i] {
+			t.Fatalf("peer %d: expected %s, got %s", i, expected[i], pt.Target())
+		}
+	}
+
+	// the snapshot only changes when it is updated
+	ledger.AddToReceivedBytes(peers[3], 500)
+	if c := contributions.get(peers[3]); c != 0 {
+		t.Fatalf("expected the snapshot not to change before an update, got %d", c)
+	}
+	contributions.update(ledger, peers[3])
+	if c := contributions.get(peers[3]); c != 500 {
+		t.Fatalf("expected a contribution of 500 after the update, got %d", c)
+	}
+}
+
+func TestReciprocityServingOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	blks := make([]blocks.Block, 0, len(keys))
+	for _, letter := range keys {
+		blks = append(blks, blocks.NewBlock([]byte(letter)))
+	}
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bs.PutMany(ctx, blks); err != nil {
+		t.Fatal(err)
+	}
+	peers := testutil.GeneratePeers(3)
+
+	// peers[0] wants the most blocks, peers[1] fewer, and peers[2] wants a
+	// single block but is the only one that sent us anything
+	servingOrder := func(reciprocity bool) []peer.ID {
+		ledger := NewDefaultScoreLedger()
+		ledger.AddToReceivedBytes(peers[2], 100)
+
+		// the wants are queued before the task worker starts, so that it
+		// sees all of them, and there is a single worker so that the order
+		// of the messages is deterministic
+		e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithScoreLedger(ledger), WithTaskWorkerCount(1), WithReciprocity(reciprocity))
+		px := process.WithTeardown(func() error { return nil })
+		defer px.Close()
+		workerCtx, stopWorker := context.WithCancel(ctx)
+		defer stopWorker()
+		e.startBlockstoreManager(px)
+		partnerWantBlocks(e, keys[:3], peers[0])
+		partnerWantBlocks(e, keys[3:5], peers[1])
+		partnerWantBlocks(e, keys[5:], peers[2])
+		px.Go(func(process.Process) { e.taskWorker(workerCtx) })
+
+		var order []peer.ID
+		for range peers {
+			var env *Envelope
+			select {
+			case next := <-e.Outbox():
+				select {
+				case env = <-next:
+				case <-ctx.Done():
+					t.Fatal("timed out waiting for a message")
+				}
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for the outbox")
+			}
+			order = append(order, env.Peer)
+			env.Sent()
+		}
+		return order
+	}
+
+	expectOrder := func(got []peer.ID, expected ...peer.ID) {
+		t.Helper()
+		for i, p := range expected {
+			if got[i] != p {
+				t.Fatalf("message %d: expected peer %s, got %s", i, p, got[i])
+			}
+		}
+	}
+
+	// without reciprocity the peers with the most pending tasks come first
+	expectOrder(servingOrder(false), peers[0], peers[1], peers[2])
+	// with it the contributing peer does, then the others as before
+	expectOrder(servingOrder(true), peers[2], peers[0], peers[1])
+}
+
 func TestPeerBlockFilter(t *testing.T) {
 	test.Flaky(t)
 
@@ -1541,6 +1849,309 @@ func TestTaggingUseful(t *testing.T) {
 	}
 }
 
+func TestPeerScores(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sampleCh := make(chan struct{})
+	mockClock := clock.NewMock()
+	me := newTestEngineWithSampling(ctx, "engine", 10*time.Millisecond, sampleCh, mockClock)
+	mockClock.Add(1 * time.Millisecond)
+	friend := peer.ID("friend")
+	stranger := peer.ID("stranger")
+
+	if dump := me.Engine.PeerScores(); len(dump) != 0 {
+		t.Fatalf("expected no peers, got %d", len(dump))
+	}
+
+	me.Engine.PeerConnected(friend)
+	me.Engine.PeerConnected(stranger)
+
+	sent := message.New(false)
+	sent.AddBlock(blocks.NewBlock([]byte("foobar")))
+	me.Engine.MessageSent(friend, sent)
+	me.Engine.ReceivedBlocks(friend, []blocks.Block{blocks.NewBlock([]byte("foo"))})
+
+	// wait for the scores to be computed
+	mockClock.Add(10 * time.Millisecond)
+	select {
+	case <-sampleCh:
+	case <-ctx.Done():
+		t.Fatal("no sample was taken")
+	}
+
+	dump := me.Engine.PeerScores()
+	if len(dump) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(dump))
+	}
+	for _, ps := range dump {
+		switch ps.Peer {
+		case friend:
+			if ps.Score <= 0 {
+				t.Fatalf("expected friend to have a positive score, got %d", ps.Score)
+			}
+			if ps.Receipt.Sent != 6 || ps.Receipt.Recv != 3 || ps.Receipt.Exchanged != 2 {
+				t.Fatalf("unexpected friend receipt %+v", ps.Receipt)
+			}
+		case stranger:
+			if ps.Score != 0 || ps.Receipt.Exchanged != 0 {
+				t.Fatalf("expected stranger to have no activity, got score %d and receipt %+v", ps.Score, ps.Receipt)
+			}
+		default:
+			t.Fatalf("unexpected peer %s", ps.Peer)
+		}
+	}
+	// the score of a peer is dropped when it disconnects
+	if me.Engine.ScoreForPeer(friend) <= 0 {
+		t.Fatal("expected friend to have a score")
+	}
+	me.Engine.PeerDisconnected(friend)
+	if score := me.Engine.ScoreForPeer(friend); score != 0 {
+		t.Fatalf("expected no score for a disconnected peer, got %d", score)
+	}
+	for _, ps := range me.Engine.PeerScores() {
+		if ps.Peer == friend {
+			t.Fatal("expected a disconnected peer not to be listed")
+		}
+	}
+}
+
+func TestPerPeerSendRates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithSendRateWindow(2*time.Second))
+	friend := peer.ID("friend")
+
+	if rates := e.PerPeerSendRates(); len(rates) != 0 {
+		t.Fatalf("expected no rates, got %v", rates)
+	}
+
+	e.PeerConnected(friend)
+	sent := message.New(false)
+	sent.AddBlock(blocks.NewBlock([]byte("foobar")))
+	e.MessageSent(friend, sent)
+
+	rates := e.PerPeerSendRates()
+	if len(rates) != 1 || rates[friend] != 3 {
+		t.Fatalf("expected a rate of 3B/s for friend, got %v", rates)
+	}
+
+	e.PeerDisconnected(friend)
+	if rates := e.PerPeerSendRates(); len(rates) != 0 {
+		t.Fatalf("expected no rates after the peer disconnected, got %v", rates)
+	}
+}
+
+func TestAvailabilityOracle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	local := blocks.NewBlock([]byte("local"))
+	available := blocks.NewBlock([]byte("available"))
+	missing := blocks.NewBlock([]byte("missing"))
+	wanted := blocks.NewBlock([]byte("wanted"))
+
+	// the blockstore has a block the oracle doesn't know about, it must be
+	// ignored
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := bs.Put(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+
+	oracle := func(c cid.Cid) bool {
+		return c.Equals(available.Cid()) || c.Equals(wanted.Cid())
+	}
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithAvailabilityOracle(oracle))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+	partner := libp2ptest.RandPeerIDFatal(t)
+
+	msg := message.New(false)
+	msg.AddEntry(available.Cid(), 3, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(missing.Cid(), 2, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(local.Cid(), 1, pb.Message_Wantlist_Have, true)
+	msg.AddEntry(wanted.Cid(), 1, pb.Message_Wantlist_Block, true)
+	e.MessageReceived(ctx, partner, msg)
+
+	haves := make(map[cid.Cid]struct{})
+	dontHaves := make(map[cid.Cid]struct{})
+	for len(haves)+len(dontHaves) < 4 {
+		_, env := getNextEnvelope(e, nil, time.Second)
+		if env == nil {
+			t.Fatalf("expected 4 block presences, got %d", len(haves)+len(dontHaves))
+		}
+		if len(env.Message.Blocks()) != 0 {
+			t.Fatal("expected no block to be sent")
+		}
+		for _, c := range env.Message.Haves() {
+			haves[c] = struct{}{}
+		}
+		for _, c := range env.Message.DontHaves() {
+			dontHaves[c] = struct{}{}
+		}
+		env.Sent()
+	}
+
+	if _, ok := haves[available.Cid()]; !ok || len(haves) != 1 {
+		t.Fatalf("expected a HAVE only for the available block, got %v", haves)
+	}
+	if _, ok := dontHaves[missing.Cid()]; !ok {
+		t.Fatal("expected a DONT_HAVE for the missing block")
+	}
+	if _, ok := dontHaves[local.Cid()]; !ok {
+		t.Fatal("expected a DONT_HAVE for the local block")
+	}
+	if _, ok := dontHaves[wanted.Cid()]; !ok {
+		t.Fatal("expected a DONT_HAVE for the want-block")
+	}
+
+	// new blocks are not sent either
+	e.NotifyNewBlocks([]blocks.Block{wanted})
+	if _, env := getNextEnvelope(e, nil, 100*time.Millisecond); env != nil && len(env.Message.Blocks()) != 0 {
+		t.Fatal("expected no block to be sent")
+	}
+
+	// a peer that must not be sent HAVEs gets a DONT_HAVE for an available
+	// block, as it can't be sent either
+	simple := libp2ptest.RandPeerIDFatal(t)
+	e = newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithAvailabilityOracle(oracle),
+		WithDisableHaveMessages(func(p peer.ID) bool { return p == simple }))
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	msg = message.New(false)
+	msg.AddEntry(available.Cid(), 1, pb.Message_Wantlist_Have, true)
+	e.MessageReceived(ctx, simple, msg)
+
+	_, env := getNextEnvelope(e, nil, time.Second)
+	if env == nil {
+		t.Fatal("expected a block presence")
+	}
+	if len(env.Message.Haves()) != 0 || len(env.Message.Blocks()) != 0 {
+		t.Fatal("expected no HAVE nor block for a peer with HAVEs disabled")
+	}
+	if dh := env.Message.DontHaves(); len(dh) != 1 || !dh[0].Equals(available.Cid()) {
+		t.Fatalf("expected a DONT_HAVE for the available block, got %v", dh)
+	}
+}
+
+func TestMaxQueuedTaskBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 100 bytes blocks
+	var keys []string
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for _, letter := range strings.Split("abcdefg", "") {
+		k := strings.Repeat(letter, 100)
+		keys = append(keys, k)
+		if err := bs.Put(ctx, blocks.NewBlock([]byte(k))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the task workers are not started so that the tasks stay queued
+	e := newEngineForTesting(ctx, bs, &fakePeerTagger{}, "localhost", 0, WithMaxQueuedTaskBytes(250))
+	e.startBlockstoreManager(process.WithTeardown(func() error { return nil }))
+	low := libp2ptest.RandPeerIDFatal(t)
+	high := libp2ptest.RandPeerIDFatal(t)
+	e.scores[high] = 10
+
+	pending := func(p peer.ID) int {
+		topics := e.peerRequestQueue.PeerTopics(p)
+		if topics == nil {
+			return 0
+		}
+		return len(topics.Pending)
+	}
+
+	// only two blocks fit
+	partnerWantBlocks(e, keys[:4], low)
+	if n := pending(low); n != 2 {
+		t.Fatalf("expected 2 queued tasks for the low scoring peer, got %d", n)
+	}
+	if n := e.DroppedTasks(); n != 2 {
+		t.Fatalf("expected 2 dropped tasks, got %d", n)
+	}
+
+	// the higher scoring peer is still served, evicting the tasks of the
+	// lower scoring peer to stay within the budget
+	partnerWantBlocks(e, keys[4:6], high)
+	if n := pending(high); n != 2 {
+		t.Fatalf("expected 2 queued tasks for the high scoring peer, got %d", n)
+	}
+	if n := pending(low); n != 0 {
+		t.Fatalf("expected the tasks of the low scoring peer to be evicted, got %d", n)
+	}
+	// and their wants to be forgotten, only the wants of the dropped tasks
+	// are left
+	if n := len(e.WantlistForPeer(low)); n != 2 {
+		t.Fatalf("expected the wants of the evicted tasks to be removed, %d wants left", n)
+	}
+	if n := e.DroppedTasks(); n != 4 {
+		t.Fatalf("expected 4 dropped tasks, got %d", n)
+	}
+
+	// the lower scoring peer can't evict the tasks of the higher scoring one
+	partnerWantBlocks(e, keys[6:], low)
+	if n := pending(low); n != 0 {
+		t.Fatalf("expected no queued task for the low scoring peer, got %d", n)
+	}
+	if n := e.DroppedTasks(); n != 5 {
+		t.Fatalf("expected 5 dropped tasks, got %d", n)
+	}
+
+	// cancelling frees some room
+	partnerCancels(e, keys[4:6], high)
+	partnerWantBlocks(e, keys[6:], low)
+	if n := pending(low); n != 1 {
+		t.Fatalf("expected 1 queued task for the low scoring peer, got %d", n)
+	}
+	if n := e.DroppedTasks(); n != 5 {
+		t.Fatalf("expected 5 dropped tasks, got %d", n)
+	}
+}
+
+func TestTaskBudgetNeverExceedsMax(t *testing.T) {
+	peers := testutil.GeneratePeers(3)
+	scores := map[peer.ID]int{peers[0]: 1, peers[1]: 2, peers[2]: 3}
+	score := func(p peer.ID) int { return scores[p] }
+	tb := newTaskBudget(500)
+
+	var keys []cid.Cid
+	for i := 0; i < 20; i++ {
+		keys = append(keys, blocks.NewBlock([]byte(fmt.Sprint(i))).Cid())
+	}
+	tasks := func(ks []cid.Cid) []peertask.Task {
+		var ts []peertask.Task
+		for _, c := range ks {
+			ts = append(ts, peertask.Task{Topic: c, Work: 100})
+		}
+		return ts
+	}
+
+	// every peer outranks the previous one, the total stays within the budget
+	for i, p := range peers {
+		admitted, _, evicted := tb.admit(p, tasks(keys[i*5:i*5+5]), score)
+		if len(admitted) != 5 {
+			t.Fatalf("expected all the tasks of peer %d to be admitted, got %d", i, len(admitted))
+		}
+		for _, ev := range evicted {
+			if scores[ev.peer] >= scores[p] {
+				t.Fatalf("evicted a task of a peer with a score not lower than %d", scores[p])
+			}
+		}
+		if tb.total > tb.max {
+			t.Fatalf("total %d exceeds the budget %d", tb.total, tb.max)
+		}
+	}
+
+	// a task larger than the budget is never admitted
+	admitted, dropped, evicted := tb.admit(peers[2], []peertask.Task{{Topic: keys[19], Work: 600}}, score)
+	if len(admitted) != 0 || dropped != 1 || len(evicted) != 0 {
+		t.Fatalf("expected the oversized task to be dropped without evictions, got %d admitted, %d evicted", len(admitted), len(evicted))
+	}
+}
+
 func partnerWantBlocks(e *Engine, wantBlocks []string, partner peer.ID) {
 	add := message.New(false)
 	for i, letter := range wantBlocks {
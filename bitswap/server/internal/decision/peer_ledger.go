@@ -22,19 +22,35 @@ func newPeerLedger() *peerLedger {
 }
 
 func (l *peerLedger) Wants(p peer.ID, e wl.Entry) {
+	l.add(p, e.Cid, entry{Priority: e.Priority, WantType: e.WantType})
+}
+
+// WantsRange records a want-block for length bytes of the block starting at
+// offset. It is only a ranged want if the peer didn't want the block yet: a
+// range wanted along with the whole block, or with another range, makes it a
+// want for the whole block.
+func (l *peerLedger) WantsRange(p peer.ID, e wl.Entry, offset, length uint64) {
+	en := entry{Priority: e.Priority, WantType: e.WantType}
+	if prev, ok := l.peers[p][e.Cid]; !ok || (prev.isRange() && prev.RangeOffset == offset && prev.RangeLength == length) {
+		en.RangeOffset, en.RangeLength = offset, length
+	}
+	l.add(p, e.Cid, en)
+}
+
+func (l *peerLedger) add(p peer.ID, k cid.Cid, e entry) {
 	cids, ok := l.peers[p]
 	if !ok {
 		cids = make(map[cid.Cid]entry)
 		l.peers[p] = cids
 	}
-	cids[e.Cid] = entry{e.Priority, e.WantType}
+	cids[k] = e
 
-	m, ok := l.cids[e.Cid]
+	m, ok := l.cids[k]
 	if !ok {
 		m = make(map[peer.ID]entry)
-		l.cids[e.Cid] = m
+		l.cids[k] = m
 	}
-	m[p] = entry{e.Priority, e.WantType}
+	m[p] = e
 }
 
 // CancelWant returns true if the cid was present in the wantlist.
@@ -52,6 +68,17 @@ func (l *peerLedger) CancelWant(p peer.ID, k cid.Cid) bool {
 	return true
 }
 
+// CancelRangeWant cancels the want of the peer for the block only if it is a
+// ranged want for the given range, and returns true if it did. A want for the
+// whole block is left untouched.
+func (l *peerLedger) CancelRangeWant(p peer.ID, k cid.Cid, offset, length uint64) bool {
+	e, ok := l.peers[p][k]
+	if !ok || !e.isRange() || e.RangeOffset != offset || e.RangeLength != length {
+		return false
+	}
+	return l.CancelWant(p, k)
+}
+
 // CancelWantWithType will not cancel WantBlock if we sent a HAVE message.
 func (l *peerLedger) CancelWantWithType(p peer.ID, k cid.Cid, typ pb.Message_Wantlist_WantType) {
 	wants, ok := l.peers[p]
@@ -93,6 +120,14 @@ type entryForPeer struct {
 type entry struct {
 	Priority int32
 	WantType pb.Message_Wantlist_WantType
+	// The byte range of the block that is wanted, if RangeLength is not 0
+	RangeOffset uint64
+	RangeLength uint64
+}
+
+// isRange returns true if only a byte range of the block is wanted
+func (e entry) isRange() bool {
+	return e.RangeLength != 0
 }
 
 func (l *peerLedger) Peers(k cid.Cid) []entryForPeer {
@@ -332,6 +332,18 @@ func (dsl *DefaultScoreLedger) PeerDisconnected(p peer.ID) {
 	delete(dsl.ledgerMap, p)
 }
 
+// Peers returns the peers the ledger keeps accounting for.
+func (dsl *DefaultScoreLedger) Peers() []peer.ID {
+	dsl.lock.RLock()
+	defer dsl.lock.RUnlock()
+
+	peers := make([]peer.ID, 0, len(dsl.ledgerMap))
+	for p := range dsl.ledgerMap {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
 // Creates a new instance of the default score ledger.
 func NewDefaultScoreLedger() *DefaultScoreLedger {
 	return &DefaultScoreLedger{
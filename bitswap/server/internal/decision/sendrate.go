@@ -0,0 +1,119 @@
+package decision
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+const (
+	defaultSendRateWindow = 10 * time.Second
+	// the number of buckets the send rate window is divided in
+	sendRateBuckets = 10
+)
+
+// sendRates keeps the bytes of blocks sent to each peer during a sliding
+// window, to compute the current send rate of the peers.
+//
+// The window is divided in sendRateBuckets buckets: the bytes are added to
+// the bucket of the current time and the buckets older than the window are
+// cleared, so the window slides in steps of one bucket. The rate is the sum
+// of the buckets divided by the duration of the window, i.e. a simple moving
+// average over the window.
+type sendRates struct {
+	window     time.Duration
+	bucketSize time.Duration
+	now        func() time.Time
+
+	lk    sync.Mutex
+	peers map[peer.ID]*sendRateWindow
+}
+
+type sendRateWindow struct {
+	buckets [sendRateBuckets]int
+	// the number of the bucket bytes were last added to, counted from the
+	// unix epoch
+	last int64
+}
+
+func newSendRates(window time.Duration) *sendRates {
+	bucketSize := window / sendRateBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &sendRates{
+		window:     window,
+		bucketSize: bucketSize,
+		now:        time.Now,
+		peers:      make(map[peer.ID]*sendRateWindow),
+	}
+}
+
+func (sr *sendRates) bucket() int64 {
+	return sr.now().UnixNano() / int64(sr.bucketSize)
+}
+
+// add records that n bytes were sent to the peer
+func (sr *sendRates) add(p peer.ID, n int) {
+	if n == 0 {
+		return
+	}
+
+	sr.lk.Lock()
+	defer sr.lk.Unlock()
+
+	b := sr.bucket()
+	w, ok := sr.peers[p]
+	if !ok {
+		w = &sendRateWindow{last: b}
+		sr.peers[p] = w
+	}
+	w.advance(b)
+	w.buckets[b%sendRateBuckets] += n
+}
+
+// remove forgets the bytes sent to the peer
+func (sr *sendRates) remove(p peer.ID) {
+	sr.lk.Lock()
+	defer sr.lk.Unlock()
+	delete(sr.peers, p)
+}
+
+// rates returns the current send rate in bytes per second of the peers that
+// were sent blocks during the window
+func (sr *sendRates) rates() map[peer.ID]float64 {
+	sr.lk.Lock()
+	defer sr.lk.Unlock()
+
+	b := sr.bucket()
+	rates := make(map[peer.ID]float64, len(sr.peers))
+	for p, w := range sr.peers {
+		w.advance(b)
+		sent := 0
+		for _, n := range w.buckets {
+			sent += n
+		}
+		if sent == 0 {
+			delete(sr.peers, p)
+			continue
+		}
+		rates[p] = float64(sent) / sr.window.Seconds()
+	}
+	return rates
+}
+
+// advance clears the buckets that fell out of the window since the last one
+// bytes were added to
+func (w *sendRateWindow) advance(b int64) {
+	if b-w.last >= sendRateBuckets {
+		w.buckets = [sendRateBuckets]int{}
+	} else {
+		for i := w.last + 1; i <= b; i++ {
+			w.buckets[i%sendRateBuckets] = 0
+		}
+	}
+	if b > w.last {
+		w.last = b
+	}
+}
@@ -0,0 +1,50 @@
+package decision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+func TestSendRatesSlidingWindow(t *testing.T) {
+	clk := clock.NewMock()
+	sr := newSendRates(10 * time.Second)
+	sr.now = clk.Now
+
+	a := peer.ID("a")
+	b := peer.ID("b")
+
+	sr.add(a, 1000)
+	sr.add(b, 500)
+	clk.Add(5 * time.Second)
+	sr.add(a, 1000)
+
+	rates := sr.rates()
+	if rates[a] != 200 || rates[b] != 50 {
+		t.Fatalf("unexpected rates %v", rates)
+	}
+
+	// the first bytes sent leave the window
+	clk.Add(5 * time.Second)
+	rates = sr.rates()
+	if rates[a] != 100 {
+		t.Fatalf("expected a rate of 100 for a, got %v", rates[a])
+	}
+	if _, ok := rates[b]; ok {
+		t.Fatalf("expected b to be omitted, got %v", rates[b])
+	}
+
+	clk.Add(time.Hour)
+	sr.add(b, 10)
+	rates = sr.rates()
+	if len(rates) != 1 || rates[b] != 1 {
+		t.Fatalf("unexpected rates %v", rates)
+	}
+
+	sr.remove(b)
+	if rates := sr.rates(); len(rates) != 0 {
+		t.Fatalf("expected no rates, got %v", rates)
+	}
+}
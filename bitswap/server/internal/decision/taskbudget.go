@@ -0,0 +1,179 @@
+package decision
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/mikelsr/go-libp2p/core/peer"
+	"github.com/mikelsr/go-peertaskqueue/peertask"
+)
+
+// taskBudget keeps track of the work (in bytes) of the tasks queued in the
+// peer request queue, to bound the memory it uses, see
+// WithMaxQueuedTaskBytes.
+//
+// The accounting is approximate: tasks dropped by the queue itself (when
+// truncating the wantlist of a peer) are only forgotten when the peer leaves
+// the queue.
+type taskBudget struct {
+	max int
+
+	lk    sync.Mutex
+	total int
+	// the work of the tasks queued for each peer, by topic
+	peers   map[peer.ID]map[cid.Cid]int
+	dropped uint64
+}
+
+func newTaskBudget(max int) *taskBudget {
+	return &taskBudget{
+		max:   max,
+		peers: make(map[peer.ID]map[cid.Cid]int),
+	}
+}
+
+// evictedTask is a queued task removed from the budget to make room for the
+// tasks of a higher scoring peer, that must be removed from the queue too
+type evictedTask struct {
+	peer  peer.ID
+	topic cid.Cid
+}
+
+// admit returns the tasks that can be queued for the peer, removing the
+// others from the slice in place, the number of tasks dropped, and the queued
+// tasks of other peers that were evicted to make room for them.
+//
+// The total work never exceeds the budget. Once it is exhausted, the queued
+// tasks of the peers with a lower score than p are evicted, lowest score
+// first, to make room for the tasks of p; the tasks that still don't fit are
+// dropped. Merging a task with a queued one for the same block doesn't count
+// against the budget if it doesn't add work.
+func (tb *taskBudget) admit(p peer.ID, tasks []peertask.Task, score func(peer.ID) int) ([]peertask.Task, int, []evictedTask) {
+	tb.lk.Lock()
+	defer tb.lk.Unlock()
+
+	var scores map[peer.ID]int
+	var evicted []evictedTask
+
+	admitted := tasks[:0]
+	dropped := 0
+	for _, t := range tasks {
+		c := t.Topic.(cid.Cid)
+		extra := t.Work - tb.peers[p][c]
+		if extra > 0 && tb.total+extra > tb.max {
+			if scores == nil {
+				scores = tb.scores(p, score)
+			}
+			if !tb.makeRoom(p, tb.total+extra-tb.max, scores, &evicted) {
+				dropped++
+				continue
+			}
+		}
+
+		if extra > 0 {
+			queued := tb.peers[p]
+			if queued == nil {
+				queued = make(map[cid.Cid]int)
+				tb.peers[p] = queued
+			}
+			queued[c] = t.Work
+			tb.total += extra
+		}
+		admitted = append(admitted, t)
+	}
+
+	tb.dropped += uint64(dropped + len(evicted))
+	return admitted, dropped, evicted
+}
+
+// scores returns the score of p and of the peers with queued tasks
+func (tb *taskBudget) scores(p peer.ID, score func(peer.ID) int) map[peer.ID]int {
+	scores := make(map[peer.ID]int, len(tb.peers)+1)
+	scores[p] = score(p)
+	for other := range tb.peers {
+		scores[other] = score(other)
+	}
+	return scores
+}
+
+// makeRoom evicts queued tasks of the peers with a lower score than p, lowest
+// score first, until the given amount of work is freed. Nothing is evicted if
+// that is not enough to free it.
+func (tb *taskBudget) makeRoom(p peer.ID, needed int, scores map[peer.ID]int, evicted *[]evictedTask) bool {
+	s := scores[p]
+	var lower []peer.ID
+	available := 0
+	for other, queued := range tb.peers {
+		if other == p || scores[other] >= s {
+			continue
+		}
+		lower = append(lower, other)
+		for _, work := range queued {
+			available += work
+		}
+	}
+	if available < needed {
+		return false
+	}
+	sort.Slice(lower, func(i, j int) bool { return scores[lower[i]] < scores[lower[j]] })
+
+	for _, other := range lower {
+		for c, work := range tb.peers[other] {
+			if needed <= 0 {
+				return true
+			}
+			tb.removeLocked(other, c)
+			*evicted = append(*evicted, evictedTask{peer: other, topic: c})
+			needed -= work
+		}
+	}
+	return true
+}
+
+// popped releases the work of the tasks popped from the queue
+func (tb *taskBudget) popped(p peer.ID, tasks []*peertask.Task) {
+	tb.lk.Lock()
+	defer tb.lk.Unlock()
+
+	for _, t := range tasks {
+		tb.removeLocked(p, t.Topic.(cid.Cid))
+	}
+}
+
+// remove releases the work of the task for the block, if any
+func (tb *taskBudget) remove(p peer.ID, c cid.Cid) {
+	tb.lk.Lock()
+	defer tb.lk.Unlock()
+
+	tb.removeLocked(p, c)
+}
+
+func (tb *taskBudget) removeLocked(p peer.ID, c cid.Cid) {
+	queued := tb.peers[p]
+	if work, ok := queued[c]; ok {
+		tb.total -= work
+		delete(queued, c)
+	}
+	if len(queued) == 0 {
+		delete(tb.peers, p)
+	}
+}
+
+// clear releases the work of all the tasks of the peer
+func (tb *taskBudget) clear(p peer.ID) {
+	tb.lk.Lock()
+	defer tb.lk.Unlock()
+
+	for _, work := range tb.peers[p] {
+		tb.total -= work
+	}
+	delete(tb.peers, p)
+}
+
+// droppedTasks returns the number of tasks dropped so far
+func (tb *taskBudget) droppedTasks() uint64 {
+	tb.lk.Lock()
+	defer tb.lk.Unlock()
+	return tb.dropped
+}
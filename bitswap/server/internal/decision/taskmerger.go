@@ -14,6 +14,32 @@ type taskData struct {
 	BlockSize int
 	// Whether the block was found
 	HaveBlock bool
+	// The byte range of the block that is wanted, if RangeLength is not 0
+	RangeOffset uint64
+	RangeLength uint64
+}
+
+// isRange returns true if only a byte range of the block is wanted
+func (td *taskData) isRange() bool {
+	return td.RangeLength != 0
+}
+
+// sameRange returns true if both tasks want the same range of the block
+func (td *taskData) sameRange(other *taskData) bool {
+	return td.RangeOffset == other.RangeOffset && td.RangeLength == other.RangeLength
+}
+
+// rangeSize returns the number of bytes of the block that will be sent for
+// a ranged want-block
+func (td *taskData) rangeSize() int {
+	size := uint64(td.BlockSize)
+	if td.RangeOffset >= size {
+		return 0
+	}
+	if size-td.RangeOffset < td.RangeLength {
+		return int(size - td.RangeOffset)
+	}
+	return int(td.RangeLength)
 }
 
 type taskMerger struct{}
@@ -25,8 +51,11 @@ func newTaskMerger() *taskMerger {
 // The request queue uses this Method to decide if a newly pushed task has any
 // new information beyond the tasks with the same Topic (CID) in the queue.
 func (*taskMerger) HasNewInfo(task peertask.Task, existing []*peertask.Task) bool {
+	newTaskData := task.Data.(*taskData)
 	haveSize := false
 	isWantBlock := false
+	isFullWantBlock := false
+	isSameRange := false
 	for _, et := range existing {
 		etd := et.Data.(*taskData)
 		if etd.HaveBlock {
@@ -35,16 +64,27 @@ func (*taskMerger) HasNewInfo(task peertask.Task, existing []*peertask.Task) boo
 
 		if etd.IsWantBlock {
 			isWantBlock = true
+			if !etd.isRange() {
+				isFullWantBlock = true
+			} else if etd.sameRange(newTaskData) {
+				isSameRange = true
+			}
 		}
 	}
 
 	// If there is no active want-block and the new task is a want-block,
 	// the new task is better
-	newTaskData := task.Data.(*taskData)
 	if !isWantBlock && newTaskData.IsWantBlock {
 		return true
 	}
 
+	// If the existing want-blocks are only for a range of the block and the
+	// new task wants the whole block, or another range of it, the new task
+	// is better
+	if isWantBlock && !isFullWantBlock && newTaskData.IsWantBlock && !isSameRange {
+		return true
+	}
+
 	// If there is no size information for the CID and the new task has
 	// size information, the new task is better
 	if !haveSize && newTaskData.HaveBlock {
@@ -71,6 +111,8 @@ func (*taskMerger) Merge(task peertask.Task, existing *peertask.Task) {
 	if !existingTask.IsWantBlock && newTask.IsWantBlock {
 		// Change the type from want-have to want-block
 		existingTask.IsWantBlock = true
+		existingTask.RangeOffset = newTask.RangeOffset
+		existingTask.RangeLength = newTask.RangeLength
 		// If the want-have was a DONT_HAVE, or the want-block has a size
 		if !existingTask.HaveBlock || newTask.HaveBlock {
 			// Update the entry size
@@ -79,9 +121,22 @@ func (*taskMerger) Merge(task peertask.Task, existing *peertask.Task) {
 		}
 	}
 
+	// A want for the whole block supersedes a want for a range of it, and
+	// the whole block is sent for two different ranges so that both are
+	// answered
+	if existingTask.isRange() && newTask.IsWantBlock && !existingTask.sameRange(newTask) {
+		existingTask.RangeOffset = 0
+		existingTask.RangeLength = 0
+	}
+
 	// If the task is a want-block, make sure the entry size is equal
-	// to the block size (because we will send the whole block)
+	// to the block size (because we will send the whole block), or to the
+	// size of the range if only a range is wanted
 	if existingTask.IsWantBlock && existingTask.HaveBlock {
-		existing.Work = existingTask.BlockSize
+		if existingTask.isRange() {
+			existing.Work = existingTask.rangeSize()
+		} else {
+			existing.Work = existingTask.BlockSize
+		}
 	}
 }
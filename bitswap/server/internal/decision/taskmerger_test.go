@@ -346,6 +346,63 @@ func TestPushSizeInfoActive(t *testing.T) {
 	runTestCase([]peertask.Task{wantHave, wantBlock}, []peertask.Task{wantHave, wantBlock})
 }
 
+func TestPushRangeVsBlock(t *testing.T) {
+	partner := testutil.GeneratePeers(1)[0]
+
+	wantRange := peertask.Task{
+		Topic:    "1",
+		Priority: 10,
+		Work:     4,
+		Data: &taskData{
+			IsWantBlock: true,
+			BlockSize:   10,
+			HaveBlock:   true,
+			RangeOffset: 2,
+			RangeLength: 4,
+		},
+	}
+	wantBlock := peertask.Task{
+		Topic:    "1",
+		Priority: 10,
+		Work:     10,
+		Data: &taskData{
+			IsWantBlock: true,
+			BlockSize:   10,
+			HaveBlock:   true,
+		},
+	}
+
+	runTestCase := func(tasks []peertask.Task, expIsRange bool, expWork int) {
+		tasks = cloneTasks(tasks)
+		ptq := peertaskqueue.New(peertaskqueue.TaskMerger(newTaskMerger()))
+		ptq.PushTasks(partner, tasks...)
+		_, popped, _ := ptq.PopTasks(100)
+		if len(popped) != 1 {
+			t.Fatalf("Expected 1 task, received %d tasks", len(popped))
+		}
+		isRange := popped[0].Data.(*taskData).isRange()
+		if isRange != expIsRange {
+			t.Fatalf("Expected task.isRange() to be %t, received %t", expIsRange, isRange)
+		}
+		if popped[0].Work != expWork {
+			t.Fatalf("Expected work to be %d, received %d", expWork, popped[0].Work)
+		}
+	}
+
+	otherRange := cloneTasks([]peertask.Task{wantRange})[0]
+	otherRange.Data.(*taskData).RangeOffset = 4
+
+	// want-block for the whole block overwrites a range
+	runTestCase([]peertask.Task{wantRange, wantBlock}, false, 10)
+	// a range does not overwrite a want-block for the whole block
+	runTestCase([]peertask.Task{wantBlock, wantRange}, false, 10)
+	// the same range wanted twice is sent once
+	runTestCase([]peertask.Task{wantRange, wantRange}, true, 4)
+	// two different ranges are merged into the whole block, so that both
+	// are answered
+	runTestCase([]peertask.Task{wantRange, otherRange}, false, 10)
+}
+
 func cloneTasks(tasks []peertask.Task) []peertask.Task {
 	var cp []peertask.Task
 	for _, t := range tasks {
@@ -359,6 +416,8 @@ func cloneTasks(tasks []peertask.Task) []peertask.Task {
 				BlockSize:    td.BlockSize,
 				HaveBlock:    td.HaveBlock,
 				SendDontHave: td.SendDontHave,
+				RangeOffset:  td.RangeOffset,
+				RangeLength:  td.RangeLength,
 			},
 		})
 	}
@@ -143,6 +143,16 @@ func WithPeerBlockRequestFilter(pbrf decision.PeerBlockRequestFilter) Option {
 	}
 }
 
+// WithDisableHaveMessages configures the peers that must never be sent HAVE
+// messages: they are sent the full block in response to a want-have, or a
+// DONT_HAVE if the block is not found.
+func WithDisableHaveMessages(pdhm decision.PeerDisableHaveMessages) Option {
+	o := decision.WithDisableHaveMessages(pdhm)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 // WithTaskComparator configures custom task prioritization logic.
 func WithTaskComparator(comparator decision.TaskComparator) Option {
 	o := decision.WithTaskComparator(comparator)
@@ -151,6 +161,29 @@ func WithTaskComparator(comparator decision.TaskComparator) Option {
 	}
 }
 
+// LocalFirstTaskComparator returns a TaskComparator, to use with
+// WithTaskComparator, that answers the wants for the available blocks before
+// the wants for the blocks that are not, which only get a DONT_HAVE. The
+// tasks are otherwise ordered by next, or by priority if it is nil.
+func LocalFirstTaskComparator(next TaskComparator) TaskComparator {
+	return decision.LocalFirstTaskComparator(next)
+}
+
+// WithReciprocity makes the server serve the peers that have sent us more
+// bytes than we have sent them ahead of the other peers, the largest net
+// contribution first, as accounted by the score ledger. It is disabled by
+// default.
+//
+// The peers with the same contribution, such as the peers that haven't sent
+// us anything, are served as without reciprocity. A comparator set with
+// WithTaskComparator still applies to them and to the tasks of the same peer.
+func WithReciprocity(enabled bool) Option {
+	o := decision.WithReciprocity(enabled)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 // Configures the engine to use the given score decision logic.
 func WithScoreLedger(scoreLedger decision.ScoreLedger) Option {
 	o := decision.WithScoreLedger(scoreLedger)
@@ -165,6 +198,26 @@ func (bs *Server) LedgerForPeer(p peer.ID) *decision.Receipt {
 	return bs.engine.LedgerForPeer(p)
 }
 
+// ScoreForPeer returns the current score given to the peer by the
+// ScoreLedger, 0 if it has none.
+func (bs *Server) ScoreForPeer(p peer.ID) int {
+	return bs.engine.ScoreForPeer(p)
+}
+
+// PeerScores returns the current score and ledger summary of the peers
+// known by the server, to help tuning the scoring. It is safe to call while
+// the server runs.
+func (bs *Server) PeerScores() []PeerScore {
+	return bs.engine.PeerScores()
+}
+
+// PerPeerSendRates returns the current rate in bytes per second at which
+// blocks are sent to each peer, averaged over a sliding window (see
+// WithSendRateWindow). It is safe to call while the server runs.
+func (bs *Server) PerPeerSendRates() map[peer.ID]float64 {
+	return bs.engine.PerPeerSendRates()
+}
+
 // EngineTaskWorkerCount sets the number of worker threads used inside the engine
 func EngineTaskWorkerCount(count int) Option {
 	o := decision.WithTaskWorkerCount(count)
@@ -194,6 +247,39 @@ func EngineBlockstoreWorkerCount(count int) Option {
 	}
 }
 
+// WithAvailabilityOracle makes the server answer want-haves with the oracle
+// instead of the blockstore, and never serve blocks: want-blocks get a
+// DONT_HAVE. This is meant for nodes that know what is available in the
+// network, such as indexers, without storing the data. The peers that must
+// not be sent HAVEs, see WithDisableHaveMessages, only get DONT_HAVEs.
+func WithAvailabilityOracle(oracle decision.AvailabilityOracle) Option {
+	o := decision.WithAvailabilityOracle(oracle)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
+// WithSendRateWindow sets the duration of the sliding window over which the
+// send rates returned by PerPeerSendRates are computed. Defaults to 10s.
+func WithSendRateWindow(window time.Duration) Option {
+	o := decision.WithSendRateWindow(window)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
+// WithMaxQueuedTaskBytes bounds the total size of the tasks waiting to be
+// sent to peers. Once it is reached, the queued tasks of lower scoring peers
+// are evicted to make room for the new tasks of a peer, and their wants are
+// forgotten until the peers send them again. The new tasks that still don't
+// fit are dropped. Setting it to 0 (the default) disables the limit.
+func WithMaxQueuedTaskBytes(max int) Option {
+	o := decision.WithMaxQueuedTaskBytes(max)
+	return func(bs *Server) {
+		bs.engineOptions = append(bs.engineOptions, o)
+	}
+}
+
 func WithTargetMessageSize(tms int) Option {
 	o := decision.WithTargetMessageSize(tms)
 	return func(bs *Server) {
@@ -384,6 +470,7 @@ type Stat struct {
 	ProvideBufLen int
 	BlocksSent    uint64
 	DataSent      uint64
+	TasksDropped  uint64
 }
 
 // Stat returns aggregated statistics about bitswap operations
@@ -392,6 +479,7 @@ func (bs *Server) Stat() (Stat, error) {
 	s := bs.counters
 	bs.counterLk.Unlock()
 	s.ProvideBufLen = len(bs.newBlocks)
+	s.TasksDropped = bs.engine.DroppedTasks()
 
 	peers := bs.engine.Peers()
 	peersStr := make([]string, len(peers))
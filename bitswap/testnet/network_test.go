@@ -1,9 +1,12 @@
 package bitswap
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	bsmsg "github.com/mikelsr/boxo/bitswap/message"
 	bsnet "github.com/mikelsr/boxo/bitswap/network"
@@ -13,7 +16,9 @@ import (
 	mockrouting "github.com/mikelsr/boxo/routing/mock"
 
 	tnet "github.com/mikelsr/go-libp2p-testing/net"
+	p2pnet "github.com/mikelsr/go-libp2p/core/network"
 	"github.com/mikelsr/go-libp2p/core/peer"
+	mocknet "github.com/mikelsr/go-libp2p/p2p/net/mock"
 )
 
 func TestSendMessageAsyncButWaitForResponse(t *testing.T) {
@@ -73,6 +78,47 @@ func TestSendMessageAsyncButWaitForResponse(t *testing.T) {
 	wg.Wait() // until waiter delegate function is executed
 }
 
+func TestSendMessageTimeoutStalledPeer(t *testing.T) {
+	ctx := context.Background()
+	mn := mocknet.New()
+	t.Cleanup(func() { mn.Close() })
+
+	streamNet, err := StreamNet(ctx, mn, mockrouting.NewServer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendTimeout := 200 * time.Millisecond
+	sender := streamNet.Adapter(tnet.RandIdentityOrFatal(t), bsnet.WithSendTimeout(sendTimeout))
+
+	// the stalled peer accepts bitswap streams but never reads from them
+	stalled, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unblock := make(chan struct{})
+	t.Cleanup(func() { close(unblock) })
+	stalled.SetStreamHandler(bsnet.ProtocolBitswap, func(s p2pnet.Stream) {
+		<-unblock
+		_ = s.Reset()
+	})
+	if err := mn.LinkAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(true)
+	msg.AddBlock(blocks.NewBlock(bytes.Repeat([]byte("x"), 64<<10)))
+
+	start := time.Now()
+	err = sender.SendMessage(ctx, stalled.ID(), msg)
+	elapsed := time.Since(start)
+	if !errors.Is(err, bsnet.ErrSendTimeout) {
+		t.Fatalf("expected a send timeout error, got %v", err)
+	}
+	if elapsed < sendTimeout || elapsed > sendTimeout+time.Second {
+		t.Fatalf("expected the send to fail after %s, took %s", sendTimeout, elapsed)
+	}
+}
+
 type receiverFunc func(ctx context.Context, p peer.ID,
 	incoming bsmsg.BitSwapMessage)
 
@@ -0,0 +1,116 @@
+package bitswap
+
+import (
+	delay "github.com/ipfs/go-ipfs-delay"
+	mockrouting "github.com/mikelsr/boxo/routing/mock"
+
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// PartitionedNetwork is a virtual network whose peers can be split in groups
+// that can't reach each other.
+type PartitionedNetwork interface {
+	Network
+
+	// Partition cuts the links between the peers of groupA and the peers of
+	// groupB: the connected pairs are disconnected, and until Heal is called
+	// they can't connect nor send messages to each other. The messages in
+	// flight are still delivered. Partition can be called several times to
+	// cut more links.
+	Partition(groupA, groupB []peer.ID)
+
+	// Heal restores all the links cut by Partition, and reconnects the pairs
+	// of peers that Partition disconnected.
+	Heal()
+}
+
+// PartitionedVirtualNetwork generates a virtual network, like VirtualNetwork,
+// that can be partitioned. The delays apply to the messages between the
+// peers that can reach each other.
+func PartitionedVirtualNetwork(rs mockrouting.Server, d delay.D) PartitionedNetwork {
+	return &partitionedNetwork{VirtualNetwork(rs, d).(*network)}
+}
+
+type partitionedNetwork struct {
+	*network
+}
+
+// peerPair is a pair of peers, in the order of tagForPeers
+type peerPair struct {
+	a, b peer.ID
+}
+
+func newPeerPair(a, b peer.ID) peerPair {
+	if a < b {
+		return peerPair{a, b}
+	}
+	return peerPair{b, a}
+}
+
+func (pn *partitionedNetwork) Partition(groupA, groupB []peer.ID) {
+	var disconnected []peerPair
+
+	pn.mu.Lock()
+	if pn.cuts == nil {
+		pn.cuts = make(map[string]struct{})
+		pn.severed = make(map[string]peerPair)
+	}
+	for _, a := range groupA {
+		for _, b := range groupB {
+			if a == b {
+				continue
+			}
+			tag := tagForPeers(a, b)
+			pn.cuts[tag] = struct{}{}
+			if _, ok := pn.conns[tag]; ok {
+				delete(pn.conns, tag)
+				pn.severed[tag] = newPeerPair(a, b)
+				disconnected = append(disconnected, newPeerPair(a, b))
+			}
+		}
+	}
+	pn.mu.Unlock()
+
+	for _, pair := range disconnected {
+		pn.notifyConnection(pair, false)
+	}
+}
+
+func (pn *partitionedNetwork) Heal() {
+	var reconnected []peerPair
+
+	pn.mu.Lock()
+	for tag, pair := range pn.severed {
+		if _, ok := pn.conns[tag]; !ok {
+			pn.conns[tag] = struct{}{}
+			reconnected = append(reconnected, pair)
+		}
+	}
+	pn.cuts = nil
+	pn.severed = nil
+	pn.mu.Unlock()
+
+	for _, pair := range reconnected {
+		pn.notifyConnection(pair, true)
+	}
+}
+
+// notifyConnection tells both peers of the pair that they are connected to,
+// or disconnected from, each other
+func (pn *partitionedNetwork) notifyConnection(pair peerPair, connected bool) {
+	pn.mu.Lock()
+	ra, okA := pn.clients[pair.a]
+	rb, okB := pn.clients[pair.b]
+	pn.mu.Unlock()
+	if !okA || !okB {
+		return
+	}
+
+	if connected {
+		ra.receiver.PeerConnected(pair.b)
+		rb.receiver.PeerConnected(pair.a)
+	} else {
+		ra.receiver.PeerDisconnected(pair.b)
+		rb.receiver.PeerDisconnected(pair.a)
+	}
+}
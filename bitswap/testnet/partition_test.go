@@ -0,0 +1,74 @@
+package bitswap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+
+	blocks "github.com/ipfs/go-block-format"
+	delay "github.com/ipfs/go-ipfs-delay"
+	mockrouting "github.com/mikelsr/boxo/routing/mock"
+
+	tnet "github.com/mikelsr/go-libp2p-testing/net"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+func TestPartitionedNetwork(t *testing.T) {
+	ctx := context.Background()
+	net := PartitionedVirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+
+	a := net.Adapter(tnet.RandIdentityOrFatal(t))
+	b := net.Adapter(tnet.RandIdentityOrFatal(t))
+	c := net.Adapter(tnet.RandIdentityOrFatal(t))
+
+	received := make(chan peer.ID, 10)
+	b.Start(lambda(func(ctx context.Context, from peer.ID, msg bsmsg.BitSwapMessage) {
+		received <- from
+	}))
+	t.Cleanup(b.Stop)
+
+	if err := a.ConnectTo(ctx, b.Self()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConnectTo(ctx, b.Self()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bsmsg.New(true)
+	msg.AddBlock(blocks.NewBlock([]byte("data")))
+
+	net.Partition([]peer.ID{a.Self()}, []peer.ID{b.Self()})
+
+	if err := a.SendMessage(ctx, b.Self(), msg); err == nil {
+		t.Fatal("expected the message across the partition to fail")
+	}
+	if err := a.ConnectTo(ctx, b.Self()); err == nil {
+		t.Fatal("expected the connection across the partition to fail")
+	}
+	// c is on neither side of the partition
+	if err := c.SendMessage(ctx, b.Self(), msg); err != nil {
+		t.Fatal(err)
+	}
+	expectSender(t, received, c.Self())
+
+	net.Heal()
+
+	if err := a.SendMessage(ctx, b.Self(), msg); err != nil {
+		t.Fatal(err)
+	}
+	expectSender(t, received, a.Self())
+}
+
+func expectSender(t *testing.T, received <-chan peer.ID, expected peer.ID) {
+	t.Helper()
+	select {
+	case from := <-received:
+		if from != expected {
+			t.Fatalf("expected a message from %s, got one from %s", expected, from)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a message from %s", expected)
+	}
+}
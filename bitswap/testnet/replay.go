@@ -0,0 +1,58 @@
+package bitswap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	bsnet "github.com/mikelsr/boxo/bitswap/network"
+	"github.com/mikelsr/boxo/bitswap/tracer"
+
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// Replay feeds the messages received in a recording (see tracer.Recorder) to
+// the receiver, usually a Bitswap instance, in the order they were recorded
+// and with the same delays between them. The remote peers are announced to
+// the receiver with PeerConnected before their first message. The sent
+// messages of the recording are skipped, as the receiver generates its own.
+//
+// Replay returns when all the messages have been delivered, or when the
+// context is done.
+func Replay(ctx context.Context, receiver bsnet.Receiver, events []tracer.Event) error {
+	connected := make(map[peer.ID]struct{})
+	var last time.Time
+	for i, e := range events {
+		if e.Direction != tracer.Received {
+			continue
+		}
+
+		msg, err := e.BitSwapMessage()
+		if err != nil {
+			return fmt.Errorf("decoding event %d: %w", i, err)
+		}
+
+		if !last.IsZero() {
+			if wait := e.Time.Sub(last); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+		last = e.Time
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := connected[e.Peer]; !ok {
+			connected[e.Peer] = struct{}{}
+			receiver.PeerConnected(e.Peer)
+		}
+		receiver.ReceiveMessage(ctx, e.Peer, msg)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package bitswap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	"github.com/mikelsr/boxo/bitswap/tracer"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/mikelsr/go-libp2p/core/peer"
+	libp2ptest "github.com/mikelsr/go-libp2p/core/test"
+)
+
+type replayReceiver struct {
+	connected []peer.ID
+	received  []peer.ID
+	blocks    []blocks.Block
+}
+
+func (r *replayReceiver) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
+	r.received = append(r.received, p)
+	r.blocks = append(r.blocks, incoming.Blocks()...)
+}
+
+func (r *replayReceiver) ReceiveError(error) {}
+
+func (r *replayReceiver) PeerConnected(p peer.ID) {
+	r.connected = append(r.connected, p)
+}
+
+func (r *replayReceiver) PeerDisconnected(peer.ID) {}
+
+func TestReplay(t *testing.T) {
+	p1, p2 := libp2ptest.RandPeerIDFatal(t), libp2ptest.RandPeerIDFatal(t)
+	blks := []blocks.Block{
+		blocks.NewBlock([]byte("block1")),
+		blocks.NewBlock([]byte("block2")),
+		blocks.NewBlock([]byte("block3")),
+	}
+
+	var buf bytes.Buffer
+	rec := tracer.NewRecorder(&buf)
+	for i, p := range []peer.ID{p1, p2, p1} {
+		msg := bsmsg.New(false)
+		msg.AddBlock(blks[i])
+		rec.MessageReceived(p, msg)
+		rec.MessageSent(p, bsmsg.New(false))
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := tracer.ReadEvents(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make the delays between the messages noticeable
+	for i := range events {
+		events[i].Time = events[0].Time.Add(time.Duration(i) * 10 * time.Millisecond)
+	}
+
+	r := new(replayReceiver)
+	start := time.Now()
+	if err := Replay(context.Background(), r, events); err != nil {
+		t.Fatal(err)
+	}
+	// the last received message was recorded 40ms after the first one
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the replay to keep the delays, took %s", elapsed)
+	}
+
+	if len(r.connected) != 2 || r.connected[0] != p1 || r.connected[1] != p2 {
+		t.Fatalf("unexpected connected peers %v", r.connected)
+	}
+	if len(r.received) != 3 || r.received[0] != p1 || r.received[1] != p2 || r.received[2] != p1 {
+		t.Fatalf("unexpected messages from %v", r.received)
+	}
+	for i, b := range r.blocks {
+		if !b.Cid().Equals(blks[i].Cid()) {
+			t.Fatalf("block %d: expected %s, got %s", i, blks[i].Cid(), b.Cid())
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Replay(ctx, new(replayReceiver), events); err != context.Canceled {
+		t.Fatalf("expected the replay to be cancelled, got %v", err)
+	}
+}
@@ -68,8 +68,16 @@ type network struct {
 	isRateLimited      bool
 	rateLimitGenerator RateLimitGenerator
 	conns              map[string]struct{}
+	// links cut by a partition, and the connections it closed, see
+	// PartitionedNetwork
+	cuts    map[string]struct{}
+	severed map[string]peerPair
 }
 
+// errUnreachable is returned when a peer can't be reached because of a
+// partition
+var errUnreachable = errors.New("peer unreachable: the network is partitioned")
+
 type message struct {
 	from       peer.ID
 	msg        bsmsg.BitSwapMessage
@@ -169,6 +177,9 @@ func (n *network) SendMessage(
 	if !ok {
 		return errors.New("cannot locate peer on network")
 	}
+	if _, ok := n.cuts[tagForPeers(from, to)]; ok {
+		return errUnreachable
+	}
 
 	// nb: terminate the context since the context wouldn't actually be passed
 	// over the network in a real scenario
@@ -341,6 +352,10 @@ func (nc *networkClient) ConnectTo(_ context.Context, p peer.ID) error {
 	}
 
 	tag := tagForPeers(nc.local, p)
+	if _, ok := nc.network.cuts[tag]; ok {
+		nc.network.mu.Unlock()
+		return errUnreachable
+	}
 	if _, ok := nc.network.conns[tag]; ok {
 		nc.network.mu.Unlock()
 		// log.Warning("ALREADY CONNECTED TO PEER (is this a reconnect? test lib needs fixing)")
@@ -0,0 +1,125 @@
+package tracer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	peer "github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// Direction tells whether a recorded message was sent or received.
+type Direction string
+
+const (
+	// Sent is the direction of the messages sent to a peer
+	Sent Direction = "sent"
+	// Received is the direction of the messages received from a peer
+	Received Direction = "received"
+)
+
+// Event is a message sent or received by Bitswap, as recorded by a Recorder.
+//
+// Recordings are stored as JSON lines, one event per line:
+//
+//	{"time":"2023-07-01T15:08:14.123456789Z","direction":"received","peer":"12D3Koo...","message":"..."}
+//
+// where time is the RFC 3339 time at which the event was traced, direction is
+// either "sent" or "received", peer is the remote peer, and message is the
+// base64 encoded message, as written on the wire with Bitswap 1.2.0: a varint
+// length followed by the protobuf message. New fields may be added, but the
+// existing ones will keep their meaning.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"direction"`
+	Peer      peer.ID   `json:"peer"`
+	Message   []byte    `json:"message"`
+}
+
+// BitSwapMessage decodes the message of the event.
+func (e Event) BitSwapMessage() (bsmsg.BitSwapMessage, error) {
+	return bsmsg.FromNet(bytes.NewReader(e.Message))
+}
+
+// Recorder is a Tracer writing the messages sent and received by Bitswap to
+// a recording, see Event for the format. Recordings can be read with
+// ReadEvents and replayed to reproduce the behaviour of Bitswap.
+type Recorder struct {
+	lk  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+var _ Tracer = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder writing the events to w. Writes to w are
+// serialized.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// MessageReceived records a message received from the peer.
+func (r *Recorder) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(Received, p, msg)
+}
+
+// MessageSent records a message sent to the peer.
+func (r *Recorder) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.record(Sent, p, msg)
+}
+
+// Err returns the first error that happened while recording, if any. The
+// events following an error are not recorded.
+func (r *Recorder) Err() error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(dir Direction, p peer.ID, msg bsmsg.BitSwapMessage) {
+	now := time.Now().UTC()
+
+	var buf bytes.Buffer
+	err := msg.ToNetV1(&buf)
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err != nil {
+		r.err = fmt.Errorf("encoding message: %w", err)
+		return
+	}
+	r.err = r.enc.Encode(Event{
+		Time:      now,
+		Direction: dir,
+		Peer:      p,
+		Message:   buf.Bytes(),
+	})
+}
+
+// ReadEvents reads all the events of a recording made with a Recorder.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Event
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, fmt.Errorf("reading event %d: %w", len(events), err)
+		}
+		if e.Direction != Sent && e.Direction != Received {
+			return events, fmt.Errorf("reading event %d: unknown direction %q", len(events), e.Direction)
+		}
+		events = append(events, e)
+	}
+}
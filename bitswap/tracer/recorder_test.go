@@ -0,0 +1,69 @@
+package tracer
+
+import (
+	"bytes"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	pb "github.com/mikelsr/boxo/bitswap/message/pb"
+	libp2ptest "github.com/mikelsr/go-libp2p/core/test"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	blk := blocks.NewBlock([]byte("block"))
+	p1, p2 := libp2ptest.RandPeerIDFatal(t), libp2ptest.RandPeerIDFatal(t)
+
+	want := bsmsg.New(false)
+	want.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, true)
+	resp := bsmsg.New(false)
+	resp.AddBlock(blk)
+
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	rec.MessageSent(p1, want)
+	rec.MessageReceived(p2, resp)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadEvents(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	if events[0].Direction != Sent || events[0].Peer != p1 {
+		t.Fatalf("unexpected first event %s %s", events[0].Direction, events[0].Peer)
+	}
+	msg, err := events[0].BitSwapMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wl := msg.Wantlist(); len(wl) != 1 || !wl[0].Cid.Equals(blk.Cid()) || wl[0].WantType != pb.Message_Wantlist_Block {
+		t.Fatalf("unexpected wantlist %v", wl)
+	}
+
+	if events[1].Direction != Received || events[1].Peer != p2 {
+		t.Fatalf("unexpected second event %s %s", events[1].Direction, events[1].Peer)
+	}
+	if events[1].Time.Before(events[0].Time) {
+		t.Fatal("expected events to be in order")
+	}
+	msg, err = events[1].BitSwapMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blks := msg.Blocks(); len(blks) != 1 || !bytes.Equal(blks[0].RawData(), blk.RawData()) {
+		t.Fatalf("unexpected blocks %v", blks)
+	}
+}
+
+func TestReadEventsInvalid(t *testing.T) {
+	_, err := ReadEvents(bytes.NewBufferString(`{"direction":"sideways","peer":"","message":""}`))
+	if err == nil {
+		t.Fatal("expected an error for an unknown direction")
+	}
+}
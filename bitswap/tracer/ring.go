@@ -0,0 +1,92 @@
+package tracer
+
+import (
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	peer "github.com/mikelsr/go-libp2p/core/peer"
+)
+
+// TracedMessage is the content of a message sent or received by Bitswap, as
+// kept by a Ring. Blocks are identified by their CID, their data is not kept.
+type TracedMessage struct {
+	Time      time.Time
+	Direction Direction
+	Peer      peer.ID
+
+	// Full is true if the wantlist replaces the previous one of the sender
+	Full bool
+	// Wantlist holds the wants and cancels of the message
+	Wantlist     []bsmsg.Entry
+	Blocks       []cid.Cid
+	Presences    []bsmsg.BlockPresence
+	PendingBytes int32
+}
+
+// Ring is a Tracer keeping the last messages sent and received by Bitswap in
+// memory, for debugging. The messages can be inspected at runtime with
+// Recent.
+type Ring struct {
+	lk       sync.Mutex
+	messages []TracedMessage
+	// index of the next message to overwrite once the ring is full
+	next int
+}
+
+var _ Tracer = (*Ring)(nil)
+
+// NewRing returns a Ring keeping the last n messages.
+func NewRing(n int) *Ring {
+	if n <= 0 {
+		panic("ring size must be > 0")
+	}
+	return &Ring{messages: make([]TracedMessage, 0, n)}
+}
+
+// MessageReceived keeps a message received from the peer.
+func (r *Ring) MessageReceived(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.add(Received, p, msg)
+}
+
+// MessageSent keeps a message sent to the peer.
+func (r *Ring) MessageSent(p peer.ID, msg bsmsg.BitSwapMessage) {
+	r.add(Sent, p, msg)
+}
+
+// Recent returns the messages kept, the oldest first.
+func (r *Ring) Recent() []TracedMessage {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	recent := make([]TracedMessage, 0, len(r.messages))
+	recent = append(recent, r.messages[r.next:]...)
+	return append(recent, r.messages[:r.next]...)
+}
+
+func (r *Ring) add(dir Direction, p peer.ID, msg bsmsg.BitSwapMessage) {
+	blks := msg.Blocks()
+	tm := TracedMessage{
+		Time:         time.Now(),
+		Direction:    dir,
+		Peer:         p,
+		Full:         msg.Full(),
+		Wantlist:     msg.Wantlist(),
+		Blocks:       make([]cid.Cid, len(blks)),
+		Presences:    msg.BlockPresences(),
+		PendingBytes: msg.PendingBytes(),
+	}
+	for i, b := range blks {
+		tm.Blocks[i] = b.Cid()
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if len(r.messages) < cap(r.messages) {
+		r.messages = append(r.messages, tm)
+		return
+	}
+	r.messages[r.next] = tm
+	r.next = (r.next + 1) % len(r.messages)
+}
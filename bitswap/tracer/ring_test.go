@@ -0,0 +1,63 @@
+package tracer
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	bsmsg "github.com/mikelsr/boxo/bitswap/message"
+	pb "github.com/mikelsr/boxo/bitswap/message/pb"
+	libp2ptest "github.com/mikelsr/go-libp2p/core/test"
+)
+
+func TestRingKeepsLastMessages(t *testing.T) {
+	const size = 3
+	p := libp2ptest.RandPeerIDFatal(t)
+	ring := NewRing(size)
+
+	if recent := ring.Recent(); len(recent) != 0 {
+		t.Fatalf("expected no messages, got %d", len(recent))
+	}
+
+	var blks []blocks.Block
+	for i := 0; i < size+2; i++ {
+		blk := blocks.NewBlock([]byte{byte(i)})
+		blks = append(blks, blk)
+
+		msg := bsmsg.New(false)
+		if i%2 == 0 {
+			msg.AddEntry(blk.Cid(), int32(i), pb.Message_Wantlist_Block, true)
+			ring.MessageSent(p, msg)
+		} else {
+			msg.AddBlock(blk)
+			msg.AddHave(blocks.NewBlock([]byte{byte(i), 1}).Cid())
+			ring.MessageReceived(p, msg)
+		}
+	}
+
+	recent := ring.Recent()
+	if len(recent) != size {
+		t.Fatalf("expected %d messages, got %d", size, len(recent))
+	}
+	for i, tm := range recent {
+		n := i + 2
+		blk := blks[n]
+		if tm.Peer != p {
+			t.Fatalf("message %d: unexpected peer %s", n, tm.Peer)
+		}
+		if n%2 == 0 {
+			if tm.Direction != Sent || len(tm.Wantlist) != 1 || !tm.Wantlist[0].Cid.Equals(blk.Cid()) {
+				t.Fatalf("message %d: expected a want for %s, got %+v", n, blk.Cid(), tm)
+			}
+		} else {
+			if tm.Direction != Received || len(tm.Blocks) != 1 || !tm.Blocks[0].Equals(blk.Cid()) {
+				t.Fatalf("message %d: expected block %s, got %+v", n, blk.Cid(), tm)
+			}
+			if len(tm.Presences) != 1 || tm.Presences[0].Type != pb.Message_Have {
+				t.Fatalf("message %d: expected a HAVE, got %+v", n, tm.Presences)
+			}
+		}
+		if i > 0 && tm.Time.Before(recent[i-1].Time) {
+			t.Fatal("expected messages to be in order")
+		}
+	}
+}
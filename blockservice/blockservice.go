@@ -64,39 +64,116 @@ type BlockService interface {
 	DeleteBlock(ctx context.Context, o cid.Cid) error
 }
 
+// VerifyResult is the result of the verification of one block by VerifyAll.
+type VerifyResult struct {
+	Cid cid.Cid
+	// Err is nil if the block passed the verification: its CID is allowed by
+	// verifcid and its data hashes to the multihash of the CID.
+	Err error
+}
+
 type blockService struct {
 	blockstore blockstore.Blockstore
 	exchange   exchange.Interface
 	// If checkFirst is true then first check that a block doesn't
 	// already exist to avoid republishing the block on the exchange.
 	checkFirst bool
+	// observer is called with the blocks returned by GetBlock and GetBlocks,
+	// nil if not set
+	observer *blockObserver
+	// noSessions is true if the sessions created with NewSession use the
+	// exchange directly rather than a session of the exchange
+	noSessions bool
+}
+
+// Option configures a BlockService created with New or NewWriteThrough.
+type Option func(*blockService)
+
+// WithBlockObserver sets a function called with every block returned by
+// GetBlock and GetBlocks, whether it comes from the blockstore or from the
+// exchange, including through the sessions created with NewSession. Each
+// block is passed once in the lifetime of the blockservice: the CIDs of the
+// blocks already observed are remembered, and never forgotten.
+//
+// The function is called with one block at a time, before the block is
+// returned, so it should not block for long.
+func WithBlockObserver(observe func(blocks.Block)) Option {
+	return func(s *blockService) {
+		s.observer = &blockObserver{observe: observe, seen: cid.NewSet()}
+	}
+}
+
+// WithoutSessionCache makes the sessions created with NewSession fetch the
+// blocks missing from the blockstore with the exchange directly, as GetBlock
+// and GetBlocks do, instead of creating and keeping a session of the exchange
+// when it is an exchange.SessionExchange.
+//
+// This saves the memory held by each exchange session, such as the peers
+// that have the blocks of the session and the state of its wants, which adds
+// up when many sessions are open at once. The cost is that related fetches
+// are no longer targeted at the peers that served the previous blocks of the
+// session, so they are slower and use more bandwidth.
+func WithoutSessionCache() Option {
+	return func(s *blockService) {
+		s.noSessions = true
+	}
+}
+
+// blockObserver deduplicates the blocks passed to an observer function
+type blockObserver struct {
+	observe func(blocks.Block)
+
+	lk   sync.Mutex
+	seen *cid.Set
+}
+
+// notify calls the observer function if the block wasn't observed yet. It is
+// safe to call on a nil blockObserver.
+func (o *blockObserver) notify(b blocks.Block) {
+	if o == nil {
+		return
+	}
+
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	if o.seen.Visit(b.Cid()) {
+		o.observe(b)
+	}
 }
 
 // NewBlockService creates a BlockService with given datastore instance.
-func New(bs blockstore.Blockstore, rem exchange.Interface) BlockService {
+func New(bs blockstore.Blockstore, rem exchange.Interface, opts ...Option) BlockService {
 	if rem == nil {
 		logger.Debug("blockservice running in local (offline) mode.")
 	}
 
-	return &blockService{
+	s := &blockService{
 		blockstore: bs,
 		exchange:   rem,
 		checkFirst: true,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // NewWriteThrough creates a BlockService that guarantees writes will go
 // through to the blockstore and are not skipped by cache checks.
-func NewWriteThrough(bs blockstore.Blockstore, rem exchange.Interface) BlockService {
+func NewWriteThrough(bs blockstore.Blockstore, rem exchange.Interface, opts ...Option) BlockService {
 	if rem == nil {
 		logger.Debug("blockservice running in local (offline) mode.")
 	}
 
-	return &blockService{
+	s := &blockService{
 		blockstore: bs,
 		exchange:   rem,
 		checkFirst: false,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Blockstore returns the blockstore behind this blockservice.
@@ -115,14 +192,22 @@ func (s *blockService) Exchange() exchange.Interface {
 // session will be created. Otherwise, the current exchange will be used
 // directly.
 func NewSession(ctx context.Context, bs BlockService) *Session {
+	var observer *blockObserver
+	noSessions := false
+	if s, ok := bs.(*blockService); ok {
+		observer = s.observer
+		noSessions = s.noSessions
+	}
+
 	exch := bs.Exchange()
-	if sessEx, ok := exch.(exchange.SessionExchange); ok {
+	if sessEx, ok := exch.(exchange.SessionExchange); ok && !noSessions {
 		return &Session{
 			sessCtx:  ctx,
 			ses:      nil,
 			sessEx:   sessEx,
 			bs:       bs.Blockstore(),
 			notifier: exch,
+			observer: observer,
 		}
 	}
 	return &Session{
@@ -130,6 +215,7 @@ func NewSession(ctx context.Context, bs BlockService) *Session {
 		sessCtx:  ctx,
 		bs:       bs.Blockstore(),
 		notifier: exch,
+		observer: observer,
 	}
 }
 
@@ -221,14 +307,14 @@ func (s *blockService) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, e
 		f = s.getExchange
 	}
 
-	return getBlock(ctx, c, s.blockstore, f) // hash security
+	return getBlock(ctx, c, s.blockstore, f, s.observer) // hash security
 }
 
 func (s *blockService) getExchange() notifiableFetcher {
 	return s.exchange
 }
 
-func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget func() notifiableFetcher) (blocks.Block, error) {
+func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget func() notifiableFetcher, observer *blockObserver) (blocks.Block, error) {
 	err := verifcid.ValidateCid(c) // hash security
 	if err != nil {
 		return nil, err
@@ -236,6 +322,7 @@ func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget fun
 
 	block, err := bs.Get(ctx, c)
 	if err == nil {
+		observer.notify(block)
 		return block, nil
 	}
 
@@ -259,6 +346,7 @@ func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget fun
 			return nil, err
 		}
 		logger.Debugf("BlockService.BlockFetched %s", c)
+		observer.notify(blk)
 		return blk, nil
 	}
 
@@ -278,10 +366,10 @@ func (s *blockService) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan block
 		f = s.getExchange
 	}
 
-	return getBlocks(ctx, ks, s.blockstore, f) // hash security
+	return getBlocks(ctx, ks, s.blockstore, f, s.observer) // hash security
 }
 
-func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget func() notifiableFetcher) <-chan blocks.Block {
+func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget func() notifiableFetcher, observer *blockObserver) <-chan blocks.Block {
 	out := make(chan blocks.Block)
 
 	go func() {
@@ -316,6 +404,7 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 				misses = append(misses, c)
 				continue
 			}
+			observer.notify(hit)
 			select {
 			case out <- hit:
 			case <-ctx.Done():
@@ -363,6 +452,7 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 			}
 			cache[0] = nil // early gc
 
+			observer.notify(b)
 			select {
 			case out <- b:
 			case <-ctx.Done():
@@ -385,6 +475,55 @@ func (s *blockService) DeleteBlock(ctx context.Context, c cid.Cid) error {
 	return err
 }
 
+// VerifyAll checks every block of the blockstore, sending a result for each
+// of them on the returned channel. The channel is closed once all blocks have
+// been checked or the context is cancelled.
+//
+// The CIDs of the results are the ones enumerated by the blockstore, which
+// may differ from the CIDs the blocks were added with when the blockstore is
+// keyed by multihash.
+func VerifyAll(ctx context.Context, bs blockstore.Blockstore) (<-chan VerifyResult, error) {
+	ks, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan VerifyResult)
+	go func() {
+		defer close(out)
+
+		for c := range ks {
+			res := VerifyResult{Cid: c, Err: verifyBlock(ctx, bs, c)}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func verifyBlock(ctx context.Context, bs blockstore.Blockstore, c cid.Cid) error {
+	if err := verifcid.ValidateCid(c); err != nil {
+		return err
+	}
+
+	blk, err := bs.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	// the blockstore may have checked it already if it hashes on read
+	rehash, err := c.Prefix().Sum(blk.RawData())
+	if err != nil {
+		return err
+	}
+	if !rehash.Equals(c) {
+		return blockstore.ErrHashMismatch
+	}
+	return nil
+}
+
 func (s *blockService) Close() error {
 	logger.Debug("blockservice is shutting down...")
 	return s.exchange.Close()
@@ -401,6 +540,7 @@ type Session struct {
 	sessEx   exchange.SessionExchange
 	sessCtx  context.Context
 	notifier notifier
+	observer *blockObserver
 	lk       sync.Mutex
 }
 
@@ -444,7 +584,7 @@ func (s *Session) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error)
 	ctx, span := internal.StartSpan(ctx, "Session.GetBlock", trace.WithAttributes(attribute.Stringer("CID", c)))
 	defer span.End()
 
-	return getBlock(ctx, c, s.bs, s.getFetcherFactory()) // hash security
+	return getBlock(ctx, c, s.bs, s.getFetcherFactory(), s.observer) // hash security
 }
 
 // GetBlocks gets blocks in the context of a request session
@@ -452,7 +592,7 @@ func (s *Session) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Blo
 	ctx, span := internal.StartSpan(ctx, "Session.GetBlocks")
 	defer span.End()
 
-	return getBlocks(ctx, ks, s.bs, s.getFetcherFactory()) // hash security
+	return getBlocks(ctx, ks, s.bs, s.getFetcherFactory(), s.observer) // hash security
 }
 
 var _ BlockGetter = (*Session)(nil)
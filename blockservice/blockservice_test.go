@@ -1,7 +1,9 @@
 package blockservice
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
@@ -171,6 +173,55 @@ func TestLazySessionInitialization(t *testing.T) {
 	}
 }
 
+func TestWithoutSessionCache(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bstore2 := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bstore3 := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	session := offline.Exchange(bstore2)
+	exch := offline.Exchange(bstore3)
+	sessionExch := &fakeSessionExchange{Interface: exch, session: session}
+	bserv := New(bstore, sessionExch, WithoutSessionCache())
+	bgen := butil.NewBlockGenerator()
+
+	local := bgen.Next()
+	if err := bstore.Put(ctx, local); err != nil {
+		t.Fatal(err)
+	}
+	remote := bgen.Next()
+	if err := bstore3.Put(ctx, remote); err != nil {
+		t.Fatal(err)
+	}
+
+	bsession := NewSession(ctx, bserv)
+	for _, b := range []blocks.Block{local, remote} {
+		returnedBlock, err := bsession.GetBlock(ctx, b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if returnedBlock.Cid() != b.Cid() {
+			t.Fatal("Got incorrect block")
+		}
+	}
+	if bsession.sessEx != nil || bsession.ses != sessionExch {
+		t.Fatal("Should have fetched the block with the exchange rather than a session")
+	}
+
+	n := 0
+	for b := range bsession.GetBlocks(ctx, []cid.Cid{local.Cid(), remote.Cid()}) {
+		if b.Cid() != local.Cid() && b.Cid() != remote.Cid() {
+			t.Fatal("Got incorrect block")
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 blocks, got %d", n)
+	}
+}
+
 var _ blockstore.Blockstore = (*PutCountingBlockstore)(nil)
 
 type PutCountingBlockstore struct {
@@ -241,3 +292,66 @@ func TestNilExchange(t *testing.T) {
 		t.Fatal("got the wrong block")
 	}
 }
+
+func TestVerifyAll(t *testing.T) {
+	ctx := context.Background()
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := New(bstore, nil)
+	bgen := butil.NewBlockGenerator()
+
+	var cids []cid.Cid
+	for i := 0; i < 10; i++ {
+		blk := bgen.Next()
+		if err := bserv.AddBlock(ctx, blk); err != nil {
+			t.Fatal(err)
+		}
+		cids = append(cids, blk.Cid())
+	}
+
+	verify := func() map[cid.Cid]error {
+		results, err := VerifyAll(ctx, bstore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		failed := make(map[cid.Cid]error)
+		count := 0
+		for res := range results {
+			count++
+			if res.Err != nil {
+				failed[res.Cid] = res.Err
+			}
+		}
+		if count != 10 {
+			t.Fatalf("expected 10 results, got %d", count)
+		}
+		return failed
+	}
+
+	if failed := verify(); len(failed) != 0 {
+		t.Fatalf("expected all blocks to pass, got %v", failed)
+	}
+
+	// replace the data of a block without changing its CID
+	victim := cids[3]
+	if err := bstore.DeleteBlock(ctx, victim); err != nil {
+		t.Fatal(err)
+	}
+	blk, err := blocks.NewBlockWithCid([]byte("not the data of the block"), victim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(ctx, blk); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := verify()
+	if len(failed) != 1 {
+		t.Fatalf("expected one block to fail, got %v", failed)
+	}
+	// the blockstore is keyed by multihash, so the CIDs may differ in codec
+	for c, err := range failed {
+		if !bytes.Equal(c.Hash(), victim.Hash()) || !errors.Is(err, blockstore.ErrHashMismatch) {
+			t.Fatalf("expected %s to fail with a hash mismatch, got %v", victim, failed)
+		}
+	}
+}
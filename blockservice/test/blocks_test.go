@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -98,3 +99,59 @@ func TestGetBlocksSequential(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockObserver(t *testing.T) {
+	var lk sync.Mutex
+	observed := make(map[cid.Cid]int)
+	observe := func(b blocks.Block) {
+		lk.Lock()
+		defer lk.Unlock()
+		observed[b.Cid()]++
+	}
+
+	servs := Mocks(2, WithBlockObserver(observe))
+	for _, s := range servs {
+		defer s.Close()
+	}
+	objs := makeObjects(10)
+
+	var cids []cid.Cid
+	for _, o := range objs {
+		cids = append(cids, o.Cid())
+		if err := servs[0].AddBlock(context.Background(), o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(observed) != 0 {
+		t.Fatal("added blocks should not be observed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// from the exchange
+	if _, err := servs[1].GetBlock(ctx, cids[0]); err != nil {
+		t.Fatal(err)
+	}
+	// from the blockstore and the exchange
+	for range servs[1].GetBlocks(ctx, cids[:5]) {
+	}
+	// through a session
+	ses := NewSession(ctx, servs[1])
+	for range ses.GetBlocks(ctx, cids) {
+	}
+	if _, err := ses.GetBlock(ctx, cids[9]); err != nil {
+		t.Fatal(err)
+	}
+
+	lk.Lock()
+	defer lk.Unlock()
+	if len(observed) != len(cids) {
+		t.Fatalf("expected %d blocks to be observed, got %d", len(cids), len(observed))
+	}
+	for _, c := range cids {
+		if observed[c] != 1 {
+			t.Fatalf("expected block %s to be observed once, got %d", c, observed[c])
+		}
+	}
+}
@@ -8,8 +8,9 @@ import (
 	mockrouting "github.com/mikelsr/boxo/routing/mock"
 )
 
-// Mocks returns |n| connected mock Blockservices
-func Mocks(n int) []blockservice.BlockService {
+// Mocks returns |n| connected mock Blockservices, created with the given
+// options
+func Mocks(n int, opts ...blockservice.Option) []blockservice.BlockService {
 	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
 	sg := testinstance.NewTestInstanceGenerator(net, nil, nil)
 
@@ -17,7 +18,7 @@ func Mocks(n int) []blockservice.BlockService {
 
 	var servs []blockservice.BlockService
 	for _, i := range instances {
-		servs = append(servs, blockservice.New(i.Blockstore(), i.Exchange))
+		servs = append(servs, blockservice.New(i.Blockstore(), i.Exchange, opts...))
 	}
 	return servs
 }
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	migrate "github.com/mikelsr/boxo/cmd/boxo-migrate/internal"
@@ -126,6 +127,40 @@ check by adding the --force flag.`)
 					return nil
 				},
 			},
+			{
+				Name:  "list-unmigrated-imports",
+				Usage: "lists the imports of the current module that update-imports would rewrite, without modifying anything",
+				Action: func(clictx *cli.Context) error {
+					configFile := clictx.String("config")
+
+					migrator, err := buildMigrator(true, configFile)
+					if err != nil {
+						return err
+					}
+
+					imports, err := migrator.FindUnmigratedImports()
+					if err != nil {
+						return err
+					}
+					if len(imports) == 0 {
+						fmt.Println("No unmigrated imports found.")
+						return nil
+					}
+
+					pkgDirs := make([]string, 0, len(imports))
+					for pkgDir := range imports {
+						pkgDirs = append(pkgDirs, pkgDir)
+					}
+					sort.Strings(pkgDirs)
+					for _, pkgDir := range pkgDirs {
+						fmt.Printf("%s:\n", pkgDir)
+						for _, imp := range imports[pkgDir] {
+							fmt.Printf("\t%s\n", imp)
+						}
+					}
+					return nil
+				},
+			},
 			{
 				Name:  "check-dependencies",
 				Usage: "checks the current module for dependencies that have migrated to go-libipfs",
@@ -9,6 +9,8 @@ import (
 type Config struct {
 	ImportPaths map[string]string
 	Modules     []string
+	// ExcludeTestFiles leaves the imports of _test.go files untouched
+	ExcludeTestFiles bool
 }
 
 var DefaultConfig = Config{
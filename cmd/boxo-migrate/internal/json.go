@@ -1,6 +1,9 @@
 package migrate
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"strings"
+)
 
 type pkgJSON struct {
 	Dir            string
@@ -11,11 +14,20 @@ type pkgJSON struct {
 	CgoFiles       []string
 }
 
-func (p *pkgJSON) allSourceFiles() []string {
+// allSourceFiles returns the source files of the package, including the ones excluded by build constraints,
+// and the test files if includeTests is set.
+func (p *pkgJSON) allSourceFiles(includeTests bool) []string {
 	var files []string
-	lists := [...][]string{p.GoFiles, p.IgnoredGoFiles, p.TestGoFiles, p.CgoFiles, p.XTestGoFiles}
+	lists := [][]string{p.GoFiles, p.IgnoredGoFiles, p.CgoFiles}
+	if includeTests {
+		lists = append(lists, p.TestGoFiles, p.XTestGoFiles)
+	}
 	for _, l := range lists {
 		for _, f := range l {
+			// IgnoredGoFiles also lists the test files excluded by build constraints
+			if !includeTests && strings.HasSuffix(f, "_test.go") {
+				continue
+			}
 			files = append(files, filepath.Join(p.Dir, f))
 		}
 	}
@@ -10,8 +10,11 @@ import (
 	"go/parser"
 	"go/token"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -22,6 +25,24 @@ type Migrator struct {
 	Config Config
 }
 
+// rewriteImportPath returns the import path that replaces val, if val matches one of the Config.ImportPaths.
+func (m *Migrator) rewriteImportPath(val string) (string, bool) {
+	// we take the first matching prefix, so you need to make sure you don't have ambiguous mappings
+	for from, to := range m.Config.ImportPaths {
+		if strings.HasPrefix(val, from) {
+			switch {
+			case len(val) == len(from):
+				return to, true
+			case val[len(from)] != '/':
+				continue
+			default:
+				return to + val[len(from):], true
+			}
+		}
+	}
+	return "", false
+}
+
 func (m *Migrator) updateFileImports(filePath string) error {
 	fset := token.NewFileSet()
 	astFile, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
@@ -40,23 +61,11 @@ func (m *Migrator) updateFileImports(filePath string) error {
 				errr = err
 				return false
 			}
-			// we take the first matching prefix, so you need to make sure you don't have ambiguous mappings
-			for from, to := range m.Config.ImportPaths {
-				if strings.HasPrefix(val, from) {
-					var newVal string
-					switch {
-					case len(val) == len(from):
-						newVal = to
-					case val[len(from)] != '/':
-						continue
-					default:
-						newVal = to + val[len(from):]
-					}
-					fmt.Printf("changing %s => %s in %s\n", x.Path.Value, newVal, filePath)
-					if !m.DryRun {
-						x.Path.Value = strconv.Quote(newVal)
-						fileChanged = true
-					}
+			if newVal, ok := m.rewriteImportPath(val); ok {
+				fmt.Printf("changing %s => %s in %s\n", x.Path.Value, newVal, filePath)
+				if !m.DryRun {
+					x.Path.Value = strconv.Quote(newVal)
+					fileChanged = true
 				}
 			}
 		}
@@ -156,7 +165,7 @@ func (m *Migrator) findSourceFiles() ([]string, error) {
 		if err != nil {
 			return nil, fmt.Errorf("decoding 'go list' JSON: %w", err)
 		}
-		files = append(files, pkg.allSourceFiles()...)
+		files = append(files, pkg.allSourceFiles(!m.Config.ExcludeTestFiles)...)
 	}
 }
 
@@ -175,6 +184,63 @@ func (m *Migrator) UpdateImports() error {
 	return nil
 }
 
+// FindUnmigratedImports scans the Go files under Dir and returns the imports that would be rewritten by UpdateImports,
+// grouped by package directory relative to Dir. Nothing is modified.
+func (m *Migrator) FindUnmigratedImports() (map[string][]string, error) {
+	found := map[string]map[string]struct{}{}
+	err := filepath.WalkDir(m.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// skip the directories ignored by the go tool
+			name := d.Name()
+			if path != m.Dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || (m.Config.ExcludeTestFiles && strings.HasSuffix(path, "_test.go")) {
+			return nil
+		}
+
+		astFile, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return fmt.Errorf("parsing %q: %w", path, err)
+		}
+		pkgDir, err := filepath.Rel(m.Dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		for _, imp := range astFile.Imports {
+			val, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return err
+			}
+			if _, ok := m.rewriteImportPath(val); !ok {
+				continue
+			}
+			if found[pkgDir] == nil {
+				found[pkgDir] = map[string]struct{}{}
+			}
+			found[pkgDir][val] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding unmigrated imports: %w", err)
+	}
+
+	imports := make(map[string][]string, len(found))
+	for pkgDir, vals := range found {
+		for val := range vals {
+			imports[pkgDir] = append(imports[pkgDir], val)
+		}
+		sort.Strings(imports[pkgDir])
+	}
+	return imports, nil
+}
+
 func (m *Migrator) GoModTidy() error {
 	fmt.Printf("\n\nRunning 'go mod tidy'...\n\n")
 	_, err := m.runOrErr("go", "mod", "tidy")
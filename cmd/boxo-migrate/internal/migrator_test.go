@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindUnmigratedImports(t *testing.T) {
+	m := &Migrator{
+		DryRun: true,
+		Dir:    "testdata/unmigrated",
+		Config: DefaultConfig,
+	}
+
+	imports, err := m.FindUnmigratedImports()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		".": {
+			"github.com/ipfs/go-bitswap",
+			"github.com/ipfs/go-merkledag/test",
+		},
+		"sub": {
+			"github.com/ipfs/go-libipfs/gateway",
+			"github.com/ipfs/go-path",
+		},
+	}
+	if !reflect.DeepEqual(imports, expected) {
+		t.Fatalf("expected %v, got %v", expected, imports)
+	}
+}
+
+// copyFixture copies the files of the given testdata directory to a temporary directory
+func copyFixture(t *testing.T, name string) string {
+	dir := t.TempDir()
+	src := filepath.Join("testdata", name)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestUpdateImportsBuildTagsAndTests(t *testing.T) {
+	for _, excludeTests := range []bool{false, true} {
+		dir := copyFixture(t, "buildtags")
+		config := DefaultConfig
+		config.ExcludeTestFiles = excludeTests
+		m := &Migrator{
+			Dir:    dir,
+			Config: config,
+		}
+
+		if err := m.UpdateImports(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range []string{"a.go", "tagged.go", "a_test.go", "x_test.go"} {
+			b, err := os.ReadFile(filepath.Join(dir, f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrated := strings.Contains(string(b), `"github.com/mikelsr/boxo/path"`)
+			expected := !excludeTests || !strings.HasSuffix(f, "_test.go")
+			if migrated != expected {
+				t.Errorf("excludeTests=%t: expected %s to be migrated: %t", excludeTests, f, expected)
+			}
+		}
+	}
+}
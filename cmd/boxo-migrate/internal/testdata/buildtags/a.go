@@ -0,0 +1,5 @@
+package a
+
+import "github.com/ipfs/go-path"
+
+var _ = path.Path("")
@@ -0,0 +1,11 @@
+package a
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-path"
+)
+
+func TestA(t *testing.T) {
+	_ = path.Path("")
+}
@@ -0,0 +1,7 @@
+//go:build never
+
+package a
+
+import "github.com/ipfs/go-path"
+
+var _ = path.Path("")
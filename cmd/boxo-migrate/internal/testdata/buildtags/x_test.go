@@ -0,0 +1,11 @@
+package a_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-path"
+)
+
+func TestX(t *testing.T) {
+	_ = path.Path("")
+}
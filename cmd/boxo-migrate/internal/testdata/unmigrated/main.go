@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+
+	bitswap "github.com/ipfs/go-bitswap"
+	"github.com/ipfs/go-bitswapper"
+	"github.com/ipfs/go-merkledag/test"
+	"github.com/mikelsr/boxo/path"
+)
+
+func main() {
+	fmt.Println(bitswap.New, bitswapper.X, test.Mock, path.Path(""))
+}
@@ -0,0 +1,9 @@
+package sub
+
+import (
+	"github.com/ipfs/go-libipfs/gateway"
+	"github.com/ipfs/go-path"
+)
+
+var _ = gateway.NewHandler
+var _ = path.Path("")
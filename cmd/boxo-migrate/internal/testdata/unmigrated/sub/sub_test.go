@@ -0,0 +1,11 @@
+package sub
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-path"
+)
+
+func TestSub(t *testing.T) {
+	_ = path.Path("")
+}
@@ -48,11 +48,21 @@ type CoreAPI interface {
 	Routing() RoutingAPI
 
 	// ResolvePath resolves the path using Unixfs resolver
-	ResolvePath(context.Context, path.Path) (path.Resolved, error)
+	//
+	// The error is an ErrNoLink if a link of the path doesn't exist, an
+	// ErrNotFound if a block can't be found, or ErrResolveBudgetExceeded if
+	// the options.Resolve.MaxBytes limit is exceeded.
+	ResolvePath(context.Context, path.Path, ...options.ResolveOption) (path.Resolved, error)
 
 	// ResolveNode resolves the path (if not resolved already) using Unixfs
 	// resolver, gets and returns the resolved Node
-	ResolveNode(context.Context, path.Path) (ipld.Node, error)
+	ResolveNode(context.Context, path.Path, ...options.ResolveOption) (ipld.Node, error)
+
+	// Stat reports the number and total size of the blocks stored by the
+	// node, and the number of pins if the implementation counts them. Whether
+	// the values are exact or approximate is indicated by RepoStat.Approximate.
+	// An exact Stat may scan the whole blockstore, see StatBlockstore.
+	Stat(context.Context) (RepoStat, error)
 
 	// WithOptions creates new instance of CoreAPI based on this instance with
 	// a set of options applied
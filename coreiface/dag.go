@@ -1,13 +1,56 @@
 package iface
 
 import (
+	"context"
+	"errors"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/mikelsr/boxo/coreiface/options"
+	"github.com/mikelsr/boxo/coreiface/path"
+	"github.com/mikelsr/boxo/ipld/merkledag"
 )
 
+// ErrLinkNotFound is returned by ResolveLink when the node has no link with
+// the given name. It is the error of merkledag, so that implementations can
+// return the error of ProtoNode.GetNodeLink as is.
+var ErrLinkNotFound = merkledag.ErrLinkNotFound
+
+// SkipChildren can be returned by the visit function of APIDagService.Walk to
+// skip the children of the node. It is not returned by Walk.
+var SkipChildren = errors.New("skip the children of this node")
+
+// DagWalkFunc is the signature of the function called by APIDagService.Walk
+// for every node of the DAG. Returning an error other than SkipChildren stops
+// the walk.
+type DagWalkFunc func(c cid.Cid, nd ipld.Node) error
+
 // APIDagService extends ipld.DAGService
 type APIDagService interface {
 	ipld.DAGService
 
 	// Pinning returns special NodeAdder which recursively pins added nodes
 	Pinning() ipld.NodeAdder
+
+	// ResolveLink fetches the base node and returns the CID of its link with
+	// the given name. It returns ErrLinkNotFound if there is no such link.
+	ResolveLink(ctx context.Context, base cid.Cid, name string) (cid.Cid, error)
+
+	// GetRaw returns the bytes of the block with the given CID verbatim, as
+	// stored. Unlike encoding the node returned by Get, which may not give the
+	// same bytes, it is suitable to build byte-exact CARs or to verify
+	// signatures over the canonical bytes of a block.
+	GetRaw(ctx context.Context, c cid.Cid) ([]byte, error)
+
+	// Walk resolves the root path and walks the DAG below it, depth first
+	// unless options.Dag.Concurrency is used, calling visit for every node.
+	// It returns the first error returned by visit, other than SkipChildren.
+	Walk(ctx context.Context, root path.Path, visit DagWalkFunc, opts ...options.DagWalkOption) error
+
+	// ImportJSONStream decodes the newline-delimited dag-json objects read
+	// from r, adds them as dag-cbor nodes and returns their paths, in order.
+	// Nothing is added if a line can't be decoded, the error then gives its
+	// number.
+	ImportJSONStream(ctx context.Context, r io.Reader) ([]path.Resolved, error)
 }
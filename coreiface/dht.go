@@ -2,6 +2,7 @@ package iface
 
 import (
 	"context"
+	"time"
 
 	"github.com/mikelsr/boxo/coreiface/path"
 
@@ -24,4 +25,18 @@ type DhtAPI interface {
 
 	// Provide announces to the network that you are providing given values
 	Provide(context.Context, path.Path, ...options.DhtProvideOption) error
+
+	// ProvideContinuously announces that you are providing the given value
+	// right away, and then again every interval, until the context is done or
+	// the returned stop function is called. An error is returned if the first
+	// announcement fails; failures of the following ones are only logged.
+	//
+	// The announcements come on top of the ones made by the node's
+	// reprovider, if it has one: the reprovider keeps announcing the value
+	// according to its own strategy and schedule, and stopping doesn't
+	// prevent it from doing so.
+	//
+	// The stop function can be called multiple times, and returns once the
+	// announcements have stopped.
+	ProvideContinuously(ctx context.Context, p path.Path, interval time.Duration) (stop func(), err error)
 }
@@ -1,10 +1,32 @@
 package iface
 
-import "errors"
+import (
+	"errors"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/mikelsr/boxo/path/resolver"
+)
 
 var (
 	ErrIsDir        = errors.New("this dag node is a directory")
 	ErrNotFile      = errors.New("this dag node is not a regular file")
 	ErrOffline      = errors.New("this action must be run in online mode, try running 'ipfs daemon' first")
 	ErrNotSupported = errors.New("operation not supported")
+
+	ErrRangeNotSatisfiable = errors.New("range starts past the end of the file")
+
+	// ErrResolveBudgetExceeded is returned, possibly wrapped, by ResolvePath
+	// and ResolveNode when resolving needs to read more bytes than allowed
+	// by options.Resolve.MaxBytes.
+	ErrResolveBudgetExceeded = errors.New("resolving the path exceeds the byte budget")
 )
+
+// ErrNoLink is returned, possibly wrapped, by ResolvePath when a segment of
+// the path names a link that doesn't exist in a node that was found. Use
+// errors.As to get the name of the missing link and the CID of the node.
+type ErrNoLink = resolver.ErrNoLink
+
+// ErrNotFound is returned, possibly wrapped, by ResolvePath when a block
+// needed to resolve the path is not available. Use errors.As to get its CID,
+// or ipld.IsNotFound.
+type ErrNotFound = ipld.ErrNotFound
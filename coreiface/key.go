@@ -40,4 +40,14 @@ type KeyAPI interface {
 
 	// Remove removes keys from keystore. Returns ipns path of the removed key
 	Remove(ctx context.Context, name string) (Key, error)
+
+	// Export returns the key with the given name encrypted with the
+	// passphrase, for backup. The format of the returned blob is the one of
+	// keystore.ExportKey: the key is sealed with AES-256-GCM under a key
+	// derived from the passphrase with scrypt.
+	Export(ctx context.Context, name string, passphrase []byte) ([]byte, error)
+
+	// Import decrypts a blob returned by Export with the passphrase and
+	// stores the key in the keystore under the specified name
+	Import(ctx context.Context, name string, blob []byte, passphrase []byte) (Key, error)
 }
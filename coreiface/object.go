@@ -69,6 +69,10 @@ type ObjectChange struct {
 
 // ObjectAPI specifies the interface to MerkleDAG and contains useful utilities
 // for manipulating MerkleDAG data structures.
+//
+// The mutation methods (AddLink, RmLink, AppendData and SetData) mirror the
+// 'ipfs object patch' commands. They only operate on dag-pb nodes, and return
+// the path of the new node rather than modifying the node in place.
 type ObjectAPI interface {
 	// New creates new, empty (by default) dag-node.
 	New(context.Context, ...options.ObjectNewOption) (ipld.Node, error)
@@ -90,16 +94,16 @@ type ObjectAPI interface {
 
 	// AddLink adds a link under the specified path. child path can point to a
 	// subdirectory within the patent which must be present (can be overridden
-	// with WithCreate option).
+	// with WithCreate option). The base must be a dag-pb node.
 	AddLink(ctx context.Context, base path.Path, name string, child path.Path, opts ...options.ObjectAddLinkOption) (path.Resolved, error)
 
-	// RmLink removes a link from the node
+	// RmLink removes a link from the dag-pb node
 	RmLink(ctx context.Context, base path.Path, link string) (path.Resolved, error)
 
-	// AppendData appends data to the node
+	// AppendData appends data to the data field of the dag-pb node
 	AppendData(context.Context, path.Path, io.Reader) (path.Resolved, error)
 
-	// SetData sets the data contained in the node
+	// SetData replaces the data field of the dag-pb node
 	SetData(context.Context, path.Path, io.Reader) (path.Resolved, error)
 
 	// Diff returns a set of changes needed to transform the first object into the
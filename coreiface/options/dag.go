@@ -0,0 +1,57 @@
+package options
+
+import "fmt"
+
+// DagWalkSettings represent the settings for APIDagService.Walk
+type DagWalkSettings struct {
+	Concurrency int
+	Dedup       bool
+}
+
+// DagWalkOption is the signature of an option for APIDagService.Walk
+type DagWalkOption func(*DagWalkSettings) error
+
+// DagWalkOptions compile a series of DagWalkOption into a ready to use
+// DagWalkSettings and set the default values.
+func DagWalkOptions(opts ...DagWalkOption) (*DagWalkSettings, error) {
+	options := &DagWalkSettings{
+		Concurrency: 1,
+		Dedup:       true,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type dagOpts struct{}
+
+var Dag dagOpts
+
+// Concurrency is an option for Dag.Walk which specifies how many nodes are
+// fetched in parallel. With more than one the nodes are no longer visited in
+// depth first order, but the visit function is still never called
+// concurrently. Default is 1.
+func (dagOpts) Concurrency(n int) DagWalkOption {
+	return func(settings *DagWalkSettings) error {
+		if n < 1 {
+			return fmt.Errorf("walk concurrency must be at least 1, got %d", n)
+		}
+		settings.Concurrency = n
+		return nil
+	}
+}
+
+// Dedup is an option for Dag.Walk which specifies whether the nodes linked
+// several times in the DAG are visited only once. Without it, a node and its
+// children are visited each time it is linked. Default is true.
+func (dagOpts) Dedup(dedup bool) DagWalkOption {
+	return func(settings *DagWalkSettings) error {
+		settings.Dedup = dedup
+		return nil
+	}
+}
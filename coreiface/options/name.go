@@ -1,6 +1,7 @@
 package options
 
 import (
+	"fmt"
 	"time"
 
 	ropts "github.com/mikelsr/boxo/coreiface/options/namesys"
@@ -42,9 +43,26 @@ func NamePublishOptions(opts ...NamePublishOption) (*NamePublishSettings, error)
 		}
 	}
 
+	if options.TTL != nil && *options.TTL > options.ValidTime {
+		return nil, fmt.Errorf("the TTL (%s) of the record can't be longer than its lifetime (%s)", *options.TTL, options.ValidTime)
+	}
+
 	return options, nil
 }
 
+// PublishOptions returns the namesys options setting the EOL, TTL and
+// compatibility of a record published now with these settings.
+func (s *NamePublishSettings) PublishOptions() []ropts.PublishOption {
+	opts := []ropts.PublishOption{
+		ropts.PublishWithEOL(time.Now().Add(s.ValidTime)),
+		ropts.PublishCompatibleWithV1(s.CompatibleWithV1),
+	}
+	if s.TTL != nil {
+		opts = append(opts, ropts.PublishWithTTL(*s.TTL))
+	}
+	return opts
+}
+
 func NameResolveOptions(opts ...NameResolveOption) (*NameResolveSettings, error) {
 	options := &NameResolveSettings{
 		Cache: true,
@@ -73,6 +91,13 @@ func (nameOpts) ValidTime(validTime time.Duration) NamePublishOption {
 	}
 }
 
+// Lifetime is an option for Name.Publish which specifies for how long the
+// record will remain valid, i.e. its EOL is the time of publication plus the
+// lifetime. It is the same as ValidTime. Default value is 24h
+func (nameOpts) Lifetime(lifetime time.Duration) NamePublishOption {
+	return nameOpts{}.ValidTime(lifetime)
+}
+
 // Key is an option for Name.Publish which specifies the key to use for
 // publishing. Default value is "self" which is the node's own PeerID.
 // The key parameter must be either PeerID or keystore key alias.
@@ -95,9 +120,14 @@ func (nameOpts) AllowOffline(allow bool) NamePublishOption {
 }
 
 // TTL is an option for Name.Publish which specifies the time duration the
-// published record should be cached for (caution: experimental).
+// published record should be cached for (caution: experimental). It is
+// independent of the lifetime of the record (see Lifetime) but can't be
+// longer.
 func (nameOpts) TTL(ttl time.Duration) NamePublishOption {
 	return func(settings *NamePublishSettings) error {
+		if ttl < 0 {
+			return fmt.Errorf("negative TTL %s", ttl)
+		}
 		settings.TTL = &ttl
 		return nil
 	}
@@ -27,6 +27,11 @@ type PinUpdateSettings struct {
 	Unpin bool
 }
 
+// PinVerifySettings represent the settings for PinAPI.Verify
+type PinVerifySettings struct {
+	BadOnly bool
+}
+
 // PinAddOption is the signature of an option for PinAPI.Add
 type PinAddOption func(*PinAddSettings) error
 
@@ -42,6 +47,9 @@ type PinRmOption func(*PinRmSettings) error
 // PinUpdateOption is the signature of an option for PinAPI.Update
 type PinUpdateOption func(*PinUpdateSettings) error
 
+// PinVerifyOption is the signature of an option for PinAPI.Verify
+type PinVerifyOption func(*PinVerifySettings) error
+
 // PinAddOptions compile a series of PinAddOption into a ready to use
 // PinAddSettings and set the default values.
 func PinAddOptions(opts ...PinAddOption) (*PinAddSettings, error) {
@@ -126,6 +134,23 @@ func PinUpdateOptions(opts ...PinUpdateOption) (*PinUpdateSettings, error) {
 	return options, nil
 }
 
+// PinVerifyOptions compile a series of PinVerifyOption into a ready to use
+// PinVerifySettings and set the default values.
+func PinVerifyOptions(opts ...PinVerifyOption) (*PinVerifySettings, error) {
+	options := &PinVerifySettings{
+		BadOnly: false,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
 type pinOpts struct {
 	Ls       pinLsOpts
 	IsPinned pinIsPinnedOpts
@@ -281,3 +306,12 @@ func (pinOpts) Unpin(unpin bool) PinUpdateOption {
 		return nil
 	}
 }
+
+// VerifyBadOnly is an option for Pin.Verify which specifies whether to only
+// report the pins that are not ok. Default is false.
+func (pinOpts) VerifyBadOnly(badOnly bool) PinVerifyOption {
+	return func(settings *PinVerifySettings) error {
+		settings.BadOnly = badOnly
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package options
+
+import "fmt"
+
+// ResolveSettings are the settings of CoreAPI.ResolvePath and
+// CoreAPI.ResolveNode.
+type ResolveSettings struct {
+	// MaxBytes is the maximum number of bytes of blocks read while resolving,
+	// 0 for no limit
+	MaxBytes int64
+}
+
+type ResolveOption func(*ResolveSettings) error
+
+func ResolveOptions(opts ...ResolveOption) (*ResolveSettings, error) {
+	options := &ResolveSettings{
+		MaxBytes: 0,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+type resolveOpts struct{}
+
+var Resolve resolveOpts
+
+// MaxBytes is an option for ResolvePath and ResolveNode which specifies the
+// maximum number of bytes of the blocks read to resolve the path, including
+// the last node for ResolveNode. Resolution stops with
+// ErrResolveBudgetExceeded once the limit is exceeded, which protects
+// against DAGs crafted to be expensive to traverse. Default is 0, no limit.
+func (resolveOpts) MaxBytes(n int64) ResolveOption {
+	return func(settings *ResolveSettings) error {
+		if n < 0 {
+			return fmt.Errorf("negative byte budget %d", n)
+		}
+		settings.MaxBytes = n
+		return nil
+	}
+}
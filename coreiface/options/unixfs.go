@@ -33,9 +33,10 @@ type UnixfsAddSettings struct {
 	FsCache  bool
 	NoCopy   bool
 
-	Events   chan<- interface{}
-	Silent   bool
-	Progress bool
+	Events      chan<- interface{}
+	Silent      bool
+	Progress    bool
+	BlockEvents bool
 }
 
 type UnixfsLsSettings struct {
@@ -43,8 +44,16 @@ type UnixfsLsSettings struct {
 	UseCumulativeSize bool
 }
 
+type UnixfsGetSettings struct {
+	// RangeOffset and RangeLength limit the returned file to a byte range,
+	// if RangeLength is not zero
+	RangeOffset int64
+	RangeLength int64
+}
+
 type UnixfsAddOption func(*UnixfsAddSettings) error
 type UnixfsLsOption func(*UnixfsLsSettings) error
+type UnixfsGetOption func(*UnixfsGetSettings) error
 
 func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix, error) {
 	options := &UnixfsAddSettings{
@@ -64,9 +73,10 @@ func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix,
 		FsCache:  false,
 		NoCopy:   false,
 
-		Events:   nil,
-		Silent:   false,
-		Progress: false,
+		Events:      nil,
+		Silent:      false,
+		Progress:    false,
+		BlockEvents: false,
 	}
 
 	for _, opt := range opts {
@@ -135,6 +145,19 @@ func UnixfsLsOptions(opts ...UnixfsLsOption) (*UnixfsLsSettings, error) {
 	return options, nil
 }
 
+func UnixfsGetOptions(opts ...UnixfsGetOption) (*UnixfsGetSettings, error) {
+	options := &UnixfsGetSettings{}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
 type unixfsOpts struct{}
 
 var Unixfs unixfsOpts
@@ -258,6 +281,20 @@ func (unixfsOpts) Progress(enable bool) UnixfsAddOption {
 	}
 }
 
+// BlockEvents tells the adder to also send an AddBlockEvent to the Events
+// channel for every block it produces, as soon as it is stored, and a final
+// one for the root. This allows to report the progress of large adds, or to
+// start providing the blocks before the add completes.
+//
+// The events are sent synchronously: the add waits for the receiver of the
+// channel, so a channel that is not drained stalls the add.
+func (unixfsOpts) BlockEvents(enable bool) UnixfsAddOption {
+	return func(settings *UnixfsAddSettings) error {
+		settings.BlockEvents = enable
+		return nil
+	}
+}
+
 // FsCache tells the adder to check the filestore for pre-existing blocks
 //
 // Experimental
@@ -291,3 +328,18 @@ func (unixfsOpts) UseCumulativeSize(use bool) UnixfsLsOption {
 		return nil
 	}
 }
+
+// Range limits the file returned by Get to length bytes starting at offset.
+// The range may go past the end of the file, in which case the file is
+// shorter, but it must start within the file. It can't be used to get a
+// directory.
+func (unixfsOpts) Range(offset, length int64) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		if offset < 0 || length <= 0 {
+			return fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+		}
+		settings.RangeOffset = offset
+		settings.RangeLength = length
+		return nil
+	}
+}
@@ -1,10 +1,13 @@
 package path
 
 import (
+	gopath "path"
 	"strings"
+	"sync"
 
 	cid "github.com/ipfs/go-cid"
 	ipfspath "github.com/mikelsr/boxo/path"
+	"github.com/mikelsr/go-libp2p/core/peer"
 )
 
 // Path is a generic wrapper for paths used in the API. A path can be resolved
@@ -20,6 +23,13 @@ type Path interface {
 	// String returns the path as a string.
 	String() string
 
+	// Original returns the string the path was created from with New, before
+	// it was normalized. For other paths it is the same as String.
+	//
+	// For example for path New("QmHash/foo"), calling String() will return
+	// "/ipfs/QmHash/foo" while calling Original() will return "QmHash/foo"
+	Original() string
+
 	// Namespace returns the first component of the path.
 	//
 	// For example path "/ipfs/QmHash", calling Namespace() will return "ipfs"
@@ -28,6 +38,16 @@ type Path interface {
 	// empty string
 	Namespace() string
 
+	// Segments returns the components of the path, starting with the
+	// namespace.
+	//
+	// For example path "/ipfs/QmHash/foo", calling Segments() will return
+	// ["ipfs", "QmHash", "foo"]
+	//
+	// Calling this method on invalid paths (IsValid() != nil) will result in
+	// nil
+	Segments() []string
+
 	// Mutable returns false if the data pointed to by this path in guaranteed
 	// to not change.
 	//
@@ -97,12 +117,40 @@ type Resolved interface {
 	// For more examples see the documentation of Cid() method
 	Remainder() string
 
+	// RemainderSegments returns the unresolved part of the path split in
+	// segments, the same way as Segments. It returns nil if the whole path
+	// was resolved.
+	//
+	// Example:
+	// When resolving "/ipld/QmRoot/A/foo/bar", as described in the
+	// documentation of Remainder(), RemainderSegments will return
+	// ["foo", "bar"]
+	RemainderSegments() []string
+
+	// FullyResolved returns true if the whole path was resolved to the node
+	// returned by Cid, i.e. there is no remainder. It returns false when the
+	// resolution stopped before the end of the path, within a node that is
+	// not UnixFS, such as a CBOR node, or at a raw leaf that has no links
+	// (see StoppedAtRawLeaf).
+	FullyResolved() bool
+
+	// StoppedAtRawLeaf returns true if the resolution intentionally stopped
+	// at a raw leaf, returned by Cid, before the end of the path. The
+	// segments after the leaf are the remainder.
+	StoppedAtRawLeaf() bool
+
 	Path
 }
 
 // path implements coreiface.Path
 type path struct {
 	path string
+	// original is the input of New, if different from path
+	original string
+
+	// segments are computed once, when first needed, as paths can't change
+	segmentsOnce sync.Once
+	segments     []string
 }
 
 // resolvedPath implements coreiface.resolvedPath
@@ -122,7 +170,7 @@ func Join(base Path, a ...string) Path {
 // IpfsPath creates new /ipfs path from the provided CID
 func IpfsPath(c cid.Cid) Resolved {
 	return &resolvedPath{
-		path:      path{"/ipfs/" + c.String()},
+		path:      path{path: "/ipfs/" + c.String()},
 		cid:       c,
 		root:      c,
 		remainder: "",
@@ -132,20 +180,67 @@ func IpfsPath(c cid.Cid) Resolved {
 // IpldPath creates new /ipld path from the provided CID
 func IpldPath(c cid.Cid) Resolved {
 	return &resolvedPath{
-		path:      path{"/ipld/" + c.String()},
+		path:      path{path: "/ipld/" + c.String()},
 		cid:       c,
 		root:      c,
 		remainder: "",
 	}
 }
 
+// IpfsPaths creates new /ipfs paths from the provided CIDs, see IpfsPath
+func IpfsPaths(cids []cid.Cid) []Resolved {
+	paths := make([]Resolved, len(cids))
+	for i, c := range cids {
+		paths[i] = IpfsPath(c)
+	}
+	return paths
+}
+
+// IpldPaths creates new /ipld paths from the provided CIDs, see IpldPath
+func IpldPaths(cids []cid.Cid) []Resolved {
+	paths := make([]Resolved, len(cids))
+	for i, c := range cids {
+		paths[i] = IpldPath(c)
+	}
+	return paths
+}
+
 // New parses string path to a Path
 func New(p string) Path {
+	original := p
 	if pp, err := ipfspath.ParsePath(p); err == nil {
 		p = pp.String()
 	}
 
-	return &path{path: p}
+	if original == p {
+		return &path{path: p}
+	}
+	return &path{path: p, original: original}
+}
+
+// Split returns the namespace of the path, the CID at its root and the
+// remaining segments. Unlike ipfspath.SplitAbsPath it works for any
+// namespace: the root of an /ipns path is the CID of the key, and it is
+// cid.Undef for a DNSLink name or any other root that is not a CID. Invalid
+// paths give an empty namespace.
+func Split(p Path) (namespace string, root cid.Cid, segments []string) {
+	segs := p.Segments()
+	if len(segs) < 2 {
+		return "", cid.Undef, nil
+	}
+
+	namespace = segs[0]
+	if namespace == "ipns" {
+		if pid, err := peer.Decode(segs[1]); err == nil {
+			root = peer.ToCid(pid)
+		}
+	}
+	if !root.Defined() {
+		if c, err := cid.Decode(segs[1]); err == nil {
+			root = c
+		}
+	}
+	return namespace, root, segs[2:]
 }
 
 // NewResolvedPath creates new Resolved path. This function performs no checks
@@ -153,27 +248,69 @@ func New(p string) Path {
 // cause panics. Handle with care.
 func NewResolvedPath(ipath ipfspath.Path, c cid.Cid, root cid.Cid, remainder string) Resolved {
 	return &resolvedPath{
-		path:      path{ipath.String()},
+		path:      path{path: ipath.String()},
 		cid:       c,
 		root:      root,
 		remainder: remainder,
 	}
 }
 
+// ChildPath creates the Resolved path of the entry name of the directory at
+// parent, which links to child, e.g. when listing the directory. The parent
+// is expected to be fully resolved, the child path keeps its root.
+func ChildPath(parent Resolved, name string, child cid.Cid) Resolved {
+	return &resolvedPath{
+		path:      path{path: parent.String() + "/" + name},
+		cid:       child,
+		root:      parent.Root(),
+		remainder: "",
+	}
+}
+
 func (p *path) String() string {
 	return p.path
 }
 
+func (p *path) Original() string {
+	if p.original == "" {
+		return p.path
+	}
+	return p.original
+}
+
+// parsedSegments returns the cached segments of the path, which must not be
+// modified
+func (p *path) parsedSegments() []string {
+	p.segmentsOnce.Do(func() {
+		ip, err := ipfspath.ParsePath(p.path)
+		if err != nil {
+			return
+		}
+
+		p.segments = ip.Segments()
+		if len(p.segments) < 1 {
+			panic("path without namespace") // this shouldn't happen under any scenario
+		}
+	})
+	return p.segments
+}
+
 func (p *path) Namespace() string {
-	ip, err := ipfspath.ParsePath(p.path)
-	if err != nil {
+	segments := p.parsedSegments()
+	if segments == nil {
 		return ""
 	}
+	return segments[0]
+}
 
-	if len(ip.Segments()) < 1 {
-		panic("path without namespace") // this shouldn't happen under any scenario
+func (p *path) Segments() []string {
+	segments := p.parsedSegments()
+	if segments == nil {
+		return nil
 	}
-	return ip.Segments()[0]
+
+	// return a copy so the cached segments can't be modified
+	return append([]string(nil), segments...)
 }
 
 func (p *path) Mutable() bool {
@@ -197,3 +334,23 @@ func (p *resolvedPath) Root() cid.Cid {
 func (p *resolvedPath) Remainder() string {
 	return p.remainder
 }
+
+func (p *resolvedPath) RemainderSegments() []string {
+	if p.remainder == "" {
+		return nil
+	}
+
+	cleaned := gopath.Clean("/" + p.remainder)
+	if cleaned == "/" {
+		return nil
+	}
+	return strings.Split(cleaned[1:], "/")
+}
+
+func (p *resolvedPath) FullyResolved() bool {
+	return p.RemainderSegments() == nil
+}
+
+func (p *resolvedPath) StoppedAtRawLeaf() bool {
+	return !p.FullyResolved() && p.cid.Type() == cid.Raw
+}
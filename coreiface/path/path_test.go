@@ -0,0 +1,231 @@
+package path
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ipfspath "github.com/mikelsr/boxo/path"
+)
+
+func TestSegments(t *testing.T) {
+	p := New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo/bar")
+
+	segments := p.Segments()
+	expected := []string{"ipfs", "QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6", "foo", "bar"}
+	if len(segments) != len(expected) {
+		t.Fatalf("expected %d segments, got %d", len(expected), len(segments))
+	}
+	for i := range expected {
+		if segments[i] != expected[i] {
+			t.Fatalf("expected segment %d to be %q, got %q", i, expected[i], segments[i])
+		}
+	}
+
+	// modifying the returned slice must not change the path
+	segments[0] = "ipns"
+	if p.Namespace() != "ipfs" || p.Segments()[0] != "ipfs" {
+		t.Fatal("cached segments were modified")
+	}
+
+	if New("/ipfs/foo").Segments() != nil {
+		t.Fatal("expected no segments for an invalid path")
+	}
+}
+
+func TestOriginal(t *testing.T) {
+	p := New("QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo")
+	if p.String() != "/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo" {
+		t.Fatalf("unexpected normalized path %s", p)
+	}
+	if p.Original() != "QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo" {
+		t.Fatalf("unexpected original path %s", p.Original())
+	}
+
+	for _, q := range []Path{
+		New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo"),
+		Join(p, "bar"),
+	} {
+		if q.Original() != q.String() {
+			t.Fatalf("expected original path %s, got %s", q, q.Original())
+		}
+	}
+}
+
+func TestNewKeepsMultibase(t *testing.T) {
+	c := cid.MustParse("bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku")
+	upper := strings.ToUpper(c.String())
+
+	for _, in := range []string{upper + "/foo", "/ipfs/" + upper + "/foo"} {
+		p := New(in)
+		if p.String() != "/ipfs/"+upper+"/foo" {
+			t.Fatalf("expected the base32upper CID to be kept, got %s", p)
+		}
+		if _, root, _ := Split(p); root != c {
+			t.Fatalf("expected the root %s, got %s", c, root)
+		}
+	}
+}
+
+func TestIpfsAndIpldPaths(t *testing.T) {
+	cids := []cid.Cid{
+		cid.MustParse("QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6"),
+		cid.MustParse("bafkqaaa"),
+	}
+
+	for ns, paths := range map[string][]Resolved{
+		"ipfs": IpfsPaths(cids),
+		"ipld": IpldPaths(cids),
+	} {
+		if len(paths) != len(cids) {
+			t.Fatalf("expected %d %s paths, got %d", len(cids), ns, len(paths))
+		}
+		for i, p := range paths {
+			if p.Namespace() != ns {
+				t.Fatalf("expected namespace %s, got %s", ns, p.Namespace())
+			}
+			if !p.Root().Equals(cids[i]) || !p.Cid().Equals(cids[i]) {
+				t.Fatalf("path %d: expected root %s, got %s", i, cids[i], p.Root())
+			}
+		}
+	}
+
+	if paths := IpfsPaths(nil); len(paths) != 0 {
+		t.Fatalf("expected no paths, got %d", len(paths))
+	}
+}
+
+func TestChildPath(t *testing.T) {
+	root := cid.MustParse("QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	dir := cid.MustParse("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	file := cid.MustParse("bafkqaaa")
+
+	parent := NewResolvedPath(ipfspath.Path("/ipfs/"+root.String()+"/dir"), dir, root, "")
+	p := ChildPath(parent, "file", file)
+
+	if p.String() != "/ipfs/"+root.String()+"/dir/file" {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if !p.Root().Equals(root) {
+		t.Fatalf("expected root %s, got %s", root, p.Root())
+	}
+	if !p.Cid().Equals(file) {
+		t.Fatalf("expected cid %s, got %s", file, p.Cid())
+	}
+	if p.Remainder() != "" {
+		t.Fatalf("expected no remainder, got %q", p.Remainder())
+	}
+	if err := p.IsValid(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkSegments(b *testing.B) {
+	p := New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo/bar/baz")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.Segments()
+	}
+}
+
+func BenchmarkNamespace(b *testing.B) {
+	p := New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/foo/bar/baz")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.Namespace()
+	}
+}
+
+func TestRemainderSegments(t *testing.T) {
+	c, err := cid.Decode("QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		"":            nil,
+		"/":           nil,
+		"foo/bar":     {"foo", "bar"},
+		"foo/bar/":    {"foo", "bar"},
+		"foo%2Fbar/a": {"foo%2Fbar", "a"},
+	}
+	for remainder, expected := range cases {
+		p := NewResolvedPath(ipfspath.FromCid(c), c, c, remainder)
+		if segments := p.RemainderSegments(); !reflect.DeepEqual(segments, expected) {
+			t.Fatalf("expected %q for remainder %q, got %q", expected, remainder, segments)
+		}
+	}
+}
+
+func TestFullyResolved(t *testing.T) {
+	root := cid.MustParse("QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	raw := cid.NewCidV1(cid.Raw, root.Hash())
+
+	if p := IpfsPath(root); !p.FullyResolved() || p.StoppedAtRawLeaf() {
+		t.Fatal("expected a CID path to be fully resolved")
+	}
+	if p := IpfsPath(raw); !p.FullyResolved() || p.StoppedAtRawLeaf() {
+		t.Fatal("expected the path of a raw leaf to be fully resolved")
+	}
+	if p := NewResolvedPath(ipfspath.Path("/ipfs/"+root.String()+"/a/b"), raw, root, "/"); !p.FullyResolved() || p.StoppedAtRawLeaf() {
+		t.Fatal("expected a path with an empty remainder to be fully resolved")
+	}
+	if p := ChildPath(IpfsPath(root), "a", raw); !p.FullyResolved() || p.StoppedAtRawLeaf() {
+		t.Fatal("expected a child path to be fully resolved")
+	}
+
+	// the resolution stopped within a CBOR node
+	cbor := cid.NewCidV1(cid.DagCBOR, root.Hash())
+	p := NewResolvedPath(ipfspath.Path("/ipfs/"+root.String()+"/a/b"), cbor, root, "b")
+	if p.FullyResolved() || p.StoppedAtRawLeaf() {
+		t.Fatal("expected a path with a remainder in a CBOR node not to be fully resolved nor stopped at a raw leaf")
+	}
+
+	// the resolution stopped at the raw leaf, which has no links
+	p = NewResolvedPath(ipfspath.Path("/ipfs/"+root.String()+"/a/b"), raw, root, "b")
+	if p.FullyResolved() || !p.StoppedAtRawLeaf() {
+		t.Fatal("expected a path with a remainder after a raw leaf to be stopped at the leaf")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	const (
+		cidStr = "QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6"
+		pidStr = "12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA"
+		keyStr = "k51qzi5uqu5dhdmyb9bd18pypu2wp5lpv2xnskfmrqa4lb5knqryrotb05e7or"
+	)
+	c := cid.MustParse(cidStr)
+	key := cid.MustParse(keyStr)
+
+	for _, tc := range []struct {
+		path      string
+		namespace string
+		root      cid.Cid
+		segments  []string
+	}{
+		{"/ipfs/" + cidStr, "ipfs", c, []string{}},
+		{"/ipfs/" + cidStr + "/a/b", "ipfs", c, []string{"a", "b"}},
+		{cidStr + "/a", "ipfs", c, []string{"a"}},
+		{"/ipld/" + cidStr + "/a", "ipld", c, []string{"a"}},
+		{"/ipns/" + pidStr + "/a", "ipns", key, []string{"a"}},
+		{"/ipns/" + keyStr + "/a", "ipns", key, []string{"a"}},
+		{"/ipns/example.com/a/b", "ipns", cid.Undef, []string{"a", "b"}},
+		{"/foo/bar", "", cid.Undef, nil},
+	} {
+		namespace, root, segments := Split(New(tc.path))
+		if namespace != tc.namespace {
+			t.Errorf("%s: expected namespace %q, got %q", tc.path, tc.namespace, namespace)
+		}
+		if root != tc.root {
+			t.Errorf("%s: expected root %s, got %s", tc.path, tc.root, root)
+		}
+		if !reflect.DeepEqual(segments, tc.segments) {
+			t.Errorf("%s: expected segments %q, got %q", tc.path, tc.segments, segments)
+		}
+	}
+}
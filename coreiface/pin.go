@@ -20,8 +20,21 @@ type Pin interface {
 	Err() error
 }
 
+// PinResult holds the outcome of pinning one of the paths passed to
+// PinAPI.AddMany
+type PinResult interface {
+	// Path is the path that was pinned, as passed to AddMany
+	Path() path.Path
+
+	// if not nil, the path could not be pinned
+	Err() error
+}
+
 // PinStatus holds information about pin health
 type PinStatus interface {
+	// Root is the path of the recursively pinned object that was verified
+	Root() path.Resolved
+
 	// Ok indicates whether the pin has been verified to be correct
 	Ok() bool
 
@@ -47,6 +60,14 @@ type PinAPI interface {
 	// tree
 	Add(context.Context, path.Path, ...options.PinAddOption) error
 
+	// AddMany pins all the given paths, with the same options as Add. The
+	// nodes shared by several paths are only traversed once.
+	//
+	// There is one result per path, in the same order, reporting whether that
+	// path was pinned. If the context is cancelled, the results of the paths
+	// processed so far are returned along with the context error.
+	AddMany(context.Context, []path.Path, ...options.PinAddOption) ([]PinResult, error)
+
 	// Ls returns list of pinned objects on this node
 	Ls(context.Context, ...options.PinLsOption) (<-chan Pin, error)
 
@@ -61,6 +82,17 @@ type PinAPI interface {
 	// the old tree
 	Update(ctx context.Context, from path.Path, to path.Path, opts ...options.PinUpdateOption) error
 
-	// Verify verifies the integrity of pinned objects
-	Verify(context.Context) (<-chan PinStatus, error)
+	// Verify checks that the whole DAG of every recursive pin is stored
+	// locally, without fetching anything from the network, and streams the
+	// status of each pin along with the nodes that are missing.
+	Verify(context.Context, ...options.PinVerifyOption) (<-chan PinStatus, error)
+
+	// Which returns the pins that keep the node the path resolves to: the
+	// direct pin on the node, if any, followed by the recursive pins whose
+	// DAG includes it. The result is empty if the node is not pinned.
+	//
+	// The DAG of every recursive pin is walked until the node is found, so
+	// the cost grows with the total size of the pinned DAGs. The nodes
+	// shared by several pins are only visited once.
+	Which(context.Context, path.Path) ([]Pin, error)
 }
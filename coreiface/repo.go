@@ -0,0 +1,60 @@
+package iface
+
+import (
+	"context"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+)
+
+// RepoStat reports how much a node is storing, see CoreAPI.Stat.
+type RepoStat struct {
+	// NumBlocks is the number of blocks in the blockstore
+	NumBlocks uint64
+
+	// Size is the total size in bytes of the data of the blocks. It doesn't
+	// include the overhead of the datastore, and the blocks of the filestore
+	// and urlstore are counted with the size of the data they reference.
+	Size uint64
+
+	// NumPins is the number of pins, or -1 if they were not counted
+	NumPins int64
+
+	// Approximate is true when the values come from counters maintained by
+	// the store rather than from a scan of the blockstore, they may then be
+	// slightly off if blocks are being added or removed.
+	Approximate bool
+}
+
+// StatBlockstore computes the exact RepoStat of the blocks of the blockstore
+// by enumerating all its keys and getting the size of each block. This is
+// expensive on large blockstores. NumPins is set to -1.
+//
+// The blocks deleted while the blockstore is being enumerated are not counted.
+func StatBlockstore(ctx context.Context, bs blockstore.Blockstore) (RepoStat, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ks, err := bs.AllKeysChan(ctx)
+	if err != nil {
+		return RepoStat{}, err
+	}
+
+	stat := RepoStat{NumPins: -1}
+	for c := range ks {
+		size, err := bs.GetSize(ctx, c)
+		if err != nil {
+			if ipld.IsNotFound(err) {
+				continue
+			}
+			return RepoStat{}, err
+		}
+		stat.NumBlocks++
+		stat.Size += uint64(size)
+	}
+	// AllKeysChan closes the channel early when the context is cancelled
+	if err := ctx.Err(); err != nil {
+		return RepoStat{}, err
+	}
+	return stat, nil
+}
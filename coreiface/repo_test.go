@@ -0,0 +1,44 @@
+package iface
+
+import (
+	"context"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+)
+
+func TestStatBlockstore(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+
+	stat, err := StatBlockstore(ctx, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat != (RepoStat{NumPins: -1}) {
+		t.Fatalf("unexpected stat of an empty blockstore %+v", stat)
+	}
+
+	for _, data := range []string{"foo", "barbaz", "foo"} {
+		if err := bs.Put(ctx, blocks.NewBlock([]byte(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stat, err = StatBlockstore(ctx, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.NumBlocks != 2 || stat.Size != 9 || stat.NumPins != -1 || stat.Approximate {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := StatBlockstore(cctx, bs); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package iface
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/mikelsr/boxo/coreiface/path"
+	"github.com/mikelsr/boxo/files"
+)
+
+// ResolveNodeReader resolves the path like CoreAPI.ResolveNode and, if it
+// points to a UnixFS file, also returns a reader of the file, to resolve and
+// stream it in one step. The reader is nil for other nodes, e.g. directories.
+//
+// The last segment of the path may be a byte offset hint instead of a link:
+// when it is a decimal number that isn't the name of a link, and the node
+// before it is a UnixFS file, the returned node is the file and the reader
+// starts at that offset. ErrRangeNotSatisfiable is returned if the offset is
+// past the end of the file. A link with a numeric name always takes
+// precedence, and an offset after a node that isn't a file fails with
+// ErrNotFile.
+func ResolveNodeReader(ctx context.Context, api CoreAPI, p path.Path) (ipld.Node, io.ReadCloser, error) {
+	var c cid.Cid
+	var offset int64
+	// whether the last segment of the path is an offset
+	var hint bool
+
+	rp, err := api.ResolvePath(ctx, p)
+	switch {
+	case err == nil && rp.Remainder() == "":
+		c = rp.Cid()
+	case err == nil:
+		// the path ends inside a node, e.g. a raw block
+		offset, hint = parseOffset(rp.RemainderSegments())
+		if !hint {
+			nd, err := api.ResolveNode(ctx, rp)
+			return nd, nil, err
+		}
+		c = rp.Cid()
+	default:
+		var noLink ErrNoLink
+		segs := p.Segments()
+		if !errors.As(err, &noLink) || noLink.Name != segs[len(segs)-1] {
+			return nil, nil, err
+		}
+		offset, hint = parseOffset(segs[len(segs)-1:])
+		if !hint {
+			return nil, nil, err
+		}
+
+		// the missing link must be the last segment, not an earlier one
+		// with the same name: the node without it must be the parent
+		parent, perr := api.ResolvePath(ctx, path.New("/"+strings.Join(segs[:len(segs)-1], "/")))
+		if perr != nil || parent.Remainder() != "" || !parent.Cid().Equals(noLink.Node) {
+			return nil, nil, err
+		}
+		c = noLink.Node
+	}
+
+	nd, err := api.Dag().Get(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prefix := c.Prefix(); prefix.Codec != cid.DagProtobuf && prefix.Codec != cid.Raw {
+		if hint {
+			return nil, nil, ErrNotFile
+		}
+		return nd, nil, nil
+	}
+
+	fnd, err := api.Unixfs().Get(ctx, path.IpfsPath(c))
+	if err != nil {
+		return nil, nil, err
+	}
+	f, ok := fnd.(files.File)
+	if !ok {
+		fnd.Close()
+		if hint {
+			return nil, nil, ErrNotFile
+		}
+		return nd, nil, nil
+	}
+
+	if hint {
+		size, err := f.Size()
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		if offset > size {
+			f.Close()
+			return nil, nil, ErrRangeNotSatisfiable
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return nd, f, nil
+}
+
+// parseOffset returns the byte offset in the remainder of a path, if it is a
+// single decimal number
+func parseOffset(remainder []string) (int64, bool) {
+	if len(remainder) != 1 {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(remainder[0], 10, 64)
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
@@ -3,8 +3,11 @@ package iface
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
+	"github.com/mikelsr/go-libp2p/core/host"
+	"github.com/mikelsr/go-libp2p/core/metrics"
 	"github.com/mikelsr/go-libp2p/core/network"
 	"github.com/mikelsr/go-libp2p/core/peer"
 	"github.com/mikelsr/go-libp2p/core/protocol"
@@ -15,6 +18,10 @@ import (
 var (
 	ErrNotConnected = errors.New("not connected")
 	ErrConnNotFound = errors.New("conn not found")
+
+	// ErrBandwidthNotEnabled is returned when the node doesn't collect
+	// bandwidth metrics
+	ErrBandwidthNotEnabled = errors.New("bandwidth reporting is not enabled")
 )
 
 // ConnectionInfo contains information about a peer
@@ -35,6 +42,16 @@ type ConnectionInfo interface {
 	Streams() ([]protocol.ID, error)
 }
 
+// PeerBandwidth contains the bandwidth used with a peer
+type PeerBandwidth struct {
+	// Total is the bandwidth used with the peer over all protocols
+	Total metrics.Stats
+
+	// ByProtocol is the bandwidth used with the peer for each protocol, it is
+	// nil if the bandwidth reporter doesn't track it
+	ByProtocol map[protocol.ID]metrics.Stats
+}
+
 // SwarmAPI specifies the interface to libp2p swarm
 type SwarmAPI interface {
 	// Connect to a given peer
@@ -46,6 +63,14 @@ type SwarmAPI interface {
 	// Peers returns the list of peers we are connected to
 	Peers(context.Context) ([]ConnectionInfo, error)
 
+	// ConnsToPeer returns the list of connections to the given peer
+	ConnsToPeer(context.Context, peer.ID) ([]ConnectionInfo, error)
+
+	// PeerBandwidth returns the bandwidth used with the given peer. It
+	// returns ErrBandwidthNotEnabled if the node doesn't collect bandwidth
+	// metrics
+	PeerBandwidth(context.Context, peer.ID) (PeerBandwidth, error)
+
 	// KnownAddrs returns the list of all addresses this node is aware of
 	KnownAddrs(context.Context) (map[peer.ID][]ma.Multiaddr, error)
 
@@ -54,4 +79,18 @@ type SwarmAPI interface {
 
 	// ListenAddrs returns the list of all listening addresses
 	ListenAddrs(context.Context) ([]ma.Multiaddr, error)
+
+	// ListProtocols returns the sorted list of the protocols the node
+	// currently has stream handlers for, with the prefixes they are
+	// configured with, e.g. the bitswap versions and the DHT protocol. It
+	// doesn't open any stream.
+	ListProtocols(context.Context) ([]protocol.ID, error)
+}
+
+// HostProtocols returns the sorted list of the protocols the host has stream
+// handlers for. It implements SwarmAPI.ListProtocols.
+func HostProtocols(h host.Host) []protocol.ID {
+	protocols := h.Mux().Protocols()
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i] < protocols[j] })
+	return protocols
 }
@@ -0,0 +1,40 @@
+package iface
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikelsr/go-libp2p/core/network"
+	"github.com/mikelsr/go-libp2p/core/protocol"
+	mocknet "github.com/mikelsr/go-libp2p/p2p/net/mock"
+)
+
+func TestHostProtocols(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	h, err := mn.GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := HostProtocols(h)
+	h.SetStreamHandler("/custom/bitswap/1.2.0", func(network.Stream) {})
+	h.SetStreamHandler("/aaa/1.0.0", func(network.Stream) {})
+
+	expected := append([]protocol.ID{"/aaa/1.0.0", "/custom/bitswap/1.2.0"}, before...)
+	got := HostProtocols(h)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i-1] >= got[i] {
+			t.Fatalf("expected sorted protocols, got %v", got)
+		}
+	}
+
+	h.RemoveStreamHandler("/aaa/1.0.0")
+	h.RemoveStreamHandler("/custom/bitswap/1.2.0")
+	if got := HostProtocols(h); !reflect.DeepEqual(got, before) {
+		t.Fatalf("expected the removed handlers not to be listed, got %v", got)
+	}
+}
@@ -81,8 +81,10 @@ func TestApi(p Provider) func(t *testing.T) {
 		t.Run("Object", tp.TestObject)
 		t.Run("Path", tp.TestPath)
 		t.Run("Pin", tp.TestPin)
+		t.Run("Repo", tp.TestRepo)
 		t.Run("PubSub", tp.TestPubSub)
 		t.Run("Routing", tp.TestRouting)
+		t.Run("Swarm", tp.TestSwarm)
 		t.Run("Unixfs", tp.TestUnixfs)
 
 		apis <- -1
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"math"
 	gopath "path"
 	"strings"
@@ -10,7 +12,10 @@ import (
 	path "github.com/mikelsr/boxo/coreiface/path"
 
 	coreiface "github.com/mikelsr/boxo/coreiface"
+	opt "github.com/mikelsr/boxo/coreiface/options"
+	mdtest "github.com/mikelsr/boxo/ipld/merkledag/test"
 
+	cid "github.com/ipfs/go-cid"
 	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
 	mh "github.com/multiformats/go-multihash"
@@ -29,6 +34,10 @@ func (tp *TestSuite) TestDag(t *testing.T) {
 	t.Run("TestPath", tp.TestDagPath)
 	t.Run("TestTree", tp.TestTree)
 	t.Run("TestBatch", tp.TestBatch)
+	t.Run("TestResolveLink", tp.TestResolveLink)
+	t.Run("TestGetRaw", tp.TestGetRaw)
+	t.Run("TestWalk", tp.TestDagWalk)
+	t.Run("TestImportJSONStream", tp.TestDagImportJSONStream)
 }
 
 var (
@@ -198,3 +207,184 @@ func (tp *TestSuite) TestBatch(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func (tp *TestSuite) TestResolveLink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snd, err := ipldcbor.FromJSON(strings.NewReader(`"foo"`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = api.Dag().Add(ctx, snd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := ipldcbor.FromJSON(strings.NewReader(`{"lnk": {"/": "`+snd.Cid().String()+`"}}`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = api.Dag().Add(ctx, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := api.Dag().ResolveLink(ctx, nd.Cid(), "lnk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.String() != snd.Cid().String() {
+		t.Errorf("got unexpected cid %s, expected %s", c.String(), snd.Cid().String())
+	}
+
+	_, err = api.Dag().ResolveLink(ctx, nd.Cid(), "missing")
+	if !errors.Is(err, coreiface.ErrLinkNotFound) {
+		t.Errorf("expected ErrLinkNotFound, got %v", err)
+	}
+}
+
+func (tp *TestSuite) TestGetRaw(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {"b": 1, "a": 2}, with the keys not in canonical order, so that
+	// encoding the decoded node gives different bytes
+	data := []byte{0xa2, 0x61, 'b', 0x01, 0x61, 'a', 0x02}
+	stat, err := api.Block().Put(ctx, bytes.NewReader(data), opt.Block.Format("dag-cbor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := stat.Path().Cid()
+
+	raw, err := api.Dag().GetRaw(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, data) {
+		t.Errorf("expected the stored bytes %x, got %x", data, raw)
+	}
+
+	nd, err := api.Dag().Get(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(c) {
+		t.Errorf("expected node %s, got %s", c, nd.Cid())
+	}
+}
+
+func (tp *TestSuite) TestDagWalk(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root -> a, b; a -> c; b -> c
+	root, _, b, c, err := mdtest.DiamondDAG(ctx, api.Dag())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(p path.Path, visit coreiface.DagWalkFunc, opts ...opt.DagWalkOption) ([]cid.Cid, error) {
+		var visited []cid.Cid
+		err := api.Dag().Walk(ctx, p, func(k cid.Cid, nd ipld.Node) error {
+			visited = append(visited, k)
+			return visit(k, nd)
+		}, opts...)
+		return visited, err
+	}
+	noop := func(cid.Cid, ipld.Node) error { return nil }
+
+	visited, err := walk(path.IpfsPath(root.Cid()), noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 4 || !visited[0].Equals(root.Cid()) {
+		t.Fatalf("expected the 4 nodes starting with the root, got %v", visited)
+	}
+
+	visited, err = walk(path.IpfsPath(root.Cid()), noop, opt.Dag.Dedup(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 5 {
+		t.Fatalf("expected c to be visited twice, got %v", visited)
+	}
+
+	visited, err = walk(path.Join(path.IpfsPath(root.Cid()), "b"), noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 2 || !visited[0].Equals(b.Cid()) || !visited[1].Equals(c.Cid()) {
+		t.Fatalf("expected b and c, got %v", visited)
+	}
+
+	visited, err = walk(path.IpfsPath(root.Cid()), func(k cid.Cid, _ ipld.Node) error {
+		if k.Equals(root.Cid()) {
+			return coreiface.SkipChildren
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 1 {
+		t.Fatalf("expected only the root, got %v", visited)
+	}
+
+	errVisit := errors.New("visit failed")
+	_, err = walk(path.IpfsPath(root.Cid()), func(k cid.Cid, _ ipld.Node) error {
+		if k.Equals(c.Cid()) {
+			return errVisit
+		}
+		return nil
+	}, opt.Dag.Concurrency(4))
+	if !errors.Is(err, errVisit) {
+		t.Fatalf("expected the visit error, got %v", err)
+	}
+}
+
+func (tp *TestSuite) TestDagImportJSONStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := "{\"a\": 1}\n{\"b\": [1, 2]}\n\n{\"c\": {\"d\": \"e\"}}\n"
+	paths, err := api.Dag().ImportJSONStream(ctx, strings.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(paths))
+	}
+
+	rp, err := api.ResolvePath(ctx, path.Join(paths[2], "c", "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rp.Cid().Equals(paths[2].Cid()) || rp.Remainder() != "c/d" {
+		t.Errorf("expected %s with the remainder c/d, got %s and %q", paths[2].Cid(), rp.Cid(), rp.Remainder())
+	}
+
+	_, err = api.Dag().ImportJSONStream(ctx, strings.NewReader("{\"a\": 1}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
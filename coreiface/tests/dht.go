@@ -21,6 +21,7 @@ func (tp *TestSuite) TestDht(t *testing.T) {
 	t.Run("TestDhtFindPeer", tp.TestDhtFindPeer)
 	t.Run("TestDhtFindProviders", tp.TestDhtFindProviders)
 	t.Run("TestDhtProvide", tp.TestDhtProvide)
+	t.Run("TestDhtProvideContinuously", tp.TestDhtProvideContinuously)
 }
 
 func (tp *TestSuite) TestDhtFindPeer(t *testing.T) {
@@ -164,3 +165,58 @@ func (tp *TestSuite) TestDhtProvide(t *testing.T) {
 		t.Errorf("got wrong provider: %s != %s", provider.ID.String(), self0.ID().String())
 	}
 }
+
+func (tp *TestSuite) TestDhtProvideContinuously(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	off0, err := apis[0].WithOptions(options.Api.Offline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := off0.Block().Put(ctx, &io.LimitedReader{R: rnd, N: 4092})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := s.Path()
+
+	self0, err := apis[0].Key().Self(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := apis[0].Dht().ProvideContinuously(ctx, p, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := apis[2].Dht().FindProviders(ctx, p, options.Dht.NumProviders(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := <-out
+
+	if provider.ID.String() != self0.ID().String() {
+		t.Errorf("got wrong provider: %s != %s", provider.ID.String(), self0.ID().String())
+	}
+
+	// stop must be idempotent
+	stop()
+	stop()
+
+	// stopping through the context must not block stop either
+	pctx, pcancel := context.WithCancel(ctx)
+	stop, err = apis[0].Dht().ProvideContinuously(pctx, p, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcancel()
+	stop()
+}
@@ -35,6 +35,7 @@ func (tp *TestSuite) TestKey(t *testing.T) {
 	t.Run("TestRenameSameNameNoForce", tp.TestRenameSameNameNoForce)
 	t.Run("TestRenameSameName", tp.TestRenameSameName)
 	t.Run("TestRemove", tp.TestRemove)
+	t.Run("TestExportImport", tp.TestExportImport)
 }
 
 func (tp *TestSuite) TestListSelf(t *testing.T) {
@@ -536,3 +537,41 @@ func (tp *TestSuite) TestRemove(t *testing.T) {
 		t.Errorf("expected the key to be called 'self', got '%s'", l[0].Name())
 	}
 }
+
+func (tp *TestSuite) TestExportImport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := api.Key().Generate(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := api.Key().Export(ctx, "foo", []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Key().Remove(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.Key().Import(ctx, "bar", blob, []byte("wrong")); err == nil {
+		t.Fatal("expected import with a wrong passphrase to fail")
+	}
+
+	imported, err := api.Key().Import(ctx, "bar", blob, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Name() != "bar" {
+		t.Errorf("expected the key to be called 'bar', got '%s'", imported.Name())
+	}
+	if imported.ID() != k.ID() {
+		t.Errorf("expected the imported key to have ID %s, got %s", k.ID(), imported.ID())
+	}
+}
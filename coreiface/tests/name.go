@@ -27,6 +27,7 @@ func (tp *TestSuite) TestName(t *testing.T) {
 	t.Run("TestPublishResolve", tp.TestPublishResolve)
 	t.Run("TestBasicPublishResolveKey", tp.TestBasicPublishResolveKey)
 	t.Run("TestBasicPublishResolveTimeout", tp.TestBasicPublishResolveTimeout)
+	t.Run("TestPublishTTLAndLifetime", tp.TestPublishTTLAndLifetime)
 }
 
 var rnd = rand.New(rand.NewSource(0x62796532303137))
@@ -164,4 +165,35 @@ func (tp *TestSuite) TestBasicPublishResolveTimeout(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func (tp *TestSuite) TestPublishTTLAndLifetime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 2)
+	require.NoError(t, err)
+	api := apis[0]
+
+	p, err := addTestObject(ctx, api)
+	require.NoError(t, err)
+
+	_, err = api.Name().Publish(ctx, p, opt.Name.TTL(time.Hour), opt.Name.Lifetime(time.Minute))
+	require.Error(t, err, "the TTL can't be longer than the lifetime")
+
+	start := time.Now()
+	name, err := api.Name().Publish(ctx, p, opt.Name.TTL(30*time.Second), opt.Name.Lifetime(72*time.Hour), opt.Name.AllowOffline(true))
+	require.NoError(t, err)
+
+	data, err := api.Routing().Get(ctx, ipns.NamespacePrefix+name.String())
+	require.NoError(t, err)
+	rec, err := ipns.UnmarshalRecord(data)
+	require.NoError(t, err)
+
+	ttl, err := rec.TTL()
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, ttl)
+
+	eol, err := rec.Validity()
+	require.NoError(t, err)
+	require.WithinDuration(t, start.Add(72*time.Hour), eol, time.Minute)
+}
+
 //TODO: When swarm api is created, add multinode tests
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	gopath "path"
+	"strings"
+	"sync"
+	"time"
+
+	coreiface "github.com/mikelsr/boxo/coreiface"
+	"github.com/mikelsr/boxo/coreiface/options"
+	"github.com/mikelsr/boxo/coreiface/path"
+	"github.com/mikelsr/boxo/ipns"
+)
+
+// NameTTLResolver is implemented by the NameAPIs that can tell how long a
+// resolved path can be cached, like MockNameAPI.
+type NameTTLResolver interface {
+	// ResolveWithTTL is like NameAPI.Resolve but also returns the TTL of the
+	// records followed to resolve the name, nil if they have none.
+	ResolveWithTTL(ctx context.Context, name string, opts ...options.NameResolveOption) (path.Path, *time.Duration, error)
+}
+
+// CachingNameAPI is a coreiface.NameAPI caching the paths resolved by another
+// NameAPI, keyed by the /ipns path of the name, in which an IPNS name is
+// normalized so that all its encodings share an entry. Entries are kept for
+// the TTL of the records if the NameAPI is a NameTTLResolver and they have
+// one, but never longer than the TTL of the cache. The expired entries are
+// dropped whenever the cache is accessed.
+//
+// Only the resolutions with the default resolve options are cached, and
+// options.Name.Cache(false) bypasses the cache. Publishing a name through the
+// CachingNameAPI invalidates its entry.
+type CachingNameAPI struct {
+	coreiface.NameAPI
+
+	ttl time.Duration
+	now func() time.Time
+
+	lk      sync.Mutex
+	entries map[string]cachedPath
+}
+
+type cachedPath struct {
+	path   path.Path
+	expiry time.Time
+}
+
+var _ coreiface.NameAPI = (*CachingNameAPI)(nil)
+
+// NewCachingNameAPI wraps api with a cache keeping the resolved paths for at
+// most ttl.
+func NewCachingNameAPI(api coreiface.NameAPI, ttl time.Duration) *CachingNameAPI {
+	return &CachingNameAPI{
+		NameAPI: api,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cachedPath),
+	}
+}
+
+// Publish publishes the path with the wrapped NameAPI and invalidates the
+// cached path of the name.
+func (c *CachingNameAPI) Publish(ctx context.Context, p path.Path, opts ...options.NamePublishOption) (ipns.Name, error) {
+	name, err := c.NameAPI.Publish(ctx, p, opts...)
+	if err != nil {
+		return name, err
+	}
+
+	c.lk.Lock()
+	c.pruneLocked()
+	delete(c.entries, cacheKey(name.String()))
+	c.lk.Unlock()
+	return name, nil
+}
+
+// Resolve returns the cached path of the name if it hasn't expired, and
+// otherwise resolves it with the wrapped NameAPI.
+func (c *CachingNameAPI) Resolve(ctx context.Context, name string, opts ...options.NameResolveOption) (path.Path, error) {
+	settings, err := options.NameResolveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Cache || len(settings.ResolveOpts) != 0 {
+		return c.NameAPI.Resolve(ctx, name, opts...)
+	}
+
+	key := cacheKey(name)
+	c.lk.Lock()
+	c.pruneLocked()
+	entry, ok := c.entries[key]
+	c.lk.Unlock()
+	if ok {
+		return entry.path, nil
+	}
+
+	ttl := c.ttl
+	var p path.Path
+	if tr, ok := c.NameAPI.(NameTTLResolver); ok {
+		var recordTTL *time.Duration
+		p, recordTTL, err = tr.ResolveWithTTL(ctx, name, opts...)
+		if recordTTL != nil && *recordTTL < ttl {
+			ttl = *recordTTL
+		}
+	} else {
+		p, err = c.NameAPI.Resolve(ctx, name, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		c.lk.Lock()
+		c.entries[key] = cachedPath{path: p, expiry: c.now().Add(ttl)}
+		c.lk.Unlock()
+	}
+	return p, nil
+}
+
+// pruneLocked drops the expired entries, c.lk must be held
+func (c *CachingNameAPI) pruneLocked() {
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiry) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cacheKey returns the cleaned /ipns path of the name, in which an IPNS name
+// is encoded like ipns.Name.String whatever its encoding in name
+func cacheKey(name string) string {
+	p := gopath.Clean("/ipns/" + strings.TrimPrefix(name, "/ipns/"))
+	root, rest, _ := strings.Cut(strings.TrimPrefix(p, "/ipns/"), "/")
+	if n, err := ipns.NameFromString(root); err == nil {
+		root = n.String()
+	}
+	return gopath.Join("/ipns", root, rest)
+}
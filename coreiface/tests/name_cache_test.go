@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikelsr/boxo/coreiface/options"
+	"github.com/mikelsr/boxo/coreiface/path"
+)
+
+func TestCachingNameAPI(t *testing.T) {
+	ctx := context.Background()
+	self := makeName(t)
+	mock := NewMockNameAPI(self)
+	api := NewCachingNameAPI(mock, time.Minute)
+	now := time.Now()
+	api.now = func() time.Time { return now }
+
+	p1 := path.New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	p2 := path.New("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n")
+	expectResolve := func(name string, expected path.Path, opts ...options.NameResolveOption) {
+		t.Helper()
+		resolved, err := api.Resolve(ctx, name, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.String() != expected.String() {
+			t.Fatalf("expected %s, got %s", expected, resolved)
+		}
+	}
+
+	if _, err := mock.Publish(ctx, p1); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.String(), p1)
+
+	// publishing with the wrapped API doesn't invalidate the cache
+	if _, err := mock.Publish(ctx, p2); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve("/ipns/"+self.String(), p1)
+	expectResolve(self.String(), p2, options.Name.Cache(false))
+
+	now = now.Add(time.Minute)
+	expectResolve(self.String(), p2)
+
+	// publishing through the cache invalidates the entry
+	if _, err := api.Publish(ctx, p1); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.String(), p1)
+
+	// the TTL of the record is used when it is shorter
+	if _, err := api.Publish(ctx, p2, options.Name.TTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.String(), p2)
+	if _, err := mock.Publish(ctx, p1); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.String(), p2)
+	now = now.Add(time.Second)
+	expectResolve(self.String(), p1)
+
+	// the peer ID encoding of the name shares the entry: publishing
+	// invalidates it
+	expectResolve(self.Peer().String(), p1)
+	if _, err := api.Publish(ctx, p2); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.Peer().String(), p2)
+	if _, err := mock.Publish(ctx, p1); err != nil {
+		t.Fatal(err)
+	}
+	expectResolve(self.String(), p2)
+
+	// the expired entries are dropped when the cache is accessed
+	mock.SetDNSLink("example.com", p1)
+	expectResolve("example.com", p1)
+	now = now.Add(2 * time.Minute)
+	expectResolve("/ipns/example.com/", p1)
+	if n := len(api.entries); n != 1 {
+		t.Fatalf("expected the expired entry of the IPNS name to be dropped, %d entries left", n)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	self := makeName(t)
+	for _, name := range []string{
+		self.String(),
+		"/ipns/" + self.String(),
+		self.Peer().String(),
+		"/ipns/" + self.Peer().String() + "/",
+	} {
+		if key := cacheKey(name); key != "/ipns/"+self.String() {
+			t.Fatalf("%s: expected the key /ipns/%s, got %s", name, self, key)
+		}
+	}
+	if key := cacheKey("/ipns/" + self.Peer().String() + "/a/../b"); key != "/ipns/"+self.String()+"/b" {
+		t.Fatalf("expected the key of a path to keep its cleaned segments, got %s", key)
+	}
+	if key := cacheKey("example.com/a"); key != "/ipns/example.com/a" {
+		t.Fatalf("expected the key of a DNSLink name to be kept, got %s", key)
+	}
+}
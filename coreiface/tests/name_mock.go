@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	coreiface "github.com/mikelsr/boxo/coreiface"
+	"github.com/mikelsr/boxo/coreiface/options"
+	nsopts "github.com/mikelsr/boxo/coreiface/options/namesys"
+	"github.com/mikelsr/boxo/coreiface/path"
+	"github.com/mikelsr/boxo/ipns"
+)
+
+// ErrMockResolveRecursion is returned by MockNameAPI when the depth limit is
+// reached before resolving a name to an immutable path.
+var ErrMockResolveRecursion = errors.New("could not resolve name (recursion limit exceeded)")
+
+// MockNameAPI is an in-memory coreiface.NameAPI for testing code that uses
+// IPNS without running a node. Published names are kept in a map, and DNSLink
+// entries can be added with SetDNSLink.
+type MockNameAPI struct {
+	self ipns.Name
+
+	lk       sync.RWMutex
+	keys     map[string]ipns.Name
+	records  map[string]path.Path
+	ttls     map[string]time.Duration
+	dnslinks map[string]path.Path
+}
+
+var (
+	_ coreiface.NameAPI = (*MockNameAPI)(nil)
+	_ NameTTLResolver   = (*MockNameAPI)(nil)
+)
+
+// NewMockNameAPI creates a MockNameAPI publishing under the given name when
+// the "self" key is used.
+func NewMockNameAPI(self ipns.Name) *MockNameAPI {
+	return &MockNameAPI{
+		self:     self,
+		keys:     make(map[string]ipns.Name),
+		records:  make(map[string]path.Path),
+		ttls:     make(map[string]time.Duration),
+		dnslinks: make(map[string]path.Path),
+	}
+}
+
+// AddKey makes the given name available to Publish under the key alias.
+func (m *MockNameAPI) AddKey(alias string, name ipns.Name) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.keys[alias] = name
+}
+
+// SetDNSLink sets the DNSLink entry of the given domain to p.
+func (m *MockNameAPI) SetDNSLink(domain string, p path.Path) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.dnslinks[domain] = p
+}
+
+// Publish stores the path under the name of the key in the options, with the
+// TTL if one is given.
+func (m *MockNameAPI) Publish(ctx context.Context, p path.Path, opts ...options.NamePublishOption) (ipns.Name, error) {
+	settings, err := options.NamePublishOptions(opts...)
+	if err != nil {
+		return ipns.Name{}, err
+	}
+	if err := p.IsValid(); err != nil {
+		return ipns.Name{}, err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	name, ok := m.keys[settings.Key]
+	if !ok {
+		if settings.Key != "self" {
+			name, err = ipns.NameFromString(settings.Key)
+			if err != nil {
+				return ipns.Name{}, fmt.Errorf("no key by the given name or PeerID was found: %w", err)
+			}
+		} else {
+			name = m.self
+		}
+	}
+
+	m.records[name.String()] = p
+	if settings.TTL != nil {
+		m.ttls[name.String()] = *settings.TTL
+	} else {
+		delete(m.ttls, name.String())
+	}
+	return name, nil
+}
+
+// Resolve resolves the name, following published names and DNSLink entries
+// until an immutable path is found or the depth limit is reached.
+func (m *MockNameAPI) Resolve(ctx context.Context, name string, opts ...options.NameResolveOption) (path.Path, error) {
+	p, _, err := m.ResolveWithTTL(ctx, name, opts...)
+	return p, err
+}
+
+// ResolveWithTTL is like Resolve but also returns the shortest TTL of the
+// records followed, nil if none had a TTL. DNSLink entries have no TTL.
+func (m *MockNameAPI) ResolveWithTTL(ctx context.Context, name string, opts ...options.NameResolveOption) (path.Path, *time.Duration, error) {
+	settings, err := options.NameResolveOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	depth := nsopts.ProcessOpts(settings.ResolveOpts).Depth
+
+	m.lk.RLock()
+	defer m.lk.RUnlock()
+
+	var ttl *time.Duration
+	p := path.New("/ipns/" + strings.TrimPrefix(name, "/ipns/"))
+	for i := uint(0); depth == nsopts.UnlimitedDepth || i < depth; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		var recordTTL *time.Duration
+		p, recordTTL, err = m.resolveOnce(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if recordTTL != nil && (ttl == nil || *recordTTL < *ttl) {
+			ttl = recordTTL
+		}
+		if !p.Mutable() {
+			return p, ttl, nil
+		}
+	}
+	return p, ttl, ErrMockResolveRecursion
+}
+
+// resolveOnce resolves the first component of a /ipns path, keeping the rest
+// of the path, and returns the TTL of the record if it has one
+func (m *MockNameAPI) resolveOnce(p path.Path) (path.Path, *time.Duration, error) {
+	segments := strings.SplitN(strings.TrimPrefix(p.String(), "/ipns/"), "/", 2)
+	key := segments[0]
+
+	var resolved path.Path
+	var ttl *time.Duration
+	if name, err := ipns.NameFromString(key); err == nil {
+		resolved = m.records[name.String()]
+		if t, ok := m.ttls[name.String()]; ok {
+			ttl = &t
+		}
+	} else {
+		resolved = m.dnslinks[key]
+	}
+	if resolved == nil {
+		return nil, nil, coreiface.ErrResolveFailed
+	}
+
+	if len(segments) > 1 && segments[1] != "" {
+		return path.Join(resolved, segments[1]), ttl, nil
+	}
+	return resolved, ttl, nil
+}
+
+// Search resolves the name and sends the result on the returned channel.
+func (m *MockNameAPI) Search(ctx context.Context, name string, opts ...options.NameResolveOption) (<-chan coreiface.IpnsResult, error) {
+	p, err := m.Resolve(ctx, name, opts...)
+
+	out := make(chan coreiface.IpnsResult, 1)
+	out <- coreiface.IpnsResult{Path: p, Err: err}
+	close(out)
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/mikelsr/boxo/coreiface/options"
+	nsopts "github.com/mikelsr/boxo/coreiface/options/namesys"
+	"github.com/mikelsr/boxo/coreiface/path"
+	"github.com/mikelsr/boxo/ipns"
+	"github.com/mikelsr/go-libp2p/core/crypto"
+	"github.com/mikelsr/go-libp2p/core/peer"
+)
+
+func makeName(t *testing.T) ipns.Name {
+	_, pk, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ipns.NameFromPeer(pid)
+}
+
+func TestMockNamePublishResolve(t *testing.T) {
+	ctx := context.Background()
+	self := makeName(t)
+	api := NewMockNameAPI(self)
+
+	p := path.New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	name, err := api.Publish(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !name.Equal(self) {
+		t.Fatalf("expected name %s, got %s", self, name)
+	}
+
+	resolved, err := api.Resolve(ctx, "/ipns/"+name.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.String() != p.String() {
+		t.Fatalf("expected %s, got %s", p, resolved)
+	}
+
+	other := makeName(t)
+	api.AddKey("other", other)
+	name, err = api.Publish(ctx, path.Join(p, "foo"), options.Name.Key("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !name.Equal(other) {
+		t.Fatalf("expected name %s, got %s", other, name)
+	}
+
+	resolved, err = api.Resolve(ctx, name.String()+"/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.String() != p.String()+"/foo/bar" {
+		t.Fatalf("expected %s/foo/bar, got %s", p, resolved)
+	}
+
+	if _, err := api.Resolve(ctx, makeName(t).String()); err == nil {
+		t.Fatal("expected unknown name not to resolve")
+	}
+
+	if _, err := api.Publish(ctx, p, options.Name.TTL(time.Hour), options.Name.Lifetime(time.Minute)); err == nil {
+		t.Fatal("expected a TTL longer than the lifetime to be rejected")
+	}
+}
+
+func TestMockNameResolveDNSLink(t *testing.T) {
+	ctx := context.Background()
+	self := makeName(t)
+	api := NewMockNameAPI(self)
+
+	p := path.New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6")
+	if _, err := api.Publish(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	// example.com -> www.example.com -> self -> p
+	api.SetDNSLink("www.example.com", path.New("/ipns/"+self.String()))
+	api.SetDNSLink("example.com", path.New("/ipns/www.example.com/sub"))
+
+	resolved, err := api.Resolve(ctx, "/ipns/example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.String() != p.String()+"/sub" {
+		t.Fatalf("expected %s/sub, got %s", p, resolved)
+	}
+
+	results, err := api.Search(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-results
+	if res.Err != nil || res.Path.String() != p.String()+"/sub" {
+		t.Fatalf("unexpected search result %v", res)
+	}
+
+	_, err = api.Resolve(ctx, "example.com", options.Name.ResolveOption(nsopts.Depth(2)))
+	if err != ErrMockResolveRecursion {
+		t.Fatalf("expected recursion error, got %v", err)
+	}
+}
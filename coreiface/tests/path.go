@@ -1,25 +1,42 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"strings"
 	"testing"
 
 	"github.com/mikelsr/boxo/coreiface/path"
 
+	coreiface "github.com/mikelsr/boxo/coreiface"
 	"github.com/mikelsr/boxo/coreiface/options"
+	"github.com/mikelsr/boxo/files"
+	"github.com/mikelsr/boxo/ipld/merkledag"
+	ft "github.com/mikelsr/boxo/ipld/unixfs"
+	"github.com/mikelsr/boxo/ipld/unixfs/hamt"
 
+	cid "github.com/ipfs/go-cid"
 	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func (tp *TestSuite) TestPath(t *testing.T) {
 	t.Run("TestMutablePath", tp.TestMutablePath)
 	t.Run("TestPathRemainder", tp.TestPathRemainder)
 	t.Run("TestEmptyPathRemainder", tp.TestEmptyPathRemainder)
+	t.Run("TestPathRawLeafRemainder", tp.TestPathRawLeafRemainder)
 	t.Run("TestInvalidPathRemainder", tp.TestInvalidPathRemainder)
+	t.Run("TestPathIdentityCid", tp.TestPathIdentityCid)
+	t.Run("TestPathDotSegments", tp.TestPathDotSegments)
 	t.Run("TestPathRoot", tp.TestPathRoot)
 	t.Run("TestPathJoin", tp.TestPathJoin)
+	t.Run("TestResolveNodeReader", tp.TestResolveNodeReader)
+	t.Run("TestResolveShardedDirectory", tp.TestResolveShardedDirectory)
+	t.Run("TestResolveMaxBytes", tp.TestResolveMaxBytes)
 }
 
 func (tp *TestSuite) TestMutablePath(t *testing.T) {
@@ -84,6 +101,9 @@ func (tp *TestSuite) TestPathRemainder(t *testing.T) {
 	if rp1.Remainder() != "foo/bar" {
 		t.Error("expected to get path remainder")
 	}
+	if rp1.FullyResolved() {
+		t.Error("expected the path with a remainder not to be fully resolved")
+	}
 }
 
 func (tp *TestSuite) TestEmptyPathRemainder(t *testing.T) {
@@ -115,6 +135,70 @@ func (tp *TestSuite) TestEmptyPathRemainder(t *testing.T) {
 	if rp1.Remainder() != "" {
 		t.Error("expected the resolved path to not have a remainder")
 	}
+	if !rp1.FullyResolved() {
+		t.Error("expected the resolved path to be fully resolved")
+	}
+}
+
+func (tp *TestSuite) TestPathRawLeafRemainder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"leaf": files.NewBytesFile([]byte("raw leaf")),
+	})
+	root, err := api.Unixfs().Add(ctx, dir, options.Unixfs.RawLeaves(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := api.ResolvePath(ctx, path.Join(root, "leaf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Cid().Type() != cid.Raw {
+		t.Fatalf("expected the file to be a raw leaf, got codec %d", leaf.Cid().Type())
+	}
+	if !leaf.FullyResolved() || leaf.StoppedAtRawLeaf() {
+		t.Error("expected the path of the raw leaf to be fully resolved")
+	}
+
+	rp, err := api.ResolvePath(ctx, path.Join(root, "leaf", "foo", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rp.Cid().Equals(leaf.Cid()) {
+		t.Errorf("expected the resolution to stop at the raw leaf %s, got %s", leaf.Cid(), rp.Cid())
+	}
+	if rp.Remainder() != "foo/bar" {
+		t.Errorf("expected the remainder after the raw leaf to be foo/bar, got %q", rp.Remainder())
+	}
+	if rp.FullyResolved() {
+		t.Error("expected the path with a remainder not to be fully resolved")
+	}
+	if !rp.StoppedAtRawLeaf() {
+		t.Error("expected the resolution to have stopped at the raw leaf")
+	}
+
+	// a remainder within a CBOR node is not a stop at a raw leaf
+	nd, err := ipldcbor.FromJSON(strings.NewReader(`{"foo": {"bar": "baz"}}`), math.MaxUint64, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Dag().Add(ctx, nd); err != nil {
+		t.Fatal(err)
+	}
+	rp, err = api.ResolvePath(ctx, path.New(nd.String()+"/foo/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.StoppedAtRawLeaf() {
+		t.Error("expected a remainder within a CBOR node not to be a stop at a raw leaf")
+	}
 }
 
 func (tp *TestSuite) TestInvalidPathRemainder(t *testing.T) {
@@ -139,8 +223,93 @@ func (tp *TestSuite) TestInvalidPathRemainder(t *testing.T) {
 	}
 
 	_, err = api.ResolvePath(ctx, path.New("/ipld/"+nd.Cid().String()+"/bar/baz"))
-	if err == nil || !strings.Contains(err.Error(), `no link named "bar"`) {
-		t.Fatalf("unexpected error: %s", err)
+	var noLink coreiface.ErrNoLink
+	if !errors.As(err, &noLink) {
+		t.Fatalf("expected ErrNoLink, got %v", err)
+	}
+	if noLink.Name != "bar" || !noLink.Node.Equals(nd.Cid()) {
+		t.Fatalf("unexpected missing link %q under %s", noLink.Name, noLink.Node)
+	}
+}
+
+func (tp *TestSuite) TestPathIdentityCid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the node is in its CID, so it resolves without being added and
+	// without fetching anything
+	api, err = api.WithOptions(options.Api.Offline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := ipldcbor.FromJSON(strings.NewReader(`{"foo": {"bar": "baz"}}`), mh.IDENTITY, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rp, err := api.ResolvePath(ctx, path.New(nd.String()+"/foo/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rp.Cid().Equals(nd.Cid()) || !rp.Root().Equals(nd.Cid()) {
+		t.Errorf("expected the path to resolve to %s, got %s", nd.Cid(), rp.Cid())
+	}
+	if rp.Remainder() != "foo/bar" {
+		t.Errorf("expected remainder foo/bar, got %q", rp.Remainder())
+	}
+
+	rnd, err := api.ResolveNode(ctx, path.New(nd.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rnd.RawData(), nd.RawData()) {
+		t.Error("unexpected node data")
+	}
+}
+
+func (tp *TestSuite) TestPathDotSegments(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewMapDirectory(map[string]files.Node{
+			"c": files.NewBytesFile([]byte("c")),
+		}),
+		"b": files.NewBytesFile([]byte("b")),
+	})
+	root, err := api.Unixfs().Add(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := api.ResolvePath(ctx, path.Join(root, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"a/../b", "./b", "a/c/../../b", "a/./../b"} {
+		rp, err := api.ResolvePath(ctx, path.Join(root, p))
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		if !rp.Cid().Equals(expected.Cid()) {
+			t.Errorf("%s: expected %s, got %s", p, expected.Cid(), rp.Cid())
+		}
+	}
+
+	for _, p := range []string{"..", "a/../../b"} {
+		if _, err := api.ResolvePath(ctx, path.Join(root, p)); err == nil {
+			t.Errorf("%s: expected an error for a path going above the root", p)
+		}
 	}
 }
 
@@ -195,3 +364,180 @@ func (tp *TestSuite) TestPathJoin(t *testing.T) {
 		t.Error("unexpected path")
 	}
 }
+
+func (tp *TestSuite) TestResolveNodeReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("0123456789", 100)
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile([]byte(data)),
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"42": files.NewBytesFile([]byte("not an offset")),
+		}),
+	})
+	root, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Chunker("size-100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(p string) (string, bool) {
+		t.Helper()
+		nd, r, err := coreiface.ResolveNodeReader(ctx, api, path.Join(root, p))
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		if nd == nil {
+			t.Fatalf("%s: no node", p)
+		}
+		if r == nil {
+			return "", false
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b), true
+	}
+
+	if got, ok := read("file"); !ok || got != data {
+		t.Fatalf("unexpected content of file")
+	}
+	if got, ok := read("file/150"); !ok || got != data[150:] {
+		t.Fatalf("unexpected content of file from offset 150")
+	}
+	if got, ok := read("sub/42"); !ok || got != "not an offset" {
+		t.Fatalf("expected the link named 42 to be resolved, got %q", got)
+	}
+	if _, ok := read("sub"); ok {
+		t.Fatal("expected no reader for a directory")
+	}
+
+	if _, _, err := coreiface.ResolveNodeReader(ctx, api, path.Join(root, "file", "1001")); !errors.Is(err, coreiface.ErrRangeNotSatisfiable) {
+		t.Fatalf("expected ErrRangeNotSatisfiable, got %v", err)
+	}
+	if _, _, err := coreiface.ResolveNodeReader(ctx, api, path.Join(root, "sub", "7")); err == nil {
+		t.Fatal("expected an error for an offset in a directory")
+	}
+	// only the last segment can be an offset, even if an earlier missing
+	// link has the same name
+	var noLink coreiface.ErrNoLink
+	if _, _, err := coreiface.ResolveNodeReader(ctx, api, path.Join(root, "file", "5", "5")); !errors.As(err, &noLink) {
+		t.Fatalf("expected ErrNoLink for a missing link before the offset, got %v", err)
+	}
+	if _, _, err := coreiface.ResolveNodeReader(ctx, api, path.Join(root, "sub", "7", "7")); !errors.As(err, &noLink) {
+		t.Fatalf("expected ErrNoLink for a missing link before the offset, got %v", err)
+	}
+}
+
+func (tp *TestSuite) TestResolveShardedDirectory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a small fanout makes the entries spread over several levels of shards
+	shard, err := hamt.NewShard(api.Dag(), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string]cid.Cid)
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("file-%d", i)
+		data := []byte(name)
+		nd := merkledag.NodeWithData(ft.FilePBData(data, uint64(len(data))))
+		if err := api.Dag().Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := shard.Set(ctx, name, nd); err != nil {
+			t.Fatal(err)
+		}
+		entries[name] = nd.Cid()
+	}
+	root, err := shard.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"file-0", "file-42", "file-199"} {
+		p, err := api.ResolvePath(ctx, path.Join(path.IpfsPath(root.Cid()), name))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if p.Cid() != entries[name] {
+			t.Fatalf("%s: expected %s, got %s", name, entries[name], p.Cid())
+		}
+		if p.Remainder() != "" {
+			t.Fatalf("%s: unexpected remainder %q", name, p.Remainder())
+		}
+
+		nd, err := api.ResolveNode(ctx, path.Join(path.IpfsPath(root.Cid()), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsn, err := ft.ExtractFSNode(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(fsn.Data()) != name {
+			t.Fatalf("%s: unexpected content %q", name, fsn.Data())
+		}
+	}
+
+	if _, err := api.ResolvePath(ctx, path.Join(path.IpfsPath(root.Cid()), "file-200")); err == nil {
+		t.Fatal("expected an error for a missing entry")
+	}
+}
+
+func (tp *TestSuite) TestResolveMaxBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file of 100 blocks of 100 bytes under a few levels of directories
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewMapDirectory(map[string]files.Node{
+			"b": files.NewMapDirectory(map[string]files.Node{
+				"file": files.NewBytesFile(bytes.Repeat([]byte("0123456789"), 1000)),
+			}),
+		}),
+	})
+	root, err := api.Unixfs().Add(ctx, dir, options.Unixfs.Chunker("size-100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := path.Join(root, "a", "b", "file")
+
+	if _, err := api.ResolvePath(ctx, p, options.Resolve.MaxBytes(10)); !errors.Is(err, coreiface.ErrResolveBudgetExceeded) {
+		t.Fatalf("expected ErrResolveBudgetExceeded, got %v", err)
+	}
+	if _, err := api.ResolveNode(ctx, p, options.Resolve.MaxBytes(10)); !errors.Is(err, coreiface.ErrResolveBudgetExceeded) {
+		t.Fatalf("expected ErrResolveBudgetExceeded, got %v", err)
+	}
+
+	rp, err := api.ResolvePath(ctx, p, options.Resolve.MaxBytes(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := api.ResolveNode(ctx, p, options.Resolve.MaxBytes(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Cid() != rp.Cid() {
+		t.Fatalf("expected node %s, got %s", rp.Cid(), nd.Cid())
+	}
+
+	if _, err := api.ResolvePath(ctx, p, options.Resolve.MaxBytes(-1)); err == nil {
+		t.Fatal("expected an error for a negative budget")
+	}
+}
@@ -24,11 +24,13 @@ func (tp *TestSuite) TestPin(t *testing.T) {
 	})
 
 	t.Run("TestPinAdd", tp.TestPinAdd)
+	t.Run("TestPinAddMany", tp.TestPinAddMany)
 	t.Run("TestPinSimple", tp.TestPinSimple)
 	t.Run("TestPinRecursive", tp.TestPinRecursive)
 	t.Run("TestPinLsIndirect", tp.TestPinLsIndirect)
 	t.Run("TestPinLsPrecedence", tp.TestPinLsPrecedence)
 	t.Run("TestPinIsPinned", tp.TestPinIsPinned)
+	t.Run("TestPinWhich", tp.TestPinWhich)
 }
 
 func (tp *TestSuite) TestPinAdd(t *testing.T) {
@@ -50,6 +52,50 @@ func (tp *TestSuite) TestPinAdd(t *testing.T) {
 	}
 }
 
+func (tp *TestSuite) TestPinAddMany(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p1, err := api.Unixfs().Add(ctx, strFile("foo")())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2, err := api.Unixfs().Add(ctx, strFile("bar")())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := path.New("/ipfs/bafkqaaa/missing")
+
+	results, err := api.Pin().AddMany(ctx, []path.Path{p1, missing, p2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("unexpected number of results: %d", len(results))
+	}
+	for i, p := range []path.Path{p1, missing, p2} {
+		if results[i].Path().String() != p.String() {
+			t.Errorf("unexpected path for result %d: %s", i, results[i].Path())
+		}
+	}
+	if results[0].Err() != nil || results[2].Err() != nil {
+		t.Fatal("expected added paths to be pinned")
+	}
+	if results[1].Err() == nil {
+		t.Error("expected missing path not to be pinned")
+	}
+
+	assertIsPinned(t, ctx, api, p1, "recursive")
+	assertIsPinned(t, ctx, api, p2, "recursive")
+}
+
 func (tp *TestSuite) TestPinSimple(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -204,6 +250,9 @@ func (tp *TestSuite) TestPinRecursive(t *testing.T) {
 		if !r.Ok() {
 			t.Error("expected pin to be ok")
 		}
+		if r.Root().Cid() != nd2.Cid() {
+			t.Errorf("unexpected verified pin %s", r.Root())
+		}
 		n++
 	}
 
@@ -399,6 +448,50 @@ func (tp *TestSuite) TestPinIsPinned(t *testing.T) {
 	assertIsPinned(t, ctx, api, path.IpldPath(leaf.Cid()), "indirect")
 }
 
+func (tp *TestSuite) TestPinWhich(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, parent, grandparent := getThreeChainedNodes(t, ctx, api, "foowhich")
+
+	pins, err := api.Pin().Which(ctx, path.IpldPath(leaf.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatalf("expected no pins, got %d", len(pins))
+	}
+
+	err = api.Pin().Add(ctx, path.IpldPath(parent.Cid()), opt.Pin.Recursive(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = api.Pin().Add(ctx, path.IpldPath(grandparent.Cid()), opt.Pin.Recursive(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pins, err = api.Pin().Which(ctx, path.IpldPath(leaf.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Type() != "recursive" || pins[0].Path().Cid() != parent.Cid() {
+		t.Fatalf("expected the leaf to be pinned by its parent, got %v", pins)
+	}
+
+	pins, err = api.Pin().Which(ctx, path.IpldPath(grandparent.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Type() != "direct" || pins[0].Path().Cid() != grandparent.Cid() {
+		t.Fatalf("expected the grandparent to be pinned directly, got %v", pins)
+	}
+}
+
 type cidContainer interface {
 	Cid() cid.Cid
 }
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	coreiface "github.com/mikelsr/boxo/coreiface"
+	opt "github.com/mikelsr/boxo/coreiface/options"
+)
+
+func (tp *TestSuite) TestRepo(t *testing.T) {
+	t.Run("TestRepoStat", tp.TestRepoStat)
+}
+
+func (tp *TestSuite) TestRepoStat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := api.Stat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := "stat me"
+	if _, err := api.Block().Put(ctx, strings.NewReader(data), opt.Block.Pin(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := api.Stat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRepoStat(t, after)
+	if after.Approximate {
+		// counters may lag behind the added block
+		return
+	}
+	if after.NumBlocks != before.NumBlocks+1 {
+		t.Errorf("expected %d blocks, got %d", before.NumBlocks+1, after.NumBlocks)
+	}
+	if after.Size != before.Size+uint64(len(data)) {
+		t.Errorf("expected a size of %d, got %d", before.Size+uint64(len(data)), after.Size)
+	}
+	if before.NumPins >= 0 && after.NumPins != before.NumPins+1 {
+		t.Errorf("expected %d pins, got %d", before.NumPins+1, after.NumPins)
+	}
+}
+
+func checkRepoStat(t *testing.T, stat coreiface.RepoStat) {
+	t.Helper()
+	if stat.NumBlocks == 0 || stat.Size == 0 {
+		t.Errorf("expected the added block to be counted, got %+v", stat)
+	}
+	if stat.NumPins < -1 {
+		t.Errorf("unexpected number of pins %d", stat.NumPins)
+	}
+}
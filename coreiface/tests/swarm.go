@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	coreiface "github.com/mikelsr/boxo/coreiface"
+
+	bsnet "github.com/mikelsr/boxo/bitswap/network"
+)
+
+func (tp *TestSuite) TestSwarm(t *testing.T) {
+	tp.hasApi(t, func(api coreiface.CoreAPI) error {
+		if api.Swarm() == nil {
+			return errAPINotImplemented
+		}
+		return nil
+	})
+
+	t.Run("TestSwarmListProtocols", tp.TestSwarmListProtocols)
+}
+
+func (tp *TestSuite) TestSwarmListProtocols(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	apis, err := tp.MakeAPISwarm(t, ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocols, err := apis[0].Swarm().ListProtocols(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sort.SliceIsSorted(protocols, func(i, j int) bool { return protocols[i] < protocols[j] }) {
+		t.Errorf("expected the protocols to be sorted, got %v", protocols)
+	}
+
+	// bitswap may be registered with a custom prefix
+	hasBitswap := false
+	for _, p := range protocols {
+		if strings.HasSuffix(string(p), string(bsnet.ProtocolBitswap)) {
+			hasBitswap = true
+		}
+	}
+	if !hasBitswap {
+		t.Errorf("expected %s to be supported, got %v", bsnet.ProtocolBitswap, protocols)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -40,6 +41,7 @@ func (tp *TestSuite) TestUnixfs(t *testing.T) {
 	t.Run("TestAdd", tp.TestAdd)
 	t.Run("TestAddPinned", tp.TestAddPinned)
 	t.Run("TestAddHashOnly", tp.TestAddHashOnly)
+	t.Run("TestAddBlockEvents", tp.TestAddBlockEvents)
 	t.Run("TestGetEmptyFile", tp.TestGetEmptyFile)
 	t.Run("TestGetDir", tp.TestGetDir)
 	t.Run("TestGetNonUnixfs", tp.TestGetNonUnixfs)
@@ -47,9 +49,11 @@ func (tp *TestSuite) TestUnixfs(t *testing.T) {
 	t.Run("TestEntriesExpired", tp.TestEntriesExpired)
 	t.Run("TestLsEmptyDir", tp.TestLsEmptyDir)
 	t.Run("TestLsNonUnixfs", tp.TestLsNonUnixfs)
+	t.Run("TestStat", tp.TestStat)
 	t.Run("TestAddCloses", tp.TestAddCloses)
 	t.Run("TestGetSeek", tp.TestGetSeek)
 	t.Run("TestGetReadAt", tp.TestGetReadAt)
+	t.Run("TestGetRange", tp.TestGetRange)
 }
 
 // `echo -n 'hello, world!' | ipfs add`
@@ -582,6 +586,55 @@ func (tp *TestSuite) TestAddHashOnly(t *testing.T) {
 	}
 }
 
+func (tp *TestSuite) TestAddBlockEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	events := make(chan interface{})
+	var blockEvents []*coreiface.AddBlockEvent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for evt := range events {
+			if evt, ok := evt.(*coreiface.AddBlockEvent); ok {
+				blockEvents = append(blockEvents, evt)
+			}
+		}
+	}()
+
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.Chunker("size-100"),
+		options.Unixfs.Events(events), options.Unixfs.BlockEvents(true))
+	close(events)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 leaves and the root
+	if len(blockEvents) < 11 {
+		t.Fatalf("expected at least 11 block events, got %d", len(blockEvents))
+	}
+	last := blockEvents[len(blockEvents)-1]
+	if !last.Root || last.Cid != p.Cid() || last.Offset != int64(len(data)) {
+		t.Fatalf("unexpected root event %+v", last)
+	}
+	for _, evt := range blockEvents[:len(blockEvents)-1] {
+		if evt.Root {
+			t.Fatal("only the last event should be the root")
+		}
+		if _, err := api.Block().Stat(ctx, path.IpfsPath(evt.Cid)); err != nil {
+			t.Fatalf("block %s of the event was not stored: %s", evt.Cid, err)
+		}
+	}
+}
+
 func (tp *TestSuite) TestGetEmptyFile(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -795,6 +848,45 @@ func (tp *TestSuite) TestLsEmptyDir(t *testing.T) {
 	}
 }
 
+func (tp *TestSuite) TestStat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, files.NewMapDirectory(map[string]files.Node{
+		"file": files.NewBytesFile([]byte(helloStr)),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := api.Unixfs().Stat(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Cid != p.Cid() || stat.Type != coreiface.TDirectory || stat.Size != 0 {
+		t.Errorf("unexpected stat of the directory %+v", stat)
+	}
+	if stat.CumulativeSize == 0 {
+		t.Error("expected the cumulative size of the directory")
+	}
+
+	stat, err = api.Unixfs().Stat(ctx, path.Join(p, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Type != coreiface.TFile || stat.Size != uint64(len(helloStr)) {
+		t.Errorf("unexpected stat of the file %+v", stat)
+	}
+
+	if _, err := api.Unixfs().Stat(ctx, path.Join(p, "missing")); err == nil {
+		t.Error("expected an error for a missing link")
+	}
+}
+
 // TODO(lgierth) this should test properly, with len(links) > 0
 func (tp *TestSuite) TestLsNonUnixfs(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -1078,3 +1170,63 @@ func (tp *TestSuite) TestGetReadAt(t *testing.T) {
 	test(0, int(dataSize), dataSize, false)
 	test(dataSize-50, 100, 50, true)
 }
+
+func (tp *TestSuite) TestGetRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api, err := tp.makeAPI(t, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataSize := int64(100000)
+	orig := make([]byte, dataSize)
+	if _, err := io.ReadFull(rand.New(rand.NewSource(1403768328)), orig); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile(orig), options.Unixfs.Chunker("size-100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := func(offset, length int64, expect []byte) {
+		t.Run(fmt.Sprintf("range%d-%d", offset, length), func(t *testing.T) {
+			r, err := api.Unixfs().Get(ctx, p, options.Unixfs.Range(offset, length))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := files.ToFile(r)
+			if f == nil {
+				t.Fatal("not a file")
+			}
+			defer f.Close()
+
+			data, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, expect) {
+				t.Fatalf("expected %d bytes of range, got %d different bytes", len(expect), len(data))
+			}
+		})
+	}
+
+	test(0, 10, orig[:10])
+	test(150, 1000, orig[150:1150])
+	test(dataSize-10, 10, orig[dataSize-10:])
+	// the range is truncated at the end of the file
+	test(dataSize-10, 100, orig[dataSize-10:])
+
+	if _, err := api.Unixfs().Get(ctx, p, options.Unixfs.Range(dataSize, 1)); !errors.Is(err, coreiface.ErrRangeNotSatisfiable) {
+		t.Fatalf("expected ErrRangeNotSatisfiable, got %v", err)
+	}
+
+	dir, err := api.Unixfs().Add(ctx, twoLevelDir()())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Unixfs().Get(ctx, dir, options.Unixfs.Range(0, 1)); !errors.Is(err, coreiface.ErrIsDir) {
+		t.Fatalf("expected ErrIsDir, got %v", err)
+	}
+}
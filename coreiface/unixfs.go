@@ -17,6 +17,18 @@ type AddEvent struct {
 	Size  string        `json:",omitempty"`
 }
 
+// AddBlockEvent is sent on the Events channel of UnixfsAPI.Add, with the
+// BlockEvents option, for every block produced by the add.
+type AddBlockEvent struct {
+	// Cid is the CID of the block
+	Cid cid.Cid
+	// Offset is the number of bytes of file data added so far, including
+	// the data of the block
+	Offset int64
+	// Root is true for the last event, which reports the root of the add
+	Root bool
+}
+
 // FileType is an enum of possible UnixFS file types.
 type FileType int32
 
@@ -60,6 +72,20 @@ type DirEntry struct {
 	Err error
 }
 
+// UnixfsStat holds the metadata of a UnixFS node, see UnixfsAPI.Stat.
+type UnixfsStat struct {
+	// Cid is the CID of the node the path resolves to
+	Cid cid.Cid
+	// Type is the type of the node
+	Type FileType
+	// Size is the size of the file in bytes, or of the symlink target, 0
+	// for directories
+	Size uint64
+	// CumulativeSize is the size of the DAG of the node, including the
+	// metadata and links
+	CumulativeSize uint64
+}
+
 // UnixfsAPI is the basic interface to immutable files in IPFS
 // NOTE: This API is heavily WIP, things are guaranteed to break frequently
 type UnixfsAPI interface {
@@ -72,9 +98,19 @@ type UnixfsAPI interface {
 	//
 	// Note that some implementations of this API may apply the specified context
 	// to operations performed on the returned file
-	Get(context.Context, path.Path) (files.Node, error)
+	//
+	// With the Range option, the returned file only holds the given byte
+	// range. Implementations skip to the offset using the sizes of the links of
+	// the DAG, without fetching the blocks before it. ErrRangeNotSatisfiable is
+	// returned if the range starts past the end of the file, and ErrIsDir if
+	// the path points to a directory.
+	Get(context.Context, path.Path, ...options.UnixfsGetOption) (files.Node, error)
 
 	// Ls returns the list of links in a directory. Links aren't guaranteed to be
 	// returned in order
 	Ls(context.Context, path.Path, ...options.UnixfsLsOption) (<-chan DirEntry, error)
+
+	// Stat resolves the path and returns the type and the sizes of the UnixFS
+	// node it points to, from that node only.
+	Stat(context.Context, path.Path) (UnixfsStat, error)
 }
@@ -281,7 +281,7 @@ func (bb *BlocksBackend) GetCAR(ctx context.Context, p ImmutablePath, params Car
 // walkGatewaySimpleSelector walks the subgraph described by the path and terminal element parameters
 func walkGatewaySimpleSelector(ctx context.Context, p ipfspath.Path, params CarParams, lsys *ipld.LinkSystem, pathResolver resolver.Resolver) error {
 	// First resolve the path since we always need to.
-	lastCid, remainder, err := pathResolver.ResolveToLastNode(ctx, p)
+	lastCid, remainder, err := resolveToLastNode(ctx, pathResolver, p)
 	if err != nil {
 		return err
 	}
@@ -621,7 +621,7 @@ func (bb *BlocksBackend) resolvePath(ctx context.Context, p ifacepath.Path) (ifa
 		return nil, fmt.Errorf("unsupported path namespace: %s", p.Namespace())
 	}
 
-	node, rest, err := bb.resolver.ResolveToLastNode(ctx, ipath)
+	node, rest, err := resolveToLastNode(ctx, bb.resolver, ipath)
 	if err != nil {
 		return nil, err
 	}
@@ -634,6 +634,17 @@ func (bb *BlocksBackend) resolvePath(ctx context.Context, p ifacepath.Path) (ifa
 	return ifacepath.NewResolvedPath(ipath, node, root, gopath.Join(rest...)), nil
 }
 
+// resolveToLastNode resolves p like the ResolveToLastNode method of r, but the
+// remainder of a path going on after a raw block is not found, as a raw block
+// has no links to follow.
+func resolveToLastNode(ctx context.Context, r resolver.Resolver, p ipfspath.Path) (cid.Cid, []string, error) {
+	c, rest, err := r.ResolveToLastNode(ctx, p)
+	if err == nil && len(rest) > 0 && c.Type() == cid.Raw {
+		return cid.Undef, nil, resolver.ErrNoLink{Name: rest[0], Node: c}
+	}
+	return c, rest, err
+}
+
 type nodeGetterToCarExporer struct {
 	ng format.NodeGetter
 	cw storage.WritableCar
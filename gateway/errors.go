@@ -182,27 +182,16 @@ func isErrNotFound(err error) bool {
 		return true
 	}
 
-	// Checks if err is of a type that does not implement the .Is interface and
-	// cannot be directly compared to. Therefore, errors.Is cannot be used.
-	for {
-		_, ok := err.(resolver.ErrNoLink)
-		if ok {
-			return true
-		}
-
-		_, ok = err.(datamodel.ErrWrongKind)
-		if ok {
-			return true
-		}
-
-		_, ok = err.(datamodel.ErrNotExists)
-		if ok {
-			return true
-		}
+	var noLink resolver.ErrNoLink
+	if errors.As(err, &noLink) {
+		return true
+	}
 
-		err = errors.Unwrap(err)
-		if err == nil {
-			return false
-		}
+	var wrongKind datamodel.ErrWrongKind
+	if errors.As(err, &wrongKind) {
+		return true
 	}
+
+	var notExists datamodel.ErrNotExists
+	return errors.As(err, &notExists)
 }
@@ -102,14 +102,37 @@ func NewImmutablePath(p path.Path) (ImmutablePath, error) {
 	return ImmutablePath{p: p}, nil
 }
 
+// JoinImmutable appends the segments to the immutable path p. The namespace
+// of p doesn't change, so the result is immutable too; only the validity of
+// the joined path is checked.
+func JoinImmutable(p ImmutablePath, segments ...string) (ImmutablePath, error) {
+	if p.p == nil {
+		return ImmutablePath{}, fmt.Errorf("empty immutable path")
+	}
+
+	joined := path.Join(p.p, segments...)
+	if err := joined.IsValid(); err != nil {
+		return ImmutablePath{}, err
+	}
+	return ImmutablePath{p: joined}, nil
+}
+
 func (i ImmutablePath) String() string {
 	return i.p.String()
 }
 
+func (i ImmutablePath) Original() string {
+	return i.p.Original()
+}
+
 func (i ImmutablePath) Namespace() string {
 	return i.p.Namespace()
 }
 
+func (i ImmutablePath) Segments() []string {
+	return i.p.Segments()
+}
+
 func (i ImmutablePath) Mutable() bool {
 	return false
 }
@@ -20,6 +20,32 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestJoinImmutable(t *testing.T) {
+	p, err := NewImmutablePath(ipath.New("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joined, err := JoinImmutable(p, "baz", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined.String() != "/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/bar/baz/foo" {
+		t.Fatalf("unexpected path %s", joined)
+	}
+	if joined.Mutable() {
+		t.Fatal("expected the joined path to be immutable")
+	}
+
+	// a mutable path can't be made an ImmutablePath, and so can't be joined
+	if _, err := NewImmutablePath(ipath.New("/ipns/example.com")); err == nil {
+		t.Fatal("expected an /ipns path to be rejected")
+	}
+	if _, err := JoinImmutable(ImmutablePath{}, "foo"); err == nil {
+		t.Fatal("expected the empty immutable path to be rejected")
+	}
+}
+
 func TestGatewayGet(t *testing.T) {
 	ts, backend, root := newTestServerAndNode(t, nil, "fixtures.car")
 
@@ -0,0 +1,61 @@
+package merkledag
+
+import (
+	"context"
+	"sync"
+
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// Batch is a NodeAdder which keeps the added nodes in memory and writes them
+// to the blockstore all at once, with a single PutMany, when Commit is
+// called. Nodes added to a batch are not retrievable until then.
+//
+// Unlike format.Batch, nothing is written in the background: the whole batch
+// must fit in memory.
+type Batch struct {
+	ds *dagService
+
+	lk    sync.Mutex
+	nodes []format.Node
+}
+
+var _ format.NodeAdder = (*Batch)(nil)
+
+// Batch returns a new, empty, Batch writing to the dagService.
+func (n *dagService) Batch() *Batch {
+	return &Batch{ds: n}
+}
+
+// Add adds a node to the batch.
+func (b *Batch) Add(ctx context.Context, nd format.Node) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.nodes = append(b.nodes, nd)
+	return nil
+}
+
+// AddMany adds many nodes to the batch.
+func (b *Batch) AddMany(ctx context.Context, nds []format.Node) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+	b.nodes = append(b.nodes, nds...)
+	return nil
+}
+
+// Commit writes the nodes of the batch to the dagService. The batch is empty
+// afterwards, and can be reused. If an error is returned, some nodes may or
+// may not have been written, and the batch is left unchanged.
+func (b *Batch) Commit(ctx context.Context) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if len(b.nodes) == 0 {
+		return nil
+	}
+	if err := b.ds.AddMany(ctx, b.nodes); err != nil {
+		return err
+	}
+	b.nodes = nil
+	return nil
+}
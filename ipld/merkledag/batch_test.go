@@ -0,0 +1,73 @@
+package merkledag_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	bserv "github.com/mikelsr/boxo/blockservice"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+	offline "github.com/mikelsr/boxo/exchange/offline"
+)
+
+// countingBlockstore counts the calls to Put and PutMany
+type countingBlockstore struct {
+	blockstore.Blockstore
+	puts, putManys int
+}
+
+func (bs *countingBlockstore) Put(ctx context.Context, blk blocks.Block) error {
+	bs.puts++
+	return bs.Blockstore.Put(ctx, blk)
+}
+
+func (bs *countingBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	bs.putManys++
+	return bs.Blockstore.PutMany(ctx, blks)
+}
+
+func TestBatchCommit(t *testing.T) {
+	ctx := context.Background()
+	bstore := &countingBlockstore{Blockstore: blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))}
+	dserv := NewDAGService(bserv.New(bstore, offline.Exchange(bstore)))
+
+	batch := dserv.Batch()
+	nodes := make([]ipld.Node, 100)
+	for i := range nodes {
+		nodes[i] = NodeWithData([]byte(fmt.Sprintf("node %d", i)))
+		if err := batch.Add(ctx, nodes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := dserv.Get(ctx, nodes[0].Cid()); !ipld.IsNotFound(err) {
+		t.Fatalf("expected nodes not to be written before commit, got %v", err)
+	}
+
+	if err := batch.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if bstore.putManys != 1 || bstore.puts != 0 {
+		t.Fatalf("expected a single PutMany, got %d PutMany and %d Put", bstore.putManys, bstore.puts)
+	}
+
+	for _, nd := range nodes {
+		if _, err := dserv.Get(ctx, nd.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the batch is empty after commit
+	if err := batch.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if bstore.putManys != 1 {
+		t.Fatalf("expected an empty commit not to write, got %d PutMany", bstore.putManys)
+	}
+}
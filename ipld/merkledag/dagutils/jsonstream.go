@@ -0,0 +1,81 @@
+package dagutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipldcbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ImportDagJSONStream decodes the newline-delimited dag-json objects read from
+// r, adds them with adder as dag-cbor nodes and returns their CIDs, in order.
+// Empty lines are skipped. Nothing is added if a line can't be decoded, the
+// error then gives its number.
+func ImportDagJSONStream(ctx context.Context, adder ipld.NodeAdder, r io.Reader) ([]cid.Cid, error) {
+	prefix := cid.Prefix{
+		Version:  1,
+		Codec:    cid.DagCBOR,
+		MhType:   mh.SHA2_256,
+		MhLength: -1,
+	}
+
+	var nodes []ipld.Node
+	br := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			nd, decodeErr := decodeDagJSON(line, prefix)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, decodeErr)
+			}
+			nodes = append(nodes, nd)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := adder.AddMany(ctx, nodes); err != nil {
+		return nil, err
+	}
+	cids := make([]cid.Cid, len(nodes))
+	for i, nd := range nodes {
+		cids[i] = nd.Cid()
+	}
+	return cids, nil
+}
+
+// decodeDagJSON decodes a dag-json object into a dag-cbor node
+func decodeDagJSON(data []byte, prefix cid.Prefix) (ipld.Node, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagjson.Decode(nb, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := dagcbor.Encode(nb.Build(), &buf); err != nil {
+		return nil, err
+	}
+	c, err := prefix.Sum(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	blk, err := blocks.NewBlockWithCid(buf.Bytes(), c)
+	if err != nil {
+		return nil, err
+	}
+	return ipldcbor.DecodeBlock(blk)
+}
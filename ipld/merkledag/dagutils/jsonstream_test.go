@@ -0,0 +1,47 @@
+package dagutils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	dag "github.com/mikelsr/boxo/ipld/merkledag"
+	mdtest "github.com/mikelsr/boxo/ipld/merkledag/test"
+)
+
+func TestImportDagJSONStream(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	linked := dag.NodeWithData([]byte("linked"))
+
+	stream := `{"name": "a"}
+
+{"name": "b", "link": {"/": "` + linked.Cid().String() + `"}}
+{"bytes": {"/": {"bytes": "aGVsbG8"}}}`
+	cids, err := ImportDagJSONStream(ctx, ds, strings.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cids) != 3 {
+		t.Fatalf("expected 3 CIDs, got %d", len(cids))
+	}
+
+	for i, c := range cids {
+		if c.Type() != cid.DagCBOR {
+			t.Fatalf("CID %d: expected a dag-cbor CID, got %s", i, c)
+		}
+		nd, err := ds.Get(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && (len(nd.Links()) != 1 || !nd.Links()[0].Cid.Equals(linked.Cid())) {
+			t.Fatalf("expected a link to %s, got %v", linked.Cid(), nd.Links())
+		}
+	}
+
+	_, err = ImportDagJSONStream(ctx, ds, strings.NewReader("{\"a\": 1}\n{\"b\": \n{}\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("expected an error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package merkledag
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// EnumerateUnique streams the CIDs of the DAG rooted at root, depth-first
+// starting with the root. Every CID is sent once, even if it is linked from
+// several nodes, as long as at most maxSeen unique CIDs have been sent. The
+// CIDs channel is closed when the whole DAG has been enumerated, or earlier
+// if the context is cancelled or a node can't be fetched. The error channel
+// then receives the reason, and is closed after the CIDs channel: it is only
+// closed without an error if the enumeration is complete. Only a failure to
+// fetch the root is returned.
+//
+// The CIDs are streamed rather than collected, but remembering which ones
+// were sent takes a set that grows with the number of unique CIDs of the
+// DAG, about the size of a CID per node. The set doesn't grow with the
+// number of paths to a node, and the nodes themselves are only kept while
+// their links are being enumerated. Raw leaves are not fetched, as they have
+// no links.
+//
+// A maxSeen greater than zero bounds the set to that many CIDs. Once it is
+// full, the CIDs that are not in it are sent, and their nodes enumerated,
+// each time they are reached, so a CID may be sent several times, as often
+// as there are paths to it in a DAG with many shared nodes. A maxSeen of
+// zero or less doesn't bound the set.
+func EnumerateUnique(ctx context.Context, root cid.Cid, getter format.NodeGetter, maxSeen int) (<-chan cid.Cid, <-chan error, error) {
+	nd, err := getter.Get(ctx, root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan cid.Cid)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+
+		send := func(c cid.Cid) bool {
+			select {
+			case out <- c:
+				return true
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return false
+			}
+		}
+
+		seen := cid.NewSet()
+		visit := func(c cid.Cid) bool {
+			if seen.Has(c) {
+				return false
+			}
+			if maxSeen <= 0 || seen.Len() < maxSeen {
+				seen.Add(c)
+			}
+			return true
+		}
+
+		visit(root)
+		if !send(root) {
+			return
+		}
+
+		// stack of the links left to enumerate for each node on the path
+		// from the root
+		stack := [][]*format.Link{nd.Links()}
+		for len(stack) > 0 {
+			top := len(stack) - 1
+			if len(stack[top]) == 0 {
+				stack = stack[:top]
+				continue
+			}
+
+			c := stack[top][0].Cid
+			stack[top] = stack[top][1:]
+			if !visit(c) {
+				continue
+			}
+			if !send(c) {
+				return
+			}
+			if c.Type() == cid.Raw {
+				continue
+			}
+
+			child, err := getter.Get(ctx, c)
+			if err != nil {
+				errc <- fmt.Errorf("enumerating the DAG of %s: %w", root, err)
+				return
+			}
+			if links := child.Links(); len(links) > 0 {
+				stack = append(stack, links)
+			}
+		}
+	}()
+	return out, errc, nil
+}
@@ -0,0 +1,160 @@
+package merkledag_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+	dstest "github.com/mikelsr/boxo/ipld/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// makeEnumerateDAG builds three levels of nodes where every node links to
+// all the nodes of the level below, and to a raw leaf shared by the whole
+// DAG, and returns the root and all the nodes
+func makeEnumerateDAG(t *testing.T, ds ipld.DAGService) (*ProtoNode, []ipld.Node) {
+	t.Helper()
+	shared := NewRawNode([]byte("shared leaf"))
+	nodes := []ipld.Node{shared}
+	var below []ipld.Node
+	for level := 0; level < 3; level++ {
+		var current []ipld.Node
+		for i := 0; i < 3; i++ {
+			nd := NodeWithData([]byte(fmt.Sprintf("level %d node %d", level, i)))
+			for _, child := range below {
+				if err := nd.AddNodeLink("child", child); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := nd.AddNodeLink("shared", shared); err != nil {
+				t.Fatal(err)
+			}
+			current = append(current, nd)
+		}
+		nodes = append(nodes, current...)
+		below = current
+	}
+	root := NodeWithData([]byte("root"))
+	for _, child := range below {
+		if err := root.AddNodeLink("child", child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes = append(nodes, root)
+	if err := ds.AddMany(context.Background(), nodes); err != nil {
+		t.Fatal(err)
+	}
+	return root, nodes
+}
+
+// enumerate collects the CIDs enumerated from the root and the number of
+// times each was sent
+func enumerate(t *testing.T, ds ipld.DAGService, root cid.Cid, maxSeen int) ([]cid.Cid, map[cid.Cid]int, error) {
+	t.Helper()
+	out, errc, err := EnumerateUnique(context.Background(), root, ds, maxSeen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cids []cid.Cid
+	counts := make(map[cid.Cid]int)
+	for c := range out {
+		cids = append(cids, c)
+		counts[c]++
+	}
+	return cids, counts, <-errc
+}
+
+func TestEnumerateUnique(t *testing.T) {
+	ds := dstest.Mock()
+	root, nodes := makeEnumerateDAG(t, ds)
+
+	for _, maxSeen := range []int{0, len(nodes)} {
+		cids, counts, err := enumerate(t, ds, root.Cid(), maxSeen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cids[0] != root.Cid() {
+			t.Fatalf("expected the root first, got %s", cids[0])
+		}
+		for c, n := range counts {
+			if n > 1 {
+				t.Fatalf("%s enumerated %d times with maxSeen %d", c, n, maxSeen)
+			}
+		}
+		if len(counts) != len(nodes) {
+			t.Fatalf("expected %d CIDs, got %d", len(nodes), len(counts))
+		}
+		for _, nd := range nodes {
+			if counts[nd.Cid()] == 0 {
+				t.Fatalf("%s was not enumerated", nd.Cid())
+			}
+		}
+	}
+}
+
+func TestEnumerateUniqueBounded(t *testing.T) {
+	ds := dstest.Mock()
+	root, nodes := makeEnumerateDAG(t, ds)
+
+	// once the set is full, the shared nodes are sent again but the
+	// enumeration is still complete
+	cids, counts, err := enumerate(t, ds, root.Cid(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != len(nodes) {
+		t.Fatalf("expected %d CIDs, got %d", len(nodes), len(counts))
+	}
+	if len(cids) <= len(nodes) {
+		t.Fatalf("expected CIDs to be sent several times, got %d CIDs", len(cids))
+	}
+	if counts[root.Cid()] != 1 {
+		t.Fatalf("expected the root, which is in the set, to be sent once, got %d", counts[root.Cid()])
+	}
+}
+
+func TestEnumerateUniqueMissingNode(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	_, _, err := EnumerateUnique(ctx, NodeWithData([]byte("missing")).Cid(), ds, 0)
+	if err == nil {
+		t.Fatal("expected an error for a missing root")
+	}
+
+	root := makeDepthTestingGraph(t, ds)
+	missing := root.Links()[0].Cid
+	if err := ds.Remove(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+	cids, _, err := enumerate(t, ds, root.Cid(), 0)
+	if !ipld.IsNotFound(err) {
+		t.Fatalf("expected the error of the missing node, got %v", err)
+	}
+	if len(cids) >= 6 {
+		t.Fatalf("expected the enumeration to stop at the missing node, got %d CIDs", len(cids))
+	}
+}
+
+func TestEnumerateUniqueCancel(t *testing.T) {
+	ds := dstest.Mock()
+	root, _ := makeEnumerateDAG(t, ds)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, errc, err := EnumerateUnique(ctx, root.Cid(), ds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-out
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, ok := <-out; ok {
+		t.Fatal("expected the CIDs channel to be closed")
+	}
+}
@@ -0,0 +1,59 @@
+package merkledag
+
+import (
+	"context"
+
+	cid "github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// multihashCodecs are the codecs tried by GetByMultihash, in order. Raw is
+// last as any block can be decoded as raw.
+var multihashCodecs = []uint64{cid.DagProtobuf, cid.DagCBOR, cid.DagJSON, cid.Raw}
+
+// GetByMultihash gets the node with the given multihash, whichever codec the
+// CID it was stored with used. It tries the CIDs of the multihash with the
+// dag-pb (CIDv0 first), dag-cbor, dag-json and raw codecs in turn, and
+// returns the first node found that can be decoded.
+//
+// Limitations:
+//   - Blocks stored under a CID with another codec are not found, unless the
+//     getter is keyed by multihash, as the default blockstore is.
+//   - When the getter is keyed by multihash, the block is returned with the
+//     first codec able to decode it, which might not be the codec it was
+//     stored with. Any block can be decoded as raw.
+//   - Every candidate is a separate Get: with a getter that fetches from the
+//     network, such as an online DAGService, this may fetch several times and
+//     wait for each fetch to fail. Prefer an offline getter, or a context
+//     with a deadline.
+func GetByMultihash(ctx context.Context, h mh.Multihash, getter format.NodeGetter) (format.Node, error) {
+	dh, err := mh.Decode(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []cid.Cid
+	if dh.Code == mh.SHA2_256 && dh.Length == 32 {
+		candidates = append(candidates, cid.NewCidV0(h))
+	}
+	for _, codec := range multihashCodecs {
+		candidates = append(candidates, cid.NewCidV1(codec, h))
+	}
+
+	err = format.ErrNotFound{Cid: cid.NewCidV1(cid.Raw, h)}
+	for _, c := range candidates {
+		nd, getErr := getter.Get(ctx, c)
+		if getErr == nil {
+			return nd, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		// keep the first error that isn't a not found, e.g. a decoding error
+		if !format.IsNotFound(getErr) && format.IsNotFound(err) {
+			err = getErr
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,75 @@
+package merkledag_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+	dstest "github.com/mikelsr/boxo/ipld/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	u "github.com/mikelsr/boxo/util"
+)
+
+func TestGetByMultihash(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+
+	pbnd := NodeWithData([]byte("foo"))
+	rawnd := NewRawNode([]byte("foobar"))
+	for _, nd := range []ipld.Node{pbnd, rawnd} {
+		if err := ds.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []ipld.Node{pbnd, rawnd} {
+		nd, err := GetByMultihash(ctx, expected.Cid().Hash(), ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !nd.Cid().Equals(expected.Cid()) {
+			t.Fatalf("expected %s, got %s", expected.Cid(), nd.Cid())
+		}
+		if !bytes.Equal(nd.RawData(), expected.RawData()) {
+			t.Fatalf("unexpected data for %s", expected.Cid())
+		}
+	}
+
+	_, err := GetByMultihash(ctx, u.Hash([]byte("missing")), ds)
+	if !ipld.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestGetByMultihashKeyedByCid(t *testing.T) {
+	ctx := context.Background()
+
+	// a getter that only finds nodes by their exact CID
+	rawnd := NewRawNode([]byte("foobar"))
+	getter := cidGetter{rawnd.Cid(): rawnd}
+
+	nd, err := GetByMultihash(ctx, rawnd.Cid().Hash(), getter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nd.Cid().Equals(rawnd.Cid()) {
+		t.Fatalf("expected %s, got %s", rawnd.Cid(), nd.Cid())
+	}
+}
+
+type cidGetter map[cid.Cid]ipld.Node
+
+func (g cidGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	nd, ok := g[c]
+	if !ok {
+		return nil, ipld.ErrNotFound{Cid: c}
+	}
+	return nd, nil
+}
+
+func (g cidGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	panic("not implemented")
+}
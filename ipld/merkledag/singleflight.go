@@ -0,0 +1,45 @@
+package merkledag
+
+import (
+	"context"
+	"errors"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"golang.org/x/sync/singleflight"
+)
+
+// Singleflight wraps a NodeGetter so that concurrent Get calls for the same
+// CID share a single call to the underlying getter, e.g. to avoid fetching
+// the same block several times from the network. GetMany calls are passed
+// through.
+//
+// The shared call uses the context of the first caller. If that context is
+// cancelled, the other callers whose context is still live retry on their
+// own.
+func Singleflight(getter ipld.NodeGetter) ipld.NodeGetter {
+	return &singleflightGetter{getter: getter}
+}
+
+type singleflightGetter struct {
+	getter ipld.NodeGetter
+	group  singleflight.Group
+}
+
+func (sg *singleflightGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	// the key is the multihash and codec, as for blocks
+	nd, err, _ := sg.group.Do(c.KeyString(), func() (interface{}, error) {
+		return sg.getter.Get(ctx, c)
+	})
+	if err != nil {
+		if ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			return sg.getter.Get(ctx, c)
+		}
+		return nil, err
+	}
+	return nd.(ipld.Node), nil
+}
+
+func (sg *singleflightGetter) GetMany(ctx context.Context, ks []cid.Cid) <-chan *ipld.NodeOption {
+	return sg.getter.GetMany(ctx, ks)
+}
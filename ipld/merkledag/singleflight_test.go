@@ -0,0 +1,108 @@
+package merkledag_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// blockingGetter counts the calls to Get, which block until release is
+// closed
+type blockingGetter struct {
+	ipld.NodeGetter
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (bg *blockingGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	bg.calls.Add(1)
+	select {
+	case <-bg.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return bg.NodeGetter.Get(ctx, c)
+}
+
+func TestSingleflight(t *testing.T) {
+	ctx := context.Background()
+	nd := NodeWithData([]byte("foo"))
+	bg := &blockingGetter{
+		NodeGetter: cidGetter{nd.Cid(): nd},
+		release:    make(chan struct{}),
+	}
+	getter := Singleflight(bg)
+
+	const n = 50
+	var started, done sync.WaitGroup
+	started.Add(n)
+	done.Add(n)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			got, err := getter.Get(ctx, nd.Cid())
+			if err == nil && !got.Cid().Equals(nd.Cid()) {
+				t.Errorf("expected %s, got %s", nd.Cid(), got.Cid())
+			}
+			errs <- err
+		}()
+	}
+	started.Wait()
+	// give the callers time to join the call before releasing it
+	time.Sleep(50 * time.Millisecond)
+	close(bg.release)
+	done.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := bg.calls.Load(); calls != 1 {
+		t.Fatalf("expected a single call to the getter, got %d", calls)
+	}
+}
+
+func TestSingleflightCancelledCaller(t *testing.T) {
+	nd := NodeWithData([]byte("foo"))
+	bg := &blockingGetter{
+		NodeGetter: cidGetter{nd.Cid(): nd},
+		release:    make(chan struct{}),
+	}
+	getter := Singleflight(bg)
+
+	cctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := getter.Get(cctx, nd.Cid())
+		firstErr <- err
+	}()
+	for bg.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error)
+	go func() {
+		_, err := getter.Get(context.Background(), nd.Cid())
+		second <- err
+	}()
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected the first caller to be cancelled, got %v", err)
+	}
+	close(bg.release)
+	if err := <-second; err != nil {
+		t.Fatalf("expected the second caller to retry, got %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package mdutils
 
 import (
+	"context"
+	"fmt"
+
 	dag "github.com/mikelsr/boxo/ipld/merkledag"
 
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -11,9 +15,34 @@ import (
 	offline "github.com/mikelsr/boxo/exchange/offline"
 )
 
+// MockOption configures the DAGService returned by Mock.
+type MockOption func(*mockSettings)
+
+type mockSettings struct {
+	orderedGetMany bool
+}
+
+// OrderedGetMany makes GetMany emit the nodes in the order of the requested
+// CIDs, ignoring the duplicates, for reproducible tests. By default they are
+// emitted in the order they are fetched, like a real DAGService.
+func OrderedGetMany() MockOption {
+	return func(s *mockSettings) {
+		s.orderedGetMany = true
+	}
+}
+
 // Mock returns a new thread-safe, mock DAGService.
-func Mock() ipld.DAGService {
-	return dag.NewDAGService(Bserv())
+func Mock(opts ...MockOption) ipld.DAGService {
+	var settings mockSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	dserv := dag.NewDAGService(Bserv())
+	if settings.orderedGetMany {
+		return &orderedDAGService{dserv}
+	}
+	return dserv
 }
 
 // Bserv returns a new, thread-safe, mock BlockService.
@@ -21,3 +50,68 @@ func Bserv() bsrv.BlockService {
 	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
 	return bsrv.New(bstore, offline.Exchange(bstore))
 }
+
+// DiamondDAG adds to na a DAG of ProtoNodes in which the two children of the
+// root share a child: root -> a, b; a -> c; b -> c. The links are named
+// after the data of the children, "a", "b" and "c".
+func DiamondDAG(ctx context.Context, na ipld.NodeAdder) (root, a, b, c *dag.ProtoNode, err error) {
+	c = dag.NodeWithData([]byte("c"))
+	a = dag.NodeWithData([]byte("a"))
+	b = dag.NodeWithData([]byte("b"))
+	root = dag.NodeWithData([]byte("root"))
+	for _, l := range []struct{ parent, child *dag.ProtoNode }{{a, c}, {b, c}, {root, a}, {root, b}} {
+		if err := l.parent.AddNodeLink(string(l.child.Data()), l.child); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	if err := na.AddMany(ctx, []ipld.Node{c, a, b, root}); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return root, a, b, c, nil
+}
+
+// orderedDAGService is a DAGService whose GetMany emits the nodes in the order
+// of the requested CIDs
+type orderedDAGService struct {
+	ipld.DAGService
+}
+
+func (ods *orderedDAGService) GetMany(ctx context.Context, keys []cid.Cid) <-chan *ipld.NodeOption {
+	var order []cid.Cid
+	seen := cid.NewSet()
+	for _, c := range keys {
+		if seen.Visit(c) {
+			order = append(order, c)
+		}
+	}
+
+	out := make(chan *ipld.NodeOption, len(order))
+	fetched := ods.DAGService.GetMany(ctx, order)
+	go func() {
+		defer close(out)
+
+		// nodes fetched before the ones preceding them in the order
+		pending := make(map[cid.Cid]ipld.Node)
+		next := 0
+		for opt := range fetched {
+			if opt.Err != nil {
+				out <- opt
+				return
+			}
+			pending[opt.Node.Cid()] = opt.Node
+			for next < len(order) {
+				nd, ok := pending[order[next]]
+				if !ok {
+					break
+				}
+				delete(pending, order[next])
+				out <- &ipld.NodeOption{Node: nd}
+				next++
+			}
+		}
+		if next != len(order) {
+			out <- &ipld.NodeOption{Err: fmt.Errorf("failed to fetch all nodes")}
+		}
+	}()
+	return out
+}
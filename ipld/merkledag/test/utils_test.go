@@ -0,0 +1,70 @@
+package mdutils
+
+import (
+	"context"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	dag "github.com/mikelsr/boxo/ipld/merkledag"
+)
+
+func TestMockOrderedGetMany(t *testing.T) {
+	ctx := context.Background()
+	dserv := Mock(OrderedGetMany())
+
+	var keys []cid.Cid
+	for i := 0; i < 50; i++ {
+		nd := dag.NodeWithData([]byte{byte(i)})
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, nd.Cid())
+	}
+	// request them out of insertion order, with duplicates
+	var requested []cid.Cid
+	for i := len(keys) - 1; i >= 0; i -= 2 {
+		requested = append(requested, keys[i], keys[i-1], keys[i])
+	}
+
+	for round := 0; round < 5; round++ {
+		var got []cid.Cid
+		for opt := range dserv.GetMany(ctx, requested) {
+			if opt.Err != nil {
+				t.Fatal(opt.Err)
+			}
+			got = append(got, opt.Node.Cid())
+		}
+		if len(got) != len(keys) {
+			t.Fatalf("expected %d nodes, got %d", len(keys), len(got))
+		}
+		for i := range got {
+			// requested holds each pair as i, i-1, i
+			if expected := requested[i/2*3+i%2]; got[i] != expected {
+				t.Fatalf("node %d: expected %s, got %s", i, expected, got[i])
+			}
+		}
+	}
+}
+
+func TestMockOrderedGetManyMissing(t *testing.T) {
+	ctx := context.Background()
+	dserv := Mock(OrderedGetMany())
+
+	present := dag.NodeWithData([]byte("present"))
+	if err := dserv.Add(ctx, present); err != nil {
+		t.Fatal(err)
+	}
+	missing := dag.NodeWithData([]byte("missing"))
+
+	var nodes, errs int
+	for opt := range dserv.GetMany(ctx, []cid.Cid{present.Cid(), missing.Cid()}) {
+		if opt.Err != nil {
+			errs++
+			continue
+		}
+		nodes++
+	}
+	if nodes != 1 || errs != 1 {
+		t.Fatalf("expected a node and an error, got %d nodes and %d errors", nodes, errs)
+	}
+}
@@ -0,0 +1,59 @@
+package merkledag
+
+import (
+	"context"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// TopoOrder returns the blocks of the DAG rooted at root in topological
+// order: every block is preceded by all of its children, so the root is
+// always the last block. Blocks linked from several parents are only
+// returned once, the first time they are reached.
+//
+// The DAG is walked depth-first using an explicit stack, so besides the
+// result only the nodes on the current path from the root are kept in
+// memory, no matter how wide the DAG is.
+func TopoOrder(ctx context.Context, root cid.Cid, getter format.NodeGetter) ([]blocks.Block, error) {
+	type frame struct {
+		node  format.Node
+		links []*format.Link
+		next  int
+	}
+
+	nd, err := getter.Get(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := cid.NewSet()
+	seen.Add(root)
+
+	var out []blocks.Block
+	stack := []*frame{{node: nd, links: nd.Links()}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.next == len(top.links) {
+			// all children have been emitted, the node can follow them
+			out = append(out, top.node)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		lnk := top.links[top.next]
+		top.next++
+		if !seen.Visit(lnk.Cid) {
+			continue
+		}
+
+		child, err := getter.Get(ctx, lnk.Cid)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, &frame{node: child, links: child.Links()})
+	}
+
+	return out, nil
+}
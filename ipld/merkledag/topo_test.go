@@ -0,0 +1,60 @@
+package merkledag_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+	dstest "github.com/mikelsr/boxo/ipld/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestTopoOrder(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root := makeDepthTestingGraph(t, ds)
+
+	blks, err := TopoOrder(ctx, root.Cid(), ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root, two level 1 nodes and three level 2 nodes, one of them shared
+	if len(blks) != 6 {
+		t.Fatalf("expected 6 blocks, got %d", len(blks))
+	}
+
+	pos := make(map[cid.Cid]int)
+	for i, b := range blks {
+		if _, ok := pos[b.Cid()]; ok {
+			t.Fatalf("block %s returned twice", b.Cid())
+		}
+		pos[b.Cid()] = i
+	}
+
+	if blks[len(blks)-1].Cid() != root.Cid() {
+		t.Fatal("expected the root to be the last block")
+	}
+
+	for _, b := range blks {
+		nd, err := ds.Get(ctx, b.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, lnk := range nd.Links() {
+			if pos[lnk.Cid] >= pos[b.Cid()] {
+				t.Fatalf("child %s does not precede its parent %s", lnk.Cid, b.Cid())
+			}
+		}
+	}
+}
+
+func TestTopoOrderMissingRoot(t *testing.T) {
+	ds := dstest.Mock()
+
+	_, err := TopoOrder(context.Background(), NodeWithData([]byte("missing")).Cid(), ds)
+	if err == nil {
+		t.Fatal("expected an error for a missing root")
+	}
+}
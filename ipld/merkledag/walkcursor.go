@@ -0,0 +1,128 @@
+package merkledag
+
+import (
+	"context"
+	"encoding/json"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// WalkCursor holds the state of a walk started with WalkWithCursor: the
+// nodes that remain to be visited and the ones already visited. It allows
+// to stop a walk over a large DAG and to resume it later with ResumeWalk,
+// possibly in another process as the cursor can be serialized to JSON.
+//
+// The zero value is the cursor of a walk that is done, as it has no node to
+// visit; NewWalkCursor returns the cursor of a walk to start.
+type WalkCursor struct {
+	// frontier is a stack of the nodes to visit, the next one is the last
+	frontier []cid.Cid
+	// visited is created when first needed, nil in the zero value
+	visited *cid.Set
+}
+
+type walkCursorJSON struct {
+	Frontier []cid.Cid `json:"frontier"`
+	Visited  []cid.Cid `json:"visited"`
+}
+
+// NewWalkCursor returns a cursor for a walk of the DAG starting at root that
+// hasn't visited any node yet.
+func NewWalkCursor(root cid.Cid) *WalkCursor {
+	return &WalkCursor{
+		frontier: []cid.Cid{root},
+		visited:  cid.NewSet(),
+	}
+}
+
+// Done returns true when the walk has visited all the nodes of the DAG.
+func (wc *WalkCursor) Done() bool {
+	return len(wc.frontier) == 0
+}
+
+// MarshalJSON implements json.Marshaler.
+func (wc *WalkCursor) MarshalJSON() ([]byte, error) {
+	wcj := walkCursorJSON{Frontier: wc.frontier}
+	if wc.visited != nil {
+		wcj.Visited = wc.visited.Keys()
+	}
+	return json.Marshal(wcj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (wc *WalkCursor) UnmarshalJSON(data []byte) error {
+	var wcj walkCursorJSON
+	if err := json.Unmarshal(data, &wcj); err != nil {
+		return err
+	}
+
+	wc.frontier = wcj.Frontier
+	wc.visited = cid.NewSet()
+	for _, c := range wcj.Visited {
+		wc.visited.Add(c)
+	}
+	return nil
+}
+
+// WalkWithCursor walks the dag in order (depth first) starting at the given
+// root, like Walk, but stops after visiting limit nodes. Every node is
+// visited once. The returned cursor can be passed to ResumeWalk to continue
+// the walk; it is Done if the whole DAG was visited. If limit is not
+// positive the whole DAG is visited.
+//
+// The visit function returns false to skip the children of a node.
+func WalkWithCursor(ctx context.Context, getLinks GetLinks, root cid.Cid, visit func(cid.Cid) bool, limit int) (*WalkCursor, error) {
+	cursor := NewWalkCursor(root)
+	return cursor, ResumeWalk(ctx, getLinks, cursor, visit, limit)
+}
+
+// ResumeWalk continues the walk captured by the cursor, visiting at most
+// limit more nodes, and advances the cursor in place. If limit is not
+// positive the rest of the DAG is visited.
+//
+// If an error is returned, the node that caused it has not been visited and
+// the walk can be retried with the same cursor.
+func ResumeWalk(ctx context.Context, getLinks GetLinks, cursor *WalkCursor, visit func(cid.Cid) bool, limit int) error {
+	if cursor.visited == nil {
+		cursor.visited = cid.NewSet()
+	}
+
+	visited := 0
+	for !cursor.Done() {
+		if limit > 0 && visited >= limit {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		last := len(cursor.frontier) - 1
+		c := cursor.frontier[last]
+		if cursor.visited.Has(c) {
+			cursor.frontier = cursor.frontier[:last]
+			continue
+		}
+
+		// fetch the links before changing the cursor so that it stays valid
+		// if this fails
+		links, err := getLinks(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		cursor.frontier = cursor.frontier[:last]
+		cursor.visited.Add(c)
+		visited++
+		if !visit(c) {
+			continue
+		}
+
+		// push the links in reverse so the first one is visited next
+		for i := len(links) - 1; i >= 0; i-- {
+			if !cursor.visited.Has(links[i].Cid) {
+				cursor.frontier = append(cursor.frontier, links[i].Cid)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package merkledag_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+	dstest "github.com/mikelsr/boxo/ipld/merkledag/test"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+func TestWalkWithCursorResume(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root := makeDepthTestingGraph(t, ds)
+	getLinks := GetLinksWithDAG(ds)
+
+	var full []cid.Cid
+	cursor, err := WalkWithCursor(ctx, getLinks, root.Cid(), func(c cid.Cid) bool {
+		full = append(full, c)
+		return true
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cursor.Done() {
+		t.Fatal("expected the walk to be done")
+	}
+	// root, two level 1 nodes and three level 2 nodes, one of them shared
+	if len(full) != 6 {
+		t.Fatalf("expected 6 nodes, got %d", len(full))
+	}
+
+	var visited []cid.Cid
+	visit := func(c cid.Cid) bool {
+		visited = append(visited, c)
+		return true
+	}
+
+	cursor, err = WalkWithCursor(ctx, getLinks, root.Cid(), visit, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Done() || len(visited) != 3 {
+		t.Fatalf("expected the walk to stop after 3 nodes, visited %d", len(visited))
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := new(WalkCursor)
+	if err := json.Unmarshal(data, resumed); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ResumeWalk(ctx, getLinks, resumed, visit, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.Done() {
+		t.Fatal("expected the resumed walk to be done")
+	}
+
+	if len(visited) != len(full) {
+		t.Fatalf("expected %d nodes, got %d", len(full), len(visited))
+	}
+	for i := range full {
+		if visited[i] != full[i] {
+			t.Fatalf("node %d: expected %s, got %s", i, full[i], visited[i])
+		}
+	}
+}
+
+func TestResumeWalkAfterError(t *testing.T) {
+	ctx := context.Background()
+	ds := dstest.Mock()
+	root := makeDepthTestingGraph(t, ds)
+	missing := root.Links()[1].Cid
+	missingNode, err := ds.Get(ctx, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Remove(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := cid.NewSet()
+	visit := func(c cid.Cid) bool {
+		if !seen.Visit(c) {
+			t.Fatalf("node %s visited twice", c)
+		}
+		return true
+	}
+
+	cursor, err := WalkWithCursor(ctx, GetLinksWithDAG(ds), root.Cid(), visit, 0)
+	if err == nil {
+		t.Fatal("expected an error for the missing node")
+	}
+	if seen.Has(missing) {
+		t.Fatal("the missing node should not have been visited")
+	}
+
+	if err := ds.Add(ctx, missingNode); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResumeWalk(ctx, GetLinksWithDAG(ds), cursor, visit, 0); err != nil {
+		t.Fatal(err)
+	}
+	if seen.Len() != 6 {
+		t.Fatalf("expected 6 nodes, got %d", seen.Len())
+	}
+}
+
+func TestZeroWalkCursor(t *testing.T) {
+	var cursor WalkCursor
+	if !cursor.Done() {
+		t.Fatal("expected the zero cursor to be done")
+	}
+
+	data, err := json.Marshal(&cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded WalkCursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Done() {
+		t.Fatal("expected the decoded zero cursor to be done")
+	}
+
+	getLinks := func(context.Context, cid.Cid) ([]*ipld.Link, error) {
+		t.Fatal("no node should be fetched")
+		return nil, nil
+	}
+	visit := func(c cid.Cid) bool {
+		t.Fatalf("node %s visited", c)
+		return true
+	}
+	if err := ResumeWalk(context.Background(), getLinks, &cursor, visit, 0); err != nil {
+		t.Fatal(err)
+	}
+}
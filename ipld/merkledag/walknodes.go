@@ -0,0 +1,40 @@
+package merkledag
+
+import (
+	"context"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// WalkNodes walks the DAG starting at root like Walk, getting the nodes from
+// ng and calling visit with each of them. shouldVisit is called first for
+// every CID and can be used to skip nodes already seen, e.g. with the Visit
+// method of a cid.Set. The children of a node are only walked if visit returns
+// true, and the walk stops at the first error returned by visit.
+// NOTE: visit is never called concurrently, even with the Concurrency option.
+func WalkNodes(ctx context.Context, ng ipld.NodeGetter, root cid.Cid, shouldVisit func(cid.Cid) bool, visit func(cid.Cid, ipld.Node) (bool, error), options ...WalkOption) error {
+	// The links are got from the visited node, so visit is called when they
+	// are fetched by the walker, which may do that in parallel.
+	var visitLk sync.Mutex
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		visitLk.Lock()
+		descend, err := visit(c, nd)
+		visitLk.Unlock()
+		if err != nil || !descend {
+			return nil, err
+		}
+		return nd.Links(), nil
+	}
+
+	return Walk(ctx, getLinks, root, shouldVisit, options...)
+}
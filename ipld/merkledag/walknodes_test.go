@@ -0,0 +1,81 @@
+package merkledag_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	. "github.com/mikelsr/boxo/ipld/merkledag"
+	mdtest "github.com/mikelsr/boxo/ipld/merkledag/test"
+)
+
+func TestWalkNodes(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	root, a, b, c, err := mdtest.DiamondDAG(ctx, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := func(cid.Cid) bool { return true }
+	descend := func(cid.Cid, ipld.Node) (bool, error) { return true, nil }
+
+	walk := func(shouldVisit func(cid.Cid) bool, visit func(cid.Cid, ipld.Node) (bool, error), opts ...WalkOption) []cid.Cid {
+		t.Helper()
+		var visited []cid.Cid
+		err := WalkNodes(ctx, ds, root.Cid(), shouldVisit, func(k cid.Cid, nd ipld.Node) (bool, error) {
+			if !nd.Cid().Equals(k) {
+				t.Fatalf("node %s visited as %s", nd.Cid(), k)
+			}
+			visited = append(visited, k)
+			return visit(k, nd)
+		}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return visited
+	}
+
+	expectCids := func(got []cid.Cid, expected ...*ProtoNode) {
+		t.Helper()
+		if len(got) != len(expected) {
+			t.Fatalf("expected %d nodes, got %d", len(expected), len(got))
+		}
+		for i, nd := range expected {
+			if !got[i].Equals(nd.Cid()) {
+				t.Fatalf("node %d: expected %s, got %s", i, nd.Cid(), got[i])
+			}
+		}
+	}
+
+	expectCids(walk(cid.NewSet().Visit, descend), root, a, c, b)
+	expectCids(walk(all, descend), root, a, c, b, c)
+	expectCids(walk(all, func(k cid.Cid, _ ipld.Node) (bool, error) {
+		return !k.Equals(a.Cid()), nil
+	}), root, a, b, c)
+
+	if visited := walk(cid.NewSet().Visit, descend, Concurrency(4)); len(visited) != 4 {
+		t.Fatalf("expected 4 nodes, got %d", len(visited))
+	}
+
+	errVisit := errors.New("visit failed")
+	for _, concurrency := range []int{1, 4} {
+		err := WalkNodes(ctx, ds, root.Cid(), all, func(k cid.Cid, _ ipld.Node) (bool, error) {
+			if k.Equals(c.Cid()) {
+				return false, errVisit
+			}
+			return true, nil
+		}, Concurrency(concurrency))
+		if err != errVisit {
+			t.Fatalf("expected the visit error with concurrency %d, got %v", concurrency, err)
+		}
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := WalkNodes(cctx, ds, root.Cid(), all, descend); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"context"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/mikelsr/boxo/ipld/merkledag"
+	ft "github.com/mikelsr/boxo/ipld/unixfs"
+)
+
+// BlockFunc is called by BlockEventsDAGService for every block produced by an
+// import. offset is the number of bytes of file data imported so far,
+// including the data of the block, and root is true for the last call, which
+// reports the root of the import.
+type BlockFunc func(ctx context.Context, c cid.Cid, offset int64, root bool) error
+
+// BlockEventsDAGService wraps the DAGService an importer writes the blocks to,
+// calling a BlockFunc for every node once it is added to the wrapped
+// DAGService. Finish reports the root of the import.
+//
+// The file data is counted from the leaves, raw nodes or UnixFS nodes without
+// links, so the offsets follow the order in which the importer stores them.
+// The BlockFunc is never called concurrently.
+type BlockEventsDAGService struct {
+	ipld.DAGService
+	onBlock BlockFunc
+
+	lk     sync.Mutex
+	offset int64
+}
+
+// NewBlockEventsDAGService returns a BlockEventsDAGService wrapping ds and
+// calling onBlock for every block.
+func NewBlockEventsDAGService(ds ipld.DAGService, onBlock BlockFunc) *BlockEventsDAGService {
+	return &BlockEventsDAGService{DAGService: ds, onBlock: onBlock}
+}
+
+// Add adds the node to the wrapped DAGService and reports it.
+func (ds *BlockEventsDAGService) Add(ctx context.Context, nd ipld.Node) error {
+	if err := ds.DAGService.Add(ctx, nd); err != nil {
+		return err
+	}
+	return ds.produced(ctx, nd)
+}
+
+// AddMany adds the nodes to the wrapped DAGService and reports them.
+func (ds *BlockEventsDAGService) AddMany(ctx context.Context, nds []ipld.Node) error {
+	if err := ds.DAGService.AddMany(ctx, nds); err != nil {
+		return err
+	}
+	for _, nd := range nds {
+		if err := ds.produced(ctx, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish reports the root of the import.
+func (ds *BlockEventsDAGService) Finish(ctx context.Context, root cid.Cid) error {
+	ds.lk.Lock()
+	defer ds.lk.Unlock()
+	return ds.onBlock(ctx, root, ds.offset, true)
+}
+
+func (ds *BlockEventsDAGService) produced(ctx context.Context, nd ipld.Node) error {
+	ds.lk.Lock()
+	defer ds.lk.Unlock()
+
+	ds.offset += leafDataSize(nd)
+	return ds.onBlock(ctx, nd.Cid(), ds.offset, false)
+}
+
+// leafDataSize returns the size of the file data of a leaf, 0 for the other
+// nodes
+func leafDataSize(nd ipld.Node) int64 {
+	if len(nd.Links()) > 0 {
+		return 0
+	}
+	switch nd := nd.(type) {
+	case *dag.RawNode:
+		return int64(len(nd.RawData()))
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil || fsn.IsDir() {
+			return 0
+		}
+		return int64(fsn.FileSize())
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	chunker "github.com/mikelsr/boxo/chunker"
+	dag "github.com/mikelsr/boxo/ipld/merkledag"
+	mdtest "github.com/mikelsr/boxo/ipld/merkledag/test"
+)
+
+func TestBlockEventsDAGService(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	type blockEvent struct {
+		c      cid.Cid
+		offset int64
+		root   bool
+	}
+	var received []blockEvent
+	eds := NewBlockEventsDAGService(ds, func(_ context.Context, c cid.Cid, offset int64, root bool) error {
+		received = append(received, blockEvent{c, offset, root})
+		return nil
+	})
+	root, err := BuildDagFromReader(eds, chunker.NewSizeSplitter(bytes.NewReader(data), 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eds.Finish(ctx, root.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	last := received[len(received)-1]
+	if !last.root || last.c != root.Cid() || last.offset != int64(len(data)) {
+		t.Fatalf("unexpected last event %+v", last)
+	}
+
+	produced := cid.NewSet()
+	var offset int64
+	for _, evt := range received[:len(received)-1] {
+		if evt.root {
+			t.Fatal("only the last event should be the root")
+		}
+		if evt.offset < offset {
+			t.Fatalf("offset went back from %d to %d", offset, evt.offset)
+		}
+		offset = evt.offset
+		produced.Add(evt.c)
+	}
+
+	// every block of the DAG was reported
+	err = dag.Walk(ctx, dag.GetLinksWithDAG(ds), root.Cid(), func(c cid.Cid) bool {
+		if !produced.Has(c) {
+			t.Fatalf("no event for %s", c)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if produced.Len() < len(data)/64 {
+		t.Fatalf("expected at least %d blocks, got %d", len(data)/64, produced.Len())
+	}
+}
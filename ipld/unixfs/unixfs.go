@@ -416,3 +416,47 @@ func ExtractFSNode(node ipld.Node) (*FSNode, error) {
 
 	return fsNode, nil
 }
+
+// Stat holds the metadata of a UnixFS node, see StatNode.
+type Stat struct {
+	// Type is the type of the node, TRaw for raw nodes
+	Type pb.Data_DataType
+	// Size is the size of the file in bytes, or of the symlink target, 0
+	// for directories
+	Size uint64
+	// CumulativeSize is the size of the DAG of the node, including the
+	// metadata and links
+	CumulativeSize uint64
+}
+
+// StatNode returns the Stat of a raw node or a `ProtoNode` holding UnixFS
+// data, from that node only. It returns ErrUnrecognizedType for the other
+// nodes.
+func StatNode(node ipld.Node) (Stat, error) {
+	cumulativeSize, err := node.Size()
+	if err != nil {
+		return Stat{}, err
+	}
+	stat := Stat{CumulativeSize: cumulativeSize}
+
+	switch node := node.(type) {
+	case *dag.RawNode:
+		stat.Type = TRaw
+		stat.Size = uint64(len(node.RawData()))
+	case *dag.ProtoNode:
+		fsNode, err := FSNodeFromBytes(node.Data())
+		if err != nil {
+			return Stat{}, err
+		}
+		stat.Type = fsNode.Type()
+		switch stat.Type {
+		case TFile, TRaw:
+			stat.Size = fsNode.FileSize()
+		case TSymlink:
+			stat.Size = uint64(len(fsNode.Data()))
+		}
+	default:
+		return Stat{}, ErrUnrecognizedType
+	}
+	return stat, nil
+}
@@ -5,7 +5,9 @@ import (
 	"testing"
 
 	proto "github.com/gogo/protobuf/proto"
+	dag "github.com/mikelsr/boxo/ipld/merkledag"
 
+	ipld "github.com/ipfs/go-ipld-format"
 	pb "github.com/mikelsr/boxo/ipld/unixfs/pb"
 )
 
@@ -184,3 +186,40 @@ func TestIsDir(t *testing.T) {
 		}
 	}
 }
+
+func TestStatNode(t *testing.T) {
+	file := dag.NodeWithData(FilePBData(make([]byte, 1000), 1000))
+	dir := EmptyDirNode()
+	if err := dir.AddNodeLink("file", file); err != nil {
+		t.Fatal(err)
+	}
+	symlinkData, err := SymlinkData("target")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		nd   ipld.Node
+		typ  pb.Data_DataType
+		size uint64
+	}{
+		{"file", file, TFile, 1000},
+		{"raw", dag.NewRawNode([]byte("raw")), TRaw, 3},
+		{"directory", dir, TDirectory, 0},
+		{"symlink", dag.NodeWithData(symlinkData), TSymlink, 6},
+	} {
+		stat, err := StatNode(tc.nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cumulativeSize, _ := tc.nd.Size()
+		if stat.Type != tc.typ || stat.Size != tc.size || stat.CumulativeSize != cumulativeSize {
+			t.Fatalf("%s: unexpected stat %+v", tc.name, stat)
+		}
+	}
+
+	if _, err := StatNode(new(dag.ProtoNode)); err == nil {
+		t.Fatal("expected an error for a node without UnixFS data")
+	}
+}
@@ -0,0 +1,102 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	ci "github.com/mikelsr/go-libp2p/core/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Parameters of the encryption of exported keys. The key derivation uses
+// scrypt with the recommended interactive parameters (N=2^15, r=8, p=1) to
+// derive a 256 bit AES key from the passphrase.
+const (
+	exportVersion = 1
+
+	exportSaltSize = 16
+	exportScryptN  = 1 << 15
+	exportScryptR  = 8
+	exportScryptP  = 1
+	exportKeySize  = 32
+)
+
+// ErrBadExportedKey is returned by ImportKey when the blob cannot be
+// decrypted, either because it is corrupted or the passphrase is wrong.
+var ErrBadExportedKey = fmt.Errorf("cannot decrypt exported key: wrong passphrase or corrupted data")
+
+// ExportKey encrypts the private key with the passphrase so that it can be
+// backed up, and imported again with ImportKey.
+//
+// The returned blob is
+//
+//	version (1 byte, currently 1) || salt (16 bytes) || nonce (12 bytes) || ciphertext
+//
+// where the ciphertext is the protobuf encoding of the key, as returned by
+// crypto.MarshalPrivateKey, sealed with AES-256-GCM using the version and
+// the salt as additional data. The AES key is derived from the passphrase
+// and the salt with scrypt (N=32768, r=8, p=1).
+func ExportKey(k ci.PrivKey, passphrase []byte) ([]byte, error) {
+	plaintext, err := ci.MarshalPrivateKey(k)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 1+exportSaltSize)
+	header[0] = exportVersion
+	if _, err := rand.Read(header[1:]); err != nil {
+		return nil, err
+	}
+
+	aead, err := exportAEAD(passphrase, header[1:])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	blob := append(header, nonce...)
+	return aead.Seal(blob, nonce, plaintext, header), nil
+}
+
+// ImportKey decrypts a blob returned by ExportKey with the passphrase.
+func ImportKey(blob, passphrase []byte) (ci.PrivKey, error) {
+	if len(blob) < 1+exportSaltSize {
+		return nil, ErrBadExportedKey
+	}
+	if blob[0] != exportVersion {
+		return nil, fmt.Errorf("unsupported exported key version %d", blob[0])
+	}
+	header, rest := blob[:1+exportSaltSize], blob[1+exportSaltSize:]
+
+	aead, err := exportAEAD(passphrase, header[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrBadExportedKey
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrBadExportedKey
+	}
+	return ci.UnmarshalPrivateKey(plaintext)
+}
+
+func exportAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, exportScryptN, exportScryptR, exportScryptP, exportKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
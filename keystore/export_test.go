@@ -0,0 +1,35 @@
+package keystore
+
+import (
+	"testing"
+)
+
+func TestExportImportKey(t *testing.T) {
+	k := privKeyOrFatal(t)
+
+	blob, err := ExportKey(k, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := ImportKey(blob, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Equals(imported) {
+		t.Fatal("imported key differs from the exported one")
+	}
+
+	if _, err := ImportKey(blob, []byte("battery staple")); err != ErrBadExportedKey {
+		t.Fatalf("expected ErrBadExportedKey with a wrong passphrase, got %v", err)
+	}
+
+	blob[len(blob)-1] ^= 1
+	if _, err := ImportKey(blob, []byte("correct horse")); err != ErrBadExportedKey {
+		t.Fatalf("expected ErrBadExportedKey for a corrupted blob, got %v", err)
+	}
+
+	if _, err := ImportKey(blob[:10], []byte("correct horse")); err != ErrBadExportedKey {
+		t.Fatalf("expected ErrBadExportedKey for a truncated blob, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package path
+
+import "time"
+
+// CacheControlConfig holds the cache lifetimes returned by CacheControl.
+type CacheControlConfig struct {
+	// ImmutableMaxAge is the lifetime of /ipfs and /ipld paths.
+	ImmutableMaxAge time.Duration
+	// MutableMaxAge is the lifetime of /ipns paths.
+	MutableMaxAge time.Duration
+}
+
+// DefaultCacheControl is the configuration used by CacheControl. The
+// immutable lifetime matches the max-age the gateway sets for immutable
+// content.
+var DefaultCacheControl = CacheControlConfig{
+	ImmutableMaxAge: 29030400 * time.Second,
+	MutableMaxAge:   time.Minute,
+}
+
+// CacheControl returns how long the content of the path can be cached, and
+// whether it is immutable, using DefaultCacheControl.
+func CacheControl(p Path) (maxAge time.Duration, immutable bool) {
+	return DefaultCacheControl.CacheControl(p)
+}
+
+// CacheControl returns how long the content of the path can be cached, and
+// whether it is immutable: /ipfs and /ipld paths are immutable and get
+// ImmutableMaxAge, /ipns paths are mutable and get MutableMaxAge. Invalid
+// paths can't be cached and get a zero max age.
+func (c CacheControlConfig) CacheControl(p Path) (maxAge time.Duration, immutable bool) {
+	pp, err := ParsePath(p.String())
+	if err != nil {
+		return 0, false
+	}
+
+	switch pp.Segments()[0] {
+	case "ipfs", "ipld":
+		return c.ImmutableMaxAge, true
+	case "ipns":
+		return c.MutableMaxAge, false
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,121 @@
+package path
+
+import (
+	"fmt"
+	"strings"
+)
+
+const upperhex = "0123456789ABCDEF"
+
+// EncodeSegment percent-encodes a path segment so that it can be used in a
+// Path, in particular when it contains a slash or a percent sign. All the
+// bytes except the ones allowed unescaped in a URL path segment by RFC 3986
+// are encoded, so any string, even if it isn't valid UTF-8, can be recovered
+// with DecodeSegment.
+func EncodeSegment(s string) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if shouldEscape(s[i]) {
+			n++
+		}
+	}
+	if n == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 2*n)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if shouldEscape(c) {
+			b.WriteByte('%')
+			b.WriteByte(upperhex[c>>4])
+			b.WriteByte(upperhex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// DecodeSegment decodes a path segment encoded with EncodeSegment, or any
+// other percent-encoding of it. It returns an error if a percent sign isn't
+// followed by two hexadecimal digits.
+func DecodeSegment(s string) (string, error) {
+	n := strings.Count(s, "%")
+	if n == 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return "", fmt.Errorf("invalid percent-encoding at offset %d of %q", i, s)
+		}
+		b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// DecodedSegments returns the segments of the path, like Segments, decoded
+// with DecodeSegment. The error is an ErrInvalidSegment.
+func (p Path) DecodedSegments() ([]string, error) {
+	segs := p.Segments()
+	for i, seg := range segs {
+		decoded, err := DecodeSegment(seg)
+		if err != nil {
+			return nil, ErrInvalidSegment{error: err, Index: i, Segment: seg}
+		}
+		segs[i] = decoded
+	}
+	return segs, nil
+}
+
+// canonicalSegment re-encodes a percent-encoded segment with EncodeSegment.
+// The segments that aren't percent-encoded, or not validly, are returned as
+// they are, and so are the encodings of "." and "..", which must not become
+// relative segments.
+func canonicalSegment(seg string) string {
+	if !strings.Contains(seg, "%") {
+		return seg
+	}
+	decoded, err := DecodeSegment(seg)
+	if err != nil || decoded == "." || decoded == ".." {
+		return seg
+	}
+	return EncodeSegment(decoded)
+}
+
+// shouldEscape returns true if the byte is not an unreserved character or a
+// sub-delimiter, nor ':' or '@', see RFC 3986 section 3.3
+func shouldEscape(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return false
+	}
+	switch c {
+	case '-', '.', '_', '~', '!', '$', '&', '\'', '(', ')', '*', '+', ',', ';', '=', ':', '@':
+		return false
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
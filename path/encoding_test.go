@@ -0,0 +1,158 @@
+package path
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSegment(t *testing.T) {
+	for s, expected := range map[string]string{
+		"":           "",
+		"foo.txt":    "foo.txt",
+		"a/b":        "a%2Fb",
+		"100%":       "100%25",
+		"a b?c#d":    "a%20b%3Fc%23d",
+		"café":       "caf%C3%A9",
+		"x:y@z+(1)!": "x:y@z+(1)!",
+	} {
+		encoded := EncodeSegment(s)
+		if encoded != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, encoded)
+		}
+		if strings.Contains(encoded, "/") {
+			t.Errorf("%q: encoded segment %q contains a slash", s, encoded)
+		}
+	}
+}
+
+func TestDecodeSegment(t *testing.T) {
+	for s, expected := range map[string]string{
+		"foo":        "foo",
+		"a%2fb":      "a/b",
+		"a%2Fb":      "a/b",
+		"caf%C3%A9":  "café",
+		"%25%2525":   "%%25",
+		"plus+stays": "plus+stays",
+	} {
+		decoded, err := DecodeSegment(s)
+		if err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+		if decoded != expected {
+			t.Errorf("%q: expected %q, got %q", s, expected, decoded)
+		}
+	}
+
+	for _, s := range []string{"%", "%2", "a%zz", "%%20"} {
+		if _, err := DecodeSegment(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestDecodedSegments(t *testing.T) {
+	p, err := FromSegments("/ipfs/", "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", EncodeSegment("a/b"), EncodeSegment("50%"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs, err := p.DecodedSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 4 || segs[2] != "a/b" || segs[3] != "50%" {
+		t.Fatalf("unexpected segments %q", segs)
+	}
+
+	_, err = Path("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/%zz").DecodedSegments()
+	var segErr ErrInvalidSegment
+	if !errors.As(err, &segErr) || segErr.Index != 2 {
+		t.Fatalf("expected an ErrInvalidSegment for the third segment, got %v", err)
+	}
+}
+
+func TestCanonicalSegments(t *testing.T) {
+	const root = "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/"
+	for s, expected := range map[string]string{
+		"a%2fb":     "a%2Fb",
+		"%41%42c":   "ABc",
+		"a b%20c":   "a%20b%20c",
+		"caf%c3%a9": "caf%C3%A9",
+		"50%":       "50%",
+		"%zz":       "%zz",
+		"%2e%2e":    "%2e%2e",
+		"a b":       "a b",
+	} {
+		p := Path(root + s)
+		if str := p.String(); str != root+expected {
+			t.Errorf("%q: expected %q, got %q", s, root+expected, str)
+		}
+		segs := p.Segments()
+		if last := segs[len(segs)-1]; last != expected {
+			t.Errorf("%q: expected the segment %q, got %q", s, expected, last)
+		}
+
+		// the segments of the string are the segments of the path
+		strSegs := Path(p.String()).Segments()
+		if strings.Join(strSegs, "/") != strings.Join(segs, "/") {
+			t.Errorf("%q: the segments of %q are %q, expected %q", s, p.String(), strSegs, segs)
+		}
+	}
+
+	// paths that only differ by the encoding of a segment are equal
+	a, b := Path(root+"a%2fb"), Path(root+"a%2Fb")
+	if a.String() != b.String() || !EqualFold(a, b) {
+		t.Fatalf("expected %q and %q to be equal", a, b)
+	}
+
+	// a path built from encoded names gives the names back
+	p, err := FromSegments(root, EncodeSegment("a/b"), EncodeSegment("50% off"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := p.DecodedSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names[2] != "a/b" || names[3] != "50% off" {
+		t.Fatalf("unexpected names %q", names)
+	}
+}
+
+func FuzzSegmentEncoding(f *testing.F) {
+	for _, s := range []string{
+		"",
+		"foo",
+		"/",
+		"a/b/c",
+		"%",
+		"%25",
+		"%2F",
+		"100%/done",
+		"..",
+		"café",
+		"日本語",
+		"\x00\xff\xfe",
+		"\xc3",
+		"space and+plus",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		encoded := EncodeSegment(s)
+		if strings.ContainsAny(encoded, "/ ") {
+			t.Fatalf("%q: encoded segment %q contains a slash or a space", s, encoded)
+		}
+		decoded, err := DecodeSegment(encoded)
+		if err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+		if decoded != s {
+			t.Fatalf("%q: round trip gave %q", s, decoded)
+		}
+		if canonical := canonicalSegment(encoded); canonical != encoded {
+			t.Fatalf("%q: encoded segment %q is not canonical, got %q", s, encoded, canonical)
+		}
+	})
+}
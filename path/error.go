@@ -25,3 +25,22 @@ func (e ErrInvalidPath) Is(err error) bool {
 		return false
 	}
 }
+
+// ErrInvalidSegment is returned, as a value, by ValidateSegments, JoinDir and
+// Path.DecodedSegments for the first segment that can't be part of a path.
+type ErrInvalidSegment struct {
+	error error
+	// Index is the position of the segment among the segments given to
+	// ValidateSegments or JoinDir, or among the segments of the path for
+	// Path.DecodedSegments.
+	Index   int
+	Segment string
+}
+
+func (e ErrInvalidSegment) Error() string {
+	return fmt.Sprintf("invalid path segment %d %q: %s", e.Index, e.Segment, e.error)
+}
+
+func (e ErrInvalidSegment) Unwrap() error {
+	return e.error
+}
@@ -3,10 +3,16 @@ package path
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"path"
 	"strings"
 
 	cid "github.com/ipfs/go-cid"
+	"github.com/mikelsr/go-libp2p/core/peer"
+	mbase "github.com/multiformats/go-multibase"
+	mc "github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
 )
 
 // A Path represents an ipfs content path:
@@ -30,8 +36,23 @@ func FromCid(c cid.Cid) Path {
 	return Path("/ipfs/" + c.String())
 }
 
+// NewIPNSPath returns the /ipns path of the name given as a CID, encoded in
+// base36 as used by subdomain gateways.
+func NewIPNSPath(c cid.Cid) Path {
+	return Path("/ipns/" + c.Encode(mbase.MustNewEncoder(mbase.Base36)))
+}
+
+// NewIPNSPathFromPeer returns the /ipns path of the key of the peer, i.e.
+// NewIPNSPath(peer.ToCid(pid)).
+func NewIPNSPathFromPeer(pid peer.ID) Path {
+	return NewIPNSPath(peer.ToCid(pid))
+}
+
 // Segments returns the different elements of a path
-// (elements are delimited by a /).
+// (elements are delimited by a /). The percent-encoded segments are
+// returned in the encoding of EncodeSegment, like in String, so that paths
+// that only differ by the encoding of their segments have the same
+// segments. Use DecodedSegments to get the decoded segments.
 func (p Path) Segments() []string {
 	cleaned := path.Clean(string(p))
 	segments := strings.Split(cleaned, "/")
@@ -41,12 +62,101 @@ func (p Path) Segments() []string {
 		segments = segments[1:]
 	}
 
+	for i, seg := range segments {
+		segments[i] = canonicalSegment(seg)
+	}
 	return segments
 }
 
-// String converts a path to string.
+// String converts a path to string. The percent-encoded segments are
+// re-encoded with EncodeSegment, e.g. /ipfs/<cid>/a%2fb%41 becomes
+// /ipfs/<cid>/a%2FbA; the other segments are returned as they are.
 func (p Path) String() string {
-	return string(p)
+	if !strings.Contains(string(p), "%") {
+		return string(p)
+	}
+
+	segments := strings.Split(string(p), "/")
+	for i, seg := range segments {
+		segments[i] = canonicalSegment(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// URLPath returns the path with its segments percent-encoded, like
+// /ipfs/<cid>/a%20b/%C3%A9, to use as the path of a gateway URL. String
+// returns the segments as they are.
+func (p Path) URLPath() string {
+	parts := strings.Split(string(p), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// TrimNamespace returns the cleaned path without its leading /namespace/,
+// i.e. the root followed by the segments, like "<cid>/a/b" for
+// "/ipfs/<cid>/a/b". Paths without a namespace are returned cleaned.
+func (p Path) TrimNamespace() string {
+	segs := p.Segments()
+	if strings.HasPrefix(string(p), "/") {
+		segs = segs[1:]
+	}
+	return strings.Join(segs, "/")
+}
+
+// SegmentsAfterRoot returns the segments of the path that follow its root,
+// i.e. Segments without the namespace and the CID, IPNS key or DNSLink
+// domain: [a b] for /ipfs/<cid>/a/b, <cid>/a/b and /ipns/example.com/a/b.
+// It returns nil if the path is just a root.
+func (p Path) SegmentsAfterRoot() []string {
+	segs := p.Segments()
+	prefix := 1
+	if strings.HasPrefix(string(p), "/") {
+		prefix = 2
+	}
+	if len(segs) <= prefix {
+		return nil
+	}
+	return segs[prefix:]
+}
+
+// SegmentCount returns the number of segments after the root of the path,
+// e.g. 2 for /ipfs/<cid>/a/b and for <cid>/a/b, like SegmentsAfterRoot. The
+// path is cleaned as in Segments, but the segments are counted without
+// allocating.
+func (p Path) SegmentCount() int {
+	s := string(p)
+	rooted := strings.HasPrefix(s, "/")
+
+	// dotdot counts the leading ".." that path.Clean keeps in relative paths
+	n, dotdot := 0, 0
+	for s != "" {
+		var seg string
+		seg, s, _ = strings.Cut(s, "/")
+		switch seg {
+		case "", ".":
+		case "..":
+			if n > dotdot {
+				n--
+			} else if !rooted {
+				n++
+				dotdot++
+			}
+		default:
+			n++
+		}
+	}
+
+	// skip the namespace and the root
+	prefix := 1
+	if rooted {
+		prefix = 2
+	}
+	if n < prefix {
+		return 0
+	}
+	return n - prefix
 }
 
 // IsJustAKey returns true if the path is of the form <key> or /ipfs/<key>, or
@@ -74,73 +184,268 @@ func (p Path) PopLastSegment() (Path, string, error) {
 	return newPath, segs[len(segs)-1], nil
 }
 
+// RelativeTo returns the segments of the path that follow base, joined with
+// /. Paths are compared segment by segment and base must be a prefix of the
+// path, otherwise an error is returned. An empty string is returned if the
+// paths are equal.
+func (p Path) RelativeTo(base Path) (string, error) {
+	pp, err := ParsePath(p.String())
+	if err != nil {
+		return "", err
+	}
+	bp, err := ParsePath(base.String())
+	if err != nil {
+		return "", err
+	}
+
+	segs, baseSegs := pp.Segments(), bp.Segments()
+	if len(baseSegs) > len(segs) {
+		return "", &ErrInvalidPath{error: fmt.Errorf("%q is not a prefix", base), path: string(p)}
+	}
+	for i, seg := range baseSegs {
+		if segs[i] != seg {
+			return "", &ErrInvalidPath{error: fmt.Errorf("%q is not a prefix", base), path: string(p)}
+		}
+	}
+
+	return strings.Join(segs[len(baseSegs):], "/"), nil
+}
+
+// IsAncestorOf returns true if other is equal to the path or inside it, i.e.
+// they share the namespace and root and the segments of other start with the
+// segments of the path. Invalid paths are not ancestors of any path.
+func (p Path) IsAncestorOf(other Path) bool {
+	_, err := other.RelativeTo(p)
+	return err == nil
+}
+
+// DefaultReservedSegments are the path segments that have a special meaning
+// for gateways, used by HasReservedSegment when no segments are given.
+var DefaultReservedSegments = []string{"_redirects", ".well-known", "ipfs-404.html"}
+
+// HasReservedSegment returns the first segment of the path, after the
+// namespace and root (see SegmentsAfterRoot), that is one of the reserved
+// segments, and true. It returns false if there is none. If no reserved
+// segments are given, DefaultReservedSegments are used.
+func (p Path) HasReservedSegment(reserved ...string) (string, bool) {
+	if len(reserved) == 0 {
+		reserved = DefaultReservedSegments
+	}
+
+	for _, seg := range p.SegmentsAfterRoot() {
+		for _, r := range reserved {
+			if seg == r {
+				return seg, true
+			}
+		}
+	}
+	return "", false
+}
+
 // FromSegments returns a path given its different segments.
 func FromSegments(prefix string, seg ...string) (Path, error) {
 	return ParsePath(prefix + strings.Join(seg, "/"))
 }
 
+// JoinDir appends the segments to base and returns the path of the resulting
+// directory, which ends with a slash: gateways rely on the trailing slash to
+// tell directories apart, e.g. to serve their index.html. A trailing slash of
+// base is not doubled, so JoinDir("/ipfs/<cid>/a/") is /ipfs/<cid>/a/. The
+// segments are checked with ValidateSegments, and none may be empty.
+func JoinDir(base Path, segs ...string) (Path, error) {
+	for i, seg := range segs {
+		if seg == "" {
+			return "", ErrInvalidSegment{error: fmt.Errorf("empty segment"), Index: i, Segment: seg}
+		}
+	}
+	if err := ValidateSegments(segs...); err != nil {
+		return "", err
+	}
+
+	joined := strings.TrimSuffix(base.String(), "/")
+	for _, seg := range segs {
+		joined += "/" + seg
+	}
+	return ParsePath(joined + "/")
+}
+
+// MaxSegmentLength is the maximum length of a path segment accepted by
+// ValidateSegments, the usual limit of file names.
+const MaxSegmentLength = 255
+
+// ValidateSegments checks that segments supplied by users can be joined into
+// a path, e.g. before calling FromSegments, so that errors can point at the
+// offending segment: only the first segment may be empty (for a leading
+// slash), and segments can't contain a slash or be longer than
+// MaxSegmentLength bytes. The error is an ErrInvalidSegment.
+func ValidateSegments(segments ...string) error {
+	for i, seg := range segments {
+		var err error
+		switch {
+		case seg == "" && i > 0:
+			err = fmt.Errorf("empty segment")
+		case strings.Contains(seg, "/"):
+			err = fmt.Errorf("segment contains a slash")
+		case len(seg) > MaxSegmentLength:
+			err = fmt.Errorf("segment is longer than %d bytes", MaxSegmentLength)
+		}
+		if err != nil {
+			return ErrInvalidSegment{error: err, Index: i, Segment: seg}
+		}
+	}
+	return nil
+}
+
+// ParseOption configures ParsePath.
+type ParseOption func(*parseSettings)
+
+type parseSettings struct {
+	rejectCidV0      bool
+	rejectNonKeyCids bool
+	rejectIPLD       bool
+}
+
+// RejectCidV0 makes ParsePath return an error for /ipfs and /ipld paths with a
+// CIDv0 root, for deployments that only support CIDv1. By default CIDv0 is
+// accepted.
+func RejectCidV0() ParseOption {
+	return func(s *parseSettings) {
+		s.rejectCidV0 = true
+	}
+}
+
+// RejectNonKeyIPNSNames makes ParsePath return an error for /ipns paths whose
+// name is a CID with another codec than libp2p-key, such as a dag-pb CID,
+// which is most likely an /ipfs path with the wrong namespace. IPNS names
+// are keys or DNSLink domains. CIDv0 names can't be told apart from base58
+// peer IDs and are accepted. By default these names are accepted.
+func RejectNonKeyIPNSNames() ParseOption {
+	return func(s *parseSettings) {
+		s.rejectNonKeyCids = true
+	}
+}
+
+// RejectIPLDNamespace makes ParsePath treat ipld as an unknown namespace, for
+// applications that only deal with /ipfs and /ipns paths. By default /ipld
+// paths are accepted.
+func RejectIPLDNamespace() ParseOption {
+	return func(s *parseSettings) {
+		s.rejectIPLD = true
+	}
+}
+
 // ParsePath returns a well-formed ipfs Path.
 // The returned path will always be prefixed with /ipfs/ or /ipns/.
 // The prefix will be added if not present in the given string.
 // This function will return an error when the given string is
 // not a valid ipfs path.
-func ParsePath(txt string) (Path, error) {
-	parts := strings.Split(txt, "/")
-	if len(parts) == 1 {
-		kp, err := ParseCidToPath(txt)
-		if err == nil {
-			return kp, nil
+//
+// Only the root of /ipfs and /ipld paths is decoded as a CID, the other
+// segments are opaque strings, so the cost of parsing doesn't depend on
+// their content. Use CidSegments to decode them. The root is kept as given,
+// in the multibase of the input, and decoded by SplitAbsPath or
+// SegmentAsCid.
+func ParsePath(txt string, opts ...ParseOption) (Path, error) {
+	p, root, err := parsePath(txt)
+	if err != nil || len(opts) == 0 {
+		return p, err
+	}
+
+	var settings parseSettings
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	if settings.rejectIPLD && p.Segments()[0] == "ipld" {
+		return "", &ErrInvalidPath{error: fmt.Errorf("unknown namespace %q", "ipld"), path: txt}
+	}
+
+	// root is only defined for /ipfs and /ipld paths
+	if settings.rejectCidV0 && root.Defined() && root.Version() == 0 {
+		v1 := cid.NewCidV1(root.Type(), root.Hash())
+		return "", &ErrInvalidPath{error: fmt.Errorf("CIDv0 %s is not allowed, convert it to CIDv1 (%s)", root, v1), path: txt}
+	}
+
+	if settings.rejectNonKeyCids {
+		segs := p.Segments()
+		if segs[0] == "ipns" {
+			// peer IDs in base58 are also valid CIDv0, so check them first
+			if _, err := peer.Decode(segs[1]); err != nil {
+				if c, err := cid.Decode(segs[1]); err == nil {
+					return "", &ErrInvalidPath{error: fmt.Errorf("IPNS name is a %s CID, not a libp2p-key", mc.Code(c.Type())), path: txt}
+				}
+			}
 		}
 	}
 
+	return p, nil
+}
+
+// parsePath parses the path, decoding its root only, which is returned for
+// /ipfs and /ipld paths (cid.Undef otherwise)
+func parsePath(txt string) (Path, cid.Cid, error) {
+	parts := strings.Split(txt, "/")
+
 	// if the path doesnt begin with a '/'
 	// we expect this to start with a hash, and be an 'ipfs' path
 	if parts[0] != "" {
-		if _, err := decodeCid(parts[0]); err != nil {
-			return "", &ErrInvalidPath{error: err, path: txt}
+		c, err := decodeCid(parts[0])
+		if err != nil {
+			return "", cid.Undef, &ErrInvalidPath{error: err, path: txt}
 		}
-		// The case when the path starts with hash without a protocol prefix
-		return Path("/ipfs/" + txt), nil
+		// The case when the path starts with hash without a protocol prefix,
+		// the CID is kept as given rather than re-encoded in its default base
+		return Path("/ipfs/" + txt), c, nil
 	}
 
 	if len(parts) < 3 {
-		return "", &ErrInvalidPath{error: fmt.Errorf("invalid ipfs path"), path: txt}
+		return "", cid.Undef, &ErrInvalidPath{error: fmt.Errorf("invalid ipfs path"), path: txt}
 	}
 
 	//TODO: make this smarter
 	switch parts[1] {
 	case "ipfs", "ipld":
 		if parts[2] == "" {
-			return "", &ErrInvalidPath{error: fmt.Errorf("not enough path components"), path: txt}
+			return "", cid.Undef, &ErrInvalidPath{error: fmt.Errorf("not enough path components"), path: txt}
 		}
 		// Validate Cid.
-		_, err := decodeCid(parts[2])
+		c, err := decodeCid(parts[2])
 		if err != nil {
-			return "", &ErrInvalidPath{error: fmt.Errorf("invalid CID: %w", err), path: txt}
+			return "", cid.Undef, &ErrInvalidPath{error: fmt.Errorf("invalid CID: %w", err), path: txt}
 		}
+		return Path(txt), c, nil
 	case "ipns":
 		if parts[2] == "" {
-			return "", &ErrInvalidPath{error: fmt.Errorf("not enough path components"), path: txt}
+			return "", cid.Undef, &ErrInvalidPath{error: fmt.Errorf("not enough path components"), path: txt}
 		}
 	default:
-		return "", &ErrInvalidPath{error: fmt.Errorf("unknown namespace %q", parts[1]), path: txt}
+		return "", cid.Undef, &ErrInvalidPath{error: fmt.Errorf("unknown namespace %q", parts[1]), path: txt}
 	}
 
-	return Path(txt), nil
+	return Path(txt), cid.Undef, nil
+}
+
+// StripQuery splits the path of a URL, such as /ipfs/<cid>/index.html?a=b,
+// into the path part and the query string, without the "?". The query is
+// empty if there is none. Call it before ParsePath, which would keep the
+// query in the last segment.
+func StripQuery(raw string) (pathPart, query string) {
+	pathPart, query, _ = strings.Cut(raw, "?")
+	return pathPart, query
 }
 
 // ParseCidToPath takes a CID in string form and returns a valid ipfs Path.
+// The CID is kept in the multibase it is given in.
 func ParseCidToPath(txt string) (Path, error) {
 	if txt == "" {
 		return "", &ErrInvalidPath{error: fmt.Errorf("empty"), path: txt}
 	}
 
-	c, err := decodeCid(txt)
-	if err != nil {
+	if _, err := decodeCid(txt); err != nil {
 		return "", &ErrInvalidPath{error: err, path: txt}
 	}
 
-	return FromCid(c), nil
+	return Path("/ipfs/" + txt), nil
 }
 
 // IsValid checks if a path is a valid ipfs Path.
@@ -159,11 +464,54 @@ func SplitList(pth string) []string {
 	return strings.Split(pth, "/")
 }
 
+// CommonPrefix returns the deepest path that is an ancestor of, or equal to,
+// all the given paths. Paths are compared segment by segment, so
+// /ipfs/<cid>/foo is not a prefix of /ipfs/<cid>/foobar. All paths must share
+// the same namespace and root, otherwise an error is returned.
+func CommonPrefix(paths ...Path) (Path, error) {
+	if len(paths) == 0 {
+		return "", &ErrInvalidPath{error: fmt.Errorf("no paths"), path: ""}
+	}
+
+	var common []string
+	for i, p := range paths {
+		pp, err := ParsePath(p.String())
+		if err != nil {
+			return "", err
+		}
+
+		segs := pp.Segments()
+		if i == 0 {
+			common = segs
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(segs) && common[n] == segs[n] {
+			n++
+		}
+		// namespace and root must match
+		if n < 2 {
+			return "", &ErrInvalidPath{error: fmt.Errorf("namespace or root differs from %q", paths[0]), path: string(p)}
+		}
+		common = common[:n]
+	}
+
+	return Path("/" + strings.Join(common, "/")), nil
+}
+
 // SplitAbsPath clean up and split fpath. It extracts the first component (which
-// must be a Multihash) and return it separately.
+// must be a Multihash) and return it separately. The "." and ".." segments
+// after it are interpreted as the current and parent node, and an error is
+// returned if ".." would go above the root.
 func SplitAbsPath(fpath Path) (cid.Cid, []string, error) {
-	parts := fpath.Segments()
-	if parts[0] == "ipfs" || parts[0] == "ipld" {
+	var parts []string
+	for _, seg := range strings.Split(string(fpath), "/") {
+		if seg != "" && seg != "." {
+			parts = append(parts, seg)
+		}
+	}
+	if len(parts) > 0 && (parts[0] == "ipfs" || parts[0] == "ipld") {
 		parts = parts[1:]
 	}
 
@@ -178,7 +526,269 @@ func SplitAbsPath(fpath Path) (cid.Cid, []string, error) {
 		return cid.Cid{}, nil, &ErrInvalidPath{error: fmt.Errorf("invalid CID: %w", err), path: string(fpath)}
 	}
 
-	return c, parts[1:], nil
+	segs := make([]string, 0, len(parts)-1)
+	for _, seg := range parts[1:] {
+		if seg != ".." {
+			segs = append(segs, seg)
+			continue
+		}
+		if len(segs) == 0 {
+			return cid.Cid{}, nil, &ErrInvalidPath{error: fmt.Errorf("\"..\" goes above the root"), path: string(fpath)}
+		}
+		segs = segs[:len(segs)-1]
+	}
+	return c, segs, nil
+}
+
+// Split splits the path into its root, i.e. the path made of the namespace
+// and the first segment (a CID, an IPNS key or a DNSLink domain), and the
+// remaining segments. For example /ipns/example.com/a/b is split into
+// /ipns/example.com and [a b]. Unlike SplitAbsPath, it works for any
+// namespace and returns the root as a Path.
+func Split(p Path) (Path, []string, error) {
+	pp, err := ParsePath(p.String())
+	if err != nil {
+		return "", nil, err
+	}
+
+	segs := pp.Segments()
+	if len(segs) < 2 || segs[1] == "" {
+		return "", nil, &ErrInvalidPath{error: fmt.Errorf("not enough path components"), path: string(p)}
+	}
+
+	return Path("/" + segs[0] + "/" + segs[1]), segs[2:], nil
+}
+
+// PrefixPaths returns the ancestors of the path, from its root (see Split)
+// down to the path itself. For example /ipfs/<cid>/a/b gives /ipfs/<cid>,
+// /ipfs/<cid>/a and /ipfs/<cid>/a/b. The root is kept as is, so it works
+// for /ipns paths of keys and DNSLink domains too. It returns nil if the
+// path is invalid.
+func PrefixPaths(p Path) []Path {
+	root, segs, err := Split(p)
+	if err != nil {
+		return nil
+	}
+
+	prefixes := make([]Path, 0, len(segs)+1)
+	prefixes = append(prefixes, root)
+	for _, seg := range segs {
+		root = Path(root.String() + "/" + seg)
+		prefixes = append(prefixes, root)
+	}
+	return prefixes
+}
+
+// Rebase returns the path with its root (see Split) replaced by newRoot,
+// keeping the namespace and the remaining segments: /ipfs/<old>/a/b becomes
+// /ipfs/<newRoot>/a/b. The root of an /ipns path must be a key, the new one
+// is then encoded like NewIPNSPath does. DNSLink paths have no CID root to
+// replace and are rejected, use RebaseWithNamespace to rebase them.
+func Rebase(p Path, newRoot cid.Cid) (Path, error) {
+	root, _, err := Split(p)
+	if err != nil {
+		return "", err
+	}
+
+	segs := root.Segments()
+	if segs[0] == "ipns" && !isIPNSKey(segs[1]) {
+		return "", &ErrInvalidPath{error: fmt.Errorf("DNSLink path has no CID root to replace"), path: string(p)}
+	}
+	return RebaseWithNamespace(p, segs[0], newRoot)
+}
+
+// RebaseWithNamespace is like Rebase but also replaces the namespace, which
+// must be one of ipfs, ipld or ipns. Any root can be replaced, DNSLink names
+// included: /ipns/example.com/a rebased in the ipfs namespace becomes
+// /ipfs/<newRoot>/a.
+func RebaseWithNamespace(p Path, namespace string, newRoot cid.Cid) (Path, error) {
+	_, rest, err := Split(p)
+	if err != nil {
+		return "", err
+	}
+	if !newRoot.Defined() {
+		return "", &ErrInvalidPath{error: fmt.Errorf("undefined root CID"), path: string(p)}
+	}
+
+	var rebased Path
+	switch namespace {
+	case "ipfs", "ipld":
+		rebased = Path("/" + namespace + "/" + newRoot.String())
+	case "ipns":
+		rebased = NewIPNSPath(newRoot)
+	default:
+		return "", &ErrInvalidPath{error: fmt.Errorf("unknown namespace %q", namespace), path: string(p)}
+	}
+
+	for _, seg := range rest {
+		rebased = Path(rebased.String() + "/" + seg)
+	}
+	return rebased, nil
+}
+
+// EqualFold returns true if the paths are equal once cleaned, ignoring the
+// case of the domain name of DNSLink paths only: /ipns/Example.COM/a equals
+// /ipns/example.com/a but not /ipns/example.com/A. The other segments, and
+// the CID roots, which may be case-sensitive depending on their multibase,
+// are compared exactly.
+func EqualFold(a, b Path) bool {
+	segsA, segsB := a.Segments(), b.Segments()
+	if len(segsA) != len(segsB) {
+		return false
+	}
+	for i := range segsA {
+		if segsA[i] == segsB[i] {
+			continue
+		}
+		if i == 1 && segsA[0] == "ipns" && !isIPNSKey(segsA[1]) && !isIPNSKey(segsB[1]) &&
+			strings.EqualFold(segsA[1], segsB[1]) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// SegmentAsCid decodes the i-th segment of the path (see Segments) as a CID,
+// in any multibase supported by cid.Decode. It returns false if the segment
+// doesn't exist or isn't a CID.
+func SegmentAsCid(p Path, i int) (cid.Cid, bool) {
+	segs := p.Segments()
+	if i < 0 || i >= len(segs) {
+		return cid.Undef, false
+	}
+
+	c, err := cid.Decode(segs[i])
+	if err != nil {
+		return cid.Undef, false
+	}
+	return c, true
+}
+
+// CidSegment is a segment of a path that is a CID, see CidSegments.
+type CidSegment struct {
+	// Index is the position of the segment in Segments
+	Index int
+	Cid   cid.Cid
+}
+
+// CidSegments decodes the segments of the path that are CIDs, in any
+// multibase supported by cid.Decode, the root of /ipfs and /ipld paths
+// included. To bound the work spent on untrusted paths, it fails with an
+// ErrInvalidPath before decoding anything if the path has more than
+// maxSegments segments. A maxSegments of zero or less means no limit.
+func CidSegments(p Path, maxSegments int) ([]CidSegment, error) {
+	segs := p.Segments()
+	if maxSegments > 0 && len(segs) > maxSegments {
+		return nil, &ErrInvalidPath{error: fmt.Errorf("more than %d segments", maxSegments), path: string(p)}
+	}
+
+	var cids []CidSegment
+	for i, seg := range segs {
+		if c, err := cid.Decode(seg); err == nil {
+			cids = append(cids, CidSegment{Index: i, Cid: c})
+		}
+	}
+	return cids, nil
+}
+
+// IPNSName returns the name at the root of an /ipns path, in the form used
+// by subdomain gateways: a base36 CIDv1 with the libp2p-key codec for a key,
+// or the domain name for DNSLink.
+func IPNSName(p Path) (string, error) {
+	pp, err := ParsePath(p.String())
+	if err != nil {
+		return "", err
+	}
+
+	segs := pp.Segments()
+	if segs[0] != "ipns" {
+		return "", &ErrInvalidPath{error: fmt.Errorf("not an ipns path"), path: string(p)}
+	}
+
+	name := segs[1]
+	if pid, err := peer.Decode(name); err == nil {
+		return peer.ToCid(pid).StringOfBase(mbase.Base36)
+	}
+	if strings.Contains(name, ".") {
+		return name, nil
+	}
+	return "", &ErrInvalidPath{error: fmt.Errorf("%q is neither a key nor a domain", name), path: string(p)}
+}
+
+// NewPathFromMultiaddrComponent returns the /ipns path of the peer in a
+// multiaddr component of the form /p2p/<peerid>, or the legacy /ipfs/<peerid>.
+// The name of the path is the peer ID as a base36 CIDv1 with the libp2p-key
+// codec, like IPNSName.
+func NewPathFromMultiaddrComponent(component string) (Path, error) {
+	parts := strings.Split(component, "/")
+	if len(parts) != 3 || parts[0] != "" || (parts[1] != "p2p" && parts[1] != "ipfs") {
+		return "", &ErrInvalidPath{error: fmt.Errorf("not a /p2p multiaddr component"), path: component}
+	}
+
+	pid, err := peer.Decode(parts[2])
+	if err != nil {
+		return "", &ErrInvalidPath{error: fmt.Errorf("invalid peer ID: %w", err), path: component}
+	}
+	return NewIPNSPathFromPeer(pid), nil
+}
+
+// NewPathFromSubdomain returns the path of the content served by a subdomain
+// gateway for the given host, of the form <root>.<namespace>.<gateway>, with
+// an optional port. For example bafy....ipfs.dweb.link gives /ipfs/bafy...
+// and en.wikipedia-on-ipfs.org.ipns.dweb.link gives
+// /ipns/en.wikipedia-on-ipfs.org.
+//
+// The namespace is the first ipfs or ipns label preceded by a valid root: a
+// CID for ipfs, and a key or DNSLink name for ipns. A DNSLink name inlined in
+// a single label, like en-wikipedia--on--ipfs-org, is converted back to the
+// name.
+func NewPathFromSubdomain(host string) (Path, error) {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	labels := strings.Split(hostname, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		ns := labels[i]
+		if ns != "ipfs" && ns != "ipns" {
+			continue
+		}
+		root := strings.Join(labels[:i], ".")
+
+		if ns == "ipfs" {
+			if _, err := cid.Decode(root); err != nil {
+				continue
+			}
+		} else if !strings.Contains(root, ".") && !isIPNSKey(root) {
+			root = inlinedDNSLinkFQDN(root)
+		}
+
+		p, err := ParsePath("/" + ns + "/" + root)
+		if err != nil {
+			continue
+		}
+		return p, nil
+	}
+	return "", &ErrInvalidPath{error: fmt.Errorf("not a subdomain gateway host"), path: host}
+}
+
+// isIPNSKey returns true if the name is a peer ID or a CID
+func isIPNSKey(name string) bool {
+	if _, err := peer.Decode(name); err == nil {
+		return true
+	}
+	_, err := cid.Decode(name)
+	return err == nil
+}
+
+// inlinedDNSLinkFQDN converts a DNSLink name inlined in a single DNS label to
+// the name: my-v--long-example-com becomes my.v-long.example.com
+func inlinedDNSLinkFQDN(label string) string {
+	fqdn := strings.ReplaceAll(label, "--", "@") // @ is not valid in DNS labels
+	fqdn = strings.ReplaceAll(fqdn, "-", ".")
+	return strings.ReplaceAll(fqdn, "@", "-")
 }
 
 func decodeCid(cstr string) (cid.Cid, error) {
@@ -186,5 +796,22 @@ func decodeCid(cstr string) (cid.Cid, error) {
 	if err != nil && len(cstr) == 46 && cstr[:2] == "qm" { // https://github.com/ipfs/go-ipfs/issues/7792
 		return cid.Cid{}, fmt.Errorf("%v (possible lowercased CIDv0; consider converting to a case-agnostic CIDv1, such as base32)", err)
 	}
+	if err != nil {
+		if h, ok := decodeMultihash(cstr); ok {
+			return cid.Cid{}, fmt.Errorf("%v (%q is a bare multihash, not a CID; wrap it in a CID with the codec of the data, e.g. %s for raw data)", err, cstr, cid.NewCidV1(cid.Raw, h))
+		}
+	}
 	return c, err
 }
+
+// decodeMultihash decodes a multihash given in base58 or hex, the usual
+// encodings of bare multihashes
+func decodeMultihash(s string) (mh.Multihash, bool) {
+	if h, err := mh.FromB58String(s); err == nil {
+		return h, true
+	}
+	if h, err := mh.FromHexString(s); err == nil {
+		return h, true
+	}
+	return nil, false
+}
@@ -1,8 +1,19 @@
 package path
 
 import (
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/mikelsr/go-libp2p/core/crypto"
+	"github.com/mikelsr/go-libp2p/core/peer"
+	"github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
 )
 
 func TestPathParsing(t *testing.T) {
@@ -38,6 +49,46 @@ func TestPathParsing(t *testing.T) {
 	}
 }
 
+func TestParsePathKeepsMultibase(t *testing.T) {
+	c, err := cid.Decode("bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, base := range []multibase.Encoding{multibase.Base32Upper, multibase.Base58BTC, multibase.Base36} {
+		str, err := c.StringOfBase(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for txt, expected := range map[string]string{
+			str:                   "/ipfs/" + str,
+			str + "/a":            "/ipfs/" + str + "/a",
+			"/ipfs/" + str + "/a": "/ipfs/" + str + "/a",
+		} {
+			p, err := ParsePath(txt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.String() != expected {
+				t.Fatalf("expected %s, got %s", expected, p)
+			}
+			root, ok := SegmentAsCid(p, 1)
+			if !ok || root != c {
+				t.Fatalf("expected the root of %s to decode to %s", p, c)
+			}
+		}
+
+		p, err := ParseCidToPath(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != "/ipfs/"+str {
+			t.Fatalf("expected /ipfs/%s, got %s", str, p)
+		}
+	}
+}
+
 func TestNoComponents(t *testing.T) {
 	for _, s := range []string{
 		"/ipfs/",
@@ -126,3 +177,882 @@ func TestV0ErrorDueToLowercase(t *testing.T) {
 		t.Fatal("should have meaningful info about case-insensitive fix")
 	}
 }
+
+func TestCommonPrefix(t *testing.T) {
+	root := "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+
+	cases := []struct {
+		paths    []Path
+		expected Path
+	}{
+		{[]Path{Path(root + "/a/b/c")}, Path(root + "/a/b/c")},
+		{[]Path{Path(root + "/a/b/c"), Path(root + "/a/b/d")}, Path(root + "/a/b")},
+		{[]Path{Path(root + "/a/b/c"), Path(root + "/a/b"), Path(root + "/a/x")}, Path(root + "/a")},
+		{[]Path{Path(root + "/a/foo"), Path(root + "/a/foobar")}, Path(root + "/a")},
+		{[]Path{Path(root + "/a"), Path(root + "/b")}, Path(root)},
+		{[]Path{Path(root + "/a/"), Path(root[len("/ipfs/"):] + "/a")}, Path(root + "/a")},
+	}
+
+	for _, tc := range cases {
+		p, err := CommonPrefix(tc.paths...)
+		if err != nil {
+			t.Fatalf("unexpected error for %v: %s", tc.paths, err)
+		}
+		if p != tc.expected {
+			t.Fatalf("expected %s for %v, got %s", tc.expected, tc.paths, p)
+		}
+	}
+
+	errCases := [][]Path{
+		{},
+		{Path(root + "/a"), Path("/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a")},
+		{Path(root + "/a"), Path("/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/a")},
+		{Path(root + "/a"), Path("/ipfs/foo")},
+	}
+	for _, paths := range errCases {
+		if _, err := CommonPrefix(paths...); err == nil {
+			t.Fatalf("expected error for %v", paths)
+		}
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	base := Path("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a")
+
+	cases := map[Path]string{
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c": "b/c",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/":  "b",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a":     "",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b":         "b",
+	}
+	for p, expected := range cases {
+		rel, err := p.RelativeTo(base)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", p, err)
+		}
+		if rel != expected {
+			t.Fatalf("expected %q for %s, got %q", expected, p, rel)
+		}
+	}
+
+	errCases := []Path{
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/ab/c",
+		"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b",
+		"/ipfs/foo",
+	}
+	for _, p := range errCases {
+		if _, err := p.RelativeTo(base); err == nil {
+			t.Fatalf("expected error for %s", p)
+		}
+	}
+}
+
+func TestURLPath(t *testing.T) {
+	const root = "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	cases := map[Path]string{
+		root:                        root,
+		root + "/a b/c":             root + "/a%20b/c",
+		root + "/été/日本/":           root + "/%C3%A9t%C3%A9/%E6%97%A5%E6%9C%AC/",
+		root + "/100%/q?x#y":        root + "/100%25/q%3Fx%23y",
+		"/ipns/example.com/a+b=c@d": "/ipns/example.com/a+b=c@d",
+	}
+	for p, expected := range cases {
+		if urlPath := p.URLPath(); urlPath != expected {
+			t.Fatalf("expected %q for %s, got %q", expected, p, urlPath)
+		}
+
+		// the URL path decodes back to the path
+		u, err := url.Parse("https://dweb.link" + p.URLPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Path != p.String() {
+			t.Fatalf("expected %s to decode back, got %s", p, u.Path)
+		}
+	}
+}
+
+func TestSegmentCount(t *testing.T) {
+	for _, p := range []Path{
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c/",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n//a/./b/../c",
+		"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a",
+		"/ipns/example.com/a/b/",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/",
+	} {
+		// Segments include the namespace and the root
+		expected := len(p.Segments()) - 2
+		if !strings.HasPrefix(p.String(), "/") {
+			expected = len(p.Segments()) - 1
+		}
+		if count := p.SegmentCount(); count != expected {
+			t.Fatalf("expected %d segments for %s, got %d", expected, p, count)
+		}
+	}
+
+	p := Path("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c")
+	if allocs := testing.AllocsPerRun(10, func() { p.SegmentCount() }); allocs != 0 {
+		t.Fatalf("expected no allocation, got %f", allocs)
+	}
+}
+
+func TestSegmentsAfterRoot(t *testing.T) {
+	const c = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	cases := map[Path][]string{
+		"/ipfs/" + c:                 nil,
+		"/ipfs/" + c + "/":           nil,
+		"/ipfs/" + c + "/a/b":        {"a", "b"},
+		"/ipfs/" + c + "/a/b/":       {"a", "b"},
+		"/ipld/" + c + "/a":          {"a"},
+		"/ipns/" + c + "/a/b":        {"a", "b"},
+		"/ipns/example.com":          nil,
+		"/ipns/example.com/a/./b/..": {"a"},
+		c:                            nil,
+		c + "/a/b":                   {"a", "b"},
+		c + "/a/b/":                  {"a", "b"},
+	}
+	for p, expected := range cases {
+		segs := p.SegmentsAfterRoot()
+		if !reflect.DeepEqual(segs, expected) {
+			t.Fatalf("expected %q for %s, got %q", expected, p, segs)
+		}
+		if len(segs) != p.SegmentCount() {
+			t.Fatalf("expected %d segments for %s, got %d", p.SegmentCount(), p, len(segs))
+		}
+	}
+}
+
+func TestTrimNamespace(t *testing.T) {
+	cases := map[Path]string{
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n":                   "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/":              "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b",
+		"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a":                 "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a",
+		"/ipns/k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8/a": "k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8/a",
+		"/ipns/example.com/a/./b":                                                "example.com/a/b",
+		"/ipns/example.com":                                                      "example.com",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a":                       "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a",
+	}
+	for p, expected := range cases {
+		if trimmed := p.TrimNamespace(); trimmed != expected {
+			t.Fatalf("expected %q for %s, got %q", expected, p, trimmed)
+		}
+	}
+}
+
+func TestEqualFold(t *testing.T) {
+	const v0 = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	equal := [][2]Path{
+		{"/ipns/example.com", "/ipns/Example.COM"},
+		{"/ipns/EXAMPLE.com/a/b", "/ipns/example.com/a/b/"},
+		{"/ipns/example.com/a/./b", "/ipns/example.com/a/b"},
+		{"/ipfs/" + v0 + "/Foo", "/ipfs/" + v0 + "/Foo"},
+	}
+	for _, c := range equal {
+		if !EqualFold(c[0], c[1]) || !EqualFold(c[1], c[0]) {
+			t.Fatalf("expected %s and %s to be equal", c[0], c[1])
+		}
+	}
+
+	notEqual := [][2]Path{
+		{"/ipns/example.com/a", "/ipns/example.com/A"},
+		{"/ipns/example.com/a", "/ipns/example.org/a"},
+		{"/ipns/example.com", "/IPNS/example.com"},
+		{"/ipfs/example.com", "/ipfs/Example.com"},
+		{"/ipfs/" + v0, Path("/ipfs/" + strings.ToLower(v0))},
+		{"/ipns/" + v0, Path("/ipns/" + strings.ToLower(v0))},
+		{"/ipfs/" + v0 + "/foo", "/ipfs/" + v0 + "/Foo"},
+		{"/ipns/example.com/a", "/ipns/example.com"},
+	}
+	for _, c := range notEqual {
+		if EqualFold(c[0], c[1]) || EqualFold(c[1], c[0]) {
+			t.Fatalf("expected %s and %s to differ", c[0], c[1])
+		}
+	}
+}
+
+func TestSegmentAsCid(t *testing.T) {
+	v0 := "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	c, err := cid.Decode(v0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := cid.NewCidV1(cid.DagProtobuf, c.Hash())
+	b58, err := v1.StringOfBase(multibase.Base58BTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b16, err := v1.StringOfBase(multibase.Base16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Path("/ipfs/" + v0 + "/foo/" + v1.String() + "/" + b58 + "/" + b16 + "/bar")
+	cases := map[int]cid.Cid{
+		1: c,
+		3: v1,
+		4: v1,
+		5: v1,
+	}
+	for i := range p.Segments() {
+		got, ok := SegmentAsCid(p, i)
+		expected, isCid := cases[i]
+		if ok != isCid {
+			t.Fatalf("segment %d: expected %t, got %t", i, isCid, ok)
+		}
+		if ok && !got.Equals(expected) {
+			t.Fatalf("segment %d: expected %s, got %s", i, expected, got)
+		}
+	}
+
+	for _, i := range []int{-1, len(p.Segments())} {
+		if _, ok := SegmentAsCid(p, i); ok {
+			t.Fatalf("expected no CID for segment %d", i)
+		}
+	}
+}
+
+func TestIsAncestorOf(t *testing.T) {
+	p := Path("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a")
+
+	cases := map[Path]bool{
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c": true,
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/":    true,
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a":     true,
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/ab":    false,
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n":       false,
+		"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b":   false,
+		"/ipns/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b":   false,
+		"/ipfs/QmYNmQKp6SuaVrpgWRsPTgCQCnpxUYGq76YEKBXuj2N4H6/a/b":   false,
+	}
+	for other, expected := range cases {
+		if p.IsAncestorOf(other) != expected {
+			t.Fatalf("expected %s.IsAncestorOf(%s) to be %t", p, other, expected)
+		}
+	}
+
+	if Path("/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/").IsAncestorOf(p) != true {
+		t.Fatal("expected trailing slash to be ignored")
+	}
+}
+
+func TestIPNSName(t *testing.T) {
+	const k51 = "k51qzi5uqu5dhdmyb9bd18pypu2wp5lpv2xnskfmrqa4lb5knqryrotb05e7or"
+
+	cases := map[Path]string{
+		"/ipns/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA":     k51,
+		"/ipns/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA/a/b": k51,
+		Path("/ipns/" + k51): k51,
+		"/ipns/QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd": "k2k4r8l36edpnyak60or5gmgxbu59usk7jcr6lddt4emxr1wk3n9l5qe",
+		"/ipns/en.wikipedia-on-ipfs.org/wiki/":                 "en.wikipedia-on-ipfs.org",
+	}
+	for p, expected := range cases {
+		name, err := IPNSName(p)
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		if name != expected {
+			t.Fatalf("%s: expected %s, got %s", p, expected, name)
+		}
+	}
+
+	for _, p := range []Path{
+		"/ipfs/QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd",
+		"/ipns/foo",
+		"/ipns/",
+	} {
+		if _, err := IPNSName(p); err == nil {
+			t.Fatalf("expected an error for %s", p)
+		}
+	}
+}
+
+func TestNewPathFromMultiaddrComponent(t *testing.T) {
+	const expected = Path("/ipns/k51qzi5uqu5dhdmyb9bd18pypu2wp5lpv2xnskfmrqa4lb5knqryrotb05e7or")
+
+	for _, component := range []string{
+		"/p2p/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA",
+		"/ipfs/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA",
+	} {
+		p, err := NewPathFromMultiaddrComponent(component)
+		if err != nil {
+			t.Fatalf("%s: %s", component, err)
+		}
+		if p != expected {
+			t.Fatalf("%s: expected %s, got %s", component, expected, p)
+		}
+		if err := p.IsValid(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, component := range []string{
+		"/ipfs/QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd/a",
+		"/ipfs/bafkqaaa",
+		"/p2p/foo",
+		"/tcp/4001",
+		"p2p/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA",
+		"",
+	} {
+		if _, err := NewPathFromMultiaddrComponent(component); err == nil {
+			t.Fatalf("expected an error for %q", component)
+		}
+	}
+}
+
+func TestNewIPNSPathFromPeer(t *testing.T) {
+	_, edPub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rsaPub, err := crypto.GenerateRSAKeyPair(2048, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pub := range []crypto.PubKey{edPub, rsaPub} {
+		pid, err := peer.IDFromPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := NewIPNSPathFromPeer(pid)
+		if expected := NewIPNSPath(peer.ToCid(pid)); p != expected {
+			t.Fatalf("expected %s, got %s", expected, p)
+		}
+		if err := p.IsValid(); err != nil {
+			t.Fatal(err)
+		}
+		if fromMultiaddr, err := NewPathFromMultiaddrComponent("/p2p/" + pid.String()); err != nil || fromMultiaddr != p {
+			t.Fatalf("expected %s, got %s (%v)", p, fromMultiaddr, err)
+		}
+	}
+}
+
+func TestNewPathFromSubdomain(t *testing.T) {
+	const (
+		b32 = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+		b36 = "k2jmtxw8rjh1z69c6not3wtnkgb8abp4wmgb9gc1tfxzkdoiqrmbxcy2"
+		key = "k51qzi5uqu5dhdmyb9bd18pypu2wp5lpv2xnskfmrqa4lb5knqryrotb05e7or"
+	)
+
+	for host, expected := range map[string]Path{
+		b32 + ".ipfs.dweb.link":                     Path("/ipfs/" + b32),
+		b36 + ".ipfs.dweb.link":                     Path("/ipfs/" + b36),
+		b32 + ".ipfs.localhost:8080":                Path("/ipfs/" + b32),
+		b32 + ".ipfs.ipfs.io":                       Path("/ipfs/" + b32),
+		key + ".ipns.dweb.link":                     Path("/ipns/" + key),
+		"en.wikipedia-on-ipfs.org.ipns.dweb.link":   "/ipns/en.wikipedia-on-ipfs.org",
+		"en-wikipedia--on--ipfs-org.ipns.dweb.link": "/ipns/en.wikipedia-on-ipfs.org",
+		"dist.ipfs.tech.ipns.localhost:8080":        "/ipns/dist.ipfs.tech",
+	} {
+		p, err := NewPathFromSubdomain(host)
+		if err != nil {
+			t.Fatalf("%s: %s", host, err)
+		}
+		if p != expected {
+			t.Fatalf("%s: expected %s, got %s", host, expected, p)
+		}
+	}
+
+	for _, host := range []string{
+		"dweb.link",
+		"ipfs.dweb.link",
+		"foo.ipfs.dweb.link",
+		b32 + ".ipfs",
+		b32 + ".dweb.link",
+		"",
+	} {
+		if _, err := NewPathFromSubdomain(host); err == nil {
+			t.Fatalf("expected an error for %q", host)
+		}
+	}
+}
+
+func TestValidateSegments(t *testing.T) {
+	for _, segs := range [][]string{
+		{},
+		{"", "ipfs", "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", "a b"},
+		{"ipfs", "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"},
+		{strings.Repeat("a", MaxSegmentLength)},
+	} {
+		if err := ValidateSegments(segs...); err != nil {
+			t.Fatalf("%q: %s", segs, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		segs  []string
+		index int
+	}{
+		{[]string{"ipfs", "", "foo"}, 1},
+		{[]string{"", ""}, 1},
+		{[]string{"ipfs", "Qm/foo"}, 1},
+		{[]string{"/ipfs"}, 0},
+		{[]string{"ipfs", "cid", "a", strings.Repeat("a", MaxSegmentLength+1)}, 3},
+	} {
+		err := ValidateSegments(tc.segs...)
+		var segErr ErrInvalidSegment
+		if !errors.As(err, &segErr) {
+			t.Fatalf("%q: expected an ErrInvalidSegment, got %v", tc.segs, err)
+		}
+		if segErr.Index != tc.index || segErr.Segment != tc.segs[tc.index] {
+			t.Fatalf("%q: expected segment %d to be invalid, got %d", tc.segs, tc.index, segErr.Index)
+		}
+	}
+}
+
+func TestJoinDir(t *testing.T) {
+	const root = "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	cases := []struct {
+		base     Path
+		segs     []string
+		expected Path
+	}{
+		{root, nil, root + "/"},
+		{root + "/", nil, root + "/"},
+		{root + "/a/", []string{"b"}, root + "/a/b/"},
+		{root + "/a", []string{"b", "c"}, root + "/a/b/c/"},
+		{"/ipns/example.com/", []string{"docs"}, "/ipns/example.com/docs/"},
+	}
+	for _, c := range cases {
+		p, err := JoinDir(c.base, c.segs...)
+		if err != nil {
+			t.Fatalf("unexpected error joining %q to %s: %s", c.segs, c.base, err)
+		}
+		if p != c.expected {
+			t.Fatalf("expected %s joining %q to %s, got %s", c.expected, c.segs, c.base, p)
+		}
+	}
+
+	for _, segs := range [][]string{{""}, {"a", ""}, {"a/b"}} {
+		var segErr ErrInvalidSegment
+		if _, err := JoinDir(root, segs...); !errors.As(err, &segErr) {
+			t.Fatalf("expected an ErrInvalidSegment for %q, got %v", segs, err)
+		}
+	}
+	if _, err := JoinDir("/ipfs/foo", "a"); err == nil {
+		t.Fatal("expected an invalid base to be rejected")
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	const c = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+
+	cases := map[Path]struct {
+		maxAge    time.Duration
+		immutable bool
+	}{
+		"/ipfs/" + c:                    {DefaultCacheControl.ImmutableMaxAge, true},
+		"/ipfs/" + c + "/a/b":           {DefaultCacheControl.ImmutableMaxAge, true},
+		"/ipld/" + c:                    {DefaultCacheControl.ImmutableMaxAge, true},
+		c:                               {DefaultCacheControl.ImmutableMaxAge, true},
+		"/ipns/example.com/a":           {DefaultCacheControl.MutableMaxAge, false},
+		"/ipns/k51qzi5uqu5dhdmyb9bd18p": {DefaultCacheControl.MutableMaxAge, false},
+		"/foo/bar":                      {0, false},
+		"":                              {0, false},
+	}
+	for p, expected := range cases {
+		maxAge, immutable := CacheControl(p)
+		if maxAge != expected.maxAge || immutable != expected.immutable {
+			t.Fatalf("%q: expected (%s, %t), got (%s, %t)", p, expected.maxAge, expected.immutable, maxAge, immutable)
+		}
+	}
+
+	config := CacheControlConfig{ImmutableMaxAge: time.Hour, MutableMaxAge: time.Second}
+	if maxAge, immutable := config.CacheControl("/ipfs/" + c); maxAge != time.Hour || !immutable {
+		t.Fatalf("unexpected immutable cache control (%s, %t)", maxAge, immutable)
+	}
+	if maxAge, immutable := config.CacheControl("/ipns/example.com"); maxAge != time.Second || immutable {
+		t.Fatalf("unexpected mutable cache control (%s, %t)", maxAge, immutable)
+	}
+}
+
+func TestHasReservedSegment(t *testing.T) {
+	const root = "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+
+	for _, p := range []Path{
+		root + "/_redirects",
+		root + "/a/_redirects",
+		root + "/a/b/c/_redirects",
+		root + "/a/_redirects/b",
+		"/ipns/example.com/a/_redirects",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/_redirects",
+	} {
+		seg, ok := p.HasReservedSegment()
+		if !ok || seg != "_redirects" {
+			t.Fatalf("expected _redirects to be found in %s, got %q", p, seg)
+		}
+	}
+
+	for _, p := range []Path{
+		root,
+		root + "/a/b",
+		root + "/a/_redirects.txt",
+		"/ipns/_redirects",
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+	} {
+		if seg, ok := p.HasReservedSegment(); ok {
+			t.Fatalf("expected no reserved segment in %s, got %q", p, seg)
+		}
+	}
+
+	p := Path(root + "/.well-known/private/_redirects")
+	if seg, ok := p.HasReservedSegment(); !ok || seg != ".well-known" {
+		t.Fatalf("expected the first reserved segment to be .well-known, got %q", seg)
+	}
+	if seg, ok := p.HasReservedSegment("private"); !ok || seg != "private" {
+		t.Fatalf("expected the custom reserved segment to be found, got %q", seg)
+	}
+	if _, ok := p.HasReservedSegment("public"); ok {
+		t.Fatal("expected the custom reserved segments to replace the default ones")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		path    Path
+		root    Path
+		subpath []string
+	}{
+		{"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", []string{}},
+		{"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/", "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", []string{"a", "b"}},
+		{"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a", "/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", []string{"a"}},
+		{"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a", "/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", []string{"a"}},
+		{"/ipns/example.com/a/b", "/ipns/example.com", []string{"a", "b"}},
+	}
+	for _, c := range cases {
+		root, subpath, err := Split(c.path)
+		if err != nil {
+			t.Fatalf("%s: %s", c.path, err)
+		}
+		if root != c.root {
+			t.Fatalf("%s: expected root %s, got %s", c.path, c.root, root)
+		}
+		if strings.Join(subpath, "/") != strings.Join(c.subpath, "/") || len(subpath) != len(c.subpath) {
+			t.Fatalf("%s: expected subpath %v, got %v", c.path, c.subpath, subpath)
+		}
+
+		// the path can be reconstructed from the root and subpath
+		joined := Path(strings.Join(append([]string{root.String()}, subpath...), "/"))
+		if rel, err := joined.RelativeTo(c.path); err != nil || rel != "" {
+			t.Fatalf("%s: could not reconstruct the path, got %s", c.path, joined)
+		}
+	}
+
+	for _, p := range []Path{"", "/ipfs/", "/foo/bar"} {
+		if _, _, err := Split(p); err == nil {
+			t.Fatalf("expected an error for %q", p)
+		}
+	}
+}
+
+func TestPrefixPaths(t *testing.T) {
+	cases := []struct {
+		path     Path
+		prefixes []Path
+	}{
+		{"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", []Path{
+			"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		}},
+		{"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c", []Path{
+			"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+			"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a",
+			"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b",
+			"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a/b/c",
+		}},
+		{"/ipns/example.com/a/", []Path{
+			"/ipns/example.com",
+			"/ipns/example.com/a",
+		}},
+	}
+	for _, c := range cases {
+		prefixes := PrefixPaths(c.path)
+		if len(prefixes) != len(c.prefixes) {
+			t.Fatalf("%s: expected %v, got %v", c.path, c.prefixes, prefixes)
+		}
+		for i := range prefixes {
+			if prefixes[i] != c.prefixes[i] {
+				t.Fatalf("%s: expected %v, got %v", c.path, c.prefixes, prefixes)
+			}
+		}
+	}
+
+	if prefixes := PrefixPaths("/foo/bar"); prefixes != nil {
+		t.Fatalf("expected no prefixes for an invalid path, got %v", prefixes)
+	}
+}
+
+func TestParsePathRejectCidV0(t *testing.T) {
+	for _, p := range []string{
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"/ipfs/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n/a",
+		"/ipld/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+	} {
+		if _, err := ParsePath(p); err != nil {
+			t.Fatalf("expected CIDv0 to be accepted by default: %s", err)
+		}
+
+		_, err := ParsePath(p, RejectCidV0())
+		if err == nil {
+			t.Fatalf("expected an error for %s", p)
+		}
+		// the error suggests the CIDv1
+		if !strings.Contains(err.Error(), "bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku") {
+			t.Fatalf("expected the error to suggest a CIDv1, got %s", err)
+		}
+	}
+
+	for _, p := range []string{
+		"bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku",
+		"/ipfs/bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku/a",
+		"/ipns/QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+		"/ipns/example.com",
+	} {
+		if _, err := ParsePath(p, RejectCidV0()); err != nil {
+			t.Fatalf("expected %s to be accepted: %s", p, err)
+		}
+	}
+}
+
+func TestParsePathRejectNonKeyIPNSNames(t *testing.T) {
+	for _, p := range []string{
+		"/ipns/bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku",
+		"/ipns/bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku/a",
+		"/ipns/bafyreib7u3pb4vlzjvr4yzavsmy2xdn4gk3ynzo6jtuqd7kkz3rjx7n2ye",
+	} {
+		if _, err := ParsePath(p); err != nil {
+			t.Fatalf("expected %s to be accepted by default: %s", p, err)
+		}
+		if _, err := ParsePath(p, RejectNonKeyIPNSNames()); err == nil {
+			t.Fatalf("expected an error for %s", p)
+		}
+	}
+
+	for _, p := range []string{
+		"/ipns/k51qzi5uqu5dhdmyb9bd18pypu2wp5lpv2xnskfmrqa4lb5knqryrotb05e7or",
+		"/ipns/12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA/a",
+		"/ipns/QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd",
+		"/ipns/example.com",
+		"/ipfs/bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku",
+	} {
+		if _, err := ParsePath(p, RejectNonKeyIPNSNames()); err != nil {
+			t.Fatalf("expected %s to be accepted: %s", p, err)
+		}
+	}
+}
+
+func TestParsePathRejectIPLDNamespace(t *testing.T) {
+	const c = "bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+
+	for _, p := range []string{"/ipld/" + c, "/ipld/" + c + "/a"} {
+		if _, err := ParsePath(p); err != nil {
+			t.Fatalf("expected %s to be accepted by default: %s", p, err)
+		}
+		_, err := ParsePath(p, RejectIPLDNamespace())
+		if !errors.Is(err, ErrInvalidPath{}) {
+			t.Fatalf("expected an ErrInvalidPath for %s, got %v", p, err)
+		}
+	}
+
+	for _, p := range []string{
+		c,
+		"/ipfs/" + c,
+		"/ipfs/" + c + "/a",
+		"/ipns/example.com",
+	} {
+		if _, err := ParsePath(p, RejectIPLDNamespace()); err != nil {
+			t.Fatalf("expected %s to be accepted: %s", p, err)
+		}
+	}
+}
+
+func TestStripQuery(t *testing.T) {
+	const root = "/ipfs/bafybeihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+
+	cases := map[string][2]string{
+		root + "/index.html?foo=bar":  {root + "/index.html", "foo=bar"},
+		root + "/index.html?a=1&b=?2": {root + "/index.html", "a=1&b=?2"},
+		root + "/index.html?":         {root + "/index.html", ""},
+		root + "?format=car":          {root, "format=car"},
+		root + "/index.html":          {root + "/index.html", ""},
+		"":                            {"", ""},
+	}
+	for raw, expected := range cases {
+		p, q := StripQuery(raw)
+		if p != expected[0] || q != expected[1] {
+			t.Fatalf("%q: expected (%q, %q), got (%q, %q)", raw, expected[0], expected[1], p, q)
+		}
+	}
+
+	p, _ := StripQuery(root + "/index.html?foo=bar")
+	parsed, err := ParsePath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segs := parsed.Segments(); segs[len(segs)-1] != "index.html" {
+		t.Fatalf("unexpected last segment %q", segs[len(segs)-1])
+	}
+}
+
+func TestSplitAbsPathDotSegments(t *testing.T) {
+	const root = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+
+	cases := map[Path][]string{
+		"/ipfs/" + root + "/a/b":         {"a", "b"},
+		"/ipfs/" + root + "/a/../b":      {"b"},
+		"/ipfs/" + root + "/./a/./b/":    {"a", "b"},
+		"/ipfs/" + root + "/a/b/../../c": {"c"},
+		"/ipld/" + root + "/a/..":        {},
+		root + "/a//b":                   {"a", "b"},
+	}
+	for p, expected := range cases {
+		c, segs, err := SplitAbsPath(p)
+		if err != nil {
+			t.Fatalf("%s: %s", p, err)
+		}
+		if c.String() != root {
+			t.Fatalf("%s: expected root %s, got %s", p, root, c)
+		}
+		if strings.Join(segs, "/") != strings.Join(expected, "/") {
+			t.Fatalf("%s: expected %q, got %q", p, expected, segs)
+		}
+	}
+
+	for _, p := range []Path{
+		"/ipfs/" + root + "/..",
+		"/ipfs/" + root + "/a/../../b",
+		"/ipfs/../" + root,
+	} {
+		if _, _, err := SplitAbsPath(p); err == nil {
+			t.Fatalf("expected an error for %s", p)
+		}
+	}
+}
+
+func TestRebase(t *testing.T) {
+	oldRoot := "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	newRoot, err := cid.Decode("bafybeiffndsajwhk3lwjewwdxqntmjm4b5wxaaanokonsggenkbw6slwk4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := cid.Decode("k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path     Path
+		expected Path
+	}{
+		{Path("/ipfs/" + oldRoot), Path("/ipfs/" + newRoot.String())},
+		{Path("/ipfs/" + oldRoot + "/a/b/c"), Path("/ipfs/" + newRoot.String() + "/a/b/c")},
+		{Path("/ipld/" + oldRoot + "/a"), Path("/ipld/" + newRoot.String() + "/a")},
+		{Path("/ipns/" + key.String() + "/a"), NewIPNSPath(newRoot) + "/a"},
+	}
+	for _, c := range cases {
+		rebased, err := Rebase(c.path, newRoot)
+		if err != nil {
+			t.Fatalf("%s: %s", c.path, err)
+		}
+		if rebased != c.expected {
+			t.Fatalf("%s: expected %s, got %s", c.path, c.expected, rebased)
+		}
+	}
+
+	for _, p := range []Path{"/ipns/example.com/a", "/ipfs/", "foo"} {
+		if _, err := Rebase(p, newRoot); err == nil {
+			t.Fatalf("%s: expected an error", p)
+		}
+	}
+
+	rebased, err := RebaseWithNamespace("/ipns/example.com/a/b", "ipfs", newRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := Path("/ipfs/" + newRoot.String() + "/a/b"); rebased != expected {
+		t.Fatalf("expected %s, got %s", expected, rebased)
+	}
+
+	if _, err := RebaseWithNamespace("/ipns/example.com/a", "foo", newRoot); err == nil {
+		t.Fatal("expected an error for an unknown namespace")
+	}
+	if _, err := Rebase(Path("/ipfs/"+oldRoot), cid.Undef); err == nil {
+		t.Fatal("expected an error for an undefined root")
+	}
+}
+
+func TestBareMultihash(t *testing.T) {
+	h, err := mh.Sum([]byte("hello"), mh.SHA2_512, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cid.NewCidV1(cid.Raw, h).String()
+
+	for _, s := range []string{h.B58String(), h.HexString()} {
+		for _, txt := range []string{s, "/ipfs/" + s, "/ipfs/" + s + "/a"} {
+			_, err := ParsePath(txt)
+			if err == nil {
+				t.Fatalf("%s: expected an error", txt)
+			}
+			if !errors.Is(err, ErrInvalidPath{}) {
+				t.Fatalf("%s: expected an ErrInvalidPath, got %v", txt, err)
+			}
+			if !strings.Contains(err.Error(), "bare multihash") || !strings.Contains(err.Error(), expected) {
+				t.Fatalf("%s: expected a hint in the error, got %v", txt, err)
+			}
+		}
+	}
+
+	p, err := ParsePath(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "/ipfs/"+expected {
+		t.Fatalf("expected /ipfs/%s, got %s", expected, p)
+	}
+
+	// a sha2-256 multihash in base58 is a CIDv0
+	v0, err := mh.Sum([]byte("hello"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePath(v0.B58String()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCidSegments(t *testing.T) {
+	root := "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	inner := "bafkreiffndsajwhk3lwjewwdxqntmjm4b5wxaaanokonsggenkbw6slwk4"
+
+	// segments after the root are not decoded by ParsePath
+	p, err := ParsePath("/ipfs/" + root + "/a/" + inner + "/bafyNotACid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cids, err := CidSegments(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cids) != 2 || cids[0].Index != 1 || cids[0].Cid.String() != root || cids[1].Index != 3 || cids[1].Cid.String() != inner {
+		t.Fatalf("unexpected CID segments %v", cids)
+	}
+
+	cids, err = CidSegments("/ipns/example.com/a", 0)
+	if err != nil || len(cids) != 0 {
+		t.Fatalf("expected no CID segments, got %v, %v", cids, err)
+	}
+
+	if _, err := CidSegments(p, 4); !errors.Is(err, ErrInvalidPath{}) {
+		t.Fatalf("expected an ErrInvalidPath for too many segments, got %v", err)
+	}
+	if _, err := CidSegments(p, 5); err != nil {
+		t.Fatal(err)
+	}
+}
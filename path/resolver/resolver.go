@@ -48,7 +48,8 @@ type Resolver interface {
 	// ResolveToLastNode walks the given path and returns the cid of the
 	// last block referenced by the path, and the path segments to
 	// traverse from the final block boundary to the final node within the
-	// block.
+	// block. The resolution stops at a raw block, which has no links, and
+	// the segments after it are returned as the remainder.
 	ResolveToLastNode(ctx context.Context, fpath path.Path) (cid.Cid, []string, error)
 	// ResolvePath fetches the node for given path. It returns the last
 	// item returned by ResolvePathComponents and the last link traversed
@@ -78,7 +79,8 @@ func NewBasicResolver(fetcherFactory fetcher.Factory) Resolver {
 
 // ResolveToLastNode walks the given path and returns the cid of the last
 // block referenced by the path, and the path segments to traverse from the
-// final block boundary to the final node within the block.
+// final block boundary to the final node within the block. The resolution
+// stops at a raw block, the segments after it being the remainder.
 func (r *basicResolver) ResolveToLastNode(ctx context.Context, fpath path.Path) (cid.Cid, []string, error) {
 	ctx, span := internal.StartSpan(ctx, "basicResolver.ResolveToLastNode", trace.WithAttributes(attribute.Stringer("Path", fpath)))
 	defer span.End()
@@ -107,7 +109,15 @@ func (r *basicResolver) ResolveToLastNode(ctx context.Context, fpath path.Path)
 
 	if len(nodes) < 1 {
 		return cid.Cid{}, nil, fmt.Errorf("path %v did not resolve to a node", fpath)
-	} else if len(nodes) < len(p) {
+	}
+
+	// a raw block has no links: stop at it and leave the rest of the path,
+	// from the segment after the raw block, as the remainder
+	if lastCid.Type() == cid.Raw && nodes[len(nodes)-1].Kind() == ipld.Kind_Bytes {
+		return lastCid, p[len(nodes)-1:], nil
+	}
+
+	if len(nodes) < len(p) {
 		return cid.Undef, nil, ErrNoLink{Name: p[len(nodes)-1], Node: lastCid}
 	}
 
@@ -24,6 +24,7 @@ import (
 	dagjson "github.com/ipld/go-ipld-prime/codec/dagjson"
 	merkledag "github.com/mikelsr/boxo/ipld/merkledag"
 	dagmock "github.com/mikelsr/boxo/ipld/merkledag/test"
+	"github.com/mikelsr/boxo/ipld/unixfs/hamt"
 	path "github.com/mikelsr/boxo/path"
 	"github.com/mikelsr/boxo/path/resolver"
 	"github.com/stretchr/testify/assert"
@@ -132,6 +133,47 @@ func TestRecurivePathResolution(t *testing.T) {
 			p.String(), rCid.String(), cKey.String()))
 	}
 }
+
+func TestResolveShardedDirectory(t *testing.T) {
+	ctx := context.Background()
+	bsrv := dagmock.Bserv()
+	dserv := merkledag.NewDAGService(bsrv)
+
+	// a small fanout makes the entries spread over several levels of shards
+	shard, err := hamt.NewShard(dserv, 16)
+	require.NoError(t, err)
+	entries := make(map[string]cid.Cid)
+	for i := 0; i < 200; i++ {
+		nd := randNode()
+		require.NoError(t, dserv.Add(ctx, nd))
+		name := fmt.Sprintf("file-%d", i)
+		require.NoError(t, shard.Set(ctx, name, nd))
+		entries[name] = nd.Cid()
+	}
+	root, err := shard.Node()
+	require.NoError(t, err)
+
+	fetcherFactory := bsfetcher.NewFetcherConfig(bsrv)
+	fetcherFactory.NodeReifier = unixfsnode.Reify
+	fetcherFactory.PrototypeChooser = dagpb.AddSupportToChooser(bsfetcher.DefaultPrototypeChooser)
+	r := resolver.NewBasicResolver(fetcherFactory)
+
+	for _, name := range []string{"file-0", "file-42", "file-199"} {
+		p, err := path.FromSegments("/ipfs/", root.Cid().String(), name)
+		require.NoError(t, err)
+
+		rCid, rest, err := r.ResolveToLastNode(ctx, p)
+		require.NoError(t, err)
+		assert.Empty(t, rest)
+		assert.Equal(t, entries[name], rCid)
+	}
+
+	p, err := path.FromSegments("/ipfs/", root.Cid().String(), "file-200")
+	require.NoError(t, err)
+	_, _, err = r.ResolveToLastNode(ctx, p)
+	require.Error(t, err)
+}
+
 func TestResolveToLastNode_ErrNoLink(t *testing.T) {
 	ctx := context.Background()
 	bsrv := dagmock.Bserv()
@@ -254,6 +296,43 @@ func TestPathRemainder(t *testing.T) {
 	require.Equal(t, "foo/bar", path.Join(remainder))
 }
 
+func TestResolveToLastNode_RawLeaf(t *testing.T) {
+	ctx := context.Background()
+	bsrv := dagmock.Bserv()
+
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	dir := randNode()
+	err := dir.AddNodeLink("leaf", leaf)
+	require.NoError(t, err)
+	for _, n := range []blocks.Block{leaf, dir} {
+		err = bsrv.AddBlock(ctx, n)
+		require.NoError(t, err)
+	}
+
+	fetcherFactory := bsfetcher.NewFetcherConfig(bsrv)
+	fetcherFactory.PrototypeChooser = dagpb.AddSupportToChooser(bsfetcher.DefaultPrototypeChooser)
+	fetcherFactory.NodeReifier = unixfsnode.Reify
+	r := resolver.NewBasicResolver(fetcherFactory)
+
+	for _, tc := range []struct {
+		path      string
+		remainder []string
+	}{
+		{"/ipfs/" + dir.Cid().String() + "/leaf", nil},
+		{"/ipfs/" + dir.Cid().String() + "/leaf/foo", []string{"foo"}},
+		{"/ipfs/" + dir.Cid().String() + "/leaf/foo/bar", []string{"foo", "bar"}},
+		{"/ipfs/" + leaf.Cid().String() + "/foo", []string{"foo"}},
+	} {
+		c, remainder, err := r.ResolveToLastNode(ctx, path.FromString(tc.path))
+		require.NoError(t, err, tc.path)
+		assert.Equal(t, leaf.Cid(), c, tc.path)
+		assert.Equal(t, len(tc.remainder), len(remainder), tc.path)
+		if len(tc.remainder) > 0 {
+			assert.Equal(t, tc.remainder, remainder, tc.path)
+		}
+	}
+}
+
 func TestResolveToLastNode_MixedSegmentTypes(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,131 @@
+// Package pinutils provides helpers to inspect the pins of a pinner against
+// the blocks stored locally.
+package pinutils
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	blockservice "github.com/mikelsr/boxo/blockservice"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+	offline "github.com/mikelsr/boxo/exchange/offline"
+	merkledag "github.com/mikelsr/boxo/ipld/merkledag"
+	pin "github.com/mikelsr/boxo/pinning/pinner"
+)
+
+// Status is the result of the verification of a recursive pin.
+type Status struct {
+	// Root is the recursively pinned CID, undefined if Err is set
+	Root cid.Cid
+
+	// BadNodes are the nodes of the DAG missing from the blockstore
+	BadNodes []BadNode
+
+	// Err is set if the pin could not be verified
+	Err error
+}
+
+// Ok returns whether the whole DAG of the pin is stored locally.
+func (s Status) Ok() bool {
+	return s.Err == nil && len(s.BadNodes) == 0
+}
+
+// BadNode is a node of a pinned DAG that has been marked as bad by Verify.
+type BadNode struct {
+	Cid cid.Cid
+
+	// Err is the reason why the node has been marked as bad
+	Err error
+}
+
+// Verify checks that the whole DAG of every recursive pin of the pinner is
+// in the blockstore and streams the status of each pin. The presence of the
+// nodes is checked with Has and the nodes are read from the blockstore only,
+// so a missing node is reported rather than fetched. A node shared by several
+// pins is only checked once. If badOnly is set, the pins that are ok are not
+// sent.
+//
+// The status of a pin whose recursive keys can't be listed carries the error.
+func Verify(ctx context.Context, pinner pin.Pinner, bs blockstore.Blockstore, badOnly bool) <-chan Status {
+	v := &verifier{
+		bs:      bs,
+		dag:     merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		checked: make(map[cid.Cid][]BadNode),
+	}
+
+	out := make(chan Status)
+	go func() {
+		defer close(out)
+
+		for sc := range pinner.RecursiveKeys(ctx) {
+			var status Status
+			if sc.Err != nil {
+				status = Status{Err: sc.Err}
+			} else {
+				bad, err := v.check(ctx, sc.C)
+				status = Status{Root: sc.C, BadNodes: bad, Err: err}
+			}
+			if badOnly && status.Ok() {
+				continue
+			}
+
+			select {
+			case out <- status:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type verifier struct {
+	bs  blockstore.Blockstore
+	dag ipld.DAGService
+
+	// the missing nodes of the DAGs already checked, by root
+	checked map[cid.Cid][]BadNode
+}
+
+// check returns the nodes of the DAG rooted at c that are not in the
+// blockstore, each once
+func (v *verifier) check(ctx context.Context, c cid.Cid) ([]BadNode, error) {
+	if bad, ok := v.checked[c]; ok {
+		return bad, nil
+	}
+
+	has, err := v.bs.Has(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		bad := []BadNode{{Cid: c, Err: ipld.ErrNotFound{Cid: c}}}
+		v.checked[c] = bad
+		return bad, nil
+	}
+
+	var bad []BadNode
+	if c.Type() != cid.Raw {
+		nd, err := v.dag.Get(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", c, err)
+		}
+		// a missing node linked from several children is reported once
+		reported := cid.NewSet()
+		for _, lnk := range nd.Links() {
+			childBad, err := v.check(ctx, lnk.Cid)
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range childBad {
+				if reported.Visit(b.Cid) {
+					bad = append(bad, b)
+				}
+			}
+		}
+	}
+	v.checked[c] = bad
+	return bad, nil
+}
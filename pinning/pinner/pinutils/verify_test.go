@@ -0,0 +1,103 @@
+package pinutils
+
+import (
+	"context"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	blockservice "github.com/mikelsr/boxo/blockservice"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+	offline "github.com/mikelsr/boxo/exchange/offline"
+	"github.com/mikelsr/boxo/ipld/merkledag"
+	"github.com/mikelsr/boxo/pinning/pinner/dspinner"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := blockstore.NewBlockstore(dstore)
+	dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	pinner, err := dspinner.New(ctx, dstore, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two pinned DAGs sharing a leaf, and a third one where the leaf has
+	// two parents: diamond -> a, b; a -> leaf; b -> leaf
+	shared := merkledag.NewRawNode([]byte("shared"))
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	complete := merkledag.NodeWithData([]byte("complete"))
+	broken := merkledag.NodeWithData([]byte("broken"))
+	a := merkledag.NodeWithData([]byte("a"))
+	b := merkledag.NodeWithData([]byte("b"))
+	diamond := merkledag.NodeWithData([]byte("diamond"))
+	for _, lnk := range []struct {
+		parent *merkledag.ProtoNode
+		child  ipld.Node
+	}{{complete, shared}, {broken, shared}, {broken, leaf}, {a, leaf}, {b, leaf}, {diamond, a}, {diamond, b}} {
+		if err := lnk.parent.AddNodeLink("child", lnk.child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dag.AddMany(ctx, []ipld.Node{shared, leaf, complete, broken, a, b, diamond}); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []ipld.Node{complete, broken, diamond} {
+		if err := pinner.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	verify := func(badOnly bool) map[cid.Cid]Status {
+		statuses := make(map[cid.Cid]Status)
+		for s := range Verify(ctx, pinner, bs, badOnly) {
+			if s.Err != nil {
+				t.Fatal(s.Err)
+			}
+			statuses[s.Root] = s
+		}
+		return statuses
+	}
+
+	statuses := verify(false)
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 pins, got %d", len(statuses))
+	}
+	for _, s := range statuses {
+		if !s.Ok() {
+			t.Fatalf("expected the pin of %s to be ok", s.Root)
+		}
+	}
+
+	if err := bs.DeleteBlock(ctx, leaf.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	statuses = verify(false)
+	if !statuses[complete.Cid()].Ok() {
+		t.Fatal("expected the complete pin to be ok")
+	}
+	s := statuses[broken.Cid()]
+	if s.Ok() {
+		t.Fatal("expected the broken pin not to be ok")
+	}
+	bad := s.BadNodes
+	if len(bad) != 1 || bad[0].Cid != leaf.Cid() || !ipld.IsNotFound(bad[0].Err) {
+		t.Fatalf("expected the leaf to be reported missing, got %v", bad)
+	}
+
+	// the leaf missing from both branches of the diamond is reported once
+	bad = statuses[diamond.Cid()].BadNodes
+	if len(bad) != 1 || bad[0].Cid != leaf.Cid() {
+		t.Fatalf("expected the leaf to be reported missing once, got %v", bad)
+	}
+
+	statuses = verify(true)
+	_, brokenOk := statuses[broken.Cid()]
+	_, diamondOk := statuses[diamond.Cid()]
+	if len(statuses) != 2 || !brokenOk || !diamondOk {
+		t.Fatalf("expected only the broken pins, got %v", statuses)
+	}
+}
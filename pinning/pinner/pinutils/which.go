@@ -0,0 +1,94 @@
+package pinutils
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	blockservice "github.com/mikelsr/boxo/blockservice"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+	offline "github.com/mikelsr/boxo/exchange/offline"
+	merkledag "github.com/mikelsr/boxo/ipld/merkledag"
+	pin "github.com/mikelsr/boxo/pinning/pinner"
+)
+
+// Which returns the pins of the pinner that keep c: the direct pin on c, if
+// any, followed by the recursive pins whose DAG includes it. A recursive pin
+// on c itself has the Recursive mode, the others have the Indirect mode with
+// the pinned root in Via. The result is empty if c is not pinned.
+//
+// The DAGs of the recursive pins are read from the blockstore only; the nodes
+// missing from it are skipped, as c can't be reached through them. The nodes
+// shared by several pins are only searched once.
+func Which(ctx context.Context, pinner pin.Pinner, bs blockstore.Blockstore, c cid.Cid) ([]pin.Pinned, error) {
+	var pins []pin.Pinned
+
+	_, direct, err := pinner.IsPinnedWithType(ctx, c, pin.Direct)
+	if err != nil {
+		return nil, err
+	}
+	if direct {
+		pins = append(pins, pin.Pinned{Key: c, Mode: pin.Direct})
+	}
+
+	f := &finder{
+		dag:      merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		target:   c,
+		searched: cid.NewSet(),
+	}
+	for sc := range pinner.RecursiveKeys(ctx) {
+		if sc.Err != nil {
+			return nil, sc.Err
+		}
+		found, err := f.contains(ctx, sc.C)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case found && sc.C == c:
+			pins = append(pins, pin.Pinned{Key: c, Mode: pin.Recursive})
+		case found:
+			pins = append(pins, pin.Pinned{Key: c, Mode: pin.Indirect, Via: sc.C})
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+type finder struct {
+	dag    ipld.DAGService
+	target cid.Cid
+
+	// the nodes whose DAG was searched without finding the target
+	searched *cid.Set
+}
+
+// contains returns whether the DAG rooted at c includes the target
+func (f *finder) contains(ctx context.Context, c cid.Cid) (bool, error) {
+	if c == f.target {
+		return true, nil
+	}
+	if c.Type() == cid.Raw || f.searched.Has(c) {
+		return false, nil
+	}
+
+	nd, err := f.dag.Get(ctx, c)
+	switch {
+	case ipld.IsNotFound(err):
+		f.searched.Add(c)
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("reading %s: %w", c, err)
+	}
+	for _, lnk := range nd.Links() {
+		found, err := f.contains(ctx, lnk.Cid)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	f.searched.Add(c)
+	return false, nil
+}
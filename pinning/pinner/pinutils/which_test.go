@@ -0,0 +1,93 @@
+package pinutils
+
+import (
+	"context"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	blockservice "github.com/mikelsr/boxo/blockservice"
+	blockstore "github.com/mikelsr/boxo/blockstore"
+	offline "github.com/mikelsr/boxo/exchange/offline"
+	"github.com/mikelsr/boxo/ipld/merkledag"
+	pin "github.com/mikelsr/boxo/pinning/pinner"
+	"github.com/mikelsr/boxo/pinning/pinner/dspinner"
+)
+
+func TestWhich(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := blockstore.NewBlockstore(dstore)
+	dag := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	pinner, err := dspinner.New(ctx, dstore, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// top -> middle -> leaf, other -> leaf, and an unpinned node
+	leaf := merkledag.NewRawNode([]byte("leaf"))
+	middle := merkledag.NodeWithData([]byte("middle"))
+	top := merkledag.NodeWithData([]byte("top"))
+	other := merkledag.NodeWithData([]byte("other"))
+	unpinned := merkledag.NewRawNode([]byte("unpinned"))
+	for _, lnk := range []struct {
+		parent *merkledag.ProtoNode
+		child  ipld.Node
+	}{{middle, leaf}, {top, middle}, {other, leaf}} {
+		if err := lnk.parent.AddNodeLink("child", lnk.child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dag.AddMany(ctx, []ipld.Node{leaf, middle, top, other, unpinned}); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []ipld.Node{top, other} {
+		if err := pinner.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pinner.Pin(ctx, middle, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// which returns the mode of the pins by pinned CID
+	which := func(nd ipld.Node) map[cid.Cid]pin.Mode {
+		pins, err := Which(ctx, pinner, bs, nd.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		modes := make(map[cid.Cid]pin.Mode)
+		for _, p := range pins {
+			if p.Key != nd.Cid() {
+				t.Fatalf("expected the pin of %s, got %s", nd.Cid(), p.Key)
+			}
+			pinned := p.Key
+			if p.Mode == pin.Indirect {
+				pinned = p.Via
+			}
+			modes[pinned] = p.Mode
+		}
+		return modes
+	}
+
+	pins := which(leaf)
+	if len(pins) != 2 || pins[top.Cid()] != pin.Indirect || pins[other.Cid()] != pin.Indirect {
+		t.Fatalf("expected the leaf to be pinned by top and other, got %v", pins)
+	}
+
+	pins = which(middle)
+	if len(pins) != 2 || pins[middle.Cid()] != pin.Direct || pins[top.Cid()] != pin.Indirect {
+		t.Fatalf("expected middle to be pinned directly and by top, got %v", pins)
+	}
+
+	pins = which(top)
+	if len(pins) != 1 || pins[top.Cid()] != pin.Recursive {
+		t.Fatalf("expected top to be pinned by itself, got %v", pins)
+	}
+
+	if pins := which(unpinned); len(pins) != 0 {
+		t.Fatalf("expected no pins, got %v", pins)
+	}
+}